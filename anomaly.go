@@ -0,0 +1,178 @@
+package wattpilot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ANOMALY_MIN_VOLTAGE and ANOMALY_MAX_VOLTAGE bound plausible phase
+	// voltages in V
+	ANOMALY_MIN_VOLTAGE = 180
+	ANOMALY_MAX_VOLTAGE = 260
+	// ANOMALY_NO_VOLTAGE in V, phases below are not connected and skipped
+	ANOMALY_NO_VOLTAGE = 50
+	// ANOMALY_CURRENT_MARGIN in A a phase may exceed the cable limit
+	ANOMALY_CURRENT_MARGIN = 1
+	// ANOMALY_POWER_TOLERANCE is the relative and ANOMALY_POWER_MARGIN the
+	// absolute deviation in W of the total power from the sum of U·I
+	ANOMALY_POWER_TOLERANCE = 0.15
+	ANOMALY_POWER_MARGIN    = 100
+
+	EventMeasurementAnomaly EventType = "measurementAnomaly"
+)
+
+// MeasurementAnomaly is an implausible measurement of the charger. Phase is
+// 1 to 3, or 0 for checks of the total.
+type MeasurementAnomaly struct {
+	Check    string    `json:"check"`
+	Phase    int       `json:"phase,omitempty"`
+	Value    float64   `json:"value"`
+	Expected string    `json:"expected"`
+	Since    time.Time `json:"since"`
+}
+
+func (a MeasurementAnomaly) String() string {
+	if a.Phase == 0 {
+		return fmt.Sprintf("%s %.1f, expected %s", a.Check, a.Value, a.Expected)
+	}
+	return fmt.Sprintf("%s of phase %d %.1f, expected %s", a.Check, a.Phase, a.Value, a.Expected)
+}
+
+type anomalyTracker struct {
+	mu      sync.Mutex
+	enabled bool
+	active  map[string]MeasurementAnomaly
+}
+
+// WithMeasurementChecks enables the plausibility checks of voltages,
+// currents and power, which emit EventMeasurementAnomaly when a check
+// starts and stops failing
+func WithMeasurementChecks() Option {
+	return func(w *Wattpilot) {
+		w._anomalies.enabled = true
+	}
+}
+
+// MeasurementAnomalies returns the failing checks
+func (w *Wattpilot) MeasurementAnomalies() []MeasurementAnomaly {
+	w._anomalies.mu.Lock()
+	defer w._anomalies.mu.Unlock()
+
+	anomalies := make([]MeasurementAnomaly, 0, len(w._anomalies.active))
+	for _, a := range w._anomalies.active {
+		anomalies = append(anomalies, a)
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if anomalies[i].Check != anomalies[j].Check {
+			return anomalies[i].Check < anomalies[j].Check
+		}
+		return anomalies[i].Phase < anomalies[j].Phase
+	})
+	return anomalies
+}
+
+// checkMeasurements returns the anomalies of the nrg values, keyed by check
+// and phase
+func checkMeasurements(lookup func(string) (interface{}, bool)) map[string]MeasurementAnomaly {
+	found := make(map[string]MeasurementAnomaly)
+	add := func(a MeasurementAnomaly) {
+		found[fmt.Sprintf("%s/%d", a.Check, a.Phase)] = a
+	}
+
+	// without a fixed cable cbl is null and the current is not checked
+	cable := 0.0
+	if v, isKnown := lookup("cbl"); isKnown && v != nil {
+		cable, _ = toFloat(v)
+	}
+
+	expected := 0.0
+	for phase := 1; phase <= 3; phase++ {
+		voltage, err := nrgValue(lookup, phase-1)
+		if err != nil {
+			return found
+		}
+		current, err := nrgValue(lookup, phase+3)
+		if err != nil {
+			return found
+		}
+		if voltage >= ANOMALY_NO_VOLTAGE && (voltage < ANOMALY_MIN_VOLTAGE || voltage > ANOMALY_MAX_VOLTAGE) {
+			add(MeasurementAnomaly{
+				Check:    "voltage",
+				Phase:    phase,
+				Value:    voltage,
+				Expected: fmt.Sprintf("%d to %d V", ANOMALY_MIN_VOLTAGE, ANOMALY_MAX_VOLTAGE),
+			})
+		}
+		if cable > 0 && current > cable+ANOMALY_CURRENT_MARGIN {
+			add(MeasurementAnomaly{
+				Check:    "current",
+				Phase:    phase,
+				Value:    current,
+				Expected: fmt.Sprintf("at most the cable limit of %.0f A", cable),
+			})
+		}
+		expected += voltage * current
+	}
+
+	power, err := nrgValue(lookup, 11)
+	if err != nil {
+		return found
+	}
+	if math.Abs(power-expected) > expected*ANOMALY_POWER_TOLERANCE+ANOMALY_POWER_MARGIN {
+		add(MeasurementAnomaly{
+			Check:    "power",
+			Value:    power,
+			Expected: fmt.Sprintf("about %.0f W from U·I", expected),
+		})
+	}
+	return found
+}
+
+// trackAnomalies is called with the read mutex held for every status update
+func (w *Wattpilot) trackAnomalies(updates map[string]interface{}) {
+	t := w._anomalies
+	if !t.enabled {
+		return
+	}
+	if _, ok := updates["nrg"]; !ok {
+		return
+	}
+	found := checkMeasurements(func(key string) (interface{}, bool) {
+		v, ok := w._status[key]
+		return v, ok
+	})
+
+	now := w._clock.Now()
+	started := []MeasurementAnomaly{}
+	stopped := []MeasurementAnomaly{}
+	t.mu.Lock()
+	for id, a := range found {
+		if previous, isActive := t.active[id]; isActive {
+			a.Since = previous.Since
+			t.active[id] = a
+			continue
+		}
+		a.Since = now
+		t.active[id] = a
+		started = append(started, a)
+	}
+	for id, a := range t.active {
+		if _, isFound := found[id]; !isFound {
+			delete(t.active, id)
+			stopped = append(stopped, a)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, a := range started {
+		w.logEntry().Warn("Implausible measurement: ", a)
+		w.emitEvent(EventMeasurementAnomaly, map[string]interface{}{"anomaly": a, "active": true})
+	}
+	for _, a := range stopped {
+		w.emitEvent(EventMeasurementAnomaly, map[string]interface{}{"anomaly": a, "active": false})
+	}
+}
@@ -0,0 +1,180 @@
+package wattpilot
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// CONTROL_HOLD is how long a write keeps the control of a key, in minutes
+	CONTROL_HOLD = 5
+
+	EventControlOwnerChanged EventType = "controlOwnerChanged"
+)
+
+var ErrControlledByOtherSource = errors.New("property is controlled by another source")
+
+// arbitratedKeys are the keys competing sources usually fight over
+var arbitratedKeys = []string{"amp", "frc"}
+
+type controlClaim struct {
+	owner    string
+	priority int
+	expires  time.Time
+	override bool
+}
+
+// arbiter decides which write source (api, session names, controllers)
+// owns a key. A source can write a key as long as no source with a higher
+// priority wrote it within CONTROL_HOLD, or holds an override on it.
+type arbiter struct {
+	mu         sync.Mutex
+	keys       map[string]bool
+	priorities map[string]int
+	claims     map[string]controlClaim
+}
+
+func newArbiter() *arbiter {
+	a := &arbiter{
+		keys:       make(map[string]bool),
+		priorities: make(map[string]int),
+		claims:     make(map[string]controlClaim),
+	}
+	for _, key := range arbitratedKeys {
+		a.keys[key] = true
+	}
+	return a
+}
+
+// SetArbitratedKeys replaces the keys (or aliases) subject to arbitration
+func (w *Wattpilot) SetArbitratedKeys(keys ...string) {
+	a := w._arbiter
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.keys = make(map[string]bool)
+	for _, key := range keys {
+		a.keys[resolveKey(key)] = true
+	}
+}
+
+// SetSourcePriority sets the priority of a write source, unknown sources
+// have priority zero
+func (w *Wattpilot) SetSourcePriority(source string, priority int) {
+	a := w._arbiter
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.priorities[source] = priority
+}
+
+// Override gives the source exclusive control of the keys for the duration,
+// e.g. a manual override of the current for one hour
+func (w *Wattpilot) Override(source string, duration time.Duration, keys ...string) {
+	now := w._clock.Now()
+	for _, key := range keys {
+		w.takeControl(resolveKey(key), controlClaim{
+			owner:    source,
+			priority: math.MaxInt,
+			expires:  now.Add(duration),
+			override: true,
+		})
+	}
+}
+
+// ReleaseOverride ends all overrides of the source
+func (w *Wattpilot) ReleaseOverride(source string) {
+	a := w._arbiter
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, claim := range a.claims {
+		if claim.override && claim.owner == source {
+			delete(a.claims, key)
+		}
+	}
+}
+
+// ControlOwner returns the source currently controlling the key
+func (w *Wattpilot) ControlOwner(key string) (string, bool) {
+	a := w._arbiter
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	claim, isKnown := a.claims[resolveKey(key)]
+	if !isKnown || !w._clock.Now().Before(claim.expires) {
+		return "", false
+	}
+	return claim.owner, true
+}
+
+func (w *Wattpilot) takeControl(key string, claim controlClaim) {
+	a := w._arbiter
+	a.mu.Lock()
+	previous := a.claims[key]
+	a.claims[key] = claim
+	a.mu.Unlock()
+
+	if previous.owner != claim.owner {
+		w.emitControlOwnerChanged(key, previous.owner, claim)
+	}
+}
+
+// arbitrate claims the key for the source or reports the current owner
+func (w *Wattpilot) arbitrate(source string, key string) (string, error) {
+	a := w._arbiter
+	now := w._clock.Now()
+
+	a.mu.Lock()
+	if !a.keys[key] {
+		a.mu.Unlock()
+		return source, nil
+	}
+	priority := a.priorities[source]
+	current, isKnown := a.claims[key]
+	active := isKnown && now.Before(current.expires)
+	if active && current.owner != source && current.priority > priority {
+		a.mu.Unlock()
+		return current.owner, ErrControlledByOtherSource
+	}
+	claim := controlClaim{owner: source, priority: priority, expires: now.Add(time.Minute * CONTROL_HOLD)}
+	if active && current.owner == source && current.override {
+		// writes of the overriding source keep the override
+		claim = current
+	}
+	a.claims[key] = claim
+	a.mu.Unlock()
+
+	if !active || current.owner != source {
+		w.emitControlOwnerChanged(key, current.owner, claim)
+	}
+	return source, nil
+}
+
+// mayControl reports whether a write of the source would pass the
+// arbitration, without claiming the key
+func (w *Wattpilot) mayControl(source string, key string) bool {
+	a := w._arbiter
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.keys[key] {
+		return true
+	}
+	current, isKnown := a.claims[key]
+	active := isKnown && w._clock.Now().Before(current.expires)
+	return !active || current.owner == source || current.priority <= a.priorities[source]
+}
+
+func (w *Wattpilot) emitControlOwnerChanged(key string, previous string, claim controlClaim) {
+	w.logEntry().Info("Control of ", key, " taken by ", claim.owner)
+	w.emitEvent(EventControlOwnerChanged, map[string]interface{}{
+		"key":      key,
+		"owner":    claim.owner,
+		"previous": previous,
+		"override": claim.override,
+		"until":    claim.expires,
+	})
+}
@@ -0,0 +1,127 @@
+package wattpilot
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// steppedClock is a real clock whose Now only moves on advance, so claims
+// expire without firing the timers of the process loop
+type steppedClock struct {
+	realClock
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *steppedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *steppedClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func nextOwnerChange(t *testing.T, events <-chan interface{}) map[string]interface{} {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event.(Event).Data
+	case <-time.After(time.Second):
+		t.Fatal("no owner change announced")
+	}
+	return nil
+}
+
+func TestArbitration(t *testing.T) {
+	clock := &steppedClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	w := New("localhost", "", WithClock(clock))
+	defer w.Close()
+	events := w.GetEvents(EventControlOwnerChanged)
+	w.SetSourcePriority("controller:surplus", 1)
+	w.SetSourcePriority("mqtt", 5)
+
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		source   string
+		key      string
+		owner    string
+		err      error
+		previous string
+	}{
+		{"first write claims", 0, "controller:surplus", "amp", "controller:surplus", nil, ""},
+		{"lower priority refused", time.Minute, SOURCE_API, "amp", "controller:surplus", ErrControlledByOtherSource, ""},
+		{"higher priority wins", 0, "mqtt", "amp", "mqtt", nil, "controller:surplus"},
+		{"former owner refused", 0, "controller:surplus", "amp", "mqtt", ErrControlledByOtherSource, ""},
+		{"owner keeps writing", 4 * time.Minute, "mqtt", "amp", "mqtt", nil, ""},
+		{"claim renewed by the write", 4 * time.Minute, "controller:surplus", "amp", "mqtt", ErrControlledByOtherSource, ""},
+		{"expiry releases", time.Minute * CONTROL_HOLD, SOURCE_API, "amp", SOURCE_API, nil, "mqtt"},
+		{"keys without arbitration", 0, "controller:surplus", "fna", "controller:surplus", nil, ""},
+	}
+	for _, step := range steps {
+		clock.advance(step.advance)
+		owner, err := w.arbitrate(step.source, step.key)
+		if owner != step.owner || !errors.Is(err, step.err) {
+			t.Fatalf("%s: got owner %s and %v, want %s and %v", step.name, owner, err, step.owner, step.err)
+		}
+		if err != nil || step.key == "fna" {
+			continue
+		}
+		if current, _ := w.ControlOwner(step.key); current != step.source {
+			t.Fatalf("%s: controlled by %s", step.name, current)
+		}
+		if step.previous != "" || step.name == "first write claims" {
+			data := nextOwnerChange(t, events)
+			if data["owner"] != step.source || data["previous"] != step.previous || data["key"] != step.key {
+				t.Fatalf("%s: announced %v", step.name, data)
+			}
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatal("unexpected owner change ", event)
+	default:
+	}
+}
+
+func TestOverride(t *testing.T) {
+	clock := &steppedClock{now: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)}
+	w := New("localhost", "", WithClock(clock))
+	defer w.Close()
+	events := w.GetEvents(EventControlOwnerChanged)
+	w.SetSourcePriority("mqtt", 5)
+
+	w.Override("manual", time.Hour, "amp", "forceState")
+	for _, key := range []string{"amp", "frc"} {
+		data := nextOwnerChange(t, events)
+		if data["key"] != key || data["owner"] != "manual" || data["override"] != true {
+			t.Fatal("override not announced: ", data)
+		}
+	}
+	if owner, err := w.arbitrate("mqtt", "amp"); owner != "manual" || !errors.Is(err, ErrControlledByOtherSource) {
+		t.Fatalf("override lost to priority 5: %s %v", owner, err)
+	}
+	// writes of the overriding source keep the override
+	clock.advance(30 * time.Minute)
+	if _, err := w.arbitrate("manual", "amp"); err != nil {
+		t.Fatal(err)
+	}
+	if w.mayControl("mqtt", "amp") {
+		t.Fatal("override ended by a write of its owner")
+	}
+
+	clock.advance(30 * time.Minute)
+	if _, err := w.arbitrate("mqtt", "amp"); err != nil {
+		t.Fatal("expired override still active: ", err)
+	}
+	w.ReleaseOverride("manual")
+	if _, isKnown := w.ControlOwner("frc"); isKnown {
+		t.Fatal("override of frc not released")
+	}
+}
@@ -0,0 +1,191 @@
+package wattpilot
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// SOURCE_API is the audit source of writes which are not issued through a
+// named session
+const SOURCE_API = "api"
+
+// AUDIT_LOG_SIZE is the number of entries kept by the MemoryAuditLog
+const AUDIT_LOG_SIZE = 1024
+
+type AuditEntry struct {
+	Time     time.Time   `json:"time"`
+	Host     string      `json:"host"`
+	Source   string      `json:"source"`
+	Key      string      `json:"key"`
+	OldValue interface{} `json:"old"`
+	NewValue interface{} `json:"new"`
+	DryRun   bool        `json:"dryRun,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// AuditFilter selects audit entries, empty fields match everything
+type AuditFilter struct {
+	Source string
+	Key    string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	if f.Source != "" && f.Source != e.Source {
+		return false
+	}
+	if f.Key != "" && f.Key != e.Key {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+func (f AuditFilter) apply(entries []AuditEntry) []AuditEntry {
+	result := []AuditEntry{}
+	for _, e := range entries {
+		if f.matches(e) {
+			result = append(result, e)
+		}
+	}
+	if f.Limit > 0 && len(result) > f.Limit {
+		result = result[len(result)-f.Limit:]
+	}
+	return result
+}
+
+// AuditLog persists all write operations
+type AuditLog interface {
+	Record(entry AuditEntry) error
+	Query(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// MemoryAuditLog keeps the last AUDIT_LOG_SIZE entries, use a FileAuditLog
+// for a complete trail
+type MemoryAuditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (l *MemoryAuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries = append(l.entries, entry)
+	if len(l.entries) > AUDIT_LOG_SIZE {
+		l.entries = l.entries[len(l.entries)-AUDIT_LOG_SIZE:]
+	}
+	return nil
+}
+
+func (l *MemoryAuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return filter.apply(l.entries), nil
+}
+
+// FileAuditLog appends the entries as JSON lines to a file
+type FileAuditLog struct {
+	mu       sync.Mutex
+	filename string
+}
+
+func NewFileAuditLog(filename string) *FileAuditLog {
+	return &FileAuditLog{filename: filename}
+}
+
+func (l *FileAuditLog) Record(entry AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func (l *FileAuditLog) Query(filter AuditFilter) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.filename)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return filter.apply(entries), nil
+}
+
+// WithAuditLog records every write operation in the given audit log
+func WithAuditLog(auditLog AuditLog) Option {
+	return func(w *Wattpilot) {
+		w._auditLog = auditLog
+	}
+}
+
+func (w *Wattpilot) AuditTrail(filter AuditFilter) ([]AuditEntry, error) {
+	if w._auditLog == nil {
+		return []AuditEntry{}, nil
+	}
+	return w._auditLog.Query(filter)
+}
+
+func (w *Wattpilot) audit(source string, key string, oldValue interface{}, newValue interface{}, err error) {
+	if w._auditLog == nil {
+		return
+	}
+	entry := AuditEntry{
+		Time:     w._clock.Now(),
+		Host:     w._host,
+		Source:   source,
+		Key:      key,
+		OldValue: oldValue,
+		NewValue: newValue,
+		DryRun:   w.IsDryRun(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := w._auditLog.Record(entry); err != nil {
+		w.logEntry().Warn("Could not record audit entry: ", err)
+	}
+}
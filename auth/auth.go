@@ -0,0 +1,79 @@
+// Package auth implements the authentication scheme of the Wattpilot
+// websocket API: the PBKDF2 password hash, the three token handshake and
+// the hmac signatures of secured messages. Comparisons are constant time.
+// The key material is not zeroed: the hashed password is a string, which
+// the client keeps for the signatures of the connection anyway, and Go
+// copies strings and slices at will.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	PBKDF2_ITERATIONS = 100000
+	PBKDF2_KEY_LENGTH = 256
+	// HASH_LENGTH is the number of base64 characters of the derived key used
+	HASH_LENGTH = 32
+	// TOKEN_LENGTH is the number of random bytes of the client token token3
+	TOKEN_LENGTH = 16
+)
+
+// HashPassword derives the hash used for the handshake and the signatures
+// from the password and the serial of the charger
+func HashPassword(password string, serial string) string {
+	key := pbkdf2.Key([]byte(password), []byte(serial), PBKDF2_ITERATIONS, PBKDF2_KEY_LENGTH, sha512.New)
+	return base64.StdEncoding.EncodeToString(key)[:HASH_LENGTH]
+}
+
+// Token3 creates the random client token of the handshake
+func Token3() (string, error) {
+	b := make([]byte, TOKEN_LENGTH)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func sha256hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// Response computes the hash answering the tokens of the charger
+func Response(token1 string, token2 string, token3 string, hashedPassword string) string {
+	hash1 := sha256hex(token1 + hashedPassword)
+	return sha256hex(token3 + token2 + hash1)
+}
+
+// VerifyResponse checks the hash of a client, as done by the charger
+func VerifyResponse(token1 string, token2 string, token3 string, hashedPassword string, hash string) bool {
+	expected := Response(token1, token2, token3, hashedPassword)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1
+}
+
+// Sign returns the hex encoded hmac of a secured message payload
+func Sign(hashedPassword string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(hashedPassword))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the hmac of a secured message payload
+func Verify(hashedPassword string, payload []byte, signature string) bool {
+	received, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(hashedPassword))
+	mac.Write(payload)
+	return hmac.Equal(received, mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package auth
+
+import "testing"
+
+// The known answers are computed independently of this package with the
+// hashlib and hmac modules of Python following the documented scheme:
+// base64(pbkdf2_sha512(password, serial, 100000, 256))[:32] for the hash,
+// sha256(token3 + token2 + sha256(token1 + hash)) for the response and
+// hmac_sha256(hash, payload) for the signatures.
+
+func TestHashPasswordKnownAnswers(t *testing.T) {
+	tests := []struct {
+		password string
+		serial   string
+		hash     string
+	}{
+		{"secret", "12345678", "hrCqEiXhC/gGz0OU/hXuyvjs8DqOogCS"},
+		{"Passw0rd!", "91234567", "DcWw+tXuOM+S0IdAzhE5dCKo/KcTaM99"},
+	}
+	for _, test := range tests {
+		if hash := HashPassword(test.password, test.serial); hash != test.hash {
+			t.Errorf("%s %s: got %s, want %s", test.password, test.serial, hash, test.hash)
+		}
+	}
+}
+
+func TestResponseKnownAnswers(t *testing.T) {
+	tests := []struct {
+		token1, token2, token3 string
+		hashed                 string
+		response               string
+	}{
+		{"token1value", "token2value", "0123456789abcdef0123456789abcdef", "hrCqEiXhC/gGz0OU/hXuyvjs8DqOogCS", "0c5e930ac98b5b9c2f78ce843914055b658f9bb0aaba5207b3757673bc14b212"},
+		{"8c1f2a", "b07e33", "00000000000000000000000000000000", "DcWw+tXuOM+S0IdAzhE5dCKo/KcTaM99", "6b445aeec4621c59d91b7b173118502f9a427bf558008ef97a4263e5dac1cbf1"},
+	}
+	for _, test := range tests {
+		response := Response(test.token1, test.token2, test.token3, test.hashed)
+		if response != test.response {
+			t.Errorf("%s %s %s: got %s, want %s", test.token1, test.token2, test.token3, response, test.response)
+		}
+		if !VerifyResponse(test.token1, test.token2, test.token3, test.hashed, test.response) {
+			t.Errorf("%s %s %s: response not verified", test.token1, test.token2, test.token3)
+		}
+		if VerifyResponse(test.token1, test.token2, test.token3, test.hashed, test.response[1:]) {
+			t.Errorf("%s %s %s: truncated response verified", test.token1, test.token2, test.token3)
+		}
+	}
+}
+
+func TestSignKnownAnswers(t *testing.T) {
+	tests := []struct {
+		hashed    string
+		payload   string
+		signature string
+	}{
+		{"hrCqEiXhC/gGz0OU/hXuyvjs8DqOogCS", `{"type":"setValue","requestId":1,"key":"amp","value":16}`, "3ef6432202916968378f54e1a25e333a3466b2d372c47025bea2e72b2002f0a9"},
+		{"DcWw+tXuOM+S0IdAzhE5dCKo/KcTaM99", "", "66b87303ae869c0da14ac5f20b9880bf6a734264c97c74691e23cdf4d229da80"},
+	}
+	for _, test := range tests {
+		if signature := Sign(test.hashed, []byte(test.payload)); signature != test.signature {
+			t.Errorf("%q: got %s, want %s", test.payload, signature, test.signature)
+		}
+		if !Verify(test.hashed, []byte(test.payload), test.signature) {
+			t.Errorf("%q: signature not verified", test.payload)
+		}
+		if Verify(test.hashed, []byte(test.payload+" "), test.signature) {
+			t.Errorf("%q: signature of a modified payload verified", test.payload)
+		}
+	}
+}
+
+func TestToken3(t *testing.T) {
+	a, err := Token3()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Token3()
+	if len(a) != 2*TOKEN_LENGTH || a == b {
+		t.Fatalf("tokens %s and %s", a, b)
+	}
+}
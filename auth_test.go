@@ -0,0 +1,134 @@
+package wattpilot_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+func hello() map[string]interface{} {
+	return map[string]interface{}{"type": "hello", "serial": testSerial, "hostname": "Wattpilot_" + testSerial, "manufacturer": "fronius", "devicetype": "wattpilot", "version": "38.5", "protocol": 2, "secured": false}
+}
+
+// handshake connects the client to a charger running the script and
+// returns the result of Connect. The script gets the connection of the
+// client and runs the handshake of the charger.
+func handshake(t *testing.T, client *wattpilot.Wattpilot, server *wattpilottest.Server, script func(conn *wattpilottest.Conn) error) error {
+	t.Helper()
+	connected := make(chan error, 1)
+	go func() {
+		connected <- client.Connect()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	conn, err := server.Accept(ctx, testPassword)
+	if err != nil {
+		t.Fatal("client did not connect: ", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := script(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-connected:
+		return err
+	case <-time.After(testTimeout):
+		t.Fatal("Connect did not return")
+	}
+	return nil
+}
+
+func newClient(t *testing.T) (*wattpilot.Wattpilot, *wattpilottest.Server) {
+	t.Helper()
+	server, err := wattpilottest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	client := wattpilot.New(server.Addr(), testPassword)
+	t.Cleanup(func() { client.Close() })
+	return client, server
+}
+
+func TestAuthSuccess(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, _, _ := connect(t, clock)
+
+	if !client.IsInitialized() || client.GetSerial() != testSerial {
+		t.Fatal("client not initialized after authSuccess")
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	client, server := newClient(t)
+
+	err := handshake(t, client, server, func(conn *wattpilottest.Conn) error {
+		if err := conn.Send(hello()); err != nil {
+			return err
+		}
+		if err := conn.Send(map[string]interface{}{"type": "authRequired", "token1": token(t), "token2": token(t)}); err != nil {
+			return err
+		}
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+		return conn.Send(map[string]interface{}{"type": "authError", "message": "Wrong password"})
+	})
+	if !errors.Is(err, wattpilot.ErrAuthFailed) {
+		t.Fatal("expected ErrAuthFailed, got ", err)
+	}
+	if client.IsInitialized() {
+		t.Fatal("client initialized after authError")
+	}
+}
+
+func TestAuthRequiredBeforeHello(t *testing.T) {
+	client, server := newClient(t)
+
+	err := handshake(t, client, server, func(conn *wattpilottest.Conn) error {
+		return conn.Send(map[string]interface{}{"type": "authRequired", "token1": token(t), "token2": token(t)})
+	})
+	if !errors.Is(err, wattpilot.ErrAuthFailed) {
+		t.Fatal("expected ErrAuthFailed, got ", err)
+	}
+}
+
+func TestAuthToken2Replayed(t *testing.T) {
+	client, server := newClient(t)
+	token1, token2 := token(t), token(t)
+	script := func(conn *wattpilottest.Conn) error {
+		if err := conn.Send(hello()); err != nil {
+			return err
+		}
+		if err := conn.Send(map[string]interface{}{"type": "authRequired", "token1": token1, "token2": token2}); err != nil {
+			return err
+		}
+		if _, err := conn.Receive(); err != nil {
+			return err
+		}
+		if err := conn.Send(map[string]interface{}{"type": "authSuccess"}); err != nil {
+			return err
+		}
+		return conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": map[string]interface{}{"car": 1}})
+	}
+	if err := handshake(t, client, server, script); err != nil {
+		t.Fatal(err)
+	}
+	client.DisconnectForTest()
+
+	err := handshake(t, client, server, func(conn *wattpilottest.Conn) error {
+		if err := conn.Send(hello()); err != nil {
+			return err
+		}
+		return conn.Send(map[string]interface{}{"type": "authRequired", "token1": token1, "token2": token2})
+	})
+	if !errors.Is(err, wattpilot.ErrTokenReplayed) {
+		t.Fatal("expected ErrTokenReplayed, got ", err)
+	}
+}
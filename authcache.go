@@ -0,0 +1,64 @@
+package wattpilot
+
+import (
+	"sync"
+
+	"github.com/mabunixda/wattpilot/auth"
+)
+
+// the password hash only depends on the password and the serial, so each
+// instance derives it once per serial and keeps it for its reconnects. The
+// cache belongs to the instance, which holds the password anyway, and is
+// cleared on Close.
+
+type pendingHash struct {
+	done chan struct{}
+	hash string
+}
+
+type hashCache struct {
+	mu     sync.Mutex
+	hashes map[string]*pendingHash
+}
+
+func newHashCache() *hashCache {
+	return &hashCache{hashes: make(map[string]*pendingHash)}
+}
+
+// get returns the hash of the password for the serial, deriving it in the
+// background if it is not known yet
+func (c *hashCache) get(password string, serial string) *pendingHash {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pending, isKnown := c.hashes[serial]; isKnown {
+		return pending
+	}
+	pending := &pendingHash{done: make(chan struct{})}
+	c.hashes[serial] = pending
+	go func() {
+		pending.hash = auth.HashPassword(password, serial)
+		close(pending.done)
+	}()
+	return pending
+}
+
+func (c *hashCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.hashes)
+}
+
+func (p *pendingHash) wait() string {
+	<-p.done
+	return p.hash
+}
+
+// PrecomputeAuth derives the password hash for the serial in the
+// background, so the next connect to the charger does not wait for it
+func (w *Wattpilot) PrecomputeAuth(serial string) {
+	if w._password == "" || serial == "" {
+		return
+	}
+	w._hashes.get(w._password, serial)
+}
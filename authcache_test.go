@@ -0,0 +1,25 @@
+package wattpilot
+
+import "testing"
+
+func TestHashCachePerInstance(t *testing.T) {
+	w := New("localhost", "secret")
+	other := New("localhost", "secret")
+	defer other.Close()
+
+	first := w._hashes.get(w._password, "12345678")
+	if w._hashes.get(w._password, "12345678") != first {
+		t.Error("hash derived twice for the same serial")
+	}
+	if other._hashes.get(other._password, "12345678") == first {
+		t.Error("hash shared between instances")
+	}
+	if first.wait() == "" {
+		t.Error("no hash derived")
+	}
+
+	w.Close()
+	if len(w._hashes.hashes) != 0 {
+		t.Error("hashes kept after Close")
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Encoding of application and context tagged values (ASHRAE 135 clause
+// 20.2), limited to the types used by the objects of the daemon and the
+// values written to them.
+
+const (
+	TAG_NULL = iota
+	TAG_BOOLEAN
+	TAG_UNSIGNED
+	TAG_SIGNED
+	TAG_REAL
+	TAG_DOUBLE
+	TAG_OCTET_STRING
+	TAG_CHARACTER_STRING
+	TAG_BIT_STRING
+	TAG_ENUMERATED
+	TAG_DATE
+	TAG_TIME
+	TAG_OBJECT_IDENTIFIER
+)
+
+// CHARSET_UTF8 is the character set of character strings
+const CHARSET_UTF8 = 0
+
+var errMalformed = errors.New("malformed bacnet message")
+
+type enumerated uint32
+
+type bitString []bool
+
+// array values can be read by index, lists only as a whole
+type array []interface{}
+type list []interface{}
+
+type objectId struct {
+	kind     uint16
+	instance uint32
+}
+
+func (o objectId) String() string {
+	return fmt.Sprintf("%d:%d", o.kind, o.instance)
+}
+
+func (o objectId) value() uint32 {
+	return uint32(o.kind)<<22 | o.instance&MAX_INSTANCE
+}
+
+func decodeObjectId(v uint32) objectId {
+	return objectId{kind: uint16(v >> 22), instance: v & MAX_INSTANCE}
+}
+
+type tag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+	// lvt is the length of data, or the value of application booleans
+	lvt  uint32
+	data []byte
+}
+
+// decodeTag reads a tag with its data and returns the remaining bytes
+func decodeTag(b []byte) (tag, []byte, error) {
+	if len(b) == 0 {
+		return tag{}, nil, errMalformed
+	}
+	t := tag{number: b[0] >> 4, context: b[0]&0x08 != 0, lvt: uint32(b[0] & 0x07)}
+	b = b[1:]
+	if t.number == 0x0F {
+		if len(b) == 0 {
+			return tag{}, nil, errMalformed
+		}
+		t.number, b = b[0], b[1:]
+	}
+	switch {
+	case t.context && t.lvt == 6:
+		t.opening = true
+		return t, b, nil
+	case t.context && t.lvt == 7:
+		t.closing = true
+		return t, b, nil
+	case !t.context && t.number == TAG_BOOLEAN:
+		return t, b, nil
+	}
+	if t.lvt == 5 {
+		if len(b) == 0 {
+			return tag{}, nil, errMalformed
+		}
+		switch {
+		case b[0] == 254 && len(b) >= 3:
+			t.lvt, b = uint32(binary.BigEndian.Uint16(b[1:3])), b[3:]
+		case b[0] == 255 && len(b) >= 5:
+			t.lvt, b = binary.BigEndian.Uint32(b[1:5]), b[5:]
+		case b[0] < 254:
+			t.lvt, b = uint32(b[0]), b[1:]
+		default:
+			return tag{}, nil, errMalformed
+		}
+	}
+	if uint32(len(b)) < t.lvt {
+		return tag{}, nil, errMalformed
+	}
+	t.data = b[:t.lvt]
+	return t, b[t.lvt:], nil
+}
+
+func (t tag) unsigned() (uint32, error) {
+	if len(t.data) == 0 || len(t.data) > 4 {
+		return 0, errMalformed
+	}
+	var v uint32
+	for _, b := range t.data {
+		v = v<<8 | uint32(b)
+	}
+	return v, nil
+}
+
+func (t tag) signed() (int32, error) {
+	v, err := t.unsigned()
+	if err != nil {
+		return 0, err
+	}
+	shift := 32 - 8*len(t.data)
+	return int32(v<<shift) >> shift, nil
+}
+
+// decodeContextUnsigned reads an unsigned value with the context tag
+func decodeContextUnsigned(b []byte, number byte) (uint32, []byte, error) {
+	t, rest, err := decodeTag(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !t.context || t.opening || t.closing || t.number != number {
+		return 0, nil, errMalformed
+	}
+	v, err := t.unsigned()
+	return v, rest, err
+}
+
+// decodeApplication returns the value of an application tag as bool,
+// uint32, int32, float32, float64, enumerated or string
+func decodeApplication(t tag) (interface{}, error) {
+	if t.context || t.opening || t.closing {
+		return nil, errMalformed
+	}
+	switch t.number {
+	case TAG_NULL:
+		return nil, nil
+	case TAG_BOOLEAN:
+		return t.lvt == 1, nil
+	case TAG_UNSIGNED:
+		return t.unsigned()
+	case TAG_SIGNED:
+		return t.signed()
+	case TAG_REAL:
+		if len(t.data) != 4 {
+			return nil, errMalformed
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(t.data)), nil
+	case TAG_DOUBLE:
+		if len(t.data) != 8 {
+			return nil, errMalformed
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(t.data)), nil
+	case TAG_ENUMERATED:
+		v, err := t.unsigned()
+		return enumerated(v), err
+	case TAG_CHARACTER_STRING:
+		if len(t.data) == 0 || t.data[0] != CHARSET_UTF8 {
+			return nil, errInvalidDataType
+		}
+		return string(t.data[1:]), nil
+	}
+	return nil, errInvalidDataType
+}
+
+func appendTag(b []byte, number byte, context bool, length int) []byte {
+	header := number << 4
+	if context {
+		header |= 0x08
+	}
+	switch {
+	case length < 5:
+		return append(b, header|byte(length))
+	case length < 254:
+		return append(b, header|5, byte(length))
+	case length < 65536:
+		return binary.BigEndian.AppendUint16(append(b, header|5, 254), uint16(length))
+	}
+	return binary.BigEndian.AppendUint32(append(b, header|5, 255), uint32(length))
+}
+
+func appendOpening(b []byte, number byte) []byte {
+	return append(b, number<<4|0x0E)
+}
+
+func appendClosing(b []byte, number byte) []byte {
+	return append(b, number<<4|0x0F)
+}
+
+// unsignedBytes returns the shortest big endian encoding of v
+func unsignedBytes(v uint32) []byte {
+	data := binary.BigEndian.AppendUint32(nil, v)
+	for len(data) > 1 && data[0] == 0 {
+		data = data[1:]
+	}
+	return data
+}
+
+func appendContextUnsigned(b []byte, number byte, v uint32) []byte {
+	data := unsignedBytes(v)
+	return append(appendTag(b, number, true, len(data)), data...)
+}
+
+func appendContextObjectId(b []byte, number byte, o objectId) []byte {
+	return binary.BigEndian.AppendUint32(appendTag(b, number, true, 4), o.value())
+}
+
+// appendApplication encodes the value with its application tag, arrays
+// and lists as the sequence of their elements
+func appendApplication(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case nil:
+		return appendTag(b, TAG_NULL, false, 0)
+	case bool:
+		if v {
+			return appendTag(b, TAG_BOOLEAN, false, 1)
+		}
+		return appendTag(b, TAG_BOOLEAN, false, 0)
+	case uint32:
+		data := unsignedBytes(v)
+		return append(appendTag(b, TAG_UNSIGNED, false, len(data)), data...)
+	case enumerated:
+		data := unsignedBytes(uint32(v))
+		return append(appendTag(b, TAG_ENUMERATED, false, len(data)), data...)
+	case float32:
+		return binary.BigEndian.AppendUint32(appendTag(b, TAG_REAL, false, 4), math.Float32bits(v))
+	case string:
+		b = append(appendTag(b, TAG_CHARACTER_STRING, false, len(v)+1), CHARSET_UTF8)
+		return append(b, v...)
+	case bitString:
+		data := make([]byte, 1+(len(v)+7)/8)
+		data[0] = byte(8*(len(data)-1) - len(v))
+		for i, set := range v {
+			if set {
+				data[1+i/8] |= 0x80 >> (i % 8)
+			}
+		}
+		return append(appendTag(b, TAG_BIT_STRING, false, len(data)), data...)
+	case objectId:
+		return binary.BigEndian.AppendUint32(appendTag(b, TAG_OBJECT_IDENTIFIER, false, 4), v.value())
+	case array:
+		for _, element := range v {
+			b = appendApplication(b, element)
+		}
+		return b
+	case list:
+		for _, element := range v {
+			b = appendApplication(b, element)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("no bacnet encoding of %T", v))
+}
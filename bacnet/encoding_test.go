@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTagLengths(t *testing.T) {
+	tests := []struct {
+		length int
+		header string
+	}{
+		{0, "30"},
+		{4, "34"},
+		{5, "35 05"},
+		{253, "35 fd"},
+		{254, "35 fe 00 fe"},
+		{65535, "35 fe ff ff"},
+		{65536, "35 ff 00 01 00 00"},
+	}
+	for _, test := range tests {
+		header := appendTag(nil, 3, false, test.length)
+		if want := frame(t, test.header); !bytes.Equal(header, want) {
+			t.Errorf("length %d: got % x, want % x", test.length, header, want)
+			continue
+		}
+		data := append(header, make([]byte, test.length)...)
+		tag, rest, err := decodeTag(append(data, 0x42))
+		if err != nil || tag.number != 3 || int(tag.lvt) != test.length || len(tag.data) != test.length || !bytes.Equal(rest, []byte{0x42}) {
+			t.Errorf("length %d: decoded %+v, rest % x, %v", test.length, tag, rest, err)
+		}
+	}
+}
+
+func TestApplicationValues(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		encoded string
+	}{
+		{nil, "00"},
+		{true, "11"},
+		{false, "10"},
+		{uint32(0), "21 00"},
+		{uint32(1476), "22 05 c4"},
+		{uint32(70000), "23 01 11 70"},
+		{enumerated(3), "91 03"},
+		{float32(72.3), "44 42 90 99 9a"},
+		{"name", "75 05 00 6e 61 6d 65"},
+	}
+	for _, test := range tests {
+		encoded := appendApplication(nil, test.value)
+		if want := frame(t, test.encoded); !bytes.Equal(encoded, want) {
+			t.Errorf("%v: got % x, want % x", test.value, encoded, want)
+			continue
+		}
+		tag, _, err := decodeTag(encoded)
+		if err != nil {
+			t.Errorf("%v: %v", test.value, err)
+			continue
+		}
+		if value, err := decodeApplication(tag); err != nil || value != test.value {
+			t.Errorf("%v: decoded %v, %v", test.value, value, err)
+		}
+	}
+}
+
+func TestSignedValues(t *testing.T) {
+	for encoded, want := range map[string]int32{"31 ff": -1, "31 7f": 127, "32 ff 7f": -129, "34 80 00 00 00": -2147483648} {
+		tag, _, err := decodeTag(frame(t, encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value, err := decodeApplication(tag); err != nil || value != want {
+			t.Errorf("%s: decoded %v, %v, want %d", encoded, value, err, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+	"strconv"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/sirupsen/logrus"
+)
+
+// wattpilot-bacnet exposes the car state, charging allowed, power, total
+// energy and the charging current of the charger as BACnet/IP objects, so
+// building management systems integrate the Wattpilot without a custom
+// driver.
+
+// deviceInstance derives the instance of the device from the digits of the
+// serial, unless BACNET_DEVICE_ID sets it
+func deviceInstance(serial string) (uint32, error) {
+	if id := os.Getenv("BACNET_DEVICE_ID"); id != "" {
+		instance, err := strconv.ParseUint(id, 10, 32)
+		if err != nil || instance >= MAX_INSTANCE {
+			return 0, strconv.ErrRange
+		}
+		return uint32(instance), nil
+	}
+	instance, err := strconv.ParseUint(serial, 10, 64)
+	if err != nil {
+		return 1, nil
+	}
+	return uint32(instance % MAX_INSTANCE), nil
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+	level := os.Getenv("WATTPILOT_LOG")
+	listen := os.Getenv("BACNET_LISTEN")
+	if pwd == "" {
+		if store, err := api.DefaultCredentialStore(); err == nil {
+			pwd, _ = store.Get(host)
+		}
+	}
+	if host == "" || pwd == "" {
+		return
+	}
+	if level == "" {
+		level = "WARN"
+	}
+	if listen == "" {
+		listen = ":47808"
+	}
+
+	options := []api.Option{}
+	if endpoint := os.Getenv("WATTPILOT_LOG_SHIP"); endpoint != "" {
+		shipper, err := api.NewLogShipperURL(endpoint, logrus.InfoLevel)
+		if err != nil {
+			log.Fatalln("Could not set up log shipping", err)
+		}
+		defer shipper.Close()
+		options = append(options, api.WithLogShipper(shipper))
+	}
+
+	charger := api.New(host, pwd, options...)
+	if err := charger.ParseLogLevel(level); err != nil {
+		log.Fatalf("Could not update loglevel to %s: %v", level, err)
+	}
+	if err := charger.Connect(); err != nil {
+		log.Fatalln("Could not connect", err)
+	}
+	instance, err := deviceInstance(charger.GetSerial())
+	if err != nil {
+		log.Fatalln("Invalid BACNET_DEVICE_ID", err)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		log.Fatalln("Invalid BACNET_LISTEN", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		log.Fatalln("Could not listen", err)
+	}
+	defer conn.Close()
+
+	s := newServer(charger, instance)
+	defer s.session.Close()
+	log.Fatal(s.serve(conn))
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// The device object describes the daemon as BACnet device, the charger
+// properties are exposed as input objects and the charging current as
+// writable analog value.
+
+const (
+	OBJECT_ANALOG_INPUT      = 0
+	OBJECT_ANALOG_VALUE      = 2
+	OBJECT_BINARY_INPUT      = 3
+	OBJECT_DEVICE            = 8
+	OBJECT_MULTI_STATE_INPUT = 13
+
+	// MAX_INSTANCE is also the wildcard instance of the device
+	MAX_INSTANCE = 0x3FFFFF
+)
+
+const (
+	PROP_APDU_TIMEOUT                    = 11
+	PROP_APPLICATION_SOFTWARE_VERSION    = 12
+	PROP_DESCRIPTION                     = 28
+	PROP_DEVICE_ADDRESS_BINDING          = 30
+	PROP_EVENT_STATE                     = 36
+	PROP_FIRMWARE_REVISION               = 44
+	PROP_MAX_APDU_LENGTH_ACCEPTED        = 62
+	PROP_MAX_PRES_VALUE                  = 65
+	PROP_MIN_PRES_VALUE                  = 69
+	PROP_MODEL_NAME                      = 70
+	PROP_NUMBER_OF_APDU_RETRIES          = 73
+	PROP_NUMBER_OF_STATES                = 74
+	PROP_OBJECT_IDENTIFIER               = 75
+	PROP_OBJECT_LIST                     = 76
+	PROP_OBJECT_NAME                     = 77
+	PROP_OBJECT_TYPE                     = 79
+	PROP_OUT_OF_SERVICE                  = 81
+	PROP_POLARITY                        = 84
+	PROP_PRESENT_VALUE                   = 85
+	PROP_PROTOCOL_OBJECT_TYPES_SUPPORTED = 96
+	PROP_PROTOCOL_SERVICES_SUPPORTED     = 97
+	PROP_PROTOCOL_VERSION                = 98
+	PROP_SEGMENTATION_SUPPORTED          = 107
+	PROP_STATE_TEXT                      = 110
+	PROP_STATUS_FLAGS                    = 111
+	PROP_SYSTEM_STATUS                   = 112
+	PROP_UNITS                           = 117
+	PROP_VENDOR_IDENTIFIER               = 120
+	PROP_VENDOR_NAME                     = 121
+	PROP_PROTOCOL_REVISION               = 139
+	PROP_DATABASE_REVISION               = 155
+	PROP_PROPERTY_LIST                   = 371
+)
+
+const (
+	PROTOCOL_VERSION  = 1
+	PROTOCOL_REVISION = 14
+	// VENDOR_ID is unset, vendor identifiers are assigned by ASHRAE
+	VENDOR_ID              = 0
+	APDU_TIMEOUT           = 3000
+	APDU_RETRIES           = 3
+	SEGMENTATION_NONE      = 3
+	SYSTEM_OPERATIONAL     = 0
+	SYSTEM_NON_OPERATIONAL = 4
+	EVENT_STATE_NORMAL     = 0
+	POLARITY_NORMAL        = 0
+	UNITS_NO_UNITS         = 95
+	SERVICE_BITS           = 40
+	OBJECT_TYPE_BITS       = 64
+	BINARY_INACTIVE        = 0
+	BINARY_ACTIVE          = 1
+	STATUS_FLAG_FAULT      = 1
+)
+
+// bits of the services in protocol-services-supported
+const (
+	SUPPORTED_READ_PROPERTY  = 12
+	SUPPORTED_WRITE_PROPERTY = 15
+	SUPPORTED_I_AM           = 26
+	SUPPORTED_WHO_IS         = 34
+)
+
+// engineeringUnits maps the units of the library to BACnet units
+var engineeringUnits = map[api.Unit]enumerated{
+	api.UnitWatt:         47,
+	api.UnitKilowatt:     48,
+	api.UnitWattHour:     18,
+	api.UnitKilowattHour: 19,
+	api.UnitVolt:         5,
+	api.UnitAmpere:       3,
+	api.UnitHertz:        27,
+	api.UnitCelsius:      62,
+}
+
+// points are the properties exposed as objects, if reported by the charger
+var points = []struct {
+	name     string
+	kind     uint16
+	instance uint32
+}{
+	{name: "car", kind: OBJECT_MULTI_STATE_INPUT, instance: 1},
+	{name: "alw", kind: OBJECT_BINARY_INPUT, instance: 1},
+	{name: "power", kind: OBJECT_ANALOG_INPUT, instance: 1},
+	{name: "eto", kind: OBJECT_ANALOG_INPUT, instance: 2},
+	{name: "amp", kind: OBJECT_ANALOG_VALUE, instance: 1},
+}
+
+type property func() (interface{}, error)
+
+type object struct {
+	id         objectId
+	properties map[uint32]property
+	// write sets the present value, nil for read-only objects
+	write func(value interface{}) error
+}
+
+func constant(v interface{}) property {
+	return func() (interface{}, error) {
+		return v, nil
+	}
+}
+
+// propertyList lists the properties of the object, except the ones
+// required to be left out
+func (o *object) propertyList() array {
+	ids := []int{}
+	for id := range o.properties {
+		switch id {
+		case PROP_OBJECT_IDENTIFIER, PROP_OBJECT_NAME, PROP_OBJECT_TYPE, PROP_PROPERTY_LIST:
+			continue
+		}
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	list := array{}
+	for _, id := range ids {
+		list = append(list, enumerated(id))
+	}
+	return list
+}
+
+func (s *server) statusFlags() (interface{}, error) {
+	flags := bitString{false, false, false, false}
+	flags[STATUS_FLAG_FAULT] = !s.charger.IsInitialized()
+	return flags, nil
+}
+
+func (s *server) newDevice(instance uint32, objectList array) *object {
+	device := s.charger.EntityDevice()
+	name := device.Name
+	if name == "" {
+		name = "Wattpilot " + device.Serial
+	}
+	services := make(bitString, SERVICE_BITS)
+	for _, service := range []int{SUPPORTED_I_AM, SUPPORTED_WHO_IS, SUPPORTED_READ_PROPERTY, SUPPORTED_WRITE_PROPERTY} {
+		services[service] = true
+	}
+	types := make(bitString, OBJECT_TYPE_BITS)
+	for _, kind := range []int{OBJECT_ANALOG_INPUT, OBJECT_ANALOG_VALUE, OBJECT_BINARY_INPUT, OBJECT_DEVICE, OBJECT_MULTI_STATE_INPUT} {
+		types[kind] = true
+	}
+	o := &object{id: objectId{kind: OBJECT_DEVICE, instance: instance}}
+	o.properties = map[uint32]property{
+		PROP_OBJECT_IDENTIFIER: constant(o.id),
+		PROP_OBJECT_NAME:       constant(name),
+		PROP_OBJECT_TYPE:       constant(enumerated(OBJECT_DEVICE)),
+		PROP_DESCRIPTION:       constant("Serial " + device.Serial),
+		PROP_SYSTEM_STATUS: func() (interface{}, error) {
+			if !s.charger.IsInitialized() {
+				return enumerated(SYSTEM_NON_OPERATIONAL), nil
+			}
+			return enumerated(SYSTEM_OPERATIONAL), nil
+		},
+		PROP_VENDOR_NAME:                     constant(device.Manufacturer),
+		PROP_VENDOR_IDENTIFIER:               constant(uint32(VENDOR_ID)),
+		PROP_MODEL_NAME:                      constant(device.Model),
+		PROP_FIRMWARE_REVISION:               constant(device.Version),
+		PROP_APPLICATION_SOFTWARE_VERSION:    constant(device.Version),
+		PROP_PROTOCOL_VERSION:                constant(uint32(PROTOCOL_VERSION)),
+		PROP_PROTOCOL_REVISION:               constant(uint32(PROTOCOL_REVISION)),
+		PROP_PROTOCOL_SERVICES_SUPPORTED:     constant(services),
+		PROP_PROTOCOL_OBJECT_TYPES_SUPPORTED: constant(types),
+		PROP_OBJECT_LIST:                     constant(objectList),
+		PROP_MAX_APDU_LENGTH_ACCEPTED:        constant(uint32(MAX_APDU)),
+		PROP_SEGMENTATION_SUPPORTED:          constant(enumerated(SEGMENTATION_NONE)),
+		PROP_APDU_TIMEOUT:                    constant(uint32(APDU_TIMEOUT)),
+		PROP_NUMBER_OF_APDU_RETRIES:          constant(uint32(APDU_RETRIES)),
+		PROP_DEVICE_ADDRESS_BINDING:          constant(list{}),
+		PROP_DATABASE_REVISION:               constant(uint32(0)),
+	}
+	o.properties[PROP_PROPERTY_LIST] = constant(o.propertyList())
+	return o
+}
+
+// analogValue reports numeric strings of post processed properties as
+// numbers
+func (s *server) analogValue(name string) (float32, error) {
+	value, err := s.session.GetProperty(name)
+	if err != nil {
+		return 0, errUnavailable
+	}
+	switch value := value.(type) {
+	case float64:
+		return float32(value), nil
+	case string:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return float32(f), nil
+		}
+	}
+	return 0, errUnavailable
+}
+
+func (s *server) newPoint(e api.Entity, kind uint16, instance uint32) *object {
+	o := &object{id: objectId{kind: kind, instance: instance}}
+	o.properties = map[uint32]property{
+		PROP_OBJECT_IDENTIFIER: constant(o.id),
+		PROP_OBJECT_NAME:       constant(e.Label),
+		PROP_OBJECT_TYPE:       constant(enumerated(kind)),
+		PROP_DESCRIPTION:       constant(e.Name),
+		PROP_STATUS_FLAGS:      s.statusFlags,
+		PROP_EVENT_STATE:       constant(enumerated(EVENT_STATE_NORMAL)),
+		PROP_OUT_OF_SERVICE:    constant(false),
+	}
+	switch kind {
+	case OBJECT_ANALOG_INPUT, OBJECT_ANALOG_VALUE:
+		units, isKnown := engineeringUnits[e.Unit]
+		if !isKnown {
+			units = UNITS_NO_UNITS
+		}
+		o.properties[PROP_UNITS] = constant(units)
+		o.properties[PROP_PRESENT_VALUE] = func() (interface{}, error) {
+			return s.analogValue(e.Name)
+		}
+		if e.Writable {
+			o.properties[PROP_MIN_PRES_VALUE] = constant(float32(e.Min))
+			o.properties[PROP_MAX_PRES_VALUE] = constant(float32(e.Max))
+			o.write = func(value interface{}) error {
+				return s.writeAnalog(e, value)
+			}
+		}
+	case OBJECT_BINARY_INPUT:
+		o.properties[PROP_POLARITY] = constant(enumerated(POLARITY_NORMAL))
+		o.properties[PROP_PRESENT_VALUE] = func() (interface{}, error) {
+			value, err := s.session.GetProperty(e.Name)
+			if err != nil {
+				return nil, errUnavailable
+			}
+			if active, _ := value.(bool); active {
+				return enumerated(BINARY_ACTIVE), nil
+			}
+			return enumerated(BINARY_INACTIVE), nil
+		}
+	case OBJECT_MULTI_STATE_INPUT:
+		// the states are numbered from 1 in the order of the values
+		values := []int{}
+		for v := range e.Options {
+			values = append(values, v)
+		}
+		sort.Ints(values)
+		texts := array{}
+		for _, v := range values {
+			texts = append(texts, e.Options[v])
+		}
+		o.properties[PROP_NUMBER_OF_STATES] = constant(uint32(len(values)))
+		o.properties[PROP_STATE_TEXT] = constant(texts)
+		o.properties[PROP_PRESENT_VALUE] = func() (interface{}, error) {
+			value, err := s.analogValue(e.Name)
+			if err != nil {
+				return nil, err
+			}
+			for i, v := range values {
+				if float32(v) == value {
+					return uint32(i + 1), nil
+				}
+			}
+			return nil, errUnavailable
+		}
+	}
+	o.properties[PROP_PROPERTY_LIST] = constant(o.propertyList())
+	return o
+}
+
+func (s *server) writeAnalog(e api.Entity, value interface{}) error {
+	var f float64
+	switch value := value.(type) {
+	case float32:
+		f = float64(value)
+	case float64:
+		f = value
+	case uint32:
+		f = float64(value)
+	case int32:
+		f = float64(value)
+	default:
+		return errInvalidDataType
+	}
+	if f < e.Min || f > e.Max || f != math.Trunc(f) {
+		return errValueOutOfRange
+	}
+	if err := s.session.SetProperty(e.Name, int(f)); err != nil {
+		log.Println("error writing", e.Name, ":", err)
+		return fmt.Errorf("%w: %v", errWriteFailed, err)
+	}
+	return nil
+}
+
+// newObjects creates the device and the objects of the points reported by
+// the charger
+func (s *server) newObjects(instance uint32) []*object {
+	entities := make(map[string]api.Entity)
+	for _, e := range s.charger.Entities() {
+		entities[e.Name] = e
+	}
+	objects := []*object{}
+	objectList := array{objectId{kind: OBJECT_DEVICE, instance: instance}}
+	for _, p := range points {
+		e, isKnown := entities[p.name]
+		if !isKnown {
+			continue
+		}
+		o := s.newPoint(e, p.kind, p.instance)
+		objects = append(objects, o)
+		objectList = append(objectList, o.id)
+	}
+	return append([]*object{s.newDevice(instance, objectList)}, objects...)
+}
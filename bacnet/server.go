@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// The server answers Who-Is with I-Am, and serves ReadProperty and
+// WriteProperty on the objects. Segmentation is not supported, the
+// responses fit into the smallest APDU anyway.
+
+const (
+	BVLC_TYPE               = 0x81
+	BVLC_FORWARDED_NPDU     = 0x04
+	BVLC_ORIGINAL_UNICAST   = 0x0A
+	BVLC_ORIGINAL_BROADCAST = 0x0B
+
+	NPDU_VERSION         = 0x01
+	NPDU_NETWORK_MESSAGE = 0x80
+	NPDU_DESTINATION     = 0x20
+	NPDU_SOURCE          = 0x08
+	NPDU_HOP_COUNT       = 0xFF
+
+	PDU_CONFIRMED   = 0x00
+	PDU_UNCONFIRMED = 0x10
+	PDU_SIMPLE_ACK  = 0x20
+	PDU_COMPLEX_ACK = 0x30
+	PDU_ERROR       = 0x50
+	PDU_REJECT      = 0x60
+	PDU_ABORT       = 0x70
+	PDU_SEGMENTED   = 0x08
+	PDU_SERVER      = 0x01
+
+	SERVICE_I_AM           = 0
+	SERVICE_WHO_IS         = 8
+	SERVICE_READ_PROPERTY  = 12
+	SERVICE_WRITE_PROPERTY = 15
+
+	REJECT_INVALID_TAG          = 4
+	REJECT_UNRECOGNIZED_SERVICE = 9
+	ABORT_SEGMENTATION          = 4
+
+	MAX_APDU = 1476
+)
+
+const (
+	ERROR_CLASS_DEVICE   = 0
+	ERROR_CLASS_OBJECT   = 1
+	ERROR_CLASS_PROPERTY = 2
+
+	ERROR_CODE_INVALID_DATA_TYPE        = 9
+	ERROR_CODE_OPERATIONAL_PROBLEM      = 25
+	ERROR_CODE_UNKNOWN_OBJECT           = 31
+	ERROR_CODE_UNKNOWN_PROPERTY         = 32
+	ERROR_CODE_VALUE_OUT_OF_RANGE       = 37
+	ERROR_CODE_WRITE_ACCESS_DENIED      = 40
+	ERROR_CODE_INVALID_ARRAY_INDEX      = 42
+	ERROR_CODE_PROPERTY_IS_NOT_AN_ARRAY = 50
+)
+
+type bacnetError struct {
+	class enumerated
+	code  enumerated
+}
+
+func (e bacnetError) Error() string {
+	return fmt.Sprintf("bacnet error class %d code %d", e.class, e.code)
+}
+
+var (
+	errUnknownObject      = bacnetError{ERROR_CLASS_OBJECT, ERROR_CODE_UNKNOWN_OBJECT}
+	errUnknownProperty    = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_UNKNOWN_PROPERTY}
+	errWriteAccessDenied  = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_WRITE_ACCESS_DENIED}
+	errInvalidArrayIndex  = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_INVALID_ARRAY_INDEX}
+	errPropertyNotAnArray = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_PROPERTY_IS_NOT_AN_ARRAY}
+	errInvalidDataType    = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_INVALID_DATA_TYPE}
+	errValueOutOfRange    = bacnetError{ERROR_CLASS_PROPERTY, ERROR_CODE_VALUE_OUT_OF_RANGE}
+	errUnavailable        = bacnetError{ERROR_CLASS_DEVICE, ERROR_CODE_OPERATIONAL_PROBLEM}
+	errWriteFailed        = bacnetError{ERROR_CLASS_DEVICE, ERROR_CODE_OPERATIONAL_PROBLEM}
+)
+
+// maxResponses are the APDU sizes by the value in confirmed requests
+var maxResponses = []int{50, 128, 206, 480, 1024, 1476}
+
+type server struct {
+	charger *api.Wattpilot
+	session *api.Session
+	objects []*object
+	conn    *net.UDPConn
+}
+
+// route is the network and address of a requester behind a router
+type route struct {
+	network uint16
+	address []byte
+}
+
+func newServer(charger *api.Wattpilot, instance uint32) *server {
+	s := &server{
+		charger: charger,
+		session: charger.NewSession("bacnet", false),
+	}
+	s.objects = s.newObjects(instance)
+	return s
+}
+
+func (s *server) device() *object {
+	return s.objects[0]
+}
+
+func (s *server) find(id objectId) *object {
+	if id.kind == OBJECT_DEVICE && id.instance == MAX_INSTANCE {
+		return s.device()
+	}
+	for _, o := range s.objects {
+		if o.id == id {
+			return o
+		}
+	}
+	return nil
+}
+
+func (s *server) serve(conn *net.UDPConn) error {
+	s.conn = conn
+	buffer := make([]byte, MAX_APDU+64)
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return err
+		}
+		datagram := make([]byte, n)
+		copy(datagram, buffer[:n])
+		// writes wait for the charger, so they must not block the reads
+		go s.handleDatagram(datagram, addr)
+	}
+}
+
+func (s *server) handleDatagram(b []byte, addr *net.UDPAddr) {
+	response, origin, ok := s.handleFrame(b)
+	if !ok {
+		return
+	}
+	if origin != nil {
+		addr = origin
+	}
+	if _, err := s.conn.WriteToUDP(response, addr); err != nil {
+		log.Println("error sending to", addr, ":", err)
+	}
+}
+
+// handleFrame returns the response to a BVLC frame, and the address of the
+// original requester of a forwarded frame
+func (s *server) handleFrame(b []byte) ([]byte, *net.UDPAddr, bool) {
+	if len(b) < 4 || b[0] != BVLC_TYPE || int(binary.BigEndian.Uint16(b[2:4])) != len(b) {
+		return nil, nil, false
+	}
+	var origin *net.UDPAddr
+	switch b[1] {
+	case BVLC_ORIGINAL_UNICAST, BVLC_ORIGINAL_BROADCAST:
+		b = b[4:]
+	case BVLC_FORWARDED_NPDU:
+		// answer the original requester instead of the broadcast manager
+		if len(b) < 10 {
+			return nil, nil, false
+		}
+		origin = &net.UDPAddr{IP: net.IP(b[4:8]), Port: int(binary.BigEndian.Uint16(b[8:10]))}
+		b = b[10:]
+	default:
+		return nil, nil, false
+	}
+	apdu, source, err := parseNPDU(b)
+	if err != nil || apdu == nil {
+		return nil, nil, false
+	}
+	response := s.handleAPDU(apdu)
+	if response == nil {
+		return nil, nil, false
+	}
+	return encodeResponse(source, response), origin, true
+}
+
+// parseNPDU returns the APDU and the route of the source, network layer
+// messages are ignored
+func parseNPDU(b []byte) ([]byte, *route, error) {
+	if len(b) < 2 || b[0] != NPDU_VERSION {
+		return nil, nil, errMalformed
+	}
+	control := b[1]
+	b = b[2:]
+	if control&NPDU_NETWORK_MESSAGE != 0 {
+		return nil, nil, nil
+	}
+	if control&NPDU_DESTINATION != 0 {
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return nil, nil, errMalformed
+		}
+		b = b[3+int(b[2]):]
+	}
+	var source *route
+	if control&NPDU_SOURCE != 0 {
+		if len(b) < 3 || len(b) < 3+int(b[2]) {
+			return nil, nil, errMalformed
+		}
+		source = &route{network: binary.BigEndian.Uint16(b[:2]), address: b[3 : 3+int(b[2])]}
+		b = b[3+int(b[2]):]
+	}
+	if control&NPDU_DESTINATION != 0 {
+		if len(b) < 1 {
+			return nil, nil, errMalformed
+		}
+		b = b[1:]
+	}
+	return b, source, nil
+}
+
+// encodeResponse wraps the APDU into a NPDU routed back to the source and
+// a BVLC unicast
+func encodeResponse(source *route, apdu []byte) []byte {
+	b := []byte{BVLC_TYPE, BVLC_ORIGINAL_UNICAST, 0, 0, NPDU_VERSION, 0}
+	if source != nil {
+		b[5] = NPDU_DESTINATION
+		b = binary.BigEndian.AppendUint16(b, source.network)
+		b = append(b, byte(len(source.address)))
+		b = append(b, source.address...)
+		b = append(b, NPDU_HOP_COUNT)
+	}
+	b = append(b, apdu...)
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	return b
+}
+
+func (s *server) handleAPDU(apdu []byte) []byte {
+	if len(apdu) < 2 {
+		return nil
+	}
+	switch apdu[0] & 0xF0 {
+	case PDU_UNCONFIRMED:
+		if apdu[1] == SERVICE_WHO_IS {
+			return s.whoIs(apdu[2:])
+		}
+		return nil
+	case PDU_CONFIRMED:
+	default:
+		return nil
+	}
+	if len(apdu) < 4 {
+		return nil
+	}
+	invokeId := apdu[2]
+	if apdu[0]&PDU_SEGMENTED != 0 {
+		return []byte{PDU_ABORT | PDU_SERVER, invokeId, ABORT_SEGMENTATION}
+	}
+	maxResponse := MAX_APDU
+	if i := int(apdu[1] & 0x0F); i < len(maxResponses) {
+		maxResponse = maxResponses[i]
+	}
+	service := apdu[3]
+
+	var ack []byte
+	var err error
+	switch service {
+	case SERVICE_READ_PROPERTY:
+		ack, err = s.readProperty(apdu[4:])
+	case SERVICE_WRITE_PROPERTY:
+		err = s.writeProperty(apdu[4:])
+	default:
+		return []byte{PDU_REJECT, invokeId, REJECT_UNRECOGNIZED_SERVICE}
+	}
+
+	var bacErr bacnetError
+	switch {
+	case errors.As(err, &bacErr):
+		response := []byte{PDU_ERROR, invokeId, service}
+		response = appendApplication(response, bacErr.class)
+		return appendApplication(response, bacErr.code)
+	case err != nil:
+		return []byte{PDU_REJECT, invokeId, REJECT_INVALID_TAG}
+	case ack == nil:
+		return []byte{PDU_SIMPLE_ACK, invokeId, service}
+	}
+	response := append([]byte{PDU_COMPLEX_ACK, invokeId, service}, ack...)
+	if len(response) > maxResponse {
+		return []byte{PDU_ABORT | PDU_SERVER, invokeId, ABORT_SEGMENTATION}
+	}
+	return response
+}
+
+// whoIs answers with I-Am when the device is within the optional range
+func (s *server) whoIs(params []byte) []byte {
+	id := s.device().id
+	if len(params) > 0 {
+		low, rest, err := decodeContextUnsigned(params, 0)
+		if err != nil {
+			return nil
+		}
+		high, _, err := decodeContextUnsigned(rest, 1)
+		if err != nil || id.instance < low || id.instance > high {
+			return nil
+		}
+	}
+	response := []byte{PDU_UNCONFIRMED, SERVICE_I_AM}
+	response = appendApplication(response, id)
+	response = appendApplication(response, uint32(MAX_APDU))
+	response = appendApplication(response, enumerated(SEGMENTATION_NONE))
+	return appendApplication(response, uint32(VENDOR_ID))
+}
+
+// decodeReference reads the object, property and optional array index
+// starting the parameters of ReadProperty and WriteProperty
+func decodeReference(params []byte) (objectId, uint32, *uint32, []byte, error) {
+	t, rest, err := decodeTag(params)
+	if err != nil || !t.context || t.number != 0 || len(t.data) != 4 {
+		return objectId{}, 0, nil, nil, errMalformed
+	}
+	id := decodeObjectId(binary.BigEndian.Uint32(t.data))
+	property, rest, err := decodeContextUnsigned(rest, 1)
+	if err != nil {
+		return objectId{}, 0, nil, nil, err
+	}
+	if len(rest) == 0 {
+		return id, property, nil, rest, nil
+	}
+	if t, _, err := decodeTag(rest); err != nil || !t.context || t.number != 2 {
+		return id, property, nil, rest, nil
+	}
+	index, rest, err := decodeContextUnsigned(rest, 2)
+	if err != nil {
+		return objectId{}, 0, nil, nil, err
+	}
+	return id, property, &index, rest, nil
+}
+
+func (s *server) readProperty(params []byte) ([]byte, error) {
+	id, property, index, _, err := decodeReference(params)
+	if err != nil {
+		return nil, err
+	}
+	o := s.find(id)
+	if o == nil {
+		return nil, errUnknownObject
+	}
+	read, isKnown := o.properties[property]
+	if !isKnown {
+		return nil, errUnknownProperty
+	}
+	value, err := read()
+	if err != nil {
+		return nil, err
+	}
+	if index != nil {
+		elements, isArray := value.(array)
+		switch {
+		case !isArray:
+			return nil, errPropertyNotAnArray
+		case *index == 0:
+			value = uint32(len(elements))
+		case int(*index) > len(elements):
+			return nil, errInvalidArrayIndex
+		default:
+			value = elements[*index-1]
+		}
+	}
+
+	ack := appendContextObjectId(nil, 0, o.id)
+	ack = appendContextUnsigned(ack, 1, property)
+	if index != nil {
+		ack = appendContextUnsigned(ack, 2, *index)
+	}
+	ack = appendOpening(ack, 3)
+	ack = appendApplication(ack, value)
+	return appendClosing(ack, 3), nil
+}
+
+func (s *server) writeProperty(params []byte) error {
+	id, property, index, rest, err := decodeReference(params)
+	if err != nil {
+		return err
+	}
+	t, rest, err := decodeTag(rest)
+	if err != nil || !t.opening || t.number != 3 {
+		return errMalformed
+	}
+	t, rest, err = decodeTag(rest)
+	if err != nil {
+		return err
+	}
+	value, err := decodeApplication(t)
+	if err != nil {
+		return err
+	}
+	if t, _, err := decodeTag(rest); err != nil || !t.closing || t.number != 3 {
+		return errMalformed
+	}
+
+	o := s.find(id)
+	if o == nil {
+		return errUnknownObject
+	}
+	if _, isKnown := o.properties[property]; !isKnown {
+		return errUnknownProperty
+	}
+	if property != PROP_PRESENT_VALUE || o.write == nil {
+		return errWriteAccessDenied
+	}
+	if index != nil {
+		return errPropertyNotAnArray
+	}
+	return o.write(value)
+}
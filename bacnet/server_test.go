@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+const (
+	testSerial   = "90000004"
+	testPassword = "secret"
+	testInstance = 1234
+)
+
+// frame decodes the hex notation of a frame, spaces separate the octets
+func frame(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// newTestServer serves the objects of a mock charger
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	mock, err := wattpilottest.NewMockCharger(testSerial, testPassword, map[string]interface{}{"car": 2, "alw": true, "amp": 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	charger := api.New(mock.Addr(), testPassword)
+	t.Cleanup(func() { charger.Close() })
+	if err := charger.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return newServer(charger, testInstance)
+}
+
+// The frames are encoded by hand after the examples of ASHRAE 135 Annex F
+// and the Who-Is broadcast of common BACnet/IP tools, they are not
+// captured from a building management system.
+func TestKnownFrames(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name     string
+		request  string
+		response string
+	}{
+		{
+			"global Who-Is",
+			"81 0b 00 0c 01 20 ff ff 00 ff 10 08",
+			// I-Am of device 1234, max APDU 1476, no segmentation, vendor 0
+			"81 0a 00 14 01 00 10 00 c4 02 00 04 d2 22 05 c4 91 03 21 00",
+		},
+		{
+			"Who-Is with the device in range",
+			"81 0a 00 0d 01 00 10 08 09 00 1a 04 d2",
+			"81 0a 00 14 01 00 10 00 c4 02 00 04 d2 22 05 c4 91 03 21 00",
+		},
+		{
+			"Who-Is with the device out of range",
+			"81 0a 00 0d 01 00 10 08 09 00 1a 03 e8",
+			"",
+		},
+		{
+			"ReadProperty present value of the current",
+			// analog-value 1, present-value
+			"81 0a 00 11 01 04 00 05 01 0c 0c 00 80 00 01 19 55",
+			"81 0a 00 17 01 00 30 01 0c 0c 00 80 00 01 19 55 3e 44 41 80 00 00 3f",
+		},
+		{
+			"ReadProperty routed from another network",
+			// source network 5, address 0x0a, object-identifier of the device
+			"81 0a 00 15 01 0c 00 05 01 0a 00 05 02 0c 0c 02 00 04 d2 19 4b",
+			"81 0a 00 1c 01 20 00 05 01 0a ff 30 02 0c 0c 02 00 04 d2 19 4b 3e c4 02 00 04 d2 3f",
+		},
+		{
+			"ReadProperty of an unknown object",
+			"81 0a 00 11 01 04 00 05 03 0c 0c 00 00 00 63 19 55",
+			"81 0a 00 0d 01 00 50 03 0c 91 01 91 1f",
+		},
+		{
+			"ReadProperty of an array index of a scalar",
+			"81 0a 00 13 01 04 00 05 04 0c 0c 00 80 00 01 19 55 29 01",
+			"81 0a 00 0d 01 00 50 04 0c 91 02 91 32",
+		},
+		{
+			"WriteProperty of a read-only property",
+			// object-name of analog-value 1
+			"81 0a 00 15 01 04 00 05 05 0f 0c 00 80 00 01 19 4d 3e 21 01 3f",
+			"81 0a 00 0d 01 00 50 05 0f 91 02 91 28",
+		},
+		{
+			"unsupported service",
+			"81 0a 00 0b 01 04 00 05 06 0e 00",
+			"81 0a 00 09 01 00 60 06 09",
+		},
+		{
+			"segmented request",
+			"81 0a 00 11 01 04 08 05 07 00 01 0c 0c 00 80 00 01",
+			"81 0a 00 09 01 00 71 07 04",
+		},
+	}
+	for _, test := range tests {
+		request := frame(t, test.request)
+		response, _, ok := s.handleFrame(request)
+		want := frame(t, test.response)
+		if test.response == "" {
+			if ok {
+				t.Errorf("%s: unexpected response % x", test.name, response)
+			}
+			continue
+		}
+		if !ok || !bytes.Equal(response, want) {
+			t.Errorf("%s: got % x, want % x", test.name, response, want)
+		}
+	}
+}
+
+func TestReadPropertyObjectName(t *testing.T) {
+	s := newTestServer(t)
+	// object-name of the device
+	response, _, ok := s.handleFrame(frame(t, "81 0a 00 11 01 04 00 05 01 0c 0c 02 00 04 d2 19 4d"))
+	if !ok {
+		t.Fatal("no response")
+	}
+	name := s.charger.EntityDevice().Name
+	value := appendApplication(nil, name)
+	if !bytes.Contains(response, value) {
+		t.Fatalf("response % x does not contain the name %q", response, name)
+	}
+}
+
+// FuzzHandleFrame feeds untrusted datagrams to the decoding of the server
+func FuzzHandleFrame(f *testing.F) {
+	seeds := []string{
+		"81 0b 00 0c 01 20 ff ff 00 ff 10 08",
+		"81 0a 00 0d 01 00 10 08 09 00 1a 04 d2",
+		"81 0a 00 11 01 04 00 05 01 0c 0c 02 00 04 d2 19 4d",
+		"81 0a 00 15 01 0c 00 05 01 0a 00 05 02 0c 0c 02 00 04 d2 19 4b",
+		"81 0a 00 16 01 04 00 05 05 0f 0c 02 00 04 d2 19 4d 3e 75 05 00 6e 61 6d 65 3f",
+		"81 04 00 19 c0 a8 01 02 ba c0 01 04 00 05 01 0c 0c 02 00 04 d2 19 4c 29 00",
+	}
+	for _, seed := range seeds {
+		f.Add(frame(f, seed))
+	}
+	// a charger without connection serves the device object only, so
+	// no write reaches a charger
+	charger := api.New("127.0.0.1:1", testPassword)
+	defer charger.Close()
+	s := newServer(charger, testInstance)
+	f.Fuzz(func(t *testing.T, datagram []byte) {
+		response, _, ok := s.handleFrame(datagram)
+		if ok && len(response) > MAX_APDU+64 {
+			t.Fatalf("response of %d bytes", len(response))
+		}
+	})
+}
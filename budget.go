@@ -0,0 +1,214 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// BUDGET_WARNING is the share of a budget which emits EventBudgetWarning
+	BUDGET_WARNING = 0.8
+
+	EventBudgetWarning   EventType = "budgetWarning"
+	EventBudgetExhausted EventType = "budgetExhausted"
+	EventBudgetReset     EventType = "budgetReset"
+)
+
+// DailyBudget limits the energy charged per day in Wh, for the charger and
+// per RFID card by its transaction value trx (card index + 1). Zero values
+// are unlimited. The day starts at ResetHour local time.
+type DailyBudget struct {
+	Energy    float64         `json:"energy,omitempty"`
+	Cards     map[int]float64 `json:"cards,omitempty"`
+	ResetHour int             `json:"resetHour"`
+}
+
+// BudgetUsage is the energy charged since the start of the budget day in Wh
+type BudgetUsage struct {
+	Day    time.Time       `json:"day"`
+	Energy float64         `json:"energy"`
+	Cards  map[int]float64 `json:"cards"`
+	Paused bool            `json:"paused"`
+}
+
+type budgetState struct {
+	mu     sync.Mutex
+	usage  BudgetUsage
+	warned map[string]bool
+}
+
+// BudgetUsage returns the usage counted by RunDailyBudget
+func (w *Wattpilot) BudgetUsage() BudgetUsage {
+	w._budget.mu.Lock()
+	defer w._budget.mu.Unlock()
+
+	return w._budget.usage.copy()
+}
+
+func (u BudgetUsage) copy() BudgetUsage {
+	cards := make(map[int]float64, len(u.Cards))
+	for card, energy := range u.Cards {
+		cards[card] = energy
+	}
+	u.Cards = cards
+	return u
+}
+
+// nextBudgetDay returns the start of the budget day after t. The date is
+// built from the calendar day, so an hour skipped by the change to summer
+// time moves only the start of that day.
+func nextBudgetDay(t time.Time, hour int) time.Time {
+	next := time.Date(t.Year(), t.Month(), t.Day(), hour, 0, 0, 0, t.Location())
+	if !next.After(t) {
+		next = time.Date(t.Year(), t.Month(), t.Day()+1, hour, 0, 0, 0, t.Location())
+	}
+	return next
+}
+
+// budgetDay returns the start of the budget day ending at next
+func budgetDay(next time.Time, hour int) time.Time {
+	return time.Date(next.Year(), next.Month(), next.Day()-1, hour, 0, 0, 0, next.Location())
+}
+
+// RunDailyBudget counts the energy charged from the total counter eto and
+// pauses the charger with frc when the daily budget of the charger or of
+// the card in use is exhausted, until the next day starts or another card
+// with budget left is used. It runs until the context is cancelled.
+func (w *Wattpilot) RunDailyBudget(ctx context.Context, budget DailyBudget) {
+	session := w.NewSession("budget", false)
+	defer session.Close()
+
+	energy := session.GetNotifications("eto")
+	card := session.GetNotifications("trx")
+	// read the counter before the timer starts, so every update arriving
+	// while the timer is pending is counted
+	last := -1.0
+	if eto, err := w.getFloatProperty("eto"); err == nil {
+		last = eto
+	}
+
+	b := w._budget
+	now := w._clock.Now()
+	reset := nextBudgetDay(now, budget.ResetHour)
+	b.mu.Lock()
+	b.usage = BudgetUsage{Day: budgetDay(reset, budget.ResetHour), Cards: make(map[int]float64)}
+	b.warned = make(map[string]bool)
+	b.mu.Unlock()
+
+	timer := w._clock.NewTimer(reset.Sub(now))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			now := w._clock.Now()
+			reset = nextBudgetDay(now, budget.ResetHour)
+			timer.Reset(reset.Sub(now))
+			b.mu.Lock()
+			previous := b.usage
+			b.usage = BudgetUsage{Day: budgetDay(reset, budget.ResetHour), Cards: make(map[int]float64), Paused: previous.Paused}
+			b.warned = make(map[string]bool)
+			b.mu.Unlock()
+			w.emitEvent(EventBudgetReset, map[string]interface{}{"usage": previous})
+			w.enforceBudget(session, budget)
+		case value, ok := <-energy:
+			if !ok {
+				return
+			}
+			eto, err := toFloat(value)
+			if err != nil {
+				continue
+			}
+			if last >= 0 && eto > last {
+				b.mu.Lock()
+				b.usage.Energy += eto - last
+				if trx := currentCard(w); trx > 0 {
+					b.usage.Cards[trx] += eto - last
+				}
+				b.mu.Unlock()
+			}
+			last = eto
+			w.enforceBudget(session, budget)
+		case _, ok := <-card:
+			if !ok {
+				return
+			}
+			w.enforceBudget(session, budget)
+		}
+	}
+}
+
+type budgetCheck struct {
+	name  string
+	used  float64
+	limit float64
+}
+
+// currentCard returns the transaction value of the card in use, zero
+// without a card
+func currentCard(w *Wattpilot) int {
+	trx, err := w.getFloatProperty("trx")
+	if err != nil || trx < 0 {
+		return 0
+	}
+	return int(trx)
+}
+
+// enforceBudget warns once per day and budget, and pauses or resumes the
+// charger when the exhaustion of the budgets in effect changed
+func (w *Wattpilot) enforceBudget(session *Session, budget DailyBudget) {
+	trx := currentCard(w)
+	b := w._budget
+	b.mu.Lock()
+	checks := []budgetCheck{{"charger", b.usage.Energy, budget.Energy}}
+	if trx > 0 {
+		checks = append(checks, budgetCheck{fmt.Sprintf("card %d", trx), b.usage.Cards[trx], budget.Cards[trx]})
+	}
+	exhausted := ""
+	warnings := []string{}
+	for _, check := range checks {
+		if check.limit <= 0 {
+			continue
+		}
+		share := check.used / check.limit
+		if share >= 1 && exhausted == "" {
+			exhausted = check.name
+		}
+		if share >= BUDGET_WARNING && !b.warned[check.name] {
+			b.warned[check.name] = true
+			warnings = append(warnings, check.name)
+		}
+	}
+	paused := b.usage.Paused
+	b.usage.Paused = exhausted != ""
+	usage := b.usage.copy()
+	b.mu.Unlock()
+
+	for _, name := range warnings {
+		w.emitEvent(EventBudgetWarning, map[string]interface{}{"budget": name, "usage": usage})
+	}
+	if paused == (exhausted != "") {
+		return
+	}
+	state := ForceNeutral
+	reason := "daily budget available"
+	if exhausted != "" {
+		state = ForceOff
+		reason = "daily budget of " + exhausted + " exhausted"
+		w.logEntry().Info("Pausing charging, ", reason)
+		w.emitEvent(EventBudgetExhausted, map[string]interface{}{"budget": exhausted, "usage": usage})
+	}
+	err := session.SetProperty("frc", int(state))
+	w.recordDecision(Decision{
+		Source:   "budget",
+		Inputs:   map[string]interface{}{"energy": usage.Energy, "card": trx},
+		Setpoint: int(state),
+		Action:   "set frc",
+		Reason:   reason,
+		Error:    err,
+	})
+}
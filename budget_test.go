@@ -0,0 +1,151 @@
+package wattpilot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// budgeted connects a client on the fake clock and runs the daily budget
+// from the status given
+func budgeted(t *testing.T, clock *wattpilottest.FakeClock, status map[string]interface{}, budget wattpilot.DailyBudget) (*wattpilot.Wattpilot, *wattpilottest.Conn) {
+	t.Helper()
+	client, _, conn := connect(t, clock)
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": status}); err != nil {
+		t.Fatal(err)
+	}
+	waitForProperty(t, client, "eto", status["eto"])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go client.RunDailyBudget(ctx, budget)
+	// process loop, watchdog and start of the next day
+	clock.BlockUntil(3)
+	return client, conn
+}
+
+// charged sends the energy counter and waits until the budget counted it
+func charged(t *testing.T, client *wattpilot.Wattpilot, conn *wattpilottest.Conn, eto float64, used float64) {
+	t.Helper()
+	if err := conn.Send(map[string]interface{}{"type": "deltaStatus", "status": map[string]interface{}{"eto": eto}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(testTimeout)
+	for client.BudgetUsage().Energy != used {
+		if time.Now().After(deadline) {
+			t.Fatalf("usage %v, want %v", client.BudgetUsage().Energy, used)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan interface{}) wattpilot.Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event.(wattpilot.Event)
+	case <-time.After(testTimeout):
+		t.Fatal("no event")
+	}
+	return wattpilot.Event{}
+}
+
+func TestBudgetRolloverAcrossDST(t *testing.T) {
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name  string
+		start time.Time
+		hour  int
+		// the first reset, the day has 25 or 23 hours
+		reset time.Time
+	}{
+		{"end of summer time", time.Date(2026, 10, 24, 12, 0, 0, 0, vienna), 6, time.Date(2026, 10, 25, 6, 0, 0, 0, vienna)},
+		{"start of summer time", time.Date(2026, 3, 28, 12, 0, 0, 0, vienna), 6, time.Date(2026, 3, 29, 6, 0, 0, 0, vienna)},
+		{"reset hour skipped", time.Date(2026, 3, 28, 12, 0, 0, 0, vienna), 2, time.Date(2026, 3, 29, 3, 0, 0, 0, vienna)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := wattpilottest.NewFakeClock(test.start)
+			client, conn := budgeted(t, clock, map[string]interface{}{"car": 2, "eto": 1000.0, "frc": 0}, wattpilot.DailyBudget{Energy: 10000, ResetHour: test.hour})
+			resets := client.GetEvents(wattpilot.EventBudgetReset)
+			charged(t, client, conn, 3000, 2000)
+
+			// a minute early does not reset, the event carries the time of
+			// the timer firing
+			clock.Advance(test.reset.Sub(test.start) - time.Minute)
+			clock.Advance(time.Minute)
+			event := nextEvent(t, resets)
+			if !event.Time.Equal(test.reset) {
+				t.Fatalf("reset at %v, want %v", event.Time, test.reset)
+			}
+			if usage := event.Data["usage"].(wattpilot.BudgetUsage); usage.Energy != 2000 || !usage.Day.Equal(time.Date(2026, test.start.Month(), test.start.Day(), test.hour, 0, 0, 0, vienna)) {
+				t.Fatal("previous day reported as ", usage)
+			}
+			// a skipped reset hour moves the start of that day only
+			next := time.Date(2026, test.reset.Month(), test.reset.Day()+1, test.hour, 0, 0, 0, vienna)
+			if usage := client.BudgetUsage(); usage.Energy != 0 || !usage.Day.Equal(test.reset) {
+				t.Fatal("usage not reset: ", usage)
+			}
+			clock.Advance(next.Sub(test.reset))
+			if event := nextEvent(t, resets); !event.Time.Equal(next) {
+				t.Fatalf("second reset at %v, want %v", event.Time, next)
+			}
+		})
+	}
+}
+
+func TestBudgetPausesCharging(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	client, conn := budgeted(t, clock, map[string]interface{}{"car": 2, "eto": 1000.0, "frc": 0, "trx": 0}, wattpilot.DailyBudget{Energy: 5000})
+	warnings := client.GetEvents(wattpilot.EventBudgetWarning)
+	exhausted := client.GetEvents(wattpilot.EventBudgetExhausted)
+
+	charged(t, client, conn, 4000, 3000)
+	charged(t, client, conn, 5000, 4000)
+	if event := nextEvent(t, warnings); event.Data["budget"] != "charger" {
+		t.Fatal("unexpected warning ", event.Data)
+	}
+
+	charged(t, client, conn, 6000, 5000)
+	expectWrite(t, conn, "frc", float64(wattpilot.ForceOff))
+	if event := nextEvent(t, exhausted); event.Data["budget"] != "charger" {
+		t.Fatal("unexpected exhaustion ", event.Data)
+	}
+	if !client.BudgetUsage().Paused {
+		t.Fatal("usage not paused")
+	}
+
+	// the next day resumes charging
+	clock.Advance(12 * time.Hour)
+	expectWrite(t, conn, "frc", float64(wattpilot.ForceNeutral))
+	if usage := client.BudgetUsage(); usage.Paused || usage.Energy != 0 {
+		t.Fatal("usage not reset: ", usage)
+	}
+}
+
+func TestCardBudgetPausesCharging(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	client, conn := budgeted(t, clock, map[string]interface{}{"car": 2, "eto": 1000.0, "frc": 0, "trx": 1}, wattpilot.DailyBudget{Cards: map[int]float64{1: 1000}})
+	exhausted := client.GetEvents(wattpilot.EventBudgetExhausted)
+
+	charged(t, client, conn, 2000, 1000)
+	expectWrite(t, conn, "frc", float64(wattpilot.ForceOff))
+	if event := nextEvent(t, exhausted); event.Data["budget"] != "card 1" {
+		t.Fatal("unexpected exhaustion ", event.Data)
+	}
+
+	// a card with budget left resumes charging
+	if err := conn.Send(map[string]interface{}{"type": "deltaStatus", "status": map[string]interface{}{"trx": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	expectWrite(t, conn, "frc", float64(wattpilot.ForceNeutral))
+	if usage := client.BudgetUsage(); usage.Paused || usage.Cards[1] != 1000 {
+		t.Fatal("unexpected usage ", usage)
+	}
+}
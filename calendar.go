@@ -0,0 +1,382 @@
+package wattpilot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnsupportedRecurrence = errors.New("unsupported recurrence rule")
+
+// Frequency of a recurring calendar entry
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+// Recurrence repeats a calendar entry every Interval days, weeks, months or
+// years. Count limits the number of occurrences including the first one,
+// Until the start of the last one, zero values repeat forever.
+type Recurrence struct {
+	Frequency Frequency
+	Interval  int
+	Count     int
+	Until     time.Time
+}
+
+// CalendarEntry is a period of whole days, End is exclusive. Recurring
+// entries repeat the period, starts listed in Exceptions are left out.
+type CalendarEntry struct {
+	Start      time.Time
+	End        time.Time
+	Summary    string
+	Recurrence *Recurrence
+	Exceptions []time.Time
+}
+
+// Calendar holds date exceptions like holidays or vacations
+type Calendar struct {
+	Entries []CalendarEntry
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// AddDays adds the days from start up to including end
+func (c *Calendar) AddDays(start time.Time, end time.Time, summary string) {
+	c.Entries = append(c.Entries, CalendarEntry{
+		Start:   truncateDay(start),
+		End:     truncateDay(end).AddDate(0, 0, 1),
+		Summary: summary,
+	})
+}
+
+func (c *Calendar) Contains(t time.Time) bool {
+	if c == nil {
+		return false
+	}
+	for _, e := range c.Entries {
+		if e.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether t is within the entry or one of its occurrences
+func (e CalendarEntry) Contains(t time.Time) bool {
+	if e.Recurrence == nil {
+		return !t.Before(e.Start) && t.Before(e.End)
+	}
+	duration := e.End.Sub(e.Start)
+	// occurrences end in the order they start, so going back from the last
+	// one starting before t ends at the first one ending before t
+	for n := e.Recurrence.occurrencesBefore(e.Start, t); n >= 0; n-- {
+		start := e.Recurrence.occurrence(e.Start, n)
+		if start.After(t) {
+			continue
+		}
+		if !t.Before(start.Add(duration)) {
+			return false
+		}
+		if e.Recurrence.includes(start, n) && !e.isException(start) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e CalendarEntry) isException(start time.Time) bool {
+	for _, exception := range e.Exceptions {
+		if exception.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// occurrence returns the start of the occurrence n, the first is 0
+func (r *Recurrence) occurrence(start time.Time, n int) time.Time {
+	step := n * r.interval()
+	switch r.Frequency {
+	case Daily:
+		return start.AddDate(0, 0, step)
+	case Weekly:
+		return start.AddDate(0, 0, 7*step)
+	case Monthly:
+		return start.AddDate(0, step, 0)
+	}
+	return start.AddDate(step, 0, 0)
+}
+
+// occurrencesBefore estimates the last occurrence starting before t, the
+// estimate may be one too high
+func (r *Recurrence) occurrencesBefore(start time.Time, t time.Time) int {
+	t = t.In(start.Location())
+	var units int
+	switch r.Frequency {
+	case Daily, Weekly:
+		units = int(math.Round(truncateDay(t).Sub(truncateDay(start)).Hours() / 24))
+		if r.Frequency == Weekly {
+			units /= 7
+		}
+	case Monthly:
+		units = (t.Year()-start.Year())*12 + int(t.Month()-start.Month())
+	default:
+		units = t.Year() - start.Year()
+	}
+	n := units/r.interval() + 1
+	if r.Count > 0 && n > r.Count-1 {
+		n = r.Count - 1
+	}
+	return n
+}
+
+func (r *Recurrence) includes(start time.Time, n int) bool {
+	if r.Count > 0 && n >= r.Count {
+		return false
+	}
+	return r.Until.IsZero() || !start.After(r.Until)
+}
+
+func (r *Recurrence) interval() int {
+	if r.Interval < 1 {
+		return 1
+	}
+	return r.Interval
+}
+
+func icalLocation(params string) *time.Location {
+	location := time.Local
+	for _, param := range strings.Split(params, ";") {
+		if tzid, found := strings.CutPrefix(param, "TZID="); found {
+			if loc, err := time.LoadLocation(tzid); err == nil {
+				location = loc
+			}
+		}
+	}
+	return location
+}
+
+func parseICalTime(value string, params string) (time.Time, error) {
+	location := icalLocation(params)
+	switch {
+	case len(value) == 8:
+		return time.ParseInLocation("20060102", value, location)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	}
+	return time.ParseInLocation("20060102T150405", value, location)
+}
+
+// parseICalTimes parses the comma separated dates of EXDATE and RDATE
+func parseICalTimes(value string, params string) ([]time.Time, error) {
+	times := []time.Time{}
+	for _, v := range strings.Split(value, ",") {
+		if strings.Contains(v, "/") {
+			return nil, fmt.Errorf("periods are not supported: %s", v)
+		}
+		t, err := parseICalTime(v, params)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// parseRecurrence parses the FREQ, INTERVAL, COUNT and UNTIL parts of an
+// RRULE. Rules with further parts like BYDAY select other days than the
+// start and are refused instead of being applied wrong.
+func parseRecurrence(value string) (*Recurrence, error) {
+	r := &Recurrence{}
+	for _, part := range strings.Split(value, ";") {
+		name, v, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Frequency = map[string]Frequency{"DAILY": Daily, "WEEKLY": Weekly, "MONTHLY": Monthly, "YEARLY": Yearly}[strings.ToUpper(v)]
+			if r.Frequency == 0 {
+				return nil, fmt.Errorf("%s: %w", value, ErrUnsupportedRecurrence)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(v)
+		case "COUNT":
+			r.Count, err = strconv.Atoi(v)
+		case "UNTIL":
+			r.Until, err = parseICalTime(v, "")
+			if err == nil && len(v) == 8 {
+				// a date includes the occurrence starting on that day
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "WKST":
+			// only used with BYDAY
+		default:
+			return nil, fmt.Errorf("%s: %w", value, ErrUnsupportedRecurrence)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", value, err)
+		}
+	}
+	if r.Frequency == 0 {
+		return nil, fmt.Errorf("%s without FREQ: %w", value, ErrUnsupportedRecurrence)
+	}
+	return r, nil
+}
+
+// parseICalDuration parses durations like P1D, P2W or PT1H30M
+func parseICalDuration(value string) (time.Duration, error) {
+	rest, found := strings.CutPrefix(strings.TrimPrefix(value, "+"), "P")
+	if !found {
+		return 0, fmt.Errorf("invalid duration %s", value)
+	}
+	var duration time.Duration
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour, 'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+	number := ""
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+		switch {
+		case c == 'T':
+		case c >= '0' && c <= '9':
+			number += string(c)
+		default:
+			n, err := strconv.Atoi(number)
+			unit, isKnown := units[c]
+			if err != nil || !isKnown {
+				return 0, fmt.Errorf("invalid duration %s", value)
+			}
+			duration += time.Duration(n) * unit
+			number = ""
+		}
+	}
+	return duration, nil
+}
+
+// ParseICal reads the events of an iCalendar file as calendar entries.
+// Events without end cover the day of their start. Recurring events are
+// repeated by FREQ with INTERVAL, COUNT and UNTIL, EXDATE and RDATE are
+// applied. Rules selecting further days, like BYDAY, return
+// ErrUnsupportedRecurrence, so no event is silently imported only once.
+func ParseICal(r io.Reader) (*Calendar, error) {
+	// unfold continuation lines first
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	calendar := &Calendar{}
+	var entry *CalendarEntry
+	var duration time.Duration
+	var additional []time.Time
+	// nested is the depth of components within the event, like VALARM,
+	// whose properties do not belong to the event
+	nested := 0
+	for _, line := range lines {
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		name, params, _ := strings.Cut(name, ";")
+		name = strings.ToUpper(name)
+		if entry != nil && nested > 0 {
+			switch name {
+			case "BEGIN":
+				nested++
+			case "END":
+				nested--
+			}
+			continue
+		}
+		var err error
+		switch name {
+		case "BEGIN":
+			if value == "VEVENT" {
+				entry, duration, additional = &CalendarEntry{}, 0, nil
+			} else if entry != nil {
+				nested++
+			}
+		case "END":
+			if value != "VEVENT" || entry == nil {
+				continue
+			}
+			if entry.Start.IsZero() {
+				return nil, errors.New("event without start: " + entry.Summary)
+			}
+			switch {
+			case !entry.End.IsZero():
+			case duration > 0:
+				entry.End = entry.Start.Add(duration)
+			default:
+				entry.End = truncateDay(entry.Start).AddDate(0, 0, 1)
+			}
+			calendar.Entries = append(calendar.Entries, *entry)
+			for _, start := range additional {
+				calendar.Entries = append(calendar.Entries, CalendarEntry{Start: start, End: start.Add(entry.End.Sub(entry.Start)), Summary: entry.Summary})
+			}
+			entry = nil
+		case "DTSTART", "DTEND":
+			if entry == nil {
+				continue
+			}
+			var t time.Time
+			if t, err = parseICalTime(value, params); err != nil {
+				break
+			}
+			if name == "DTSTART" {
+				entry.Start = t
+			} else {
+				entry.End = t
+			}
+		case "DURATION":
+			if entry != nil {
+				duration, err = parseICalDuration(value)
+			}
+		case "RRULE":
+			if entry != nil {
+				entry.Recurrence, err = parseRecurrence(value)
+			}
+		case "EXDATE":
+			if entry != nil {
+				var times []time.Time
+				times, err = parseICalTimes(value, params)
+				entry.Exceptions = append(entry.Exceptions, times...)
+			}
+		case "RDATE":
+			if entry != nil {
+				var times []time.Time
+				times, err = parseICalTimes(value, params)
+				additional = append(additional, times...)
+			}
+		case "SUMMARY":
+			if entry != nil {
+				entry.Summary = value
+			}
+		}
+		if err != nil {
+			if entry != nil && entry.Summary != "" {
+				return nil, fmt.Errorf("event %s: %w", entry.Summary, err)
+			}
+			return nil, err
+		}
+	}
+	return calendar, nil
+}
@@ -0,0 +1,141 @@
+package wattpilot_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func ical(events ...string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(events, "") + "END:VCALENDAR\r\n"
+}
+
+func TestParseICal(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Vienna"); err != nil {
+		t.Skip(err)
+	}
+	day := func(year int, month time.Month, d int, hour int) time.Time {
+		return time.Date(year, month, d, hour, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name    string
+		data    string
+		summary string
+		inside  []time.Time
+		outside []time.Time
+	}{
+		{
+			name:    "date",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261224\r\nDTEND;VALUE=DATE:20261227\r\nSUMMARY:Christmas\r\nEND:VEVENT\r\n",
+			summary: "Christmas",
+			inside:  []time.Time{day(2026, 12, 24, 0), day(2026, 12, 26, 23)},
+			outside: []time.Time{day(2026, 12, 23, 23), day(2026, 12, 27, 0)},
+		},
+		{
+			name:    "date without end",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261026\r\nSUMMARY:National holiday\r\nEND:VEVENT\r\n",
+			summary: "National holiday",
+			inside:  []time.Time{day(2026, 10, 26, 12)},
+			outside: []time.Time{day(2026, 10, 27, 0)},
+		},
+		{
+			name:    "date-time in UTC",
+			data:    "BEGIN:VEVENT\r\nDTSTART:20261017T080000Z\r\nDTEND:20261017T120000Z\r\nSUMMARY:Service\r\nEND:VEVENT\r\n",
+			summary: "Service",
+			inside:  []time.Time{time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)},
+			outside: []time.Time{time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "date-time with TZID",
+			data:    "BEGIN:VEVENT\r\nDTSTART;TZID=Europe/Vienna:20261017T080000\r\nDTEND;TZID=Europe/Vienna:20261017T120000\r\nSUMMARY:Service\r\nEND:VEVENT\r\n",
+			summary: "Service",
+			inside:  []time.Time{time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC)},
+			outside: []time.Time{time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "duration",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260803\r\nDURATION:P2W\r\nSUMMARY:Vacation\r\nEND:VEVENT\r\n",
+			summary: "Vacation",
+			inside:  []time.Time{day(2026, 8, 16, 23)},
+			outside: []time.Time{day(2026, 8, 17, 0)},
+		},
+		{
+			name:    "folded lines",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:2026\r\n 1208\r\nSUMMARY:Immaculate\r\n  Conception\r\nEND:VEVENT\r\n",
+			summary: "Immaculate Conception",
+			inside:  []time.Time{day(2026, 12, 8, 10)},
+		},
+		{
+			name:    "nested alarm",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261101\r\nSUMMARY:All Saints\r\nBEGIN:VALARM\r\nACTION:DISPLAY\r\nSUMMARY:Reminder\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nEND:VEVENT\r\n",
+			summary: "All Saints",
+			inside:  []time.Time{day(2026, 11, 1, 10)},
+			outside: []time.Time{day(2026, 11, 2, 0)},
+		},
+		{
+			name:    "yearly",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20200501\r\nRRULE:FREQ=YEARLY\r\nSUMMARY:Labour Day\r\nEND:VEVENT\r\n",
+			summary: "Labour Day",
+			inside:  []time.Time{day(2020, 5, 1, 0), day(2026, 5, 1, 12), day(2040, 5, 1, 23)},
+			outside: []time.Time{day(2019, 5, 1, 12), day(2026, 5, 2, 0), day(2026, 4, 30, 23)},
+		},
+		{
+			name:    "weekly with count and exception",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261005\r\nRRULE:FREQ=WEEKLY;COUNT=3\r\nEXDATE;VALUE=DATE:20261012\r\nSUMMARY:Mondays\r\nEND:VEVENT\r\n",
+			summary: "Mondays",
+			inside:  []time.Time{day(2026, 10, 5, 12), day(2026, 10, 19, 12)},
+			outside: []time.Time{day(2026, 10, 12, 12), day(2026, 10, 26, 12), day(2026, 10, 6, 12)},
+		},
+		{
+			name:    "every other month until",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260115\r\nRRULE:FREQ=MONTHLY;INTERVAL=2;UNTIL=20260515\r\nSUMMARY:Meter reading\r\nEND:VEVENT\r\n",
+			summary: "Meter reading",
+			inside:  []time.Time{day(2026, 3, 15, 12), day(2026, 5, 15, 12)},
+			outside: []time.Time{day(2026, 2, 15, 12), day(2026, 7, 15, 12)},
+		},
+		{
+			name:    "additional dates",
+			data:    "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261231\r\nRDATE;VALUE=DATE:20270106,20270101\r\nSUMMARY:Days off\r\nEND:VEVENT\r\n",
+			summary: "Days off",
+			inside:  []time.Time{day(2026, 12, 31, 12), day(2027, 1, 1, 12), day(2027, 1, 6, 12)},
+			outside: []time.Time{day(2027, 1, 2, 12)},
+		},
+	}
+	for _, test := range tests {
+		calendar, err := wattpilot.ParseICal(strings.NewReader(ical(test.data)))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(calendar.Entries) == 0 || calendar.Entries[0].Summary != test.summary {
+			t.Errorf("%s: got entries %+v, want summary %s", test.name, calendar.Entries, test.summary)
+			continue
+		}
+		for _, at := range test.inside {
+			if !calendar.Contains(at) {
+				t.Errorf("%s: %v not in the calendar", test.name, at)
+			}
+		}
+		for _, at := range test.outside {
+			if calendar.Contains(at) {
+				t.Errorf("%s: %v in the calendar", test.name, at)
+			}
+		}
+	}
+}
+
+func TestParseICalRefusesUnsupportedRecurrence(t *testing.T) {
+	for _, rule := range []string{"FREQ=MONTHLY;BYDAY=1MO", "FREQ=HOURLY", "INTERVAL=2"} {
+		data := ical("BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261005\r\nRRULE:" + rule + "\r\nSUMMARY:Rule\r\nEND:VEVENT\r\n")
+		if _, err := wattpilot.ParseICal(strings.NewReader(data)); !errors.Is(err, wattpilot.ErrUnsupportedRecurrence) {
+			t.Errorf("%s: expected ErrUnsupportedRecurrence, got %v", rule, err)
+		}
+	}
+	data := ical("BEGIN:VEVENT\r\nSUMMARY:No start\r\nEND:VEVENT\r\n")
+	if _, err := wattpilot.ParseICal(strings.NewReader(data)); err == nil {
+		t.Error("expected an error for an event without start")
+	}
+}
@@ -0,0 +1,52 @@
+package wattpilot
+
+import "sync"
+
+// Correction maps a reported measurement to value*Factor + Offset, the zero
+// value leaves the measurement untouched
+type Correction struct {
+	Factor float64
+	Offset float64
+}
+
+func (c Correction) apply(value float64) float64 {
+	if c.Factor == 0 {
+		return value + c.Offset
+	}
+	return value*c.Factor + c.Offset
+}
+
+// Calibration holds per-phase corrections for users whose reference meter
+// disagrees with the measurements of the charger. Only GetVoltages and
+// GetCurrents are calibrated. The status, the power, the virtual
+// properties and the anomaly detection keep the values of the charger,
+// since a corrected power can not be derived from the per-phase values.
+type Calibration struct {
+	Voltage [3]Correction
+	Current [3]Correction
+}
+
+type calibrationStore struct {
+	mu  sync.RWMutex
+	cal Calibration
+}
+
+// WithCalibration applies the corrections to GetVoltages and GetCurrents,
+// all other readings stay uncalibrated
+func WithCalibration(cal Calibration) Option {
+	return func(w *Wattpilot) {
+		w.SetCalibration(cal)
+	}
+}
+
+func (w *Wattpilot) SetCalibration(cal Calibration) {
+	w._calibration.mu.Lock()
+	defer w._calibration.mu.Unlock()
+	w._calibration.cal = cal
+}
+
+func (w *Wattpilot) GetCalibration() Calibration {
+	w._calibration.mu.RLock()
+	defer w._calibration.mu.RUnlock()
+	return w._calibration.cal
+}
@@ -0,0 +1,148 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CARBON_REFRESH is the default interval of fetching the forecast
+const CARBON_REFRESH = time.Hour
+
+// CarbonIntensity is the forecast carbon intensity of the grid electricity
+// in gCO2eq/kWh from Start until End
+type CarbonIntensity struct {
+	Start     time.Time
+	End       time.Time
+	Intensity float64
+}
+
+// CarbonIntensitySource provides the forecast of the grid carbon intensity,
+// e.g. from an electricityMaps style API
+type CarbonIntensitySource interface {
+	Forecast(ctx context.Context) ([]CarbonIntensity, error)
+}
+
+// CarbonController charges in the hours of the lowest carbon intensity
+// before the departure. It picks the cleanest periods of the forecast
+// until they add up to the charging time of the remaining energy, and
+// charges from the grid regardless once the deadline could not be met
+// otherwise. Without a forecast it only charges when the deadline requires
+// it.
+type CarbonController struct {
+	Source    CarbonIntensitySource
+	Departure Departure
+	// Below charges whenever the intensity is below it, also once the
+	// energy of the departure is charged, zero disables it
+	Below   float64
+	Check   time.Duration
+	Refresh time.Duration
+
+	mu       sync.Mutex
+	forecast []CarbonIntensity
+	fetched  time.Time
+}
+
+func NewCarbonController(source CarbonIntensitySource, departure Departure) *CarbonController {
+	return &CarbonController{Source: source, Departure: departure, Check: time.Minute, Refresh: CARBON_REFRESH}
+}
+
+func (c *CarbonController) Name() string {
+	return "carbon"
+}
+
+func (c *CarbonController) Interval() time.Duration {
+	return c.Check
+}
+
+func (c *CarbonController) Evaluate(ctx context.Context, snapshot Snapshot) []Action {
+	forecast := c.getForecast(ctx, snapshot.Time)
+	current, known := intensityAt(forecast, snapshot.Time)
+
+	switch {
+	case c.Departure.MustCharge(snapshot):
+		return c.Departure.chargeActions(snapshot, fmt.Sprintf("charging needed by %s", c.Departure.Deadline(snapshot.Time).Format("15:04")))
+	case known && c.Below > 0 && current < c.Below:
+		return c.Departure.chargeActions(snapshot, fmt.Sprintf("carbon intensity %.0f g/kWh below %.0f", current, c.Below))
+	case known && c.Departure.Remaining(snapshot) > 0 && c.isCleanest(forecast, snapshot):
+		return c.Departure.chargeActions(snapshot, fmt.Sprintf("carbon intensity %.0f g/kWh among the lowest before departure", current))
+	}
+	if state, err := snapshot.Float("frc"); err == nil && ForceState(state) == ForceOff {
+		return []Action{}
+	}
+	reason := "waiting for lower carbon intensity"
+	if !known {
+		reason = "no carbon intensity forecast"
+	}
+	return []Action{{Key: "frc", Value: int(ForceOff), Reason: reason}}
+}
+
+// getForecast returns the forecast, fetching it again after Refresh. A
+// failed fetch keeps the previous forecast and is retried with the next
+// evaluation.
+func (c *CarbonController) getForecast(ctx context.Context, now time.Time) []CarbonIntensity {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	refresh := c.Refresh
+	if refresh <= 0 {
+		refresh = CARBON_REFRESH
+	}
+	if c.forecast != nil && now.Sub(c.fetched) < refresh {
+		return c.forecast
+	}
+	forecast, err := c.Source.Forecast(ctx)
+	if err != nil {
+		return c.forecast
+	}
+	c.forecast = forecast
+	c.fetched = now
+	return forecast
+}
+
+// isCleanest reports whether the period of the snapshot time is among the
+// periods of the lowest intensity adding up to the charging time
+func (c *CarbonController) isCleanest(forecast []CarbonIntensity, snapshot Snapshot) bool {
+	now := snapshot.Time
+	deadline := c.Departure.Deadline(now)
+	periods := []CarbonIntensity{}
+	for _, period := range forecast {
+		if !period.End.After(now) || !period.Start.Before(deadline) {
+			continue
+		}
+		if period.Start.Before(now) {
+			period.Start = now
+		}
+		if period.End.After(deadline) {
+			period.End = deadline
+		}
+		periods = append(periods, period)
+	}
+	sort.SliceStable(periods, func(i, j int) bool {
+		return periods[i].Intensity < periods[j].Intensity
+	})
+
+	needed := c.Departure.ChargingTime(snapshot)
+	for _, period := range periods {
+		if needed <= 0 {
+			break
+		}
+		if !period.Start.After(now) {
+			return true
+		}
+		needed -= period.End.Sub(period.Start)
+	}
+	// the forecast does not cover the charging time, charge now
+	return needed > 0
+}
+
+func intensityAt(forecast []CarbonIntensity, t time.Time) (float64, bool) {
+	for _, period := range forecast {
+		if !t.Before(period.Start) && t.Before(period.End) {
+			return period.Intensity, true
+		}
+	}
+	return 0, false
+}
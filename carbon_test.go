@@ -0,0 +1,87 @@
+package wattpilot_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+type staticForecast struct {
+	periods []wattpilot.CarbonIntensity
+	err     error
+}
+
+func (f *staticForecast) Forecast(ctx context.Context) ([]wattpilot.CarbonIntensity, error) {
+	return f.periods, f.err
+}
+
+func TestCarbonController(t *testing.T) {
+	evening := time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC)
+	intensities := []float64{300, 280, 250, 200, 100, 120, 220, 260, 300}
+	source := &staticForecast{}
+	for i, intensity := range intensities {
+		start := evening.Add(time.Duration(i) * time.Hour)
+		source.periods = append(source.periods, wattpilot.CarbonIntensity{Start: start, End: start.Add(time.Hour), Intensity: intensity})
+	}
+	// 22 kWh with 11 kW take two hours, the cleanest are 02:00 to 04:00
+	departure := wattpilot.Departure{At: 7 * time.Hour, Energy: 22000, Current: 16, Power: 11000}
+	wait := []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOff), Reason: "waiting for lower carbon intensity"}}
+	charge := func(reason string) []wattpilot.Action {
+		return []wattpilot.Action{
+			{Key: "amp", Value: 16.0, Reason: reason},
+			{Key: "frc", Value: int(wattpilot.ForceOn), Reason: reason},
+		}
+	}
+
+	tests := []struct {
+		name string
+		at   time.Duration
+		wh   float64
+		want []wattpilot.Action
+	}{
+		{"dirty evening", 0, 0, wait},
+		{"cleanest hour", 4*time.Hour + 30*time.Minute, 0, charge("carbon intensity 100 g/kWh among the lowest before departure")},
+		{"second cleanest hour", 5*time.Hour + 30*time.Minute, 16500, charge("carbon intensity 120 g/kWh among the lowest before departure")},
+		{"energy charged", 6 * time.Hour, 22000, wait},
+		{"cleanest of the remaining hours", 7 * time.Hour, 5000, charge("carbon intensity 260 g/kWh among the lowest before departure")},
+		{"deadline", 8*time.Hour + 30*time.Minute, 5000, charge("charging needed by 07:00")},
+	}
+	for _, test := range tests {
+		c := wattpilot.NewCarbonController(source, departure)
+		status := map[string]interface{}{"car": 3.0, "wh": test.wh, "amp": 6.0, "frc": 0.0}
+		actions := c.Evaluate(context.Background(), wattpilot.Snapshot{Time: evening.Add(test.at), Status: status})
+		if !reflect.DeepEqual(actions, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, actions, test.want)
+		}
+	}
+
+	// below the threshold it charges beyond the energy of the departure
+	c := wattpilot.NewCarbonController(source, departure)
+	c.Below = 150
+	status := map[string]interface{}{"car": 4.0, "wh": 22000.0, "amp": 16.0, "frc": float64(wattpilot.ForceOn)}
+	if actions := c.Evaluate(context.Background(), wattpilot.Snapshot{Time: evening.Add(4 * time.Hour), Status: status}); len(actions) != 0 {
+		t.Fatal("expected to keep charging below the threshold, got ", actions)
+	}
+}
+
+func TestCarbonControllerWithoutForecast(t *testing.T) {
+	source := &staticForecast{err: errors.New("service unavailable")}
+	c := wattpilot.NewCarbonController(source, wattpilot.Departure{At: 7 * time.Hour, Energy: 11000, Current: 16, Power: 11000})
+	evening := time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC)
+	status := map[string]interface{}{"car": 3.0, "wh": 0.0, "amp": 16.0, "frc": 0.0}
+
+	actions := c.Evaluate(context.Background(), wattpilot.Snapshot{Time: evening, Status: status})
+	want := []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOff), Reason: "no carbon intensity forecast"}}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("got %v, want %v", actions, want)
+	}
+	actions = c.Evaluate(context.Background(), wattpilot.Snapshot{Time: evening.Add(8 * time.Hour), Status: status})
+	want = []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOn), Reason: "charging needed by 07:00"}}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("got %v, want %v", actions, want)
+	}
+}
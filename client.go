@@ -0,0 +1,39 @@
+package wattpilot
+
+import "os/signal"
+
+// Client is the public surface of a charger connection, implemented by
+// *Wattpilot. Applications can depend on it to replace the charger by a
+// mock in their own tests.
+type Client interface {
+	Observer
+	SetProperty(name string, value interface{}) error
+	Subscribe(prop string) (<-chan interface{}, func())
+}
+
+var _ Client = (*Wattpilot)(nil)
+
+// Subscribe returns the updates of a property and a function ending the
+// subscription, which closes the channel
+func (w *Wattpilot) Subscribe(prop string) (<-chan interface{}, func()) {
+	ch := w._notifications.Subscribe(prop)
+	return ch, func() {
+		w._notifications.Unsubscribe(prop, ch)
+	}
+}
+
+// Close disconnects and stops the background processing, the instance can
+// not be used afterwards. The channels of GetNotifications, GetEvents and
+// Subscribe are closed.
+func (w *Wattpilot) Close() error {
+	w.logEntry().Info("Closing...")
+	w._isConnected.Store(false)
+	signal.Stop(w._interrupt)
+	w._stop()
+	<-w._loopDone
+	w._background.Wait()
+	w._notifications.Close()
+	w._hashes.clear()
+	removeRedactHook(w._log, w)
+	return nil
+}
@@ -0,0 +1,47 @@
+package wattpilot
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestCloseEndsSubscriptionsAndGoroutines(t *testing.T) {
+	// the first signal.Notify starts the signal loop of the runtime for good
+	New("localhost", "").Close()
+	before := runtime.NumGoroutine()
+	w := New("localhost", "")
+	updates, _ := w.Subscribe("car")
+	channels := map[string]<-chan interface{}{
+		"notifications": w.GetNotifications("amp"),
+		"events":        w.GetEvents(EventCarConnected),
+		"subscription":  updates,
+	}
+	w.OnCarConnected(func(CarSession) {})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ch := range channels {
+		select {
+		case _, ok := <-ch:
+			if ok {
+				t.Errorf("%s: received a value after Close", name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: channel not closed by Close", name)
+		}
+	}
+	if _, ok := <-w.GetNotifications("amp"); ok {
+		t.Error("subscription after Close is open")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines outlive Close, %d before New:\n%s", runtime.NumGoroutine(), before, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,182 @@
+package wattpilot_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+const (
+	testSerial   = "12345678"
+	testPassword = "secret"
+	testTimeout  = 5 * time.Second
+)
+
+// accept waits for the next client and authenticates it like a charger
+func accept(t *testing.T, server *wattpilottest.Server) *wattpilottest.Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	conn, err := server.Accept(ctx, testPassword)
+	if err != nil {
+		t.Fatal("client did not connect: ", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	steps := []map[string]interface{}{
+		{"type": "hello", "serial": testSerial, "hostname": "Wattpilot_" + testSerial, "manufacturer": "fronius", "devicetype": "wattpilot", "version": "38.5", "protocol": 2, "secured": false},
+		// the client refuses tokens it has seen before
+		{"type": "authRequired", "token1": token(t), "token2": token(t)},
+	}
+	for _, step := range steps {
+		if err := conn.Send(step); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if message, err := conn.Receive(); err != nil || message["type"] != "auth" {
+		t.Fatal("expected auth, got ", message, err)
+	}
+	if err := conn.Send(map[string]interface{}{"type": "authSuccess"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": map[string]interface{}{"car": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func token(t *testing.T) string {
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(token)
+}
+
+// connect creates a client on the fake clock and waits until it is
+// initialized
+func connect(t *testing.T, clock *wattpilottest.FakeClock) (*wattpilot.Wattpilot, *wattpilottest.Server, *wattpilottest.Conn) {
+	t.Helper()
+	server, err := wattpilottest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	client := wattpilot.New(server.Addr(), testPassword, wattpilot.WithClock(clock))
+	t.Cleanup(func() { client.Close() })
+
+	connected := make(chan error, 1)
+	go func() {
+		connected <- client.Connect()
+	}()
+	conn := accept(t, server)
+	select {
+	case err := <-connected:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("client did not initialize")
+	}
+	return client, server, conn
+}
+
+// receive returns the next message of the client which is not an answer of
+// the handshake
+func receive(t *testing.T, conn *wattpilottest.Conn) map[string]interface{} {
+	t.Helper()
+	received := make(chan map[string]interface{}, 1)
+	go func() {
+		message, _ := conn.Receive()
+		received <- message
+	}()
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(testTimeout):
+		t.Fatal("no message from the client")
+	}
+	return nil
+}
+
+func TestProcessLoopRequestsStatus(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	_, _, conn := connect(t, clock)
+
+	// process loop and watchdog
+	clock.BlockUntil(2)
+	clock.Advance(time.Second * wattpilot.CONTEXT_TIMEOUT)
+	// the request is delayed after the tick
+	clock.BlockUntil(3)
+	clock.Advance(100 * time.Millisecond)
+
+	message := receive(t, conn)
+	if message["type"] != "requestFullStatus" {
+		t.Fatal("expected requestFullStatus, got ", message)
+	}
+}
+
+func TestReconnectAfterConnectionLoss(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, server, conn := connect(t, clock)
+
+	conn.Close()
+	deadline := time.Now().Add(testTimeout)
+	for client.WatchdogStatus().Receiving {
+		if time.Now().After(deadline) {
+			t.Fatal("receive handler did not end")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.BlockUntil(2)
+	clock.Advance(time.Second * wattpilot.WATCHDOG_INTERVAL)
+	// the reconnect waits before dialing
+	clock.BlockUntil(3)
+	clock.Advance(time.Second * wattpilot.RECONNECT_TIMEOUT)
+
+	accept(t, server)
+	deadline = time.Now().Add(testTimeout)
+	for !client.IsInitialized() {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestConcurrentDisconnects closes the connection from several goroutines
+// while reconnects are requested, one reconnect replaces the connection
+func TestConcurrentDisconnects(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, server, _ := connect(t, clock)
+
+	reconnected := make(chan (<-chan struct{}), 8)
+	for i := 0; i < 8; i++ {
+		go client.DisconnectForTest()
+		go func() {
+			reconnected <- client.ReconnectForTest()
+		}()
+	}
+
+	accept(t, server)
+	for i := 0; i < 8; i++ {
+		select {
+		case <-<-reconnected:
+		case <-time.After(testTimeout):
+			t.Fatal("reconnect did not finish")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := server.Accept(ctx, testPassword); err == nil {
+		t.Fatal("more than one reconnect")
+	}
+}
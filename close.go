@@ -0,0 +1,68 @@
+package wattpilot
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+const (
+	CLOSE_TIMEOUT = 2 // seconds
+	CLOSE_REASON  = "client disconnect"
+)
+
+const EventDisconnected EventType = "disconnected"
+
+// closeConnection performs the websocket close handshake, it sends a close
+// frame and waits for the peer's answer before closing the TCP connection.
+// It returns after the receive handler of the connection ended, so the
+// handlers of the old and a new connection never overlap.
+func (w *Wattpilot) closeConnection(c *connection) {
+	atomic.StoreInt32(&w._closing, 1)
+	defer atomic.StoreInt32(&w._closing, 0)
+
+	body := ws.NewCloseFrameBody(ws.StatusNormalClosure, CLOSE_REASON)
+	if err := wsutil.WriteClientMessage(c.conn, ws.OpClose, body); err != nil {
+		w.logEntry().Trace("Could not send close frame: ", err)
+	} else {
+		// the receive handler answers and ends on the close frame of the peer
+		select {
+		case <-c.receiveDone:
+		case <-time.After(time.Second * CLOSE_TIMEOUT):
+			w.logEntry().Trace("No close frame received from peer")
+		}
+	}
+	if err := c.conn.Close(); err != nil {
+		w.logEntry().Trace("Error on closing connection: ", err)
+	}
+	// closing ends the pending read of the receive handler, it only stays
+	// blocked when a handler of the connection itself is waiting for the
+	// disconnect
+	select {
+	case <-c.receiveDone:
+	case <-time.After(time.Second * CLOSE_TIMEOUT):
+		w.logEntry().Warn("Receive handler did not end after closing the connection")
+	}
+	w.emitEvent(EventDisconnected, map[string]interface{}{
+		"initiator": "client",
+		"code":      int(ws.StatusNormalClosure),
+		"reason":    CLOSE_REASON,
+	})
+}
+
+// onPeerClosed publishes the close reason sent by the charger
+func (w *Wattpilot) onPeerClosed(err error) {
+	var closed wsutil.ClosedError
+	if !errors.As(err, &closed) || atomic.LoadInt32(&w._closing) == 1 {
+		return
+	}
+	w.logEntry().Info("Connection closed by charger: ", closed.Code, " ", closed.Reason)
+	w.emitEvent(EventDisconnected, map[string]interface{}{
+		"initiator": "peer",
+		"code":      int(closed.Code),
+		"reason":    closed.Reason,
+	})
+}
@@ -0,0 +1,188 @@
+package wattpilot
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	COMPLETION_WINDOW = 10 // minutes of power samples used for the trend
+	// TAPER_RATIO is the share of the session peak power below which a
+	// falling charging power is considered as the car finishing
+	TAPER_RATIO = 0.2
+	// TAPER_MIN_POWER ignores sessions which never charged notably, in W
+	TAPER_MIN_POWER = 1000
+
+	carStateComplete = 4
+
+	EventChargingComplete EventType = "chargingComplete"
+)
+
+var (
+	ErrNoEnergyTarget = errors.New("no energy limit configured")
+	ErrNotCharging    = errors.New("car is not charging")
+)
+
+type powerSample struct {
+	time  time.Time
+	power float64
+}
+
+type completionTracker struct {
+	mu       sync.Mutex
+	samples  []powerSample
+	peak     float64
+	complete bool
+}
+
+// trackCompletion is called with the read mutex held for every status update
+func (w *Wattpilot) trackCompletion(updates map[string]interface{}) {
+	t := w._completion
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, err := toFloat(w._status["car"])
+	if err != nil {
+		return
+	}
+	if int(state) == carStateIdle {
+		t.samples = t.samples[:0]
+		t.peak = 0
+		t.complete = false
+		return
+	}
+
+	if _, ok := updates["nrg"]; ok {
+		lookup := func(key string) (interface{}, bool) {
+			v, ok := w._status[key]
+			return v, ok
+		}
+		if power, err := nrgValue(lookup, 11); err == nil {
+			now := w._clock.Now()
+			t.samples = append(t.samples, powerSample{time: now, power: power})
+			cutoff := now.Add(-time.Minute * COMPLETION_WINDOW)
+			for len(t.samples) > 0 && t.samples[0].time.Before(cutoff) {
+				t.samples = t.samples[1:]
+			}
+			t.peak = math.Max(t.peak, power)
+		}
+	}
+
+	if t.complete {
+		return
+	}
+	reason := ""
+	if int(state) == carStateComplete {
+		reason = "charger"
+	} else if t.isTapered() {
+		reason = "taper"
+	}
+	if reason == "" {
+		return
+	}
+	t.complete = true
+	w.logEntry().Info("Charging complete, detected by ", reason)
+	w.emitEvent(EventChargingComplete, map[string]interface{}{
+		"reason": reason,
+		"peak":   t.peak,
+	})
+}
+
+// isTapered reports a charging power which has been falling over the whole
+// window and dropped far below the peak of the session
+func (t *completionTracker) isTapered() bool {
+	if t.peak < TAPER_MIN_POWER || len(t.samples) < 2 {
+		return false
+	}
+	last := t.samples[len(t.samples)-1].power
+	if last > t.peak*TAPER_RATIO {
+		return false
+	}
+	_, slope := t.trend()
+	return slope < 0
+}
+
+// trend returns the power at the latest sample and its change in W per
+// second, using a least squares fit over the sample window
+func (t *completionTracker) trend() (float64, float64) {
+	n := float64(len(t.samples))
+	if n == 0 {
+		return 0, 0
+	}
+	last := t.samples[len(t.samples)-1]
+	var sx, sy, sxx, sxy float64
+	for _, s := range t.samples {
+		x := s.time.Sub(last.time).Seconds()
+		sx += x
+		sy += s.power
+		sxx += x * x
+		sxy += x * s.power
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return last.power, 0
+	}
+	slope := (n*sxy - sx*sy) / d
+	return (sy - slope*sx) / n, slope
+}
+
+// IsChargingComplete reports whether the current session finished, either
+// signalled by the charger or detected from the tapering charging power
+func (w *Wattpilot) IsChargingComplete() bool {
+	w._completion.mu.Lock()
+	defer w._completion.mu.Unlock()
+	return w._completion.complete
+}
+
+// EstimatedCompletion extrapolates the recent power trend until the energy
+// limit (dwo) of the session is reached. If the power is expected to fade
+// out before, the time the power reaches zero is returned.
+func (w *Wattpilot) EstimatedCompletion() (time.Time, error) {
+	v, err := w.GetProperty("dwo")
+	if err != nil {
+		return time.Time{}, err
+	}
+	if v == nil {
+		return time.Time{}, ErrNoEnergyTarget
+	}
+	limit, err := toFloat(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if limit <= 0 {
+		return time.Time{}, ErrNoEnergyTarget
+	}
+	charged, err := w.getFloatProperty("wh")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	t := w._completion
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := w._clock.Now()
+	remaining := limit - charged
+	if t.complete || remaining <= 0 {
+		return now, nil
+	}
+	power, slope := t.trend()
+	if power <= 0 {
+		return time.Time{}, ErrNotCharging
+	}
+
+	// energy in Ws delivered after x seconds: power*x + slope*x²/2
+	energy := remaining * 3600
+	seconds := energy / power
+	if slope < 0 {
+		disc := power*power + 2*slope*energy
+		if disc < 0 {
+			seconds = -power / slope
+		} else {
+			seconds = (-power + math.Sqrt(disc)) / slope
+		}
+	}
+	return now.Add(time.Duration(seconds * float64(time.Second))), nil
+}
@@ -0,0 +1,93 @@
+package wattpilot
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+
+	"github.com/gobwas/httphead"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// WithCompression offers permessage-deflate in the websocket handshake to
+// reduce the bandwidth on constrained links. Without context takeover every
+// message is decompressed on its own.
+func WithCompression() Option {
+	return func(w *Wattpilot) {
+		w._compression = true
+	}
+}
+
+func (w *Wattpilot) dialExtensions() []httphead.Option {
+	if !w._compression {
+		return nil
+	}
+	params := wsflate.Parameters{
+		ServerNoContextTakeover: true,
+		ClientNoContextTakeover: true,
+	}
+	return []httphead.Option{params.Option()}
+}
+
+func isDeflateNegotiated(hs ws.Handshake) bool {
+	for _, ext := range hs.Extensions {
+		if bytes.Equal(ext.Name, wsflate.ExtensionNameBytes) {
+			return true
+		}
+	}
+	return false
+}
+
+// readMessage reads the next text or binary message from the charger and
+// decompresses it when permessage-deflate has been negotiated
+func (w *Wattpilot) readMessage(c *connection) ([]byte, error) {
+	conn := c.conn
+	wsState := ws.StateClientSide
+	if w._deflate {
+		wsState |= ws.StateExtended
+	}
+	controlHandler := wsutil.ControlFrameHandler(conn, wsState)
+	var state wsflate.MessageState
+	rd := wsutil.Reader{
+		Source:         conn,
+		State:          wsState,
+		CheckUTF8:      !w._deflate,
+		OnIntermediate: controlHandler,
+	}
+	if w._deflate {
+		rd.Extensions = []wsutil.RecvExtension{&state}
+	}
+	for {
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := controlHandler(hdr, &rd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if hdr.OpCode != ws.OpText && hdr.OpCode != ws.OpBinary {
+			if err := rd.Discard(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var src io.Reader = &rd
+		if state.IsCompressed() {
+			src = wsflate.NewReader(&rd, func(r io.Reader) wsflate.Decompressor {
+				return flate.NewReader(r)
+			})
+		}
+		// the buffer of the connection is reused, messages are only valid
+		// until the next read of its receive handler
+		c.readBuffer.Reset()
+		if _, err := c.readBuffer.ReadFrom(src); err != nil {
+			return nil, err
+		}
+		return c.readBuffer.Bytes(), nil
+	}
+}
@@ -0,0 +1,204 @@
+package wattpilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Snapshot is a consistent copy of the charger status, including the
+// virtual properties, handed to controllers
+type Snapshot struct {
+	Time time.Time
+	// Updated is the time of the last status message of the charger
+	Updated time.Time
+	Status  map[string]interface{}
+}
+
+// Get returns a value by key, alias or virtual property name
+func (s Snapshot) Get(name string) (interface{}, bool) {
+	if m, post := PostProcess[name]; post {
+		value, isKnown := s.Status[m.key]
+		if !isKnown {
+			return nil, false
+		}
+		value, err := m.f(value)
+		return value, err == nil
+	}
+	value, isKnown := s.Status[resolveKey(name)]
+	return value, isKnown
+}
+
+func (s Snapshot) Float(name string) (float64, error) {
+	value, isKnown := s.Get(name)
+	if !isKnown {
+		return 0, fmt.Errorf("could not find value of %s: %w", name, ErrPropertyNotFound)
+	}
+	return toFloat(value)
+}
+
+// Snapshot copies the current status
+func (w *Wattpilot) Snapshot() Snapshot {
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	status := make(map[string]interface{}, len(w._status)+len(w._virtuals))
+	for k, v := range w._status {
+		status[k] = v
+	}
+	for name, v := range w._virtuals {
+		if value, err := w.computeVirtual(v); err == nil {
+			status[name] = value
+		}
+	}
+	return Snapshot{Time: w._clock.Now(), Updated: w._lastStatusUpdate, Status: status}
+}
+
+// Action is a property write requested by a controller
+type Action struct {
+	Key    string
+	Value  interface{}
+	Reason string
+	// Preset is applied instead of writing Key
+	Preset string
+}
+
+// Controller implements a charging strategy. Evaluate is called every
+// Interval with the latest status and returns the writes to apply.
+type Controller interface {
+	Name() string
+	Evaluate(ctx context.Context, snapshot Snapshot) []Action
+	Interval() time.Duration
+}
+
+// Prioritizer can be implemented by controllers to win conflicting writes,
+// controllers without it have priority zero
+type Prioritizer interface {
+	Priority() int
+}
+
+func controllerPriority(c Controller) int {
+	if p, ok := c.(Prioritizer); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// RunControllers evaluates all controllers at their interval until the
+// context is cancelled. Each controller writes as source "controller:<name>"
+// with its priority, so conflicting writes are resolved by the arbitration.
+func (w *Wattpilot) RunControllers(ctx context.Context, controllers ...Controller) {
+	var wg sync.WaitGroup
+	for _, c := range controllers {
+		wg.Add(1)
+		go func(c Controller) {
+			defer wg.Done()
+			w.runController(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// controllerSwitch holds the controllers disabled by name, e.g. by presets
+type controllerSwitch struct {
+	mu       sync.Mutex
+	disabled map[string]bool
+}
+
+// EnableController pauses or resumes the evaluation of the controller with
+// the name, controllers are enabled unless disabled here
+func (w *Wattpilot) EnableController(name string, enabled bool) {
+	s := w._controllers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if enabled {
+		delete(s.disabled, name)
+		return
+	}
+	s.disabled[name] = true
+}
+
+func (w *Wattpilot) ControllerEnabled(name string) bool {
+	s := w._controllers
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return !s.disabled[name]
+}
+
+func (w *Wattpilot) runController(ctx context.Context, c Controller) {
+	session := w.NewSession("controller:"+c.Name(), false)
+	defer session.Close()
+
+	w.SetSourcePriority(session.GetName(), controllerPriority(c))
+	interval := c.Interval()
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	timer := w._clock.NewTimer(0)
+	defer timer.Stop()
+
+	safeMode := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(interval)
+			if !w.IsInitialized() || !w.ControllerEnabled(c.Name()) {
+				continue
+			}
+			snapshot := w.Snapshot()
+			err := w.checkController(c, snapshot)
+			var actions []Action
+			if err == nil {
+				actions, err = evaluate(ctx, c, snapshot)
+			}
+			if err != nil {
+				if !safeMode {
+					safeMode = true
+					w.enterSafeMode(session, c, err)
+				}
+				continue
+			}
+			if safeMode {
+				safeMode = false
+				w.leaveSafeMode(c)
+			}
+			for _, action := range actions {
+				action = w.capDerated(c, snapshot, action)
+				w.applyAction(session, c, action)
+			}
+		}
+	}
+}
+
+func (w *Wattpilot) applyAction(session *Session, c Controller, action Action) {
+	if action.Preset != "" {
+		w.logEntry().Info("Controller ", c.Name(), " activates preset ", action.Preset, ": ", action.Reason)
+		if err := w.activatePreset(action.Preset, action.Reason); err != nil {
+			w.logEntry().Warn("Could not apply preset ", action.Preset, ": ", err)
+		}
+		return
+	}
+	key := resolveKey(action.Key)
+	decision := Decision{
+		Source:   c.Name(),
+		Inputs:   map[string]interface{}{"priority": controllerPriority(c)},
+		Setpoint: action.Value,
+		Action:   "set " + key,
+		Reason:   action.Reason,
+	}
+	err := session.SetProperty(key, action.Value)
+	if errors.Is(err, ErrControlledByOtherSource) {
+		owner, _ := w.ControlOwner(key)
+		decision.Action = "reject " + key
+		decision.Reason = "controlled by " + owner
+	} else {
+		decision.Error = err
+	}
+	w.recordDecision(decision)
+}
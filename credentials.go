@@ -0,0 +1,219 @@
+package wattpilot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	CREDENTIALS_FILE = "credentials"
+	CREDENTIALS_KEY  = "credentials.key"
+	// CREDENTIALS_SERVICE is the service name of the keyring entries
+	CREDENTIALS_SERVICE = "wattpilot"
+)
+
+var ErrNoCredentials = errors.New("no credentials stored for host")
+
+// CredentialStore keeps charger passwords by host, so tools do not need
+// them in plaintext configuration or the shell history
+type CredentialStore interface {
+	Get(host string) (string, error)
+	Set(host string, password string) error
+	Delete(host string) error
+}
+
+// DefaultCredentialStore returns the keyring of the operating system, the
+// Secret Service on Linux, the Keychain on macOS and the Credential Manager
+// on Windows. Where it is not available, e.g. on a headless system without
+// a Secret Service, the FileCredentialStore in DefaultCredentialDir is used.
+func DefaultCredentialStore() (CredentialStore, error) {
+	if store := NewKeyringCredentialStore(); store.available() {
+		return store, nil
+	}
+	dir, err := DefaultCredentialDir()
+	if err != nil {
+		return nil, err
+	}
+	return NewFileCredentialStore(dir)
+}
+
+// KeyringCredentialStore keeps the passwords in the keyring of the
+// operating system, with the host as user of the CREDENTIALS_SERVICE
+type KeyringCredentialStore struct {
+	service string
+}
+
+func NewKeyringCredentialStore() *KeyringCredentialStore {
+	return &KeyringCredentialStore{service: CREDENTIALS_SERVICE}
+}
+
+// available probes the keyring with a lookup, a missing entry is the
+// answer of a working keyring
+func (s *KeyringCredentialStore) available() bool {
+	_, err := keyring.Get(s.service, "")
+	return err == nil || errors.Is(err, keyring.ErrNotFound)
+}
+
+func (s *KeyringCredentialStore) Get(host string) (string, error) {
+	password, err := keyring.Get(s.service, host)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNoCredentials
+	}
+	return password, err
+}
+
+func (s *KeyringCredentialStore) Set(host string, password string) error {
+	return keyring.Set(s.service, host, password)
+}
+
+func (s *KeyringCredentialStore) Delete(host string) error {
+	err := keyring.Delete(s.service, host)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// FileCredentialStore encrypts the passwords with AES-GCM. The random key
+// lives in a separate file readable by the owner only, which protects the
+// passwords in backups and config repositories of the credential file, but
+// not against the local user. It is the fallback of DefaultCredentialStore
+// where no keyring is available.
+type FileCredentialStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// DefaultCredentialDir is the wattpilot directory in the user config dir
+func DefaultCredentialDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wattpilot"), nil
+}
+
+func NewFileCredentialStore(dir string) (*FileCredentialStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileCredentialStore{dir: dir}, nil
+}
+
+func (s *FileCredentialStore) key() ([]byte, error) {
+	path := filepath.Join(s.dir, CREDENTIALS_KEY)
+	key, err := os.ReadFile(path)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, errors.New("invalid credential key in " + path)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, os.WriteFile(path, key, 0600)
+}
+
+func (s *FileCredentialStore) cipher() (cipher.AEAD, error) {
+	key, err := s.key()
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *FileCredentialStore) load() (map[string]string, error) {
+	credentials := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(s.dir, CREDENTIALS_FILE))
+	if os.IsNotExist(err) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("credential file is corrupt")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	return credentials, json.Unmarshal(plain, &credentials)
+}
+
+func (s *FileCredentialStore) save(credentials map[string]string) error {
+	plain, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	gcm, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, CREDENTIALS_FILE), gcm.Seal(nonce, nonce, plain, nil), 0600)
+}
+
+func (s *FileCredentialStore) Get(host string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	password, isKnown := credentials[host]
+	if !isKnown {
+		return "", ErrNoCredentials
+	}
+	return password, nil
+}
+
+func (s *FileCredentialStore) Set(host string, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+	credentials[host] = password
+	return s.save(credentials)
+}
+
+func (s *FileCredentialStore) Delete(host string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(credentials, host)
+	return s.save(credentials)
+}
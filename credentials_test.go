@@ -0,0 +1,120 @@
+package wattpilot_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := wattpilot.NewFileCredentialStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("wattpilot.local"); !errors.Is(err, wattpilot.ErrNoCredentials) {
+		t.Fatal("expected ErrNoCredentials, got ", err)
+	}
+	if err := store.Set("wattpilot.local", testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("garage.local", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the password is encrypted in a file of the owner only
+	for _, name := range []string{wattpilot.CREDENTIALS_FILE, wattpilot.CREDENTIALS_KEY} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v", name, info.Mode().Perm())
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, wattpilot.CREDENTIALS_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte(testPassword)) || bytes.Contains(data, []byte("wattpilot.local")) {
+		t.Fatal("credentials stored in plaintext")
+	}
+
+	// a new store reads the passwords with the key
+	reopened, err := wattpilot.NewFileCredentialStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password, err := reopened.Get("wattpilot.local"); err != nil || password != testPassword {
+		t.Fatal("unexpected password ", password, err)
+	}
+	if err := reopened.Delete("wattpilot.local"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("wattpilot.local"); !errors.Is(err, wattpilot.ErrNoCredentials) {
+		t.Fatal("deleted password still stored: ", err)
+	}
+	if password, err := store.Get("garage.local"); err != nil || password != "other" {
+		t.Fatal("other password lost: ", password, err)
+	}
+}
+
+func TestFileCredentialStoreRefusesOtherKey(t *testing.T) {
+	dir := t.TempDir()
+	store, err := wattpilot.NewFileCredentialStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("wattpilot.local", testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	key := filepath.Join(dir, wattpilot.CREDENTIALS_KEY)
+	if err := os.WriteFile(key, bytes.Repeat([]byte{1}, 32), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if password, err := store.Get("wattpilot.local"); err == nil || errors.Is(err, wattpilot.ErrNoCredentials) {
+		t.Fatal("decrypted with another key: ", password, err)
+	}
+	if err := os.WriteFile(key, []byte("short"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("wattpilot.local"); err == nil {
+		t.Fatal("expected an error for an invalid key")
+	}
+}
+
+// TestStoredPasswordConnects authenticates at the charger with the password
+// read from the store, as the tools do without WATTPILOT_PASSWORD
+func TestStoredPasswordConnects(t *testing.T) {
+	mock, err := wattpilottest.NewMockCharger(testSerial, testPassword, map[string]interface{}{"car": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	store, err := wattpilot.NewFileCredentialStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(mock.Addr(), testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := store.Get(mock.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := wattpilot.New(mock.Addr(), password)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if !client.IsInitialized() || client.GetSerial() != testSerial {
+		t.Fatal("client not authenticated with the stored password")
+	}
+}
@@ -0,0 +1,129 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Departure is the energy a car needs charged by a time of day
+type Departure struct {
+	// At is the time of day of the departure, the deadline is its next
+	// occurrence
+	At time.Duration
+	// Energy in Wh to charge since the car was plugged in (wh)
+	Energy float64
+	// Current in A used when charging from the grid
+	Current float64
+	// Power in W charged with Current, e.g. 11000 for 16 A on three phases
+	Power float64
+	// Margin is added to the charging time for slower charging
+	Margin time.Duration
+}
+
+// Deadline returns the next departure after t. At is taken as wall clock
+// time, so the departure stays at the same time across daylight saving
+// changes.
+func (d Departure) Deadline(t time.Time) time.Time {
+	deadline := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, int(d.At), t.Location())
+	if !deadline.After(t) {
+		deadline = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, int(d.At), t.Location())
+	}
+	return deadline
+}
+
+// Remaining returns the energy in Wh still missing, zero without a car
+func (d Departure) Remaining(snapshot Snapshot) float64 {
+	if state, err := snapshot.Float("car"); err != nil || !isPlugged(int(state)) {
+		return 0
+	}
+	charged, _ := snapshot.Float("wh")
+	if charged >= d.Energy {
+		return 0
+	}
+	return d.Energy - charged
+}
+
+// ChargingTime returns how long charging the remaining energy with Current
+// takes, including the margin
+func (d Departure) ChargingTime(snapshot Snapshot) time.Duration {
+	remaining := d.Remaining(snapshot)
+	if remaining <= 0 || d.Power <= 0 {
+		return 0
+	}
+	return time.Duration(remaining/d.Power*float64(time.Hour)) + d.Margin
+}
+
+// MustCharge reports whether charging has to start now to charge the
+// remaining energy by the deadline
+func (d Departure) MustCharge(snapshot Snapshot) bool {
+	needed := d.ChargingTime(snapshot)
+	if needed <= 0 {
+		return false
+	}
+	return !snapshot.Time.Add(needed).Before(d.Deadline(snapshot.Time))
+}
+
+// chargeActions returns the writes charging from the grid with Current,
+// leaving out those already in place
+func (d Departure) chargeActions(snapshot Snapshot, reason string) []Action {
+	actions := []Action{}
+	if current, err := snapshot.Float("amp"); err != nil || current != d.Current {
+		actions = append(actions, Action{Key: "amp", Value: d.Current, Reason: reason})
+	}
+	if state, err := snapshot.Float("frc"); err != nil || ForceState(state) != ForceOn {
+		actions = append(actions, Action{Key: "frc", Value: int(ForceOn), Reason: reason})
+	}
+	return actions
+}
+
+// MinimumCharge guarantees the energy of the departure with any charging
+// strategy. While the wrapped controller can still make it in time its
+// actions are applied, when waiting longer would miss the deadline it
+// charges from the grid with the current of the departure until the
+// energy is charged, then hands back to the wrapped controller.
+type MinimumCharge struct {
+	Controller
+	Departure Departure
+
+	mu     sync.Mutex
+	active bool
+}
+
+// GuaranteeMinimum wraps the controller with the minimum charge
+func GuaranteeMinimum(c Controller, departure Departure) *MinimumCharge {
+	return &MinimumCharge{Controller: c, Departure: departure}
+}
+
+func (m *MinimumCharge) Evaluate(ctx context.Context, snapshot Snapshot) []Action {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Departure.MustCharge(snapshot) {
+		m.active = true
+		reason := fmt.Sprintf("minimum charge of %.0f Wh by %s", m.Departure.Energy, m.Departure.Deadline(snapshot.Time).Format("15:04"))
+		return m.Departure.chargeActions(snapshot, reason)
+	}
+	if !m.active {
+		return m.Controller.Evaluate(ctx, snapshot)
+	}
+	m.active = false
+	actions := []Action{{Key: "frc", Value: int(ForceNeutral), Reason: "minimum charge reached"}}
+	return append(actions, m.Controller.Evaluate(ctx, snapshot)...)
+}
+
+func (m *MinimumCharge) Unwrap() Controller {
+	return m.Controller
+}
+
+func (m *MinimumCharge) Priority() int {
+	return controllerPriority(m.Controller)
+}
+
+func (m *MinimumCharge) Healthy() error {
+	if h, ok := m.Controller.(HealthChecker); ok {
+		return h.Healthy()
+	}
+	return nil
+}
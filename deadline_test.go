@@ -0,0 +1,80 @@
+package wattpilot_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func TestDepartureDeadline(t *testing.T) {
+	d := wattpilot.Departure{At: 7 * time.Hour}
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 10, 17, 6, 0, 0, 0, vienna), time.Date(2026, 10, 17, 7, 0, 0, 0, vienna)},
+		{time.Date(2026, 10, 17, 7, 0, 0, 0, vienna), time.Date(2026, 10, 18, 7, 0, 0, 0, vienna)},
+		{time.Date(2026, 10, 17, 22, 0, 0, 0, vienna), time.Date(2026, 10, 18, 7, 0, 0, 0, vienna)},
+		// the night of the change from summer time is one hour longer
+		{time.Date(2026, 10, 24, 22, 0, 0, 0, vienna), time.Date(2026, 10, 25, 7, 0, 0, 0, vienna)},
+	}
+	for _, test := range tests {
+		if got := d.Deadline(test.now); !got.Equal(test.want) {
+			t.Errorf("deadline after %v: got %v, want %v", test.now, got, test.want)
+		}
+	}
+}
+
+// pausing is an opportunistic strategy which never charges
+type pausing struct{}
+
+func (pausing) Name() string {
+	return "pausing"
+}
+
+func (pausing) Interval() time.Duration {
+	return time.Minute
+}
+
+func (pausing) Evaluate(ctx context.Context, snapshot wattpilot.Snapshot) []wattpilot.Action {
+	return []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOff), Reason: "no surplus"}}
+}
+
+func TestMinimumCharge(t *testing.T) {
+	// 11 kWh with 11 kW take one hour, plus a margin of 15 minutes
+	m := wattpilot.GuaranteeMinimum(pausing{}, wattpilot.Departure{At: 7 * time.Hour, Energy: 11000, Current: 16, Power: 11000, Margin: 15 * time.Minute})
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	status := map[string]interface{}{"car": 3.0, "wh": 0.0, "amp": 6.0, "frc": float64(wattpilot.ForceOff)}
+	pause := []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOff), Reason: "no surplus"}}
+	charge := []wattpilot.Action{
+		{Key: "amp", Value: 16.0, Reason: "minimum charge of 11000 Wh by 07:00"},
+		{Key: "frc", Value: int(wattpilot.ForceOn), Reason: "minimum charge of 11000 Wh by 07:00"},
+	}
+
+	tests := []struct {
+		name string
+		at   time.Duration
+		car  float64
+		wh   float64
+		want []wattpilot.Action
+	}{
+		{"strategy in time", 5 * time.Hour, 3, 0, pause},
+		{"falling behind", 5*time.Hour + 45*time.Minute, 3, 0, charge},
+		{"partly charged by the strategy", 6*time.Hour + 15*time.Minute, 3, 5500, charge},
+		{"minimum reached", 6*time.Hour + 30*time.Minute, 4, 11000, append([]wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceNeutral), Reason: "minimum charge reached"}}, pause...)},
+		{"no car", 6*time.Hour + 45*time.Minute, 1, 0, pause},
+	}
+	for _, test := range tests {
+		status["car"], status["wh"] = test.car, test.wh
+		actions := m.Evaluate(context.Background(), wattpilot.Snapshot{Time: day.Add(test.at), Status: status})
+		if !reflect.DeepEqual(actions, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, actions, test.want)
+		}
+	}
+}
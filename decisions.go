@@ -0,0 +1,75 @@
+package wattpilot
+
+import (
+	"sync"
+	"time"
+)
+
+const DECISION_LOG_SIZE = 256
+
+// Decision documents an automatic action taken by a controlling component
+// like the lock schedule, including the inputs it was based on.
+type Decision struct {
+	Time     time.Time
+	Source   string
+	Inputs   map[string]interface{}
+	Setpoint interface{}
+	Action   string
+	Reason   string
+	Error    error
+}
+
+type decisionLog struct {
+	mu      sync.Mutex
+	entries []Decision
+	next    int
+	full    bool
+}
+
+func newDecisionLog(size int) *decisionLog {
+	if size <= 0 {
+		size = DECISION_LOG_SIZE
+	}
+	return &decisionLog{entries: make([]Decision, size)}
+}
+
+func (l *decisionLog) add(d Decision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = d
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+func (l *decisionLog) list() []Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]Decision{}, l.entries[:l.next]...)
+	}
+	return append(append([]Decision{}, l.entries[l.next:]...), l.entries[:l.next]...)
+}
+
+// WithDecisionLogSize sets the number of decisions kept in memory
+func WithDecisionLogSize(size int) Option {
+	return func(w *Wattpilot) {
+		w._decisions = newDecisionLog(size)
+	}
+}
+
+// Decisions returns the recorded decisions, oldest first
+func (w *Wattpilot) Decisions() []Decision {
+	return w._decisions.list()
+}
+
+func (w *Wattpilot) recordDecision(d Decision) {
+	if d.Time.IsZero() {
+		d.Time = w._clock.Now()
+	}
+	w._decisions.add(d)
+	w.forward(FleetDecision, d.Source, d)
+}
@@ -0,0 +1,332 @@
+package wattpilot
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var errInvalidJSON = errors.New("invalid json message")
+
+// maxNesting is the nesting limit of encoding/json
+const maxNesting = 10000
+
+// WithFastDecode decodes the received messages with a hand-rolled parser
+// instead of encoding/json. It produces the same values but skips the
+// validation pass and reflection of encoding/json, which dominates the CPU
+// usage with many chargers on small devices.
+func WithFastDecode() Option {
+	return func(w *Wattpilot) {
+		w._fastDecode = true
+	}
+}
+
+func (w *Wattpilot) decodeMessage(msg []byte) (map[string]interface{}, error) {
+	if !w._fastDecode {
+		data := make(map[string]interface{})
+		err := json.Unmarshal(msg, &data)
+		return data, err
+	}
+	// the message itself is the first level of nesting
+	p := jsonParser{data: msg, depth: 1}
+	p.skipSpace()
+	if p.literal("null") == nil {
+		// like encoding/json, which decodes null to a nil map
+		p.skipSpace()
+		if p.pos != len(p.data) {
+			return nil, errInvalidJSON
+		}
+		return nil, nil
+	}
+	value, err := p.parseObject()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.data) {
+		return nil, errInvalidJSON
+	}
+	return value, nil
+}
+
+type jsonParser struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.data) && p.data[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *jsonParser) parseValue() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, errInvalidJSON
+	}
+	switch c := p.data[p.pos]; {
+	case c == '{' || c == '[':
+		if p.depth >= maxNesting {
+			return nil, errInvalidJSON
+		}
+		p.depth++
+		var value interface{}
+		var err error
+		if c == '{' {
+			value, err = p.parseObject()
+		} else {
+			value, err = p.parseArray()
+		}
+		p.depth--
+		return value, err
+	case c == '"':
+		return p.parseString()
+	case c == 't':
+		return true, p.literal("true")
+	case c == 'f':
+		return false, p.literal("false")
+	case c == 'n':
+		return nil, p.literal("null")
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	}
+	return nil, errInvalidJSON
+}
+
+func (p *jsonParser) literal(s string) error {
+	if len(p.data)-p.pos < len(s) || string(p.data[p.pos:p.pos+len(s)]) != s {
+		return errInvalidJSON
+	}
+	p.pos += len(s)
+	return nil
+}
+
+func (p *jsonParser) parseObject() (map[string]interface{}, error) {
+	if !p.consume('{') {
+		return nil, errInvalidJSON
+	}
+	object := make(map[string]interface{})
+	if p.consume('}') {
+		return object, nil
+	}
+	for {
+		p.skipSpace()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(':') {
+			return nil, errInvalidJSON
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+		if p.consume(',') {
+			continue
+		}
+		if p.consume('}') {
+			return object, nil
+		}
+		return nil, errInvalidJSON
+	}
+}
+
+func (p *jsonParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	array := []interface{}{}
+	if p.consume(']') {
+		return array, nil
+	}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		if p.consume(',') {
+			continue
+		}
+		if p.consume(']') {
+			return array, nil
+		}
+		return nil, errInvalidJSON
+	}
+}
+
+// parseKey returns the interned instance of known keys without allocating
+func (p *jsonParser) parseKey() (string, error) {
+	if p.pos < len(p.data) && p.data[p.pos] == '"' {
+		end := p.pos + 1
+		for end < len(p.data) && p.data[end] != '"' && p.data[end] != '\\' {
+			end++
+		}
+		if end < len(p.data) && p.data[end] == '"' {
+			if key, isKnown := statusKeys[string(p.data[p.pos+1:end])]; isKnown {
+				p.pos = end + 1
+				return key, nil
+			}
+		}
+	}
+	return p.parseString()
+}
+
+// parseNumber follows the number grammar of JSON: no leading zeros, no
+// plus sign and digits on both sides of the decimal point
+func (p *jsonParser) parseNumber() (float64, error) {
+	start := p.pos
+	if p.pos < len(p.data) && p.data[p.pos] == '-' {
+		p.pos++
+	}
+	switch {
+	case p.pos < len(p.data) && p.data[p.pos] == '0':
+		p.pos++
+	case !p.digits():
+		return 0, errInvalidJSON
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '.' {
+		p.pos++
+		if !p.digits() {
+			return 0, errInvalidJSON
+		}
+	}
+	if p.pos < len(p.data) && (p.data[p.pos] == 'e' || p.data[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.data) && (p.data[p.pos] == '+' || p.data[p.pos] == '-') {
+			p.pos++
+		}
+		if !p.digits() {
+			return 0, errInvalidJSON
+		}
+	}
+	value, err := strconv.ParseFloat(string(p.data[start:p.pos]), 64)
+	if err != nil {
+		return 0, errInvalidJSON
+	}
+	return value, nil
+}
+
+// digits skips a non-empty run of digits
+func (p *jsonParser) digits() bool {
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '9' {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+func (p *jsonParser) parseString() (string, error) {
+	if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+		return "", errInvalidJSON
+	}
+	p.pos++
+	start := p.pos
+	// fast path for strings without escapes
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '"' {
+			s := p.data[start:p.pos]
+			p.pos++
+			if !utf8.Valid(s) {
+				return string([]rune(string(s))), nil
+			}
+			return string(s), nil
+		}
+		if c == '\\' {
+			break
+		}
+		if c < 0x20 {
+			return "", errInvalidJSON
+		}
+		p.pos++
+	}
+	buf := append([]byte{}, p.data[start:p.pos]...)
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return string([]rune(string(buf))), nil
+		case c < 0x20:
+			return "", errInvalidJSON
+		case c != '\\':
+			buf = append(buf, c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		if p.pos >= len(p.data) {
+			return "", errInvalidJSON
+		}
+		esc := p.data[p.pos]
+		p.pos++
+		switch esc {
+		case '"', '\\', '/':
+			buf = append(buf, esc)
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, ok := p.parseHex()
+			if !ok {
+				return "", errInvalidJSON
+			}
+			if utf16.IsSurrogate(r) {
+				r2 := utf8.RuneError
+				if len(p.data)-p.pos >= 6 && p.data[p.pos] == '\\' && p.data[p.pos+1] == 'u' {
+					save := p.pos
+					p.pos += 2
+					if low, ok := p.parseHex(); ok {
+						r2 = utf16.DecodeRune(r, low)
+					}
+					if r2 == utf8.RuneError {
+						p.pos = save
+					}
+				}
+				r = r2
+			}
+			buf = utf8.AppendRune(buf, r)
+		default:
+			return "", errInvalidJSON
+		}
+	}
+	return "", errInvalidJSON
+}
+
+func (p *jsonParser) parseHex() (rune, bool) {
+	if len(p.data)-p.pos < 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(string(p.data[p.pos:p.pos+4]), 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	p.pos += 4
+	return rune(v), true
+}
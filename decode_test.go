@@ -0,0 +1,64 @@
+package wattpilot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeNumberGrammar(t *testing.T) {
+	fast := &Wattpilot{_fastDecode: true}
+	for _, number := range []string{"01", "-01", "1.", ".5", "-", "+1", "1e", "1e+", "0x10", "1.5.2", "--1"} {
+		if _, err := fast.decodeMessage([]byte(`{"amp": ` + number + `}`)); err == nil {
+			t.Errorf("%s: expected an error", number)
+		}
+	}
+	for _, number := range []string{"0", "-0", "16", "-1.5", "0.25", "1e3", "1E-2", "-0.5e+1"} {
+		if _, err := fast.decodeMessage([]byte(`{"amp": ` + number + `}`)); err != nil {
+			t.Errorf("%s: %v", number, err)
+		}
+	}
+}
+
+func TestDecodeNesting(t *testing.T) {
+	fast := &Wattpilot{_fastDecode: true}
+	nested := func(depth int) []byte {
+		return []byte(`{"a":` + strings.Repeat("[", depth-1) + strings.Repeat("]", depth-1) + "}")
+	}
+	if _, err := fast.decodeMessage(nested(maxNesting)); err != nil {
+		t.Error("nesting limit refused: ", err)
+	}
+	if _, err := fast.decodeMessage(nested(maxNesting + 1)); err == nil {
+		t.Error("nesting beyond the limit accepted")
+	}
+}
+
+// FuzzDecode checks that the fast decoder accepts and refuses the same
+// messages as encoding/json and produces the same values
+func FuzzDecode(f *testing.F) {
+	seeds := []string{
+		`{"type":"deltaStatus","status":{"amp":16,"nrg":[230,231.5,-1e3,0],"car":2}}`,
+		`{"type":"hello","serial":"12345678","friendly_name":"Garäge 🚗","secured":true}`,
+		`{"a":null,"b":false,"c":[{},[]],"d":"\"\\\/\b\f\n\r\t"}`,
+		`{"amp": 01}`,
+		`{"amp": 1.}`,
+		`{"s":"\ud800x"}`,
+		` null `,
+		`[1]`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	fast := &Wattpilot{_fastDecode: true}
+	standard := &Wattpilot{}
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		want, wantErr := standard.decodeMessage(msg)
+		got, err := fast.decodeMessage(msg)
+		if (err != nil) != (wantErr != nil) {
+			t.Fatalf("%q: error %v, encoding/json %v", msg, err, wantErr)
+		}
+		if err == nil && !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: decoded %#v, encoding/json %#v", msg, got, want)
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package wattpilot
+
+// DeviceInfo is the identity the charger announces in its hello message
+type DeviceInfo struct {
+	Serial       string
+	Hostname     string
+	FriendlyName string
+	Manufacturer string
+	DeviceType   string
+	Version      string
+	Protocol     float64
+	Secured      bool
+}
+
+func (w *Wattpilot) DeviceInfo() DeviceInfo {
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
+	return DeviceInfo{
+		Serial:       w._serial,
+		Hostname:     w._hostname,
+		FriendlyName: w._name,
+		Manufacturer: w._manufacturer,
+		DeviceType:   w._devicetype,
+		Version:      w._version,
+		Protocol:     w._protocol,
+		Secured:      w._secured,
+	}
+}
+
+// RawStatus copies the status as reported by the charger, without the
+// virtual properties
+func (w *Wattpilot) RawStatus() map[string]interface{} {
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	status := make(map[string]interface{}, len(w._status))
+	for k, v := range w._status {
+		status[k] = v
+	}
+	return status
+}
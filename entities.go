@@ -0,0 +1,124 @@
+package wattpilot
+
+// Entity platforms as used by Home Assistant
+const (
+	PlatformSensor       = "sensor"
+	PlatformBinarySensor = "binary_sensor"
+	PlatformNumber       = "number"
+	PlatformSelect       = "select"
+)
+
+// Entity describes a property for home automation integrations. The
+// UniqueId is derived of the serial and the key, so it is stable across
+// restarts and renames of the charger.
+type Entity struct {
+	UniqueId string `json:"unique_id"`
+	// Name is the property name of the library, Source the key of the
+	// charger the value is derived of
+	Name        string         `json:"name"`
+	Source      string         `json:"source"`
+	Label       string         `json:"label"`
+	Platform    string         `json:"platform"`
+	DeviceClass string         `json:"device_class,omitempty"`
+	StateClass  string         `json:"state_class,omitempty"`
+	Unit        Unit           `json:"unit_of_measurement,omitempty"`
+	Options     map[int]string `json:"options,omitempty"`
+	Writable    bool           `json:"writable"`
+	Min         float64        `json:"min,omitempty"`
+	Max         float64        `json:"max,omitempty"`
+}
+
+// EntityDevice is the device info of the entities
+type EntityDevice struct {
+	Identifier   string `json:"identifier"`
+	Name         string `json:"name"`
+	Manufacturer string `json:"manufacturer"`
+	Model        string `json:"model"`
+	Version      string `json:"sw_version"`
+	Serial       string `json:"serial_number"`
+}
+
+type entitySpec struct {
+	name        string
+	label       string
+	platform    string
+	deviceClass string
+	stateClass  string
+	writable    bool
+	min, max    float64
+}
+
+var entitySpecs = []entitySpec{
+	{name: "car", label: "Car state", platform: PlatformSensor, deviceClass: "enum"},
+	{name: "alw", label: "Charging allowed", platform: PlatformBinarySensor},
+	{name: "power", label: "Power", platform: PlatformSensor, deviceClass: "power", stateClass: "measurement"},
+	{name: "eto", label: "Total energy", platform: PlatformSensor, deviceClass: "energy", stateClass: "total_increasing"},
+	{name: "wh", label: "Session energy", platform: PlatformSensor, deviceClass: "energy", stateClass: "total_increasing"},
+	{name: "voltage1", label: "Voltage L1", platform: PlatformSensor, deviceClass: "voltage", stateClass: "measurement"},
+	{name: "voltage2", label: "Voltage L2", platform: PlatformSensor, deviceClass: "voltage", stateClass: "measurement"},
+	{name: "voltage3", label: "Voltage L3", platform: PlatformSensor, deviceClass: "voltage", stateClass: "measurement"},
+	{name: "amps1", label: "Current L1", platform: PlatformSensor, deviceClass: "current", stateClass: "measurement"},
+	{name: "amps2", label: "Current L2", platform: PlatformSensor, deviceClass: "current", stateClass: "measurement"},
+	{name: "amps3", label: "Current L3", platform: PlatformSensor, deviceClass: "current", stateClass: "measurement"},
+	{name: "fhz", label: "Grid frequency", platform: PlatformSensor, deviceClass: "frequency", stateClass: "measurement"},
+	{name: "amp", label: "Charging current", platform: PlatformNumber, deviceClass: "current", writable: true, min: 6, max: 32},
+	{name: "frc", label: "Force state", platform: PlatformSelect, writable: true},
+	{name: "psm", label: "Phase switch mode", platform: PlatformSelect, writable: true},
+	{name: "lmo", label: "Logic mode", platform: PlatformSelect, writable: true},
+}
+
+func entitySource(name string) string {
+	if m, post := PostProcess[name]; post {
+		return m.key
+	}
+	return resolveKey(name)
+}
+
+// EntityDevice returns the device info of the connected charger
+func (w *Wattpilot) EntityDevice() EntityDevice {
+	d := w.DeviceInfo()
+	return EntityDevice{
+		Identifier:   "wattpilot_" + d.Serial,
+		Name:         d.FriendlyName,
+		Manufacturer: d.Manufacturer,
+		Model:        d.DeviceType,
+		Version:      d.Version,
+		Serial:       d.Serial,
+	}
+}
+
+// Entities returns the entities of the properties reported by the charger
+func (w *Wattpilot) Entities() []Entity {
+	serial := w.GetSerial()
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	entities := []Entity{}
+	for _, spec := range entitySpecs {
+		source := entitySource(spec.name)
+		if _, isKnown := w._status[source]; !isKnown {
+			continue
+		}
+		e := Entity{
+			UniqueId:    "wattpilot_" + serial + "_" + spec.name,
+			Name:        spec.name,
+			Source:      source,
+			Label:       spec.label,
+			Platform:    spec.platform,
+			DeviceClass: spec.deviceClass,
+			StateClass:  spec.stateClass,
+			Writable:    spec.writable,
+			Min:         spec.min,
+			Max:         spec.max,
+		}
+		e.Unit, _ = PropertyUnit(spec.name)
+		if values := EnumValues(spec.name); len(values) > 0 {
+			e.Options = make(map[int]string, len(values))
+			for _, v := range values {
+				e.Options[v], _ = EnumLabel(spec.name, v, DEFAULT_LOCALE)
+			}
+		}
+		entities = append(entities, e)
+	}
+	return entities
+}
@@ -0,0 +1,16 @@
+package wattpilot
+
+import "errors"
+
+// The errors of the client wrap these, so callers can match them with
+// errors.Is. Errors of the network and the websocket are wrapped as well
+// and can be unwrapped with errors.As, e.g. to net.Error for own retries.
+var (
+	ErrNotConnected     = errors.New("connection is not valid")
+	ErrConnectFailed    = errors.New("could not connect")
+	ErrAuthFailed       = errors.New("authentication failed")
+	ErrPropertyNotFound = errors.New("property not found")
+	ErrInvalidValue     = errors.New("invalid value")
+	ErrSessionClosed    = errors.New("session is closed")
+	ErrSessionReadOnly  = errors.New("session is read-only")
+)
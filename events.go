@@ -0,0 +1,40 @@
+package wattpilot
+
+import "time"
+
+type EventType string
+
+const (
+	EventConnectionPathChanged EventType = "connectionPathChanged"
+	EventConnected             EventType = "connected"
+)
+
+// Event is published on the notification bus for library level incidents
+// which are not property updates of the charger.
+type Event struct {
+	Type EventType
+	Host string
+	Time time.Time
+	Data map[string]interface{}
+}
+
+func eventTopic(eventType EventType) string {
+	return "event:" + string(eventType)
+}
+
+// GetEvents subscribes to all events of the given type, the channel delivers
+// values of type Event.
+func (w *Wattpilot) GetEvents(eventType EventType) <-chan interface{} {
+	return w._notifications.Subscribe(eventTopic(eventType))
+}
+
+func (w *Wattpilot) emitEvent(eventType EventType, data map[string]interface{}) {
+	event := Event{
+		Type: eventType,
+		Host: w._host,
+		Time: w._clock.Now(),
+		Data: data,
+	}
+	w._notifications.Publish(eventTopic(eventType), event)
+	w.forward(FleetDeviceEvent, string(eventType), event)
+}
@@ -0,0 +1,75 @@
+package wattpilot
+
+import "time"
+
+// EVENT_SCHEMA_VERSION is sent in every EventMessage, it is increased on
+// incompatible changes of the message so consumers like Node-RED flows can
+// detect them
+const EVENT_SCHEMA_VERSION = 1
+
+// EventMessage is the stable JSON form of a FleetEvent sent by bridges like
+// the websocket, server-sent events and MQTT. Kind is property, event,
+// decision or status. Value is the property value, the data of the event,
+// the decision or the status by key.
+type EventMessage struct {
+	Schema int         `json:"schema"`
+	Kind   string      `json:"kind"`
+	Serial string      `json:"serial"`
+	Name   string      `json:"name,omitempty"`
+	Key    string      `json:"key,omitempty"`
+	Value  interface{} `json:"value"`
+	Time   time.Time   `json:"time"`
+}
+
+// DecisionMessage is the JSON form of a Decision in an EventMessage
+type DecisionMessage struct {
+	Source   string                 `json:"source"`
+	Action   string                 `json:"action"`
+	Reason   string                 `json:"reason,omitempty"`
+	Setpoint interface{}            `json:"setpoint,omitempty"`
+	Inputs   map[string]interface{} `json:"inputs,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// NewEventMessage converts the event of the bus of the Manager
+func NewEventMessage(e FleetEvent) EventMessage {
+	m := EventMessage{
+		Schema: EVENT_SCHEMA_VERSION,
+		Kind:   string(e.Kind),
+		Serial: e.Serial,
+		Name:   e.Name,
+		Key:    e.Key,
+		Value:  e.Value,
+		Time:   e.Time,
+	}
+	switch v := e.Value.(type) {
+	case Event:
+		m.Value = v.Data
+	case Decision:
+		decision := DecisionMessage{
+			Source:   v.Source,
+			Action:   v.Action,
+			Reason:   v.Reason,
+			Setpoint: v.Setpoint,
+			Inputs:   v.Inputs,
+		}
+		if v.Error != nil {
+			decision.Error = v.Error.Error()
+		}
+		m.Value = decision
+	}
+	return m
+}
+
+// StatusMessage holds the current status of the charger, sent by bridges
+// before the changes
+func (w *Wattpilot) StatusMessage() EventMessage {
+	return EventMessage{
+		Schema: EVENT_SCHEMA_VERSION,
+		Kind:   "status",
+		Serial: w.GetSerial(),
+		Name:   w.GetName(),
+		Value:  w.RawStatus(),
+		Time:   w._clock.Now(),
+	}
+}
@@ -0,0 +1,38 @@
+package wattpilot
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEventMessageJSON(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		event FleetEvent
+		json  string
+	}{
+		{
+			FleetEvent{Kind: FleetPropertyChanged, Serial: "1", Time: at, Key: "amp", Value: 16.0},
+			`{"schema":1,"kind":"property","serial":"1","key":"amp","value":16,"time":"2024-01-01T12:00:00Z"}`,
+		},
+		{
+			FleetEvent{Kind: FleetDeviceEvent, Serial: "1", Name: "garage", Time: at, Key: "connected", Value: Event{Type: EventConnected, Data: map[string]interface{}{"version": "38.5"}}},
+			`{"schema":1,"kind":"event","serial":"1","name":"garage","key":"connected","value":{"version":"38.5"},"time":"2024-01-01T12:00:00Z"}`,
+		},
+		{
+			FleetEvent{Kind: FleetDecision, Serial: "1", Time: at, Key: "night", Value: Decision{Source: "night", Action: "set amp", Setpoint: 10, Error: errors.New("offline")}},
+			`{"schema":1,"kind":"decision","serial":"1","key":"night","value":{"source":"night","action":"set amp","setpoint":10,"error":"offline"},"time":"2024-01-01T12:00:00Z"}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(NewEventMessage(test.event))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.json {
+			t.Errorf("got %s\nwant %s", data, test.json)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// dashboard serves the state of several chargers as JSON for a web
+// dashboard: /api/devices lists all chargers, /api/devices/<serial> returns
+// one. WATTPILOT_HOSTS lists the chargers sharing WATTPILOT_PASSWORD.
+// Without it the backend runs against mock chargers, fetches the list once
+// and exits, so it doubles as smoke test.
+
+const timeout = 10 * time.Second
+
+var keys = []string{"car", "amp", "frc", "alw", "power", "eto"}
+
+type device struct {
+	Serial    string                 `json:"serial"`
+	Name      string                 `json:"name"`
+	Host      string                 `json:"host"`
+	Connected bool                   `json:"connected"`
+	Status    map[string]interface{} `json:"status"`
+}
+
+func describe(w *api.Wattpilot) device {
+	d := device{
+		Serial:    w.GetSerial(),
+		Name:      w.GetName(),
+		Host:      w.GetHost(),
+		Connected: w.IsInitialized(),
+		Status:    make(map[string]interface{}),
+	}
+	for _, key := range keys {
+		if value, err := w.GetProperty(key); err == nil {
+			d.Status[key] = value
+		}
+	}
+	return d
+}
+
+func writeJSON(rw http.ResponseWriter, status int, data interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(data); err != nil {
+		log.Println("error writing response:", err)
+	}
+}
+
+func handler(m *api.Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/devices", func(rw http.ResponseWriter, r *http.Request) {
+		devices := []device{}
+		for _, w := range m.Devices() {
+			devices = append(devices, describe(w))
+		}
+		writeJSON(rw, http.StatusOK, devices)
+	})
+	mux.HandleFunc("/api/devices/", func(rw http.ResponseWriter, r *http.Request) {
+		w, ok := m.Device(strings.TrimPrefix(r.URL.Path, "/api/devices/"))
+		if !ok {
+			writeJSON(rw, http.StatusNotFound, map[string]string{"error": "unknown device"})
+			return
+		}
+		writeJSON(rw, http.StatusOK, describe(w))
+	})
+	return mux
+}
+
+func main() {
+	hosts := os.Getenv("WATTPILOT_HOSTS")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+	listen := os.Getenv("DASHBOARD_LISTEN")
+	if listen == "" {
+		listen = ":8082"
+	}
+
+	smoke := hosts == ""
+	if smoke {
+		pwd = "wattpilot-example"
+		addrs := []string{}
+		for i, car := range []int{1, 2, 4} {
+			mock, err := wattpilottest.NewMockCharger(fmt.Sprintf("9000001%d", i), pwd, map[string]interface{}{
+				"car": car,
+				"amp": 16,
+				"frc": 0,
+				"alw": car == 2,
+				"eto": 1000 * (i + 1),
+				"nrg": []interface{}{230, 230, 230, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			})
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer mock.Close()
+			addrs = append(addrs, mock.Addr())
+		}
+		hosts = strings.Join(addrs, ",")
+		listen = "127.0.0.1:0"
+	}
+
+	m := api.NewManager()
+	for _, host := range strings.Split(hosts, ",") {
+		w := api.New(strings.TrimSpace(host), pwd)
+		defer w.Close()
+		m.Add(w)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	connectCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := m.Connect(connectCtx); err != nil {
+		// the dashboard shows the chargers not reachable as disconnected
+		log.Println(err)
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	server := &http.Server{Handler: handler(m)}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if !smoke {
+		log.Println("Serving the dashboard API on", listener.Addr())
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer server.Close()
+	response, err := http.Get("http://" + listener.Addr().String() + "/api/devices")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer response.Body.Close()
+	devices := []device{}
+	if err := json.NewDecoder(response.Body).Decode(&devices); err != nil {
+		log.Fatal(err)
+	}
+	for _, d := range devices {
+		if !d.Connected {
+			log.Fatal("mock charger not connected: ", d.Host)
+		}
+		fmt.Println(d.Serial, d.Name, d.Status)
+	}
+	if len(devices) != 3 {
+		log.Fatal("expected 3 devices, got ", len(devices))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// monitor prints the car state and the charging power of a charger. Without
+// WATTPILOT_HOST it runs against a mock charger, plugs in a car and exits
+// once the change was seen, so it doubles as smoke test.
+
+const timeout = 10 * time.Second
+
+var status = map[string]interface{}{
+	"car": 1,
+	"amp": 16,
+	"frc": 0,
+	"alw": false,
+	"nrg": []interface{}{230, 230, 230, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+
+	var mock *wattpilottest.MockCharger
+	if host == "" {
+		var err error
+		pwd = "wattpilot-example"
+		if mock, err = wattpilottest.NewMockCharger("90000001", pwd, status); err != nil {
+			log.Fatal(err)
+		}
+		defer mock.Close()
+		host = mock.Addr()
+	}
+
+	charger := api.New(host, pwd)
+	defer charger.Close()
+	if err := charger.Connect(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	states := charger.GetNotifications("car")
+	energy := charger.GetNotifications("nrg")
+	fmt.Println("Connected to", charger.GetName(), charger.GetSerial())
+	if state, err := charger.GetProperty("car"); err == nil {
+		fmt.Println("car:", state)
+	}
+
+	if mock != nil {
+		mock.Update(map[string]interface{}{
+			"car": 2,
+			"nrg": []interface{}{230, 230, 230, 0, 16, 16, 16, 3680, 3680, 3680, 0, 11040, 100, 100, 100, 0},
+		})
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	for seen := 0; ; {
+		select {
+		case <-ctx.Done():
+			if mock != nil {
+				log.Fatal("no updates of the mock charger: ", ctx.Err())
+			}
+			return
+		case state := <-states:
+			fmt.Println("car:", state)
+			seen++
+		case <-energy:
+			// power is derived of the energy array nrg
+			power, err := charger.GetPower()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			fmt.Println("power:", power, "W")
+			seen++
+		}
+		if mock != nil && seen == 2 {
+			return
+		}
+	}
+}
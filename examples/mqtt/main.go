@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// mqtt publishes property changes of a charger to an MQTT broker as retained
+// messages on <MQTT_TOPIC>/<serial>/<key>, events and decisions as versioned
+// api.EventMessage JSON on <MQTT_TOPIC>/<serial>/events. MQTT_LAYOUT=python
+// uses the topics of the Python wattpilot bridge instead,
+// <MQTT_TOPIC>/properties/<key>/state and <MQTT_TOPIC>/available, so
+// existing flows keep working. It speaks the few packets of
+// MQTT 3.1.1 needed to publish with QoS 0, a real bridge would use a client
+// library. Without WATTPILOT_HOST it runs against a mock charger and a mock
+// broker and exits once the changes arrived, so it doubles as smoke test.
+
+const (
+	keepAlive = 30 * time.Second
+	timeout   = 10 * time.Second
+)
+
+const (
+	packetConnect = 0x10
+	packetConnack = 0x20
+	packetPublish = 0x30
+	packetPingreq = 0xc0
+	flagRetain    = 0x01
+)
+
+var status = map[string]interface{}{
+	"car": 1,
+	"amp": 16,
+	"frc": 0,
+	"alw": false,
+	"eto": 120000,
+}
+
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// remainingLength encodes the length of a packet as variable byte integer
+func remainingLength(length int) []byte {
+	encoded := []byte{}
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		encoded = append(encoded, b)
+		if length == 0 {
+			return encoded
+		}
+	}
+}
+
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func packet(header byte, body []byte) []byte {
+	return append(append([]byte{header}, remainingLength(len(body))...), body...)
+}
+
+type publisher struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func dialBroker(broker string, clientId string) (*publisher, error) {
+	conn, err := net.DialTimeout("tcp", broker, timeout)
+	if err != nil {
+		return nil, err
+	}
+	// protocol name and level 4, clean session
+	body := append(mqttString("MQTT"), 4, 0x02, byte(keepAlive/time.Second>>8), byte(keepAlive/time.Second))
+	body = append(body, mqttString(clientId)...)
+	if _, err := conn.Write(packet(packetConnect, body)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, connack); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if connack[0] != packetConnack || connack[3] != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("broker refused the connection: %d", connack[3])
+	}
+	return &publisher{conn: conn}, nil
+}
+
+func (p *publisher) write(data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.conn.Write(data)
+	return err
+}
+
+func (p *publisher) Publish(topic string, payload []byte) error {
+	return p.write(packet(packetPublish|flagRetain, append(mqttString(topic), payload...)))
+}
+
+func (p *publisher) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.write([]byte{packetPingreq, 0}); err != nil {
+				log.Println("ping failed:", err)
+			}
+		}
+	}
+}
+
+func (p *publisher) Close() error {
+	return p.conn.Close()
+}
+
+// mockBroker accepts one client and reports the topics published by it
+func mockBroker() (string, <-chan string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	topics := make(chan string, 16)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			header, body, err := readPacket(conn)
+			if err != nil {
+				return
+			}
+			switch header & 0xf0 {
+			case packetConnect:
+				_, _ = conn.Write([]byte{packetConnack, 2, 0, 0})
+			case packetPublish:
+				length := int(body[0])<<8 | int(body[1])
+				topics <- string(body[2 : 2+length])
+			}
+		}
+	}()
+	return listener.Addr().String(), topics, nil
+}
+
+func readPacket(r io.Reader) (byte, []byte, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+	header := b[0]
+	length, multiplier := 0, 1
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		length += int(b[0]&0x7f) * multiplier
+		multiplier *= 128
+		if b[0]&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return header, body, err
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+	broker := getEnv("MQTT_BROKER", "localhost:1883")
+	prefix := getEnv("MQTT_TOPIC", "wattpilot")
+	keys := strings.Split(getEnv("MQTT_KEYS", "car,amp,frc,alw,eto,nrg"), ",")
+	layout := getEnv("MQTT_LAYOUT", "default")
+
+	var mock *wattpilottest.MockCharger
+	var published <-chan string
+	if host == "" {
+		var err error
+		pwd = "wattpilot-example"
+		if mock, err = wattpilottest.NewMockCharger("90000003", pwd, status); err != nil {
+			log.Fatal(err)
+		}
+		defer mock.Close()
+		host = mock.Addr()
+		if broker, published, err = mockBroker(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	charger := api.New(host, pwd)
+	defer charger.Close()
+	if err := charger.Connect(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := dialBroker(broker, "wattpilot-"+charger.GetSerial())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+	go client.keepAlive(ctx)
+
+	base := prefix + "/" + charger.GetSerial()
+	propertyTopic := func(key string) string {
+		return base + "/" + key
+	}
+	if layout == "python" {
+		base = prefix
+		propertyTopic = func(key string) string {
+			return base + "/properties/" + key + "/state"
+		}
+		if err := client.Publish(base+"/available", []byte("online")); err != nil {
+			log.Println("publish failed:", err)
+		}
+	}
+	send := func(topic string, value interface{}) {
+		payload, err := json.Marshal(value)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if err := client.Publish(topic, payload); err != nil {
+			log.Println("publish failed:", err)
+		}
+	}
+	publish := func(key string, value interface{}) {
+		send(propertyTopic(key), value)
+	}
+
+	manager := api.NewManager(charger)
+	events := manager.GetNotifications()
+	go func() {
+		for update := range events {
+			if event, ok := update.(api.FleetEvent); ok && event.Kind != api.FleetPropertyChanged {
+				send(base+"/events", api.NewEventMessage(event))
+			}
+		}
+	}()
+	for _, key := range keys {
+		updates := charger.GetNotifications(key)
+		if value, err := charger.GetProperty(key); err == nil {
+			publish(key, value)
+		}
+		go func(key string) {
+			for value := range updates {
+				publish(key, value)
+			}
+		}(key)
+	}
+
+	if mock == nil {
+		<-ctx.Done()
+		return
+	}
+
+	mock.Update(map[string]interface{}{"car": 2, "frc": 2})
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// the initial value and the change
+	pending := map[string]int{"car": 2, "frc": 2}
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			log.Fatal("changes were not published: ", ctx.Err())
+		case topic := <-published:
+			fmt.Println("published", topic)
+			key := strings.TrimSuffix(topic, "/state")
+			key = key[strings.LastIndex(key, "/")+1:]
+			if _, ok := pending[key]; !ok {
+				continue
+			}
+			if pending[key]--; pending[key] == 0 {
+				delete(pending, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// surplus charges with the excess power of a PV system. The grid meter is
+// mocked: it replays a feed-in curve instead of reading a real meter. Without
+// WATTPILOT_HOST it runs against a mock charger and exits once the
+// controller followed the surplus, so it doubles as smoke test.
+
+const (
+	voltage    = 230
+	phases     = 3
+	minCurrent = 6
+	maxCurrent = 16
+	timeout    = 10 * time.Second
+)
+
+var status = map[string]interface{}{
+	"car": 2,
+	"amp": 6,
+	"frc": 0,
+	"alw": true,
+	"nrg": []interface{}{230, 230, 230, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+}
+
+// Meter reports the power at the grid connection in W, negative values
+// are fed into the grid
+type Meter interface {
+	GridPower() float64
+}
+
+// mockMeter replays the readings and repeats the last one
+type mockMeter struct {
+	mu       sync.Mutex
+	readings []float64
+}
+
+func (m *mockMeter) GridPower() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	power := m.readings[0]
+	if len(m.readings) > 1 {
+		m.readings = m.readings[1:]
+	}
+	return power
+}
+
+// surplusController sets the current to the power fed into the grid plus
+// the power already used for charging, and pauses below the minimum
+type surplusController struct {
+	meter Meter
+}
+
+func (c *surplusController) Name() string {
+	return "surplus"
+}
+
+func (c *surplusController) Interval() time.Duration {
+	return time.Second
+}
+
+func (c *surplusController) Evaluate(ctx context.Context, snapshot api.Snapshot) []api.Action {
+	charging, _ := snapshot.Float("power")
+	surplus := charging - c.meter.GridPower()
+	current := math.Floor(surplus / (voltage * phases))
+	if current < minCurrent {
+		return []api.Action{{Key: "frc", Value: int(api.ForceOff), Reason: fmt.Sprintf("surplus %.0f W", surplus)}}
+	}
+	return []api.Action{
+		{Key: "amp", Value: math.Min(current, maxCurrent), Reason: fmt.Sprintf("surplus %.0f W", surplus)},
+		{Key: "frc", Value: int(api.ForceNeutral), Reason: "surplus available"},
+	}
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+
+	var mock *wattpilottest.MockCharger
+	if host == "" {
+		var err error
+		pwd = "wattpilot-example"
+		if mock, err = wattpilottest.NewMockCharger("90000002", pwd, status); err != nil {
+			log.Fatal(err)
+		}
+		defer mock.Close()
+		host = mock.Addr()
+	}
+
+	charger := api.New(host, pwd)
+	defer charger.Close()
+	if err := charger.Connect(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	currents := charger.GetNotifications("amp")
+	meter := &mockMeter{readings: []float64{-2000, -7000, -9000, -12000}}
+	go charger.RunControllers(ctx, &surplusController{meter: meter})
+
+	if mock != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if mock != nil {
+				log.Fatal("controller did not follow the surplus: ", ctx.Err())
+			}
+			return
+		case current := <-currents:
+			fmt.Println("charging with", current, "A")
+			if mock != nil && current == float64(maxCurrent) {
+				return
+			}
+		}
+	}
+}
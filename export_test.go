@@ -0,0 +1,11 @@
+package wattpilot
+
+// DisconnectForTest and ReconnectForTest export the connection handling to
+// the tests of the wattpilot_test package
+func (w *Wattpilot) DisconnectForTest() {
+	w.disconnectImpl()
+}
+
+func (w *Wattpilot) ReconnectForTest() <-chan struct{} {
+	return w.reconnect(nil, 0)
+}
@@ -0,0 +1,380 @@
+package wattpilot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// Expression is a compiled condition or calculation over a status snapshot,
+// e.g. `car == 2 && power > 2.0 && hour >= 22`. Identifiers are property
+// keys, aliases or virtual properties and the time fields hour, minute and
+// weekday (0 = sunday) of the snapshot. Supported are number, string and
+// boolean literals, null, parentheses, ! - + * / and the comparison and
+// logical operators.
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+var exprOperators = map[string]bool{
+	"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true,
+	"<": true, ">": true, "!": true, "+": true, "-": true, "*": true, "/": true,
+	"(": true, ")": true,
+}
+
+type exprNode func(s Snapshot) (interface{}, error)
+
+type exprToken struct {
+	kind  string // num, str, ident, op, eof
+	text  string
+	value interface{}
+}
+
+// CompileExpression parses the expression
+func CompileExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q in expression", p.peek().text)
+	}
+	return &Expression{source: source, root: root}, nil
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (e *Expression) Eval(s Snapshot) (interface{}, error) {
+	return e.root(s)
+}
+
+// EvalBool evaluates the expression as a condition
+func (e *Expression) EvalBool(s Snapshot) (bool, error) {
+	v, err := e.root(s)
+	if err != nil {
+		return false, err
+	}
+	return toBool(v)
+}
+
+func tokenize(source string) ([]exprToken, error) {
+	tokens := []exprToken{}
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", text)
+			}
+			tokens = append(tokens, exprToken{kind: "num", text: text, value: value})
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("unterminated string in expression")
+			}
+			text := string(runes[i+1 : end])
+			tokens = append(tokens, exprToken{kind: "str", text: text, value: text})
+			i = end + 1
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: string(runes[start:i])})
+		default:
+			op := string(r)
+			if i+1 < len(runes) && exprOperators[string(runes[i:i+2])] {
+				op = string(runes[i : i+2])
+			}
+			if !exprOperators[op] {
+				return nil, fmt.Errorf("unexpected %q in expression", op)
+			}
+			tokens = append(tokens, exprToken{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{kind: "eof"}), nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode(left, right, true)
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode(left, right, false)
+	}
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return binaryNode(op, left, right), nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	op, ok := p.accept("!", "-")
+	if !ok {
+		return p.parsePrimary()
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return func(s Snapshot) (interface{}, error) {
+		v, err := operand(s)
+		if err != nil {
+			return nil, err
+		}
+		if op == "!" {
+			b, err := toBool(v)
+			return !b, err
+		}
+		f, err := toFloat(v)
+		return -f, err
+	}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case "num", "str":
+		p.pos++
+		value := t.value
+		return func(Snapshot) (interface{}, error) { return value, nil }, nil
+	case "ident":
+		p.pos++
+		return identNode(t.text), nil
+	}
+	if _, ok := p.accept("("); ok {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, errors.New("missing ) in expression")
+		}
+		return node, nil
+	}
+	if t.kind == "eof" {
+		return nil, errors.New("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q in expression", t.text)
+}
+
+func identNode(name string) exprNode {
+	switch name {
+	case "true", "false":
+		value := name == "true"
+		return func(Snapshot) (interface{}, error) { return value, nil }
+	case "null":
+		return func(Snapshot) (interface{}, error) { return nil, nil }
+	case "hour":
+		return func(s Snapshot) (interface{}, error) { return float64(s.Time.Hour()), nil }
+	case "minute":
+		return func(s Snapshot) (interface{}, error) { return float64(s.Time.Minute()), nil }
+	case "weekday":
+		return func(s Snapshot) (interface{}, error) { return float64(s.Time.Weekday()), nil }
+	}
+	return func(s Snapshot) (interface{}, error) {
+		value, isKnown := s.Get(name)
+		if !isKnown {
+			return nil, fmt.Errorf("could not find value of %s: %w", name, ErrPropertyNotFound)
+		}
+		return value, nil
+	}
+}
+
+func logicalNode(left exprNode, right exprNode, or bool) exprNode {
+	return func(s Snapshot) (interface{}, error) {
+		l, err := left(s)
+		if err != nil {
+			return nil, err
+		}
+		lb, err := toBool(l)
+		if err != nil {
+			return nil, err
+		}
+		if lb == or {
+			return lb, nil
+		}
+		r, err := right(s)
+		if err != nil {
+			return nil, err
+		}
+		return toBool(r)
+	}
+}
+
+func binaryNode(op string, left exprNode, right exprNode) exprNode {
+	return func(s Snapshot) (interface{}, error) {
+		l, err := left(s)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(s)
+		if err != nil {
+			return nil, err
+		}
+		if op == "==" || op == "!=" {
+			equal := exprEqual(l, r)
+			return equal == (op == "=="), nil
+		}
+		lf, err := toFloat(l)
+		if err != nil {
+			return nil, err
+		}
+		rf, err := toFloat(r)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "<":
+			return lf < rf, nil
+		case "<=":
+			return lf <= rf, nil
+		case ">":
+			return lf > rf, nil
+		case ">=":
+			return lf >= rf, nil
+		case "+":
+			return lf + rf, nil
+		case "-":
+			return lf - rf, nil
+		case "*":
+			return lf * rf, nil
+		}
+		if rf == 0 {
+			return nil, errors.New("division by zero in expression")
+		}
+		return lf / rf, nil
+	}
+}
+
+// exprEqual compares numerically where possible, so 2 == "2" as the
+// post-processed properties are strings
+func exprEqual(l interface{}, r interface{}) bool {
+	if l == nil || r == nil {
+		return l == r
+	}
+	lf, lerr := toFloat(l)
+	rf, rerr := toFloat(r)
+	if lerr == nil && rerr == nil {
+		return lf == rf
+	}
+	return fmt.Sprint(l) == fmt.Sprint(r)
+}
@@ -0,0 +1,125 @@
+package wattpilot_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func snapshot() wattpilot.Snapshot {
+	return wattpilot.Snapshot{
+		// a saturday
+		Time:   time.Date(2024, 1, 6, 22, 30, 0, 0, time.UTC),
+		Status: map[string]interface{}{"car": 2.0, "amp": 16.0, "fna": "garage", "alw": true, "trx": nil},
+	}
+}
+
+func TestExpressionEval(t *testing.T) {
+	tests := []struct {
+		source string
+		value  interface{}
+	}{
+		// precedence
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"8 / 4 / 2", 1.0},
+		{"10 - 4 - 3", 3.0},
+		{"false && true || true", true},
+		{"true || false && false", true},
+		// unary minus and not
+		{"-2 * 3", -6.0},
+		{"- -2", 2.0},
+		{"2 - -2", 4.0},
+		{"-(1 + 2)", -3.0},
+		{"!false && !(1 > 2)", true},
+		{"!!alw", true},
+		// comparisons
+		{"amp >= 16", true},
+		{"amp > 16", false},
+		{"amp <= 16 && amp < 17", true},
+		{"car != 1", true},
+		{"car == \"2\"", true},
+		{"fna == 'garage'", true},
+		{"trx == null", true},
+		{"fna != null", true},
+		// identifiers of the snapshot time
+		{"hour >= 22 && minute == 30 && weekday == 6", true},
+		// the right side is not evaluated once the result is known
+		{"car == 1 && unknown > 0", false},
+		{"car == 2 || unknown > 0", true},
+	}
+	for _, test := range tests {
+		e, err := wattpilot.CompileExpression(test.source)
+		if err != nil {
+			t.Errorf("%s: %v", test.source, err)
+			continue
+		}
+		value, err := e.Eval(snapshot())
+		if err != nil || value != test.value {
+			t.Errorf("%s: got %v %v, want %v", test.source, value, err, test.value)
+		}
+	}
+}
+
+func TestExpressionUnknownIdentifier(t *testing.T) {
+	e, err := wattpilot.CompileExpression("unknown > 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.EvalBool(snapshot()); !errors.Is(err, wattpilot.ErrPropertyNotFound) {
+		t.Fatal("expected ErrPropertyNotFound, got ", err)
+	}
+}
+
+func TestExpressionEvalErrors(t *testing.T) {
+	for _, source := range []string{"1 / 0", "fna > 1", "-fna", "fna && true"} {
+		e, err := wattpilot.CompileExpression(source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+			continue
+		}
+		if _, err := e.EvalBool(snapshot()); err == nil {
+			t.Errorf("%s: no error", source)
+		}
+	}
+}
+
+func TestExpressionMalformed(t *testing.T) {
+	for _, source := range []string{
+		"", " ", "1 +", "* 2", "(1 + 2", "1 + 2)", "()", "1 2", "car ==", "== 2",
+		"1.2.3", ".", "'open", "\"open", "a & b", "a | b", "a = 1", "#", "1 < 2 < 3", "1 < 2 == true",
+	} {
+		if _, err := wattpilot.CompileExpression(source); err == nil {
+			t.Errorf("%q: compiled", source)
+		}
+	}
+}
+
+// FuzzExpression checks that expressions loaded from user configuration
+// never panic the parser or the evaluation
+func FuzzExpression(f *testing.F) {
+	seeds := []string{
+		"car == 2 && power > 2.0 && hour >= 22",
+		"-(amp + 1) * 2 / 3 != 'x'",
+		"!(alw || trx == null)",
+		"((((1))))",
+		"1..2",
+		"'unterminated",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, source string) {
+		e, err := wattpilot.CompileExpression(source)
+		if err != nil {
+			return
+		}
+		if e.String() != source {
+			t.Fatalf("%q: source not kept", source)
+		}
+		_, _ = e.Eval(snapshot())
+		_, _ = e.EvalBool(snapshot())
+	})
+}
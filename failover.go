@@ -0,0 +1,168 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	CLOUD_URL           = "wss://app.wattpilot.io/app/%s?version=1.2.9"
+	LOCAL_PROBE_TIMEOUT = 2 // seconds
+)
+
+type ConnectionPath string
+
+const (
+	PathLocal ConnectionPath = "local"
+	PathCloud ConnectionPath = "cloud"
+)
+
+// FailoverPolicy controls switching between the local LAN connection and the
+// cloud connection when both are configured.
+type FailoverPolicy struct {
+	// MaxLocalFailures is the number of consecutive local connection failures
+	// before falling back to the cloud.
+	MaxLocalFailures int
+	// FailbackInterval is the delay between checks whether the local charger
+	// is reachable again while connected through the cloud.
+	FailbackInterval time.Duration
+}
+
+func DefaultFailoverPolicy() FailoverPolicy {
+	return FailoverPolicy{
+		MaxLocalFailures: 3,
+		FailbackInterval: 5 * time.Minute,
+	}
+}
+
+// WithCloud enables the cloud connection for the charger with the given
+// serial. Without a local host the cloud is used exclusively.
+func WithCloud(serial string) Option {
+	return func(w *Wattpilot) {
+		w._cloudSerial = serial
+		if w._host == "" {
+			w._path = PathCloud
+		}
+	}
+}
+
+// WithFailover enables the automatic failover between local and cloud
+// connection, it requires WithCloud.
+func WithFailover(policy FailoverPolicy) Option {
+	return func(w *Wattpilot) {
+		w._failover = &policy
+	}
+}
+
+func (w *Wattpilot) GetConnectionPath() ConnectionPath {
+	w._pathMutex.Lock()
+	defer w._pathMutex.Unlock()
+
+	return w._path
+}
+
+func (w *Wattpilot) connectionURL() string {
+	if w.GetConnectionPath() == PathCloud {
+		return fmt.Sprintf(CLOUD_URL, w._cloudSerial)
+	}
+	return fmt.Sprintf("ws://%s/ws", w._host)
+}
+
+func (w *Wattpilot) canFailover() bool {
+	return w._failover != nil && w._cloudSerial != "" && w._host != ""
+}
+
+func (w *Wattpilot) switchPath(path ConnectionPath) {
+	w._pathMutex.Lock()
+	previous := w._path
+	w._path = path
+	w._pathSince = w._clock.Now()
+	w._localFailures = 0
+	w._pathMutex.Unlock()
+
+	if previous == path {
+		return
+	}
+	w.logEntry().Info("Switching connection from ", previous, " to ", path)
+	w.emitEvent(EventConnectionPathChanged, map[string]interface{}{
+		"path":     path,
+		"previous": previous,
+	})
+}
+
+// onConnectFailure registers a failed connection attempt and reports
+// whether the connection path has been switched to the cloud.
+func (w *Wattpilot) onConnectFailure() bool {
+	if !w.canFailover() || w.GetConnectionPath() != PathLocal {
+		return false
+	}
+	w._pathMutex.Lock()
+	w._localFailures++
+	failures := w._localFailures
+	w._pathMutex.Unlock()
+
+	w.logEntry().Debug("Local connection failures: ", failures)
+	if failures < w._failover.MaxLocalFailures {
+		return false
+	}
+	w.switchPath(PathCloud)
+	return true
+}
+
+func (w *Wattpilot) onConnectSuccess() {
+	w._pathMutex.Lock()
+	defer w._pathMutex.Unlock()
+
+	w._localFailures = 0
+}
+
+// runFailback checks for the local charger in the failback interval, in a
+// single goroutine for the lifetime of the instance
+func (w *Wattpilot) runFailback(ctx context.Context) {
+	timer := w._clock.NewTimer(w._failover.FailbackInterval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+		}
+		w.checkFailback()
+		timer.Reset(w._failover.FailbackInterval)
+	}
+}
+
+// checkFailback probes the local charger while connected through the cloud
+// and switches back as soon as it is reachable again.
+func (w *Wattpilot) checkFailback() {
+	if !w.canFailover() || w.GetConnectionPath() != PathCloud {
+		return
+	}
+	w._pathMutex.Lock()
+	since := w._pathSince
+	w._pathMutex.Unlock()
+	if w._clock.Now().Sub(since) < w._failover.FailbackInterval {
+		return
+	}
+
+	address := w._host
+	if !strings.Contains(address, ":") {
+		address = address + ":80"
+	}
+	conn, err := net.DialTimeout("tcp", address, time.Second*LOCAL_PROBE_TIMEOUT)
+	if err != nil {
+		w.logEntry().Trace("Local connection still unavailable: ", err)
+		w._pathMutex.Lock()
+		w._pathSince = w._clock.Now()
+		w._pathMutex.Unlock()
+		return
+	}
+	conn.Close()
+
+	// the reconnect closes the cloud connection and connects locally
+	w.switchPath(PathLocal)
+	w.reconnect(nil, time.Second*RECONNECT_TIMEOUT)
+}
@@ -0,0 +1,137 @@
+package wattpilot
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EventGroundFault     EventType = "groundFault"
+	EventOverTemperature EventType = "overTemperature"
+	EventFaultCleared    EventType = "faultCleared"
+)
+
+// FaultCode is the error state err of the charger
+type FaultCode int
+
+const (
+	FaultNone FaultCode = iota
+	FaultResidualAC
+	FaultResidualDC
+	FaultPhase
+	FaultOvervoltage
+	FaultOvercurrent
+	FaultDiode
+	FaultPilotInvalid
+	FaultGroundInvalid
+	FaultContactorStuck
+	FaultContactorMissing
+	FaultResidualUnknown
+	FaultUnknown
+	FaultOvertemperature
+	FaultNoCommunication
+	FaultLockStuckOpen
+	FaultLockStuckLocked
+)
+
+type Severity string
+
+const (
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Fault is an active fault of the charger with a recommended action
+type Fault struct {
+	Code     FaultCode `json:"code"`
+	Name     string    `json:"name"`
+	Severity Severity  `json:"severity"`
+	Action   string    `json:"action"`
+	Since    time.Time `json:"since"`
+}
+
+type faultInfo struct {
+	name   string
+	event  EventType
+	action string
+}
+
+// faults lists the codes surfaced with dedicated events, all of them are
+// critical as charging stops until they are resolved
+var faults = map[FaultCode]faultInfo{
+	FaultResidualAC: {"residual AC current", EventGroundFault,
+		"Unplug the car and have the car and the installation checked for insulation faults before charging again"},
+	FaultResidualDC: {"residual DC current", EventGroundFault,
+		"Unplug the car, the DC leakage usually originates in the on-board charger of the car, have it checked"},
+	FaultResidualUnknown: {"residual current device tripped", EventGroundFault,
+		"Unplug the car and have an electrician check the installation before charging again"},
+	FaultGroundInvalid: {"no valid ground connection", EventGroundFault,
+		"Have an electrician check the earthing of the supply, in IT grids disable the earth check with SetEarthCheckDisabled"},
+	FaultOvertemperature: {"over temperature", EventOverTemperature,
+		"Let the charger cool down, check its ventilation, direct sunlight and the tightness of the supply terminals"},
+}
+
+type faultTracker struct {
+	mu     sync.Mutex
+	active *Fault
+}
+
+// ActiveFault returns the fault surfaced by a dedicated event which is not
+// cleared yet
+func (w *Wattpilot) ActiveFault() (Fault, bool) {
+	w._faults.mu.Lock()
+	defer w._faults.mu.Unlock()
+
+	if w._faults.active == nil {
+		return Fault{}, false
+	}
+	return *w._faults.active, true
+}
+
+// trackFaults is called with the read mutex held for every status update
+func (w *Wattpilot) trackFaults(updates map[string]interface{}) {
+	v, ok := updates["err"]
+	if !ok {
+		return
+	}
+	code := FaultNone
+	if v != nil {
+		f, err := toFloat(v)
+		if err != nil {
+			return
+		}
+		code = FaultCode(f)
+	}
+	info, isSurfaced := faults[code]
+
+	t := w._faults
+	t.mu.Lock()
+	previous := t.active
+	if previous != nil && previous.Code == code {
+		t.mu.Unlock()
+		return
+	}
+	t.active = nil
+	if isSurfaced {
+		t.active = &Fault{
+			Code:     code,
+			Name:     info.name,
+			Severity: SeverityCritical,
+			Action:   info.action,
+			Since:    w._clock.Now(),
+		}
+	}
+	current := t.active
+	t.mu.Unlock()
+
+	if previous != nil {
+		w.emitEvent(EventFaultCleared, map[string]interface{}{"fault": *previous})
+	}
+	if current != nil {
+		w.logEntry().Error("Charger fault ", current.Name, ": ", current.Action)
+		w.emitEvent(info.event, map[string]interface{}{
+			"fault":    *current,
+			"severity": current.Severity,
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package wattpilot
+
+import "time"
+
+const fleetTopic = "fleet"
+
+type FleetEventKind string
+
+const (
+	FleetPropertyChanged FleetEventKind = "property"
+	FleetDeviceEvent     FleetEventKind = "event"
+	FleetDecision        FleetEventKind = "decision"
+)
+
+// FleetEvent is published on the bus of the Manager with the identity of
+// the device. Key is the property, the event type or the source of the
+// decision, Value is the property value, the Event or the Decision.
+type FleetEvent struct {
+	Kind   FleetEventKind
+	Serial string
+	Host   string
+	Name   string
+	Time   time.Time
+	Key    string
+	Value  interface{}
+}
+
+// GetNotifications subscribes to the property changes, events and
+// decisions of all managed devices, the channel delivers FleetEvent values
+func (m *Manager) GetNotifications() <-chan interface{} {
+	return m.bus.Subscribe(fleetTopic)
+}
+
+// SetNotificationBuffer sets the number of events queued per subscriber
+// before further events are dropped for it
+func (m *Manager) SetNotificationBuffer(size int) {
+	m.bus.SetBufferSize(size)
+}
+
+func (m *Manager) Unsubscribe(ch <-chan interface{}) {
+	m.bus.Unsubscribe(fleetTopic, ch)
+}
+
+// forward publishes on the bus of the manager the device belongs to
+func (w *Wattpilot) forward(kind FleetEventKind, key string, value interface{}) {
+	m := w._fleet.Load()
+	if m == nil || !m.bus.hasSubscribers(fleetTopic) {
+		return
+	}
+	m.bus.Publish(fleetTopic, FleetEvent{
+		Kind:   kind,
+		Serial: w.GetSerial(),
+		Host:   w._host,
+		Name:   w.GetName(),
+		Time:   w._clock.Now(),
+		Key:    key,
+		Value:  value,
+	})
+}
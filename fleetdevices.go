@@ -0,0 +1,149 @@
+package wattpilot
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrNoSerial = errors.New("the cloud connection requires the serial of the charger")
+
+// DeviceConfig describes a managed charger reachable locally, through the
+// cloud or both. Each device carries its own password, so chargers of
+// several owners can be managed together.
+type DeviceConfig struct {
+	Host     string
+	Serial   string
+	Password string
+	Cloud    bool
+	Tags     []string
+	Options  []Option
+}
+
+// merge combines the configurations of the same charger, the local
+// password wins as the cloud relay passes the authentication through
+func (c DeviceConfig) merge(other DeviceConfig) DeviceConfig {
+	merged := c
+	if merged.Host == "" {
+		merged.Host = other.Host
+		if other.Password != "" {
+			merged.Password = other.Password
+		}
+	}
+	if merged.Serial == "" {
+		merged.Serial = other.Serial
+	}
+	if merged.Password == "" {
+		merged.Password = other.Password
+	}
+	merged.Cloud = c.Cloud || other.Cloud
+	merged.Tags = append(append([]string{}, c.Tags...), other.Tags...)
+	merged.Options = append(append([]Option{}, c.Options...), other.Options...)
+	return merged
+}
+
+func (c DeviceConfig) build() *Wattpilot {
+	options := []Option{}
+	if c.Cloud {
+		options = append(options, WithCloud(c.Serial))
+		if c.Host != "" {
+			options = append(options, WithFailover(DefaultFailoverPolicy()))
+		}
+	}
+	return New(c.Host, c.Password, append(options, c.Options...)...)
+}
+
+// AddDevice creates and manages the charger of the configuration. A
+// charger already managed with the same serial, e.g. once locally and once
+// through the cloud, is replaced by a single device using the local
+// connection with failover to the cloud.
+func (m *Manager) AddDevice(config DeviceConfig) (*Wattpilot, error) {
+	if config.Cloud && config.Serial == "" {
+		return nil, ErrNoSerial
+	}
+	if config.Serial != "" {
+		if existing, isManaged := m.deviceBySerial(config.Serial); isManaged {
+			return m.replace(config.merge(m.config(existing)), existing), nil
+		}
+	}
+	w := config.build()
+	m.Add(w, config.Tags...)
+	m.mu.Lock()
+	m.configs[w] = config
+	m.mu.Unlock()
+	return w, nil
+}
+
+func (m *Manager) config(w *Wattpilot) DeviceConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.configs[w]
+}
+
+// deviceBySerial finds devices added with a configuration by the serial
+// configured or reported by the charger
+func (m *Manager) deviceBySerial(serial string) (*Wattpilot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for w, config := range m.configs {
+		if config.Serial == serial || w.GetSerial() == serial {
+			return w, true
+		}
+	}
+	return nil, false
+}
+
+// replace closes the devices and manages a device built from the config
+func (m *Manager) replace(config DeviceConfig, devices ...*Wattpilot) *Wattpilot {
+	for _, w := range devices {
+		config.Tags = append(config.Tags, m.Tags(w)...)
+		m.Remove(w)
+		w.Close()
+	}
+	tags := []string{}
+	for _, tag := range config.Tags {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	config.Tags = tags
+
+	w := config.build()
+	m.Add(w, config.Tags...)
+	m.mu.Lock()
+	m.configs[w] = config
+	m.mu.Unlock()
+	w.logEntry().Info("Merged the local and cloud connection of ", config.Serial)
+	return w
+}
+
+// deduplicate merges devices whose serial became known on connect with
+// another device of the same serial, it returns the merged devices
+func (m *Manager) deduplicate() []*Wattpilot {
+	m.mu.RLock()
+	bySerial := make(map[string][]*Wattpilot)
+	for w, config := range m.configs {
+		serial := config.Serial
+		if serial == "" {
+			serial = w.GetSerial()
+		}
+		if serial != "" {
+			bySerial[serial] = append(bySerial[serial], w)
+		}
+	}
+	m.mu.RUnlock()
+
+	merged := []*Wattpilot{}
+	for serial, devices := range bySerial {
+		if len(devices) < 2 {
+			continue
+		}
+		config := DeviceConfig{Serial: serial}
+		for _, w := range devices {
+			config = config.merge(m.config(w))
+		}
+		merged = append(merged, m.replace(config, devices...))
+	}
+	return merged
+}
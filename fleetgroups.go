@@ -0,0 +1,118 @@
+package wattpilot
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// Tag adds the device to the groups, e.g. "garage-left" or "visitor"
+func (m *Manager) Tag(w *Wattpilot, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		if !m.hasTag(w, tag) {
+			m.tags[w] = append(m.tags[w], tag)
+		}
+	}
+}
+
+func (m *Manager) Untag(w *Wattpilot, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, tag := range tags {
+		m.tags[w] = remove(m.tags[w], tag)
+	}
+}
+
+// Tags returns the sorted groups of the device
+func (m *Manager) Tags(w *Wattpilot) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := append([]string{}, m.tags[w]...)
+	sort.Strings(tags)
+	return tags
+}
+
+// Group returns the devices tagged with the group, the empty tag is the
+// whole fleet
+func (m *Manager) Group(tag string) []*Wattpilot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.group(tag)
+}
+
+func (m *Manager) group(tag string) []*Wattpilot {
+	if tag == "" {
+		return append([]*Wattpilot{}, m.devices...)
+	}
+	members := []*Wattpilot{}
+	for _, w := range m.devices {
+		if m.hasTag(w, tag) {
+			members = append(members, w)
+		}
+	}
+	return members
+}
+
+func (m *Manager) hasTag(w *Wattpilot, tag string) bool {
+	for _, other := range m.tags[w] {
+		if other == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ForEachInGroup is ForEach restricted to the members of the group
+func (m *Manager) ForEachInGroup(ctx context.Context, tag string, fn func(ctx context.Context, w *Wattpilot) error) error {
+	return m.forEach(ctx, m.Group(tag), fn)
+}
+
+// SetGroupLimit limits the summed charging current of the group in
+// ampere, zero removes the limit. The limit of the empty tag is the limit
+// of the site.
+func (m *Manager) SetGroupLimit(tag string, current float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if current <= 0 {
+		delete(m.limits, tag)
+		return
+	}
+	m.limits[tag] = current
+}
+
+func (m *Manager) GroupLimit(tag string) (float64, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limit, isLimited := m.limits[tag]
+	return limit, isLimited
+}
+
+// currentCap is the share of the device of the tightest group limit
+func (m *Manager) currentCap(w *Wattpilot) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	max := math.Inf(1)
+	for _, tag := range append([]string{""}, m.tags[w]...) {
+		if limit, isLimited := m.limits[tag]; isLimited {
+			max = math.Min(max, limit/float64(len(m.group(tag))))
+		}
+	}
+	return max
+}
+
+// SetCurrentGroup sets the charging current of all members of the group,
+// capped to their share of the group limits
+func (m *Manager) SetCurrentGroup(ctx context.Context, tag string, current float64) error {
+	return m.ForEachInGroup(ctx, tag, func(ctx context.Context, w *Wattpilot) error {
+		return w.SetCurrent(math.Floor(math.Min(current, m.currentCap(w))))
+	})
+}
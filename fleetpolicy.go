@@ -0,0 +1,143 @@
+package wattpilot
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FirstComeFirstServed charges the cars in the order they were plugged in,
+// each with the maximal current the remaining limit allows. A car waits
+// until the cars before it are done, so no charger is preempted.
+type FirstComeFirstServed struct{}
+
+func (FirstComeFirstServed) Name() string {
+	return "firstComeFirstServed"
+}
+
+func (FirstComeFirstServed) Allocate(now time.Time, limit float64, demands []Demand) []float64 {
+	order := make([]int, len(demands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return demands[order[i]].Since.Before(demands[order[j]].Since)
+	})
+	return allocateInOrder(limit, order)
+}
+
+// Priority charges the cars by the static priority of their charger, cars
+// of equal priority in the order they were plugged in. Without Preempt a
+// charger keeps its current until its car is done, with Preempt a charger
+// of higher priority takes over once the served charger has been charging
+// for MinRuntime.
+type Priority struct {
+	Priorities map[string]int // by serial
+	Preempt    bool
+	MinRuntime time.Duration
+
+	mu     sync.Mutex
+	served map[string]time.Time
+}
+
+func NewPriority(priorities map[string]int, preempt bool) *Priority {
+	return &Priority{Priorities: priorities, Preempt: preempt, served: make(map[string]time.Time)}
+}
+
+func (p *Priority) Name() string {
+	return "priority"
+}
+
+func (p *Priority) Allocate(now time.Time, limit float64, demands []Demand) []float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.served == nil {
+		p.served = make(map[string]time.Time)
+	}
+	// served chargers which may not be preempted go first
+	locked := func(d Demand) bool {
+		since, isServed := p.served[d.Serial]
+		return isServed && (!p.Preempt || now.Sub(since) < p.MinRuntime)
+	}
+	order := make([]int, len(demands))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := demands[order[i]], demands[order[j]]
+		if locked(a) != locked(b) {
+			return locked(a)
+		}
+		if p.Priorities[a.Serial] != p.Priorities[b.Serial] {
+			return p.Priorities[a.Serial] > p.Priorities[b.Serial]
+		}
+		return a.Since.Before(b.Since)
+	})
+
+	currents := allocateInOrder(limit, order)
+	served := make(map[string]time.Time)
+	for i, current := range currents {
+		if current <= 0 {
+			continue
+		}
+		since, isServed := p.served[demands[i].Serial]
+		if !isServed {
+			since = now
+		}
+		served[demands[i].Serial] = since
+	}
+	p.served = served
+	return currents
+}
+
+// allocateInOrder hands out the maximal current to the demands in order
+// until the limit does not cover the minimal current anymore
+func allocateInOrder(limit float64, order []int) []float64 {
+	currents := make([]float64, len(order))
+	for _, i := range order {
+		current := math.Min(FLEET_MAX_CURRENT, math.Floor(limit))
+		if current < FLEET_MIN_CURRENT {
+			break
+		}
+		currents[i] = current
+		limit -= current
+	}
+	return currents
+}
+
+// SetGroupPolicy selects the policy RunSchedulers uses for the group, nil
+// removes it
+func (m *Manager) SetGroupPolicy(tag string, policy FleetPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if policy == nil {
+		delete(m.policies, tag)
+		return
+	}
+	m.policies[tag] = policy
+}
+
+// RunSchedulers runs a scheduler for every group with a policy until the
+// context ends
+func (m *Manager) RunSchedulers(ctx context.Context, interval time.Duration) {
+	m.mu.RLock()
+	policies := make(map[string]FleetPolicy, len(m.policies))
+	for tag, policy := range m.policies {
+		policies[tag] = policy
+	}
+	m.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for tag, policy := range policies {
+		wg.Add(1)
+		go func(tag string, policy FleetPolicy) {
+			defer wg.Done()
+			m.RunScheduler(ctx, tag, policy, interval)
+		}(tag, policy)
+	}
+	wg.Wait()
+}
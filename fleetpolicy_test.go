@@ -0,0 +1,103 @@
+package wattpilot_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+var plugged = time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+
+// demands returns demands of the chargers A, B, C... plugged in the order
+// of the minutes given
+func demands(minutes ...int) []wattpilot.Demand {
+	result := make([]wattpilot.Demand, len(minutes))
+	for i, minute := range minutes {
+		result[i] = wattpilot.Demand{Serial: string(rune('A' + i)), Since: plugged.Add(time.Duration(minute) * time.Minute), Remaining: -1}
+	}
+	return result
+}
+
+func checkAllocation(t *testing.T, name string, limit float64, currents []float64, want []float64) {
+	t.Helper()
+	if !reflect.DeepEqual(currents, want) {
+		t.Errorf("%s: got %v, want %v", name, currents, want)
+	}
+	sum := 0.0
+	for _, current := range currents {
+		if current != 0 && current < wattpilot.FLEET_MIN_CURRENT {
+			t.Errorf("%s: current %v below the minimum", name, current)
+		}
+		sum += current
+	}
+	if sum > limit {
+		t.Errorf("%s: allocated %v above the limit %v", name, sum, limit)
+	}
+}
+
+func TestFirstComeFirstServed(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    float64
+		minutes  []int
+		currents []float64
+	}{
+		{"limit below the minimum", 5.5, []int{0, 1}, []float64{0, 0}},
+		{"first takes the maximum", 40, []int{0, 1}, []float64{32, 8}},
+		{"rest below the minimum", 36, []int{0, 1}, []float64{32, 0}},
+		{"by plug-in time", 40, []int{5, 0, 10}, []float64{8, 32, 0}},
+		{"fractional limit", 20.7, []int{0}, []float64{20}},
+		{"no demands", 32, []int{}, []float64{}},
+	}
+	for _, test := range tests {
+		currents := wattpilot.FirstComeFirstServed{}.Allocate(plugged, test.limit, demands(test.minutes...))
+		checkAllocation(t, test.name, test.limit, currents, test.currents)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	priorities := map[string]int{"A": 1, "B": 2, "C": 1}
+	tests := []struct {
+		name     string
+		limit    float64
+		minutes  []int
+		currents []float64
+	}{
+		{"limit below the minimum", 4, []int{0, 1, 2}, []float64{0, 0, 0}},
+		{"highest priority first", 40, []int{0, 1, 2}, []float64{8, 32, 0}},
+		{"equal priorities by plug-in time", 46, []int{5, 1, 0}, []float64{0, 32, 14}},
+		{"all served", 96, []int{0, 1, 2}, []float64{32, 32, 32}},
+	}
+	for _, test := range tests {
+		for _, preempt := range []bool{false, true} {
+			policy := wattpilot.NewPriority(priorities, preempt)
+			currents := policy.Allocate(plugged, test.limit, demands(test.minutes...))
+			checkAllocation(t, test.name, test.limit, currents, test.currents)
+		}
+	}
+}
+
+func TestPriorityPreemption(t *testing.T) {
+	priorities := map[string]int{"A": 1, "B": 2}
+	tests := []struct {
+		name    string
+		preempt bool
+		// currents at the start, before and after MinRuntime of A
+		currents [3][]float64
+	}{
+		{"without preemption", false, [3][]float64{{32, 0}, {32, 0}, {32, 0}}},
+		{"with preemption", true, [3][]float64{{32, 0}, {32, 0}, {0, 32}}},
+	}
+	for _, test := range tests {
+		policy := wattpilot.NewPriority(priorities, test.preempt)
+		policy.MinRuntime = 10 * time.Minute
+		// A charges alone, then B of higher priority plugs in
+		checkAllocation(t, test.name+", A alone", 32, policy.Allocate(plugged, 32, demands(0)), []float64{32})
+		for i, at := range []time.Duration{time.Minute, 5 * time.Minute, 10 * time.Minute} {
+			currents := policy.Allocate(plugged.Add(at), 32, demands(0, 1))
+			checkAllocation(t, test.name, 32, currents, test.currents[i])
+		}
+	}
+}
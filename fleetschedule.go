@@ -0,0 +1,305 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	FLEET_MIN_CURRENT   = 6  // ampere, the lowest current a charger sets
+	FLEET_MAX_CURRENT   = 32 // ampere
+	FAIR_SHARE_ROTATION = 15 // minutes
+)
+
+const carStateCharging, carStateWaiting = 2, 3
+
+// EventThrottled is emitted on a charger whose current is lowered or which
+// is paused by a fleet policy
+const EventThrottled EventType = "throttled"
+
+// Demand is a charger with a car requesting current
+type Demand struct {
+	Device *Wattpilot
+	Serial string
+	// Since is the time the car started to request current
+	Since time.Time
+	// Remaining is the energy in Wh until the target of the session, or
+	// -1 without target
+	Remaining float64
+}
+
+// FleetPolicy divides the current limit of a group among the demands. The
+// result holds the current of each demand, zero pauses the charger.
+type FleetPolicy interface {
+	Name() string
+	Allocate(now time.Time, limit float64, demands []Demand) []float64
+}
+
+// FairShare splits the limit by the remaining energy of the cars, each car
+// served gets the minimal current and the rest of the limit is shared in
+// proportion to the energy it still needs. Cars without a target count
+// like the car needing the most, so without targets the limit is split
+// equally. If it does not suffice to charge all cars with the minimal
+// current, the cars served rotate.
+type FairShare struct {
+	Rotation time.Duration
+	offset   int
+	rotated  time.Time
+}
+
+func NewFairShare() *FairShare {
+	return &FairShare{Rotation: time.Minute * FAIR_SHARE_ROTATION}
+}
+
+func (f *FairShare) Name() string {
+	return "fairShare"
+}
+
+func (f *FairShare) Allocate(now time.Time, limit float64, demands []Demand) []float64 {
+	currents := make([]float64, len(demands))
+	if len(demands) == 0 {
+		return currents
+	}
+	if now.Sub(f.rotated) >= f.Rotation {
+		f.offset++
+		f.rotated = now
+	}
+	served := int(math.Min(float64(len(demands)), math.Floor(limit/FLEET_MIN_CURRENT)))
+	if served == 0 {
+		return currents
+	}
+	indexes := make([]int, served)
+	for i := range indexes {
+		indexes[i] = (f.offset + i) % len(demands)
+	}
+	shares := weightedShares(limit, remainingWeights(demands, indexes))
+	for i, index := range indexes {
+		currents[index] = math.Floor(shares[i])
+	}
+	return currents
+}
+
+// remainingWeights returns the remaining energy of the demands as weights,
+// demands without a target weigh like the largest remaining energy
+func remainingWeights(demands []Demand, indexes []int) []float64 {
+	largest := 0.0
+	for _, index := range indexes {
+		largest = math.Max(largest, demands[index].Remaining)
+	}
+	if largest <= 0 {
+		largest = 1
+	}
+	weights := make([]float64, len(indexes))
+	for i, index := range indexes {
+		weights[i] = demands[index].Remaining
+		if weights[i] < 0 {
+			weights[i] = largest
+		}
+	}
+	return weights
+}
+
+// weightedShares gives every weight the minimal current and divides the
+// rest of the limit in proportion to the weights. Shares capped at the
+// maximal current pass their excess on to the others.
+func weightedShares(limit float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	open := make([]int, 0, len(weights))
+	for i := range shares {
+		shares[i] = FLEET_MIN_CURRENT
+		open = append(open, i)
+	}
+	rest := limit - FLEET_MIN_CURRENT*float64(len(weights))
+	for rest > 0 && len(open) > 0 {
+		total := 0.0
+		for _, i := range open {
+			total += weights[i]
+		}
+		capped := open[:0:0]
+		next := open[:0:0]
+		distributed := 0.0
+		for _, i := range open {
+			extra := rest / float64(len(open))
+			if total > 0 {
+				extra = rest * weights[i] / total
+			}
+			if shares[i]+extra >= FLEET_MAX_CURRENT {
+				extra = FLEET_MAX_CURRENT - shares[i]
+				capped = append(capped, i)
+			} else {
+				next = append(next, i)
+			}
+			shares[i] += extra
+			distributed += extra
+		}
+		rest -= distributed
+		if len(capped) == 0 {
+			break
+		}
+		open = next
+	}
+	return shares
+}
+
+type fleetScheduler struct {
+	tag       string
+	policy    FleetPolicy
+	since     map[*Wattpilot]time.Time
+	paused    map[*Wattpilot]bool
+	allocated map[*Wattpilot]float64
+}
+
+// RunScheduler divides the limit of the group, see SetGroupLimit, among
+// its chargers with a car requesting current until the context ends.
+// Cars which reached the energy target of their session are skipped.
+func (m *Manager) RunScheduler(ctx context.Context, tag string, policy FleetPolicy, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	s := &fleetScheduler{
+		tag:       tag,
+		policy:    policy,
+		since:     make(map[*Wattpilot]time.Time),
+		paused:    make(map[*Wattpilot]bool),
+		allocated: make(map[*Wattpilot]float64),
+	}
+
+	m.mu.RLock()
+	clock := m.clock
+	m.mu.RUnlock()
+	timer := clock.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(interval)
+			limit, isLimited := m.GroupLimit(tag)
+			if !isLimited {
+				continue
+			}
+			m.schedule(s, clock.Now(), limit)
+		}
+	}
+}
+
+func (m *Manager) schedule(s *fleetScheduler, now time.Time, limit float64) {
+	demands := []Demand{}
+	idle := []*Wattpilot{}
+	for _, w := range m.Group(s.tag) {
+		if !w.IsInitialized() {
+			continue
+		}
+		demand, isRequesting := s.demand(w, now)
+		if !isRequesting {
+			delete(s.since, w)
+			delete(s.allocated, w)
+			idle = append(idle, w)
+			continue
+		}
+		demands = append(demands, demand)
+	}
+	sort.Slice(demands, func(i, j int) bool {
+		return demands[i].Serial < demands[j].Serial
+	})
+
+	currents := s.policy.Allocate(now, limit, demands)
+	for i, demand := range demands {
+		m.allocate(s, demand.Device, currents[i])
+	}
+	// chargers paused by the scheduler are released once the car is done
+	for _, w := range idle {
+		m.release(s, w, "no current requested")
+	}
+}
+
+func (s *fleetScheduler) demand(w *Wattpilot, now time.Time) (Demand, bool) {
+	snapshot := w.Snapshot()
+	state, err := snapshot.Float("car")
+	if err != nil || (int(state) != carStateCharging && int(state) != carStateWaiting) {
+		return Demand{}, false
+	}
+	remaining := -1.0
+	if target, err := snapshot.Float("dwo"); err == nil && target > 0 {
+		charged, _ := snapshot.Float("wh")
+		remaining = math.Max(0, target-charged)
+		if remaining == 0 {
+			return Demand{}, false
+		}
+	}
+	since, isKnown := s.since[w]
+	if !isKnown {
+		since = now
+		s.since[w] = now
+	}
+	return Demand{Device: w, Serial: deviceId(w), Since: since, Remaining: remaining}, true
+}
+
+func (m *Manager) allocate(s *fleetScheduler, w *Wattpilot, current float64) {
+	current = math.Floor(current)
+	if current < FLEET_MIN_CURRENT {
+		current = 0
+	}
+	previous, isKnown := s.allocated[w]
+	if !isKnown {
+		previous, _ = w.Snapshot().Float("amp")
+	}
+	s.allocated[w] = current
+	if current < previous {
+		w.emitEvent(EventThrottled, map[string]interface{}{
+			"policy":   s.policy.Name(),
+			"group":    s.tag,
+			"current":  current,
+			"previous": previous,
+		})
+	}
+
+	if current == 0 {
+		s.paused[w] = true
+		m.setScheduled(s, w, "frc", float64(ForceOff), "no share of the limit")
+		return
+	}
+	reason := fmt.Sprintf("share of the limit of %s", s.name())
+	m.setScheduled(s, w, "amp", current, reason)
+	m.release(s, w, reason)
+}
+
+// release resumes a charger paused by the scheduler, force states set by
+// the user are kept
+func (m *Manager) release(s *fleetScheduler, w *Wattpilot, reason string) {
+	if !s.paused[w] {
+		return
+	}
+	delete(s.paused, w)
+	m.setScheduled(s, w, "frc", float64(ForceNeutral), reason)
+}
+
+func (s *fleetScheduler) name() string {
+	if s.tag == "" {
+		return "the site"
+	}
+	return "group " + s.tag
+}
+
+// setScheduled writes the value if it differs and records the decision
+// on the device
+func (m *Manager) setScheduled(s *fleetScheduler, w *Wattpilot, key string, value float64, reason string) {
+	if current, err := w.Snapshot().Float(key); err == nil && current == value {
+		return
+	}
+	source := "fleet:" + s.policy.Name()
+	err := w.setProperty(source, key, value)
+	w.recordDecision(Decision{
+		Source:   source,
+		Inputs:   map[string]interface{}{"group": s.tag},
+		Setpoint: value,
+		Action:   "set " + key,
+		Reason:   reason,
+		Error:    err,
+	})
+}
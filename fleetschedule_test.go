@@ -0,0 +1,36 @@
+package wattpilot_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func TestFairShareAllocate(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     float64
+		remaining []float64
+		currents  []float64
+	}{
+		{"equal without targets", 32, []float64{-1, -1}, []float64{16, 16}},
+		{"by remaining energy", 32, []float64{1000, 3000}, []float64{11, 21}},
+		{"without target like the largest", 36, []float64{3000, -1, 1000}, []float64{13, 13, 8}},
+		{"excess of capped shares passed on", 60, []float64{100, 10000}, []float64{28, 32}},
+		{"minimal current for all served", 18, []float64{100, 20000, 100}, []float64{6, 6, 6}},
+		{"rotating when short", 12, []float64{-1, -1, -1}, []float64{0, 6, 6}},
+	}
+	for _, test := range tests {
+		demands := make([]wattpilot.Demand, len(test.remaining))
+		for i, remaining := range test.remaining {
+			demands[i] = wattpilot.Demand{Remaining: remaining}
+		}
+		// the first allocation rotates the cars served by one
+		currents := wattpilot.NewFairShare().Allocate(time.Now(), test.limit, demands)
+		if !reflect.DeepEqual(currents, test.currents) {
+			t.Errorf("%s: got %v, want %v", test.name, currents, test.currents)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package wattpilot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formatter renders values for exports following the conventions of a
+// locale, e.g. decimal commas and semicolon separated CSV for german
+// accountants, with times in ISO 8601 in the configured timezone.
+type Formatter struct {
+	Locale   Locale
+	Location *time.Location
+}
+
+var decimalSeparators = map[Locale]string{
+	LocaleEN: ".",
+	LocaleDE: ",",
+}
+
+func NewFormatter(locale Locale, timezone string) (*Formatter, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &Formatter{Locale: locale, Location: location}, nil
+}
+
+func (f *Formatter) decimalSeparator() string {
+	if sep, ok := decimalSeparators[f.Locale]; ok {
+		return sep
+	}
+	return decimalSeparators[DEFAULT_LOCALE]
+}
+
+// CSVSeparator is the field separator, locales with a decimal comma use
+// a semicolon
+func (f *Formatter) CSVSeparator() rune {
+	if f.decimalSeparator() == "," {
+		return ';'
+	}
+	return ','
+}
+
+// FormatNumber formats with the given decimals, negative decimals use the
+// smallest number necessary
+func (f *Formatter) FormatNumber(value float64, decimals int) string {
+	s := strconv.FormatFloat(value, 'f', decimals, 64)
+	return strings.Replace(s, ".", f.decimalSeparator(), 1)
+}
+
+// FormatTime formats as ISO 8601 in the timezone of the formatter
+func (f *Formatter) FormatTime(t time.Time) string {
+	if f.Location != nil {
+		t = t.In(f.Location)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// FormatValue formats a property value as returned by GetProperty
+func (f *Formatter) FormatValue(value interface{}) string {
+	switch value := value.(type) {
+	case float64:
+		return f.FormatNumber(value, -1)
+	case time.Time:
+		return f.FormatTime(value)
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = f.FormatValue(item)
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
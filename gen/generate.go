@@ -7,17 +7,46 @@ import (
 	"net/http"
 	"os"
 	"sort"
+	"strings"
 
 	api "github.com/mabunixda/wattpilot"
 	"gopkg.in/yaml.v2"
 )
 
 const (
-	fullURLFile = "https://github.com/joscha82/wattpilot/blob/main/src/wattpilot/ressources/wattpilot.yaml"
-	output      = "wattpilot_mapping_gen.go"
+	fullURLFile  = "https://github.com/joscha82/wattpilot/blob/main/src/wattpilot/ressources/wattpilot.yaml"
+	output       = "wattpilot_mapping_gen.go"
+	descriptions = "gen/descriptions.yaml"
 )
 
+// groupPrefixes assigns the properties by alias prefix to logical groups,
+// the first matching group wins and unmatched properties end up in system
+var groupPrefixes = []struct {
+	group    string
+	prefixes []string
+}{
+	{"lumina", []string{"led", "color"}},
+	{"rfid", []string{"registeredCards", "transaction"}},
+	{"network", []string{"wifi", "cloud", "http", "ws", "dns", "defaultRoute", "hostname", "timeServer", "queueSize", "lastSta", "currentlyConnectedWifi"}},
+	{"energy", []string{"energy", "power", "pAkku", "pGrid", "pPv", "average", "avg", "akku", "ohmpilot", "frequency", "totalPowerAverage", "awattar", "inverter", "fbuf", "zeroFeedin", "carConsumption"}},
+	{"charging", []string{"access", "adapter", "allow", "button", "cable", "car", "charging", "cp", "currentLimit", "delta", "effective", "forceS", "lastCar", "lastForce", "load", "logicMode", "maxCurrent", "minCharg", "minPhase", "minimum", "model", "numberOfPhases", "phase", "pv", "prio", "rounding", "scheduler", "simulate", "starting", "stop", "temperatureCurrentLimit", "threePhase", "unlock", "use", "lockFeedback", "norwayMode", "residualCurrent", "relayFeedback"}},
+}
+
+func propertyGroup(alias string) string {
+	for _, g := range groupPrefixes {
+		for _, prefix := range g.prefixes {
+			if strings.HasPrefix(alias, prefix) {
+				return g.group
+			}
+		}
+	}
+	return "system"
+}
+
 func downloadWattpilotYaml() ([]byte, error) {
+	if path := os.Getenv("WATTPILOT_YAML"); path != "" {
+		return os.ReadFile(path)
+	}
 
 	client := http.Client{
 		CheckRedirect: func(r *http.Request, via []*http.Request) error {
@@ -28,38 +57,109 @@ func downloadWattpilotYaml() ([]byte, error) {
 	// Put content on file
 	resp, err := client.Get(fullURLFile)
 	if err != nil {
-		print(err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 	return io.ReadAll(resp.Body)
 }
 
+// addDiscovered maps the keys found by the capture mode of the proxy,
+// listed one per line in the file named by WATTPILOT_DISCOVERED, to
+// themselves until the upstream description names them
+func addDiscovered(propertyMap map[string]string) {
+	path := os.Getenv("WATTPILOT_DISCOVERED")
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		print(err)
+		return
+	}
+	defer f.Close()
+
+	known := make(map[string]bool, len(propertyMap))
+	for _, key := range propertyMap {
+		known[key] = true
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key := strings.TrimSpace(scanner.Text())
+		if key == "" || known[key] {
+			continue
+		}
+		known[key] = true
+		propertyMap[key] = key
+	}
+}
+
+// localizedDescriptions returns the descriptions by key and locale of the
+// keys in descriptions.yaml. Keys missing there are left out, so every
+// generated description is translated. The title of the upstream
+// description is the english one unless descriptions.yaml overrides it.
+func localizedDescriptions(titles map[string]string) map[string]map[string]string {
+	localized := make(map[string]map[string]string)
+	data, err := os.ReadFile(descriptions)
+	if err != nil {
+		print(err)
+		return localized
+	}
+	translated := make(map[string]map[string]string)
+	if err := yaml.Unmarshal(data, &translated); err != nil {
+		print(err)
+		return localized
+	}
+	for key, locales := range translated {
+		localized[key] = make(map[string]string)
+		if title, isKnown := titles[key]; isKnown {
+			localized[key]["en"] = title
+		}
+		for locale, description := range locales {
+			localized[key][locale] = description
+		}
+	}
+	return localized
+}
+
 func main() {
-	s, _ := downloadWattpilotYaml()
+	s, err := downloadWattpilotYaml()
+	if err != nil {
+		print(err)
+		return
+	}
 	a := make(map[string]interface{})
 	if err := yaml.Unmarshal([]byte(s), &a); err != nil {
 		print(err)
 		return
 	}
 	propertyMap := make(map[string]string)
+	titles := make(map[string]string)
 	for _, v := range a["properties"].([]interface{}) {
 		key := ""
 		alias := ""
+		title := ""
 		data := v.(map[interface{}]interface{})
 		for x, y := range data {
 
 			switch x.(string) {
 			case "key":
-				key = y.(string)
+				key, _ = y.(string)
 			case "alias":
-				alias = y.(string)
+				alias, _ = y.(string)
+			case "title":
+				title, _ = y.(string)
 			}
 		}
 		if key != "" && alias != "" {
 			propertyMap[alias] = key
 		}
+		if key != "" && title != "" {
+			titles[key] = title
+		}
 	}
 
+	addDiscovered(propertyMap)
+
 	f, _ := os.Create(output)
 	defer f.Close()
 
@@ -77,6 +177,31 @@ func main() {
 			return
 		}
 	}
+	if _, err := w.WriteString("}\n\nvar propertyGroups = map[string]string {\n"); err != nil {
+		return
+	}
+	for _, i := range keys {
+		if _, err := w.WriteString(fmt.Sprintf("\"%s\": \"%s\",\n", i, propertyGroup(i))); err != nil {
+			return
+		}
+	}
+	if _, err := w.WriteString("}\n\nvar propertyDescriptions = map[string]labels {\n"); err != nil {
+		return
+	}
+	localized := localizedDescriptions(titles)
+	descriptionKeys := api.Keys(localized)
+	sort.Strings(descriptionKeys)
+	for _, i := range descriptionKeys {
+		locales := api.Keys(localized[i])
+		sort.Strings(locales)
+		entries := []string{}
+		for _, locale := range locales {
+			entries = append(entries, fmt.Sprintf("%q: %q", locale, localized[i][locale]))
+		}
+		if _, err := w.WriteString(fmt.Sprintf("\"%s\": {%s},\n", i, strings.Join(entries, ", "))); err != nil {
+			return
+		}
+	}
 	if _, err := w.WriteString("}\n"); err != nil {
 		return
 	}
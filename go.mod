@@ -3,15 +3,19 @@ module github.com/mabunixda/wattpilot
 go 1.21
 
 require (
+	github.com/gobwas/httphead v0.1.0
 	github.com/gobwas/ws v1.3.2
 	github.com/sirupsen/logrus v1.9.3
+	github.com/zalando/go-keyring v0.2.3
 	golang.org/x/crypto v0.18.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
-	github.com/gobwas/httphead v0.1.0 // indirect
+	github.com/alessio/shellescape v1.4.1 // indirect
+	github.com/danieljoos/wincred v1.2.0 // indirect
 	github.com/gobwas/pool v0.2.1 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
 	golang.org/x/sys v0.16.0 // indirect
 )
 
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Access to the daemon is granted by tokens with a scope, configured in
+// GOEAPI_TOKENS as "<token>:read,<token>:control". Read tokens see the
+// status, control tokens can also write, e.g. a wall mounted dashboard gets
+// a read token while the automation backend gets a control token.
+//
+// Clients pass the token as bearer token, as password of basic auth (for
+// tools where only the URL can be configured, http://any:<token>@host) or
+// as token query parameter (for browsers opening websockets and event
+// streams). Without configured tokens reading is open and writing refused.
+
+type scope int
+
+const (
+	scopeNone scope = iota
+	scopeRead
+	scopeControl
+)
+
+var scopeNames = map[string]scope{
+	"read":    scopeRead,
+	"control": scopeControl,
+}
+
+func (s scope) String() string {
+	for name, v := range scopeNames {
+		if v == s {
+			return name
+		}
+	}
+	return "none"
+}
+
+type tokenAuth struct {
+	tokens map[string]scope
+}
+
+func parseTokens(spec string) (*tokenAuth, error) {
+	a := &tokenAuth{tokens: make(map[string]scope)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		token, name, found := strings.Cut(entry, ":")
+		granted, isKnown := scopeNames[name]
+		if !found || token == "" || !isKnown {
+			return nil, fmt.Errorf("invalid token %q, expected <token>:read or <token>:control", entry)
+		}
+		a.tokens[token] = granted
+	}
+	return a, nil
+}
+
+// credential returns the token of the request
+func credential(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, found := strings.CutPrefix(header, "Bearer "); found {
+			return token
+		}
+		if _, password, ok := r.BasicAuth(); ok {
+			return password
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// scopeOf returns the scope granted to the request, all tokens are
+// compared in constant time
+func (a *tokenAuth) scopeOf(r *http.Request) scope {
+	if len(a.tokens) == 0 {
+		return scopeRead
+	}
+	token := []byte(credential(r))
+	granted := scopeNone
+	for t, s := range a.tokens {
+		if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+			granted = s
+		}
+	}
+	return granted
+}
+
+// require wraps the handler of a route which needs the scope
+func (a *tokenAuth) require(required scope, handler http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		granted := a.scopeOf(r)
+		switch {
+		case granted >= required:
+			handler(rw, r)
+		case granted == scopeNone:
+			rw.Header().Set("WWW-Authenticate", `Bearer realm="wattpilot"`)
+			writeJSON(rw, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+		case len(a.tokens) == 0:
+			writeJSON(rw, http.StatusForbidden, map[string]string{"error": "writing requires a control token in GOEAPI_TOKENS"})
+		default:
+			writeJSON(rw, http.StatusForbidden, map[string]string{"error": "token lacks the " + required.String() + " scope"})
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequire(t *testing.T) {
+	configured, err := parseTokens("rtok:read, ctok:control")
+	if err != nil {
+		t.Fatal(err)
+	}
+	open, err := parseTokens("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		auth     *tokenAuth
+		required scope
+		header   string
+		query    string
+		status   int
+	}{
+		{"open read", open, scopeRead, "", "", http.StatusOK},
+		{"open write", open, scopeControl, "", "", http.StatusForbidden},
+		{"missing token", configured, scopeRead, "", "", http.StatusUnauthorized},
+		{"invalid token", configured, scopeRead, "Bearer nope", "", http.StatusUnauthorized},
+		{"read token reads", configured, scopeRead, "Bearer rtok", "", http.StatusOK},
+		{"read token writes", configured, scopeControl, "Bearer rtok", "", http.StatusForbidden},
+		{"control token writes", configured, scopeControl, "Bearer ctok", "", http.StatusOK},
+		{"basic auth password", configured, scopeControl, "Basic eDpjdG9r", "", http.StatusOK},
+		{"query token", configured, scopeRead, "", "token=rtok", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := test.auth.require(test.required, func(rw http.ResponseWriter, r *http.Request) {
+				rw.WriteHeader(http.StatusOK)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/api/set?"+test.query, nil)
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+			rw := httptest.NewRecorder()
+			handler(rw, r)
+			if rw.Code != test.status {
+				t.Errorf("got status %d, want %d", rw.Code, test.status)
+			}
+		})
+	}
+}
+
+func TestParseTokensRejectsUnknownScope(t *testing.T) {
+	for _, spec := range []string{"tok", "tok:admin", ":read"} {
+		if _, err := parseTokens(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// The daemon runs the rule controllers of the JSON file in
+// GOEAPI_CONTROLLERS, e.g.
+//
+//	[{"name": "night", "interval": "1m", "rules": [
+//	  {"when": "car == 2", "actions": [{"key": "amp", "value": 10}]}]}]
+//
+// /api/controllers lists them, /api/controllers/set pauses or resumes one.
+//
+// smooth filters noisy inputs before the rules see them, with an
+// exponentially weighted moving average (ewma, weight of the newest value)
+// or the median of the last values (median, window size), e.g.
+//
+//	"smooth": [{"input": "grid_power", "ewma": 0.3}]
+
+type ruleConfig struct {
+	When    string       `json:"when"`
+	Actions []api.Action `json:"actions"`
+}
+
+type smoothConfig struct {
+	Input  string  `json:"input"`
+	EWMA   float64 `json:"ewma"`
+	Median int     `json:"median"`
+}
+
+type controllerConfig struct {
+	Name     string         `json:"name"`
+	Interval string         `json:"interval"`
+	Rules    []ruleConfig   `json:"rules"`
+	Smooth   []smoothConfig `json:"smooth"`
+}
+
+type controllerState struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	Enabled  bool   `json:"enabled"`
+}
+
+func loadControllers(path string) ([]api.Controller, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	configs := []controllerConfig{}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	controllers := []api.Controller{}
+	for _, config := range configs {
+		interval, err := time.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("controller %s: %w", config.Name, err)
+		}
+		rules := make(map[string][]api.Action)
+		for _, rule := range config.Rules {
+			rules[rule.When] = append(rules[rule.When], rule.Actions...)
+		}
+		rc, err := api.NewRuleController(config.Name, interval, rules)
+		if err != nil {
+			return nil, fmt.Errorf("controller %s: %w", config.Name, err)
+		}
+		var c api.Controller = rc
+		for _, smooth := range config.Smooth {
+			filter, err := smooth.filter()
+			if err != nil {
+				return nil, fmt.Errorf("controller %s: %w", config.Name, err)
+			}
+			c = api.Smooth(c, smooth.Input, filter)
+		}
+		controllers = append(controllers, c)
+	}
+	return controllers, nil
+}
+
+func (config smoothConfig) filter() (api.Filter, error) {
+	switch {
+	case config.Input == "":
+		return nil, fmt.Errorf("smoothing needs an input")
+	case config.EWMA > 0 && config.Median > 0:
+		return nil, fmt.Errorf("input %s: either ewma or median", config.Input)
+	case config.EWMA > 0 && config.EWMA <= 1:
+		return api.NewEWMA(config.EWMA), nil
+	case config.Median > 0:
+		return api.NewMedianWindow(config.Median), nil
+	}
+	return nil, fmt.Errorf("input %s: ewma must be in (0, 1] or median a window size", config.Input)
+}
+
+// ruleController returns the rule controller wrapped by c
+func ruleController(c api.Controller) (*api.RuleController, bool) {
+	for {
+		if rules, ok := c.(*api.RuleController); ok {
+			return rules, true
+		}
+		wrapper, ok := c.(api.ControllerWrapper)
+		if !ok {
+			return nil, false
+		}
+		c = wrapper.Unwrap()
+	}
+}
+
+func (s *shim) controllerStates() []controllerState {
+	states := []controllerState{}
+	for _, c := range s.controllers {
+		states = append(states, controllerState{
+			Name:     c.Name(),
+			Interval: c.Interval().String(),
+			Enabled:  s.charger.ControllerEnabled(c.Name()),
+		})
+	}
+	return states
+}
+
+func (s *shim) listControllers(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, s.controllerStates())
+}
+
+func (s *shim) setController(rw http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	enabled, err := strconv.ParseBool(r.URL.Query().Get("enabled"))
+	if err != nil {
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "enabled must be true or false"})
+		return
+	}
+	for _, c := range s.controllers {
+		if c.Name() == name {
+			s.charger.EnableController(name, enabled)
+			writeJSON(rw, http.StatusOK, s.controllerStates())
+			return
+		}
+	}
+	writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "unknown controller " + name})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	api "github.com/mabunixda/wattpilot"
+)
+
+// The entity API serves the properties with the metadata a Home Assistant
+// integration needs. /api/entities returns the device and the entities
+// with their state. /api/entities/ws sends the same as "entities" message
+// after connecting, pushes "state" messages on changes and accepts "set"
+// messages for writable entities, answered by a "result" message. All
+// messages carry the schema version of api.EVENT_SCHEMA_VERSION.
+
+type entityState struct {
+	api.Entity
+	State interface{} `json:"state"`
+}
+
+type entitySocket struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *entitySocket) send(message interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return wsutil.WriteServerText(c.conn, data)
+}
+
+// entityValue reports numeric strings of post processed properties as
+// numbers
+func (s *shim) entityValue(e api.Entity) interface{} {
+	value, err := s.session.GetProperty(e.Name)
+	if err != nil {
+		return nil
+	}
+	if text, ok := value.(string); ok {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+func (s *shim) entityStates(entities []api.Entity) []entityState {
+	states := []entityState{}
+	for _, e := range entities {
+		states = append(states, entityState{Entity: e, State: s.entityValue(e)})
+	}
+	return states
+}
+
+func (s *shim) entities(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, entitiesResponse{
+		Device:   s.charger.EntityDevice(),
+		Entities: s.entityStates(s.charger.Entities()),
+	})
+}
+
+func (s *shim) entitySocket(rw http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, rw)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	c := &entitySocket{conn: conn}
+
+	// subscribe before the states are taken, so no update is missed
+	entities := s.charger.Entities()
+	// set messages of clients without the control scope are refused by
+	// the read-only session
+	session := s.charger.NewSession("entities:"+r.RemoteAddr, s.auth.scopeOf(r) < scopeControl)
+	defer session.Close()
+	updates := make(map[string]<-chan interface{})
+	for _, e := range entities {
+		if _, subscribed := updates[e.Source]; !subscribed {
+			updates[e.Source] = session.GetNotifications(e.Source)
+		}
+	}
+
+	states := s.entityStates(entities)
+	if err := c.send(map[string]interface{}{
+		"schema":   api.EVENT_SCHEMA_VERSION,
+		"type":     "entities",
+		"device":   s.charger.EntityDevice(),
+		"entities": states,
+	}); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	for source, ch := range updates {
+		group := []entityState{}
+		for _, state := range states {
+			if state.Source == source {
+				group = append(group, state)
+			}
+		}
+		go s.pushStates(ctx, c, ch, group)
+	}
+
+	for {
+		data, op, err := wsutil.ReadClientData(conn)
+		if err != nil {
+			return
+		}
+		if op != ws.OpText && op != ws.OpBinary {
+			continue
+		}
+		request := struct {
+			Type     string      `json:"type"`
+			UniqueId string      `json:"unique_id"`
+			Value    interface{} `json:"value"`
+		}{}
+		if err := json.Unmarshal(data, &request); err != nil || request.Type != "set" {
+			continue
+		}
+		result := map[string]interface{}{"schema": api.EVENT_SCHEMA_VERSION, "type": "result", "unique_id": request.UniqueId, "success": true}
+		if err := setEntity(session, entities, request.UniqueId, request.Value); err != nil {
+			result["success"] = false
+			result["error"] = err.Error()
+		}
+		if err := c.send(result); err != nil {
+			return
+		}
+	}
+}
+
+func setEntity(session *api.Session, entities []api.Entity, uniqueId string, value interface{}) error {
+	for _, e := range entities {
+		if e.UniqueId != uniqueId {
+			continue
+		}
+		if !e.Writable {
+			return fmt.Errorf("%s is not writable: %w", uniqueId, api.ErrInvalidValue)
+		}
+		return session.SetProperty(e.Source, value)
+	}
+	return fmt.Errorf("%s: %w", uniqueId, api.ErrPropertyNotFound)
+}
+
+// pushStates sends the states of the entities derived of the updated key,
+// starting from the states sent with the entities message
+func (s *shim) pushStates(ctx context.Context, c *entitySocket, updates <-chan interface{}, states []entityState) {
+	last := make(map[string]interface{}, len(states))
+	entities := make([]api.Entity, 0, len(states))
+	for _, state := range states {
+		last[state.UniqueId] = state.State
+		entities = append(entities, state.Entity)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-updates:
+			if !ok {
+				return
+			}
+		}
+		for _, e := range entities {
+			state := s.entityValue(e)
+			if reflect.DeepEqual(last[e.UniqueId], state) {
+				continue
+			}
+			last[e.UniqueId] = state
+			if err := c.send(map[string]interface{}{"schema": api.EVENT_SCHEMA_VERSION, "type": "state", "unique_id": e.UniqueId, "state": state}); err != nil {
+				log.Println("error pushing state:", err)
+				return
+			}
+		}
+	}
+}
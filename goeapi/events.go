@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// /events streams the property changes, events and decisions of the charger
+// as server-sent events for browser dashboards and curl. The stream starts
+// with a status event holding the current values. The data of all events is
+// a versioned api.EventMessage. The query parameters keys and kinds
+// (property, event, decision) restrict the stream.
+
+const (
+	EVENTS_BUFFER = 256
+	// EVENTS_KEEPALIVE in seconds between comments keeping idle streams open
+	EVENTS_KEEPALIVE = 30
+)
+
+func filterSet(value string) map[string]bool {
+	if value == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, v := range strings.Split(value, ",") {
+		set[strings.TrimSpace(v)] = true
+	}
+	return set
+}
+
+func writeEvent(rw http.ResponseWriter, name string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(rw, "event: %s\ndata: %s\n\n", name, payload)
+	return err
+}
+
+func (s *shim) events(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	keys := filterSet(r.URL.Query().Get("keys"))
+	kinds := filterSet(r.URL.Query().Get("kinds"))
+
+	// subscribe before the status is taken, so no update is missed
+	updates := s.manager.GetNotifications()
+	defer s.manager.Unsubscribe(updates)
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.WriteHeader(http.StatusOK)
+
+	status := s.charger.StatusMessage()
+	values := status.Value.(map[string]interface{})
+	for key := range values {
+		if keys != nil && !keys[key] {
+			delete(values, key)
+		}
+	}
+	if err := writeEvent(rw, status.Kind, status); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(time.Second * EVENTS_KEEPALIVE)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			event, ok := update.(api.FleetEvent)
+			if !ok {
+				continue
+			}
+			if kinds != nil && !kinds[string(event.Kind)] {
+				continue
+			}
+			if keys != nil && event.Kind == api.FleetPropertyChanged && !keys[event.Key] {
+				continue
+			}
+			if err := writeEvent(rw, string(event.Kind), api.NewEventMessage(event)); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// HISTORY_SIZE is the number of charging sessions kept by the daemon
+const HISTORY_SIZE = 50
+
+// chargingSession is a plug-in to unplug cycle, Energy in Wh is taken of
+// the total energy counter
+type chargingSession struct {
+	Connected     time.Time  `json:"connected"`
+	Disconnected  *time.Time `json:"disconnected,omitempty"`
+	Card          int        `json:"card,omitempty"`
+	CarIdentifier string     `json:"car_identifier,omitempty"`
+	Energy        float64    `json:"energy"`
+}
+
+type history struct {
+	mu       sync.Mutex
+	sessions []chargingSession
+	start    float64
+}
+
+// track records the sessions of the charger from now on, the history is not
+// persisted
+func (h *history) track(charger *api.Wattpilot) {
+	energy := func() float64 {
+		eto, err := charger.GetPropertyAs("eto", api.UnitWattHour)
+		if err != nil {
+			return 0
+		}
+		return eto
+	}
+	charger.OnCarConnected(func(session api.CarSession) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.start = energy()
+		h.sessions = append(h.sessions, chargingSession{
+			Connected:     session.Connected,
+			Card:          session.Card,
+			CarIdentifier: session.CarIdentifier,
+		})
+		if len(h.sessions) > HISTORY_SIZE {
+			h.sessions = h.sessions[1:]
+		}
+	})
+	charger.OnCarDisconnected(func(session api.CarSession) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if len(h.sessions) == 0 {
+			return
+		}
+		last := &h.sessions[len(h.sessions)-1]
+		disconnected := session.Disconnected
+		last.Disconnected = &disconnected
+		if h.start > 0 {
+			last.Energy = energy() - h.start
+		}
+	})
+}
+
+// list returns the sessions, the latest first
+func (h *history) list() []chargingSession {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sessions := make([]chargingSession, 0, len(h.sessions))
+	for i := len(h.sessions) - 1; i >= 0; i-- {
+		sessions = append(sessions, h.sessions[i])
+	}
+	return sessions
+}
+
+func (s *shim) sessions(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, s.history.list())
+}
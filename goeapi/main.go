@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+	"github.com/sirupsen/logrus"
+)
+
+// goeapi mimics the local HTTP API v2 of go-e chargers (/api/status and
+// /api/set) so tools like evcc can use the go-e integration with a
+// Wattpilot behind the websocket connection of this library. /api/set
+// needs a control token, evcc passes it as basic auth password of its uri.
+// Next to it the daemon serves a dashboard, entities, events and its
+// controllers.
+
+type shim struct {
+	charger     *api.Wattpilot
+	session     *api.Session
+	manager     *api.Manager
+	history     *history
+	controllers []api.Controller
+	auth        *tokenAuth
+}
+
+func writeJSON(rw http.ResponseWriter, status int, data interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(data); err != nil {
+		log.Println("error writing response:", err)
+	}
+}
+
+func (s *shim) status(rw http.ResponseWriter, r *http.Request) {
+	keys := s.charger.Properties()
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		keys = strings.Split(filter, ",")
+	}
+	result := make(map[string]interface{})
+	for _, key := range keys {
+		value, err := s.session.GetProperty(key)
+		if err != nil {
+			continue
+		}
+		result[key] = value
+	}
+	writeJSON(rw, http.StatusOK, result)
+}
+
+func (s *shim) set(rw http.ResponseWriter, r *http.Request) {
+	result := make(map[string]interface{})
+	status := http.StatusOK
+	for key, values := range r.URL.Query() {
+		if len(values) == 0 || key == "token" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal([]byte(values[0]), &value); err != nil {
+			value = values[0]
+		}
+		if err := s.session.SetProperty(key, value); err != nil {
+			result[key] = err.Error()
+			status = http.StatusBadRequest
+			continue
+		}
+		result[key] = true
+	}
+	writeJSON(rw, status, result)
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+	level := os.Getenv("WATTPILOT_LOG")
+	listen := os.Getenv("GOEAPI_LISTEN")
+	if pwd == "" {
+		if store, err := api.DefaultCredentialStore(); err == nil {
+			pwd, _ = store.Get(host)
+		}
+	}
+	if host == "" || pwd == "" {
+		return
+	}
+	if level == "" {
+		level = "WARN"
+	}
+	if listen == "" {
+		listen = ":8080"
+	}
+
+	options := []api.Option{}
+	if endpoint := os.Getenv("WATTPILOT_LOG_SHIP"); endpoint != "" {
+		shipper, err := api.NewLogShipperURL(endpoint, logrus.InfoLevel)
+		if err != nil {
+			log.Fatalln("Could not set up log shipping", err)
+		}
+		defer shipper.Close()
+		options = append(options, api.WithLogShipper(shipper))
+	}
+
+	auth, err := parseTokens(os.Getenv("GOEAPI_TOKENS"))
+	if err != nil {
+		log.Fatalln("Could not parse GOEAPI_TOKENS", err)
+	}
+
+	charger := api.New(host, pwd, options...)
+	if err := charger.ParseLogLevel(level); err != nil {
+		log.Fatalf("Could not update loglevel to %s: %v", level, err)
+	}
+	if err := charger.Connect(); err != nil {
+		log.Fatalln("Could not connect", err)
+	}
+
+	manager := api.NewManager(charger)
+	manager.SetNotificationBuffer(EVENTS_BUFFER)
+
+	s := &shim{
+		charger: charger,
+		session: charger.NewSession("goeapi", false),
+		manager: manager,
+		history: &history{},
+		auth:    auth,
+	}
+	defer s.session.Close()
+	s.history.track(charger)
+
+	if path := os.Getenv("GOEAPI_CONTROLLERS"); path != "" {
+		controllers, err := loadControllers(path)
+		if err != nil {
+			log.Fatalln("Could not load controllers", err)
+		}
+		s.controllers = controllers
+	}
+	if path := os.Getenv("GOEAPI_MQTT_INPUTS"); path != "" {
+		inputs, err := loadInputs(path)
+		if err != nil {
+			log.Fatalln("Could not load MQTT inputs", err)
+		}
+		for _, input := range inputs {
+			if err := input.register(charger); err != nil {
+				log.Fatalln("Could not register MQTT input", err)
+			}
+		}
+		config := mqttConfig{
+			broker:   os.Getenv("GOEAPI_MQTT_BROKER"),
+			user:     os.Getenv("GOEAPI_MQTT_USER"),
+			password: os.Getenv("GOEAPI_MQTT_PASSWORD"),
+			clientId: "wattpilot-goeapi-" + charger.GetSerial(),
+		}
+		if config.broker == "" {
+			config.broker = "localhost:1883"
+		}
+		go s.runInputs(context.Background(), config, inputs)
+	}
+	if len(s.controllers) > 0 {
+		go charger.RunControllers(context.Background(), s.controllers...)
+	}
+
+	if addr := os.Getenv("GOEAPI_TELEMETRY"); addr != "" {
+		interval, _ := strconv.Atoi(os.Getenv("GOEAPI_TELEMETRY_INTERVAL"))
+		go func() {
+			if err := charger.RunTelemetry(context.Background(), addr, time.Duration(interval)*time.Second); err != nil {
+				log.Println("Could not send telemetry", err)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	s.register(mux)
+	log.Fatal(http.ListenAndServe(listen, mux))
+}
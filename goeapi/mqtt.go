@@ -0,0 +1,406 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// External measurements like the grid power, the battery state of charge or
+// prices are read from MQTT topics into virtual properties, so controllers
+// use them in their rules like charger values. The inputs are configured in
+// the JSON file of GOEAPI_MQTT_INPUTS, e.g.
+//
+//	[{"name": "grid_power", "topic": "home/meter", "path": "$.power.total", "max_age": "1m"}]
+//
+// Topics can hold the wildcards + and #. path selects the value of a JSON
+// payload, payloads without path are used as number or string. Values
+// older than max_age are unknown, so rules using them fail and their
+// controllers enter safe mode. All messages are
+// also passed to the preset triggers of the rule controllers.
+//
+// The client speaks the few packets of MQTT 3.1.1 needed to subscribe with
+// QoS 0 to GOEAPI_MQTT_BROKER (host:port), with GOEAPI_MQTT_USER and
+// GOEAPI_MQTT_PASSWORD if the broker needs them.
+
+const (
+	MQTT_KEEPALIVE = 30 // seconds
+	MQTT_TIMEOUT   = 10 // seconds
+	MQTT_RECONNECT = 5  // seconds
+	// MQTT_MAX_PACKET bounds the payloads read from the broker
+	MQTT_MAX_PACKET = 1 << 20
+)
+
+const (
+	packetConnect   = 0x10
+	packetConnack   = 0x20
+	packetPublish   = 0x30
+	packetSubscribe = 0x82
+	packetSuback    = 0x90
+	packetPingreq   = 0xc0
+)
+
+var errPacketTooLarge = errors.New("mqtt packet too large")
+
+type mqttInput struct {
+	Name   string `json:"name"`
+	Topic  string `json:"topic"`
+	Path   string `json:"path"`
+	MaxAge string `json:"max_age"`
+
+	maxAge time.Duration
+	mu     sync.Mutex
+	value  interface{}
+	time   time.Time
+}
+
+type mqttConfig struct {
+	broker   string
+	user     string
+	password string
+	clientId string
+}
+
+func loadInputs(path string) ([]*mqttInput, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	inputs := []*mqttInput{}
+	if err := json.Unmarshal(data, &inputs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, input := range inputs {
+		if input.Name == "" || input.Topic == "" {
+			return nil, fmt.Errorf("%s: inputs need a name and a topic", path)
+		}
+		if _, err := parsePath(input.Path); err != nil {
+			return nil, fmt.Errorf("input %s: %w", input.Name, err)
+		}
+		if input.MaxAge != "" {
+			if input.maxAge, err = time.ParseDuration(input.MaxAge); err != nil {
+				return nil, fmt.Errorf("input %s: %w", input.Name, err)
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// register adds the input as virtual property of the charger
+func (input *mqttInput) register(charger *api.Wattpilot) error {
+	return charger.RegisterVirtualProperty(input.Name, nil, func(func(string) (interface{}, bool)) (interface{}, error) {
+		input.mu.Lock()
+		defer input.mu.Unlock()
+		if input.time.IsZero() {
+			return nil, fmt.Errorf("%s: no value received on %s", input.Name, input.Topic)
+		}
+		if input.maxAge > 0 && time.Since(input.time) > input.maxAge {
+			return nil, fmt.Errorf("%s: value older than %s", input.Name, input.maxAge)
+		}
+		return input.value, nil
+	})
+}
+
+func (input *mqttInput) update(payload []byte) error {
+	value, err := extract(payload, input.Path)
+	if err != nil {
+		return err
+	}
+	input.mu.Lock()
+	defer input.mu.Unlock()
+	input.value = value
+	input.time = time.Now()
+	return nil
+}
+
+// extract returns the value of the path in the JSON payload. Without path
+// the payload is a number or a string.
+func extract(payload []byte, path string) (interface{}, error) {
+	if path == "" {
+		text := strings.TrimSpace(string(payload))
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+		return text, nil
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			field, isKnown := v[step]
+			if !isKnown {
+				return nil, fmt.Errorf("%s: no field %s", path, step)
+			}
+			value = field
+		case []interface{}:
+			index, err := strconv.Atoi(step)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("%s: no element %s", path, step)
+			}
+			value = v[index]
+		default:
+			return nil, fmt.Errorf("%s: %s of a %T", path, step, value)
+		}
+	}
+	return value, nil
+}
+
+// parsePath splits a JSONPath of fields and indexes, e.g.
+// $.meters[0].power or $['power']
+func parsePath(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	rest, found := strings.CutPrefix(path, "$")
+	if !found {
+		return nil, fmt.Errorf("path %s does not start with $", path)
+	}
+	steps := []string{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %s has an empty field", path)
+			}
+			steps = append(steps, rest[1:1+end])
+			rest = rest[1+end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 2 {
+				return nil, fmt.Errorf("path %s has an invalid index", path)
+			}
+			steps = append(steps, strings.Trim(rest[1:end], `'"`))
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %s: unexpected %q", path, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+// topicMatches reports whether the topic matches the filter with the
+// wildcards + for a level and # for the remaining levels
+func topicMatches(filter string, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || (level != "+" && level != topicLevels[i]) {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
+func remainingLength(length int) []byte {
+	encoded := []byte{}
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		encoded = append(encoded, b)
+		if length == 0 {
+			return encoded
+		}
+	}
+}
+
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
+
+func packet(header byte, body []byte) []byte {
+	return append(append([]byte{header}, remainingLength(len(body))...), body...)
+}
+
+func readPacket(r io.Reader) (byte, []byte, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return 0, nil, err
+	}
+	header := b[0]
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+		length += int(b[0]&0x7f) * multiplier
+		multiplier *= 128
+		if b[0]&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errPacketTooLarge
+		}
+	}
+	if length > MQTT_MAX_PACKET {
+		return 0, nil, errPacketTooLarge
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+	return header, body, err
+}
+
+// subscribe connects to the broker and hands the messages of the topics to
+// receive until the connection fails
+func subscribe(ctx context.Context, config mqttConfig, topics []string, receive func(topic string, payload []byte)) error {
+	conn, err := net.DialTimeout("tcp", config.broker, time.Second*MQTT_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// protocol name and level 4, clean session
+	flags := byte(0x02)
+	if config.user != "" {
+		flags |= 0x80
+	}
+	if config.password != "" {
+		flags |= 0x40
+	}
+	body := append(mqttString("MQTT"), 4, flags, 0, MQTT_KEEPALIVE)
+	body = append(body, mqttString(config.clientId)...)
+	if config.user != "" {
+		body = append(body, mqttString(config.user)...)
+	}
+	if config.password != "" {
+		body = append(body, mqttString(config.password)...)
+	}
+	if _, err := conn.Write(packet(packetConnect, body)); err != nil {
+		return err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * MQTT_TIMEOUT))
+	header, ack, err := readPacket(conn)
+	if err != nil {
+		return err
+	}
+	if header != packetConnack || len(ack) != 2 || ack[1] != 0 {
+		return fmt.Errorf("broker refused the connection: %v", ack)
+	}
+
+	body = []byte{0, 1}
+	for _, topic := range topics {
+		body = append(append(body, mqttString(topic)...), 0)
+	}
+	if _, err := conn.Write(packet(packetSubscribe, body)); err != nil {
+		return err
+	}
+
+	// the pings are the only writes after subscribing
+	go func() {
+		ticker := time.NewTicker(time.Second * MQTT_KEEPALIVE / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := conn.Write([]byte{packetPingreq, 0}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * MQTT_KEEPALIVE * 3 / 2))
+		header, body, err := readPacket(conn)
+		if err != nil {
+			return err
+		}
+		switch header & 0xf0 {
+		case packetSuback:
+			for _, code := range body[min(2, len(body)):] {
+				if code == 0x80 {
+					return errors.New("broker refused the subscription")
+				}
+			}
+		case packetPublish:
+			if len(body) < 2 {
+				return errors.New("malformed mqtt publish")
+			}
+			length := int(body[0])<<8 | int(body[1])
+			payload := body[2:]
+			if length > len(payload) {
+				return errors.New("malformed mqtt publish")
+			}
+			topic := string(payload[:length])
+			payload = payload[length:]
+			if header&0x06 != 0 {
+				// packet id of QoS 1 and 2, not expected as QoS 0 was requested
+				payload = payload[min(2, len(payload)):]
+			}
+			receive(topic, payload)
+		}
+	}
+}
+
+// runInputs keeps the subscription of the inputs until the context ends,
+// reconnecting after failures
+func (s *shim) runInputs(ctx context.Context, config mqttConfig, inputs []*mqttInput) {
+	topics := []string{}
+	seen := make(map[string]bool)
+	for _, input := range inputs {
+		if !seen[input.Topic] {
+			seen[input.Topic] = true
+			topics = append(topics, input.Topic)
+		}
+	}
+	receive := func(topic string, payload []byte) {
+		for _, input := range inputs {
+			if !topicMatches(input.Topic, topic) {
+				continue
+			}
+			if err := input.update(payload); err != nil {
+				log.Println("input", input.Name, "ignores message:", err)
+			}
+		}
+		for _, c := range s.controllers {
+			if rules, ok := ruleController(c); ok {
+				rules.Message(topic, string(payload))
+			}
+		}
+	}
+	for {
+		err := subscribe(ctx, config, topics, receive)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("mqtt connection failed:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Second * MQTT_RECONNECT):
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		payload string
+		path    string
+		value   interface{}
+	}{
+		{"1234.5", "", 1234.5},
+		{" on ", "", "on"},
+		{`{"power": {"total": -1500}}`, "$.power.total", -1500.0},
+		{`{"meters": [{"soc": 80}, {"soc": 40}]}`, "$.meters[1].soc", 40.0},
+		{`{"price": 0.31}`, "$['price']", 0.31},
+	}
+	for _, test := range tests {
+		value, err := extract([]byte(test.payload), test.path)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.payload, test.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, test.value) {
+			t.Errorf("%s %s: got %v, want %v", test.payload, test.path, value, test.value)
+		}
+	}
+
+	for _, path := range []string{"power", "$..power", "$.meters[]", "$.missing", "$.list[5]"} {
+		if _, err := extract([]byte(`{"list": [1]}`), path); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"home/meter", "home/meter", true},
+		{"home/meter", "home/meter/power", false},
+		{"home/+/power", "home/meter/power", true},
+		{"home/#", "home/meter/power", true},
+		{"home/+", "home", false},
+	}
+	for _, test := range tests {
+		if topicMatches(test.filter, test.topic) != test.match {
+			t.Errorf("%s on %s: expected %v", test.filter, test.topic, test.match)
+		}
+	}
+}
+
+// TestSubscribe runs the client against a broker which acknowledges the
+// subscription and publishes one message
+func TestSubscribe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	subscribed := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if header, _, err := readPacket(conn); err != nil || header != packetConnect {
+			return
+		}
+		_, _ = conn.Write([]byte{packetConnack, 2, 0, 0})
+		header, body, err := readPacket(conn)
+		if err != nil || header != packetSubscribe {
+			return
+		}
+		subscribed <- body
+		_, _ = conn.Write([]byte{packetSuback, 3, 0, 1, 0})
+		_, _ = conn.Write(packet(packetPublish, append(mqttString("home/meter"), `{"power": 42}`...)))
+		_, _, _ = readPacket(conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	received := make(chan string, 1)
+	go func() {
+		_ = subscribe(ctx, mqttConfig{broker: listener.Addr().String(), clientId: "test"}, []string{"home/meter"}, func(topic string, payload []byte) {
+			received <- topic + " " + string(payload)
+		})
+	}()
+
+	select {
+	case body := <-subscribed:
+		want := append([]byte{0, 1}, append(mqttString("home/meter"), 0)...)
+		if !reflect.DeepEqual(body, want) {
+			t.Errorf("subscribe packet %v, want %v", body, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("client did not subscribe")
+	}
+	select {
+	case message := <-received:
+		if message != `home/meter {"power": 42}` {
+			t.Errorf("received %s", message)
+		}
+	case <-ctx.Done():
+		t.Fatal("message not received")
+	}
+}
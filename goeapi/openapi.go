@@ -0,0 +1,300 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	api "github.com/mabunixda/wattpilot"
+)
+
+// The routes of the daemon are registered from a table which also
+// generates the OpenAPI 3 document served on /api/openapi.json, so clients
+// in other languages can be generated from it. Response schemas are derived
+// of the Go types of the responses.
+
+const OPENAPI_VERSION = "3.0.3"
+
+type parameter struct {
+	name        string
+	description string
+	// free form parameters are arbitrary key=value pairs
+	freeForm bool
+}
+
+type route struct {
+	path        string
+	summary     string
+	description string
+	query       []parameter
+	// response is a value of the type written as JSON
+	response interface{}
+	// badRequest is a value of the type written on invalid requests
+	badRequest interface{}
+	websocket  bool
+	stream     bool
+	// write routes need the control scope, all others the read scope
+	write   bool
+	handler http.HandlerFunc
+}
+
+type entitiesResponse struct {
+	Device   api.EntityDevice `json:"device"`
+	Entities []entityState    `json:"entities"`
+}
+
+func (s *shim) routes() []route {
+	return []route{
+		{
+			path:        "/api/status",
+			summary:     "Status of the charger",
+			description: "Values of all properties by key, like the status API of go-e chargers.",
+			query:       []parameter{{name: "filter", description: "Comma separated keys to return"}},
+			response:    map[string]interface{}{},
+			handler:     s.status,
+		},
+		{
+			path:        "/api/set",
+			summary:     "Write properties",
+			description: "Each query parameter except token writes a key, values are parsed as JSON or used as string. The result holds true or the error by key.",
+			query:       []parameter{{name: "properties", description: "Keys and values to write", freeForm: true}},
+			response:    map[string]interface{}{},
+			badRequest:  map[string]interface{}{},
+			write:       true,
+			handler:     s.set,
+		},
+		{
+			path:        "/api/entities",
+			summary:     "Entities for home automation integrations",
+			description: "The charger as device with its entities, their metadata and state.",
+			response:    entitiesResponse{},
+			handler:     s.entities,
+		},
+		{
+			path:        "/api/entities/ws",
+			summary:     "Entity updates",
+			description: "Websocket sending an entities message, then state messages on changes. Accepts set messages with unique_id and value, answered by result messages. Set messages need the control scope.",
+			websocket:   true,
+			handler:     s.entitySocket,
+		},
+		{
+			path:        "/api/sessions",
+			summary:     "Charging sessions",
+			description: "Plug-in to unplug cycles since the start of the daemon, the latest first. Energy in Wh.",
+			response:    []chargingSession{},
+			handler:     s.sessions,
+		},
+		{
+			path:        "/api/controllers",
+			summary:     "Controllers",
+			description: "The rule controllers run by the daemon and whether they are enabled.",
+			response:    []controllerState{},
+			handler:     s.listControllers,
+		},
+		{
+			path:        "/api/controllers/set",
+			summary:     "Enable or disable a controller",
+			description: "Pauses or resumes the controller, returns all controllers.",
+			query: []parameter{
+				{name: "name", description: "Name of the controller"},
+				{name: "enabled", description: "true or false"},
+			},
+			response:   []controllerState{},
+			badRequest: map[string]string{},
+			write:      true,
+			handler:    s.setController,
+		},
+		{
+			path:        "/events",
+			summary:     "Server-sent events",
+			description: "Stream starting with a status event of the current values, followed by property, event and decision events. The data of all events is a JSON object with schema (version), kind, serial, name, key, value and time.",
+			query: []parameter{
+				{name: "keys", description: "Comma separated keys of the status and property events"},
+				{name: "kinds", description: "Comma separated kinds of events: property, event, decision"},
+			},
+			stream:  true,
+			handler: s.events,
+		},
+		{
+			path:     "/api/openapi.json",
+			summary:  "This document",
+			response: map[string]interface{}{},
+			handler:  s.openAPI,
+		},
+	}
+}
+
+func (s *shim) register(mux *http.ServeMux) {
+	for _, r := range s.routes() {
+		required := scopeRead
+		if r.write {
+			required = scopeControl
+		}
+		mux.HandleFunc(r.path, s.auth.require(required, r.handler))
+	}
+	mux.Handle("/", dashboard())
+}
+
+func (s *shim) openAPI(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, openAPIDocument(s.routes()))
+}
+
+func openAPIDocument(routes []route) map[string]interface{} {
+	paths := make(map[string]interface{}, len(routes))
+	for _, r := range routes {
+		operation := map[string]interface{}{
+			"summary":     r.summary,
+			"operationId": operationId(r.path),
+			"responses":   responses(r),
+		}
+		if r.description != "" {
+			operation["description"] = r.description
+		}
+		parameters := []interface{}{}
+		for _, p := range r.query {
+			parameter := map[string]interface{}{
+				"name":        p.name,
+				"in":          "query",
+				"description": p.description,
+				"schema":      map[string]interface{}{"type": "string"},
+			}
+			if p.freeForm {
+				parameter["style"] = "form"
+				parameter["explode"] = true
+				parameter["schema"] = map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}}
+			}
+			parameters = append(parameters, parameter)
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if r.write {
+			operation["description"] = strings.TrimSpace(r.description + " Needs the control scope.")
+		}
+		paths[r.path] = map[string]interface{}{"get": operation}
+	}
+	return map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":       "Wattpilot go-e API",
+			"description": "Local HTTP API of go-e chargers on top of the websocket connection to a Wattpilot.",
+			"version":     "2",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"bearer":     map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"basic":      map[string]interface{}{"type": "http", "scheme": "basic", "description": "The token as password"},
+				"queryToken": map[string]interface{}{"type": "apiKey", "in": "query", "name": "token"},
+			},
+		},
+		// reading is open when the daemon has no tokens configured
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"basic": []string{}},
+			map[string]interface{}{"queryToken": []string{}},
+			map[string]interface{}{},
+		},
+	}
+}
+
+func operationId(path string) string {
+	parts := strings.FieldsFunc(strings.TrimPrefix(path, "/api"), func(r rune) bool {
+		return r == '/' || r == '.' || r == '_'
+	})
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func responses(r route) map[string]interface{} {
+	responses := map[string]interface{}{
+		"401": jsonResponse("Missing or invalid token", map[string]string{}),
+		"403": jsonResponse("The token lacks the scope", map[string]string{}),
+	}
+	switch {
+	case r.websocket:
+		responses["101"] = map[string]interface{}{"description": "Switching to the websocket protocol"}
+	case r.stream:
+		responses["200"] = map[string]interface{}{
+			"description": "Event stream",
+			"content": map[string]interface{}{
+				"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		}
+	default:
+		responses["200"] = jsonResponse("Success", r.response)
+	}
+	if r.badRequest != nil {
+		responses["400"] = jsonResponse("Invalid request or failed writes", r.badRequest)
+	}
+	return responses
+}
+
+func jsonResponse(description string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schemaOf(reflect.TypeOf(value))},
+		},
+	}
+}
+
+// schemaOf derives the JSON schema of a type from its kind and json tags,
+// interfaces allow any value
+func schemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		structProperties(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// structProperties adds the fields of the struct, fields of embedded
+// structs are promoted like by encoding/json
+func structProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			structProperties(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaOf(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
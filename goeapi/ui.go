@@ -0,0 +1,27 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The dashboard on / shows the live status of the charger of the /events
+// stream, the charging sessions and the controllers with toggles. It is
+// plain HTML and JavaScript using the API of the daemon, for users without
+// a home automation system.
+
+//go:embed ui
+var assets embed.FS
+
+func dashboard() http.Handler {
+	files, _ := fs.Sub(assets, "ui")
+	static := http.FileServer(http.FS(files))
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/index.html" {
+			http.NotFound(rw, r)
+			return
+		}
+		static.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,105 @@
+package wattpilot
+
+import (
+	"math"
+	"strconv"
+)
+
+// GridQuality collects the power quality values measured by the charger
+type GridQuality struct {
+	// Frequency of the grid in Hz
+	Frequency float64    `json:"frequency"`
+	Voltages  [3]float64 `json:"voltages"`
+	// PowerFactors per phase in %
+	PowerFactors [3]float64 `json:"powerFactors"`
+	// VoltageImbalance is the largest deviation of a phase from the mean of
+	// the connected phases in %
+	VoltageImbalance float64 `json:"voltageImbalance"`
+}
+
+// GetFrequency returns the grid frequency in Hz
+func (w *Wattpilot) GetFrequency() (float64, error) {
+	return w.getFloatProperty("fhz")
+}
+
+// SubscribeFrequency delivers the grid frequency in Hz on every update
+func (w *Wattpilot) SubscribeFrequency() (<-chan float64, func(), error) {
+	return SubscribeTyped[float64](w, "fhz")
+}
+
+// SubscribeVoltageImbalance delivers the voltage imbalance in % on every
+// update of the measurements
+func (w *Wattpilot) SubscribeVoltageImbalance() (<-chan float64, func(), error) {
+	return SubscribeTyped[float64](w, "voltageImbalance")
+}
+
+// GetPowerFactors returns the power factors of the phases in %
+func (w *Wattpilot) GetPowerFactors() (float64, float64, float64, error) {
+
+	var factors []float64
+	for _, i := range []string{"powerFactor1", "powerFactor2", "powerFactor3"} {
+		v, err := w.GetProperty(i)
+		if err != nil {
+			return -1, -1, -1, err
+		}
+		fi, err := strconv.ParseFloat(v.(string), 64)
+		if err != nil {
+			return -1, -1, -1, err
+		}
+
+		factors = append(factors, fi)
+	}
+	return factors[0], factors[1], factors[2], nil
+}
+
+// GetVoltageImbalance returns the largest deviation of a phase voltage from
+// the mean of the connected phases in %
+func (w *Wattpilot) GetVoltageImbalance() (float64, error) {
+	return w.getFloatProperty("voltageImbalance")
+}
+
+// GridQuality returns the power quality values of the last measurement
+func (w *Wattpilot) GridQuality() (GridQuality, error) {
+	quality := GridQuality{}
+	frequency, err := w.GetFrequency()
+	if err != nil {
+		return quality, err
+	}
+	quality.Frequency = frequency
+	if quality.Voltages[0], quality.Voltages[1], quality.Voltages[2], err = w.GetVoltages(); err != nil {
+		return quality, err
+	}
+	if quality.PowerFactors[0], quality.PowerFactors[1], quality.PowerFactors[2], err = w.GetPowerFactors(); err != nil {
+		return quality, err
+	}
+	quality.VoltageImbalance, err = w.GetVoltageImbalance()
+	return quality, err
+}
+
+// voltageImbalance is computed over the phases above ANOMALY_NO_VOLTAGE, so
+// single phase installations are not reported as imbalanced
+func voltageImbalance(lookup func(string) (interface{}, bool)) (interface{}, error) {
+	voltages := []float64{}
+	for idx := 0; idx <= 2; idx++ {
+		v, err := nrgValue(lookup, idx)
+		if err != nil {
+			return nil, err
+		}
+		if v >= ANOMALY_NO_VOLTAGE {
+			voltages = append(voltages, v)
+		}
+	}
+	if len(voltages) < 2 {
+		return 0.0, nil
+	}
+	mean := 0.0
+	for _, v := range voltages {
+		mean += v
+	}
+	mean /= float64(len(voltages))
+	deviation := 0.0
+	for _, v := range voltages {
+		deviation = math.Max(deviation, math.Abs(v-mean))
+	}
+	return deviation / mean * 100, nil
+}
@@ -0,0 +1,87 @@
+package wattpilot
+
+import "sort"
+
+type PropertyGroup string
+
+const (
+	GroupCharging PropertyGroup = "charging"
+	GroupEnergy   PropertyGroup = "energy"
+	GroupNetwork  PropertyGroup = "network"
+	GroupLumina   PropertyGroup = "lumina"
+	GroupRfid     PropertyGroup = "rfid"
+	GroupSystem   PropertyGroup = "system"
+)
+
+// PropertyUpdate is published on group subscriptions
+type PropertyUpdate struct {
+	Key   string
+	Alias string
+	Value interface{}
+}
+
+var keyAliases = func() map[string]string {
+	aliases := make(map[string]string, len(propertyMap))
+	for alias, key := range propertyMap {
+		aliases[key] = alias
+	}
+	return aliases
+}()
+
+func Groups() []PropertyGroup {
+	return []PropertyGroup{GroupCharging, GroupEnergy, GroupNetwork, GroupLumina, GroupRfid, GroupSystem}
+}
+
+// PropertiesByGroup returns the sorted aliases of all properties in the group
+func (w *Wattpilot) PropertiesByGroup(group PropertyGroup) []string {
+	aliases := []string{}
+	for alias, g := range propertyGroups {
+		if g == string(group) {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+func (w *Wattpilot) LookupGroup(name string) PropertyGroup {
+	if alias, isKnown := keyAliases[name]; isKnown {
+		name = alias
+	}
+	return PropertyGroup(propertyGroups[name])
+}
+
+func groupTopic(group PropertyGroup) string {
+	return "group:" + string(group)
+}
+
+// groupTopics avoids building the topic for every published update
+var groupTopics = func() map[string]string {
+	topics := make(map[string]string)
+	for _, group := range propertyGroups {
+		topics[group] = groupTopic(PropertyGroup(group))
+	}
+	return topics
+}()
+
+// GetGroupNotifications subscribes to all property updates of a group, the
+// channel delivers values of type PropertyUpdate.
+func (w *Wattpilot) GetGroupNotifications(group PropertyGroup) <-chan interface{} {
+	return w._notifications.Subscribe(groupTopic(group))
+}
+
+func (w *Wattpilot) publishGroupUpdate(key string, value interface{}) {
+	alias, isKnown := keyAliases[key]
+	if !isKnown {
+		return
+	}
+	topic := groupTopics[propertyGroups[alias]]
+	if !w._notifications.hasSubscribers(topic) {
+		return
+	}
+	w._notifications.Publish(topic, PropertyUpdate{
+		Key:   key,
+		Alias: alias,
+		Value: value,
+	})
+}
@@ -0,0 +1,245 @@
+package wattpilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	GUEST_HISTORY = 256
+
+	EventGuestSessionStarted EventType = "guestSessionStarted"
+	EventGuestSessionEnded   EventType = "guestSessionEnded"
+)
+
+var (
+	ErrGuestSessionActive = errors.New("guest session already active")
+	ErrNoGuestSession     = errors.New("no guest session active")
+	ErrNoCar              = errors.New("no car plugged in")
+)
+
+// GuestLimits caps a guest session, zero values are unlimited
+type GuestLimits struct {
+	Energy   float64       `json:"energy,omitempty"` // Wh
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// GuestSession is the record of a guest charging session for billing
+type GuestSession struct {
+	Guest   string      `json:"guest"`
+	Card    int         `json:"card,omitempty"`
+	Limits  GuestLimits `json:"limits"`
+	Started time.Time   `json:"started"`
+	Ended   time.Time   `json:"ended,omitempty"`
+	Energy  float64     `json:"energy"`
+	Reason  string      `json:"reason,omitempty"`
+}
+
+type guestState struct {
+	mu      sync.Mutex
+	active  *GuestSession
+	stop    chan string
+	history []GuestSession
+	onEnd   []func(GuestSession)
+
+	authorizer Authorizer
+}
+
+// OnGuestSessionEnd registers a callback receiving the finished record
+func (w *Wattpilot) OnGuestSessionEnd(f func(GuestSession)) {
+	w._guest.mu.Lock()
+	defer w._guest.mu.Unlock()
+
+	w._guest.onEnd = append(w._guest.onEnd, f)
+}
+
+// StartGuestSession allows the plugged car to charge until one of the
+// limits is reached, the car is unplugged or StopGuestSession is called.
+// The charger is paused with frc afterwards until the next guest session.
+// With an Authorizer the session has to be authorized first.
+func (w *Wattpilot) StartGuestSession(guest string, card int, limits GuestLimits) error {
+	if _, _, plugged := w.currentCar(); !plugged {
+		return ErrNoCar
+	}
+	if _, isActive := w.ActiveGuestSession(); isActive {
+		return ErrGuestSessionActive
+	}
+	record := &GuestSession{Guest: guest, Card: card, Limits: limits, Started: w._clock.Now()}
+	if err := w.authorizeGuest(*record); err != nil {
+		return err
+	}
+
+	g := w._guest
+	g.mu.Lock()
+	if g.active != nil {
+		g.mu.Unlock()
+		return ErrGuestSessionActive
+	}
+	g.active = record
+	g.stop = make(chan string, 1)
+	stop := g.stop
+	g.mu.Unlock()
+
+	session := w.NewSession("guest", false)
+	if err := session.SetProperty("frc", int(ForceNeutral)); err != nil {
+		session.Close()
+		w.endGuestSession(record, "failed")
+		return err
+	}
+	// every update after the return is counted for the guest
+	ready := make(chan struct{})
+	go w.runGuestSession(session, record, stop, ready)
+	<-ready
+	w.logEntry().Info("Guest session of ", guest, " started")
+	w.emitEvent(EventGuestSessionStarted, map[string]interface{}{"session": *record})
+	return nil
+}
+
+// StopGuestSession ends the active guest session and pauses the charger
+func (w *Wattpilot) StopGuestSession() error {
+	w._guest.mu.Lock()
+	defer w._guest.mu.Unlock()
+
+	if w._guest.active == nil {
+		return ErrNoGuestSession
+	}
+	select {
+	case w._guest.stop <- "stopped":
+	default:
+	}
+	return nil
+}
+
+// ActiveGuestSession returns the running guest session
+func (w *Wattpilot) ActiveGuestSession() (GuestSession, bool) {
+	w._guest.mu.Lock()
+	defer w._guest.mu.Unlock()
+
+	if w._guest.active == nil {
+		return GuestSession{}, false
+	}
+	return *w._guest.active, true
+}
+
+// GuestSessions returns the finished guest sessions, oldest first
+func (w *Wattpilot) GuestSessions() []GuestSession {
+	w._guest.mu.Lock()
+	defer w._guest.mu.Unlock()
+
+	return append([]GuestSession{}, w._guest.history...)
+}
+
+// runGuestSession closes ready once it counts the energy and the duration
+func (w *Wattpilot) runGuestSession(session *Session, record *GuestSession, stop <-chan string, ready chan<- struct{}) {
+	defer session.Close()
+
+	energy := session.GetNotifications("wh")
+	car := session.GetNotifications("car")
+	start, _ := w.Snapshot().Float("wh")
+
+	var deadline <-chan time.Time
+	if record.Limits.Duration > 0 {
+		timer := w._clock.NewTimer(record.Limits.Duration)
+		defer timer.Stop()
+		deadline = timer.C()
+	}
+	close(ready)
+
+	reason := ""
+	for reason == "" {
+		select {
+		case reason = <-stop:
+		case <-deadline:
+			reason = "duration"
+		case value, ok := <-energy:
+			if !ok {
+				reason = "disconnected"
+				break
+			}
+			charged, err := toFloat(value)
+			if err != nil {
+				continue
+			}
+			w._guest.mu.Lock()
+			record.Energy = charged - start
+			w._guest.mu.Unlock()
+			if record.Limits.Energy > 0 && charged-start >= record.Limits.Energy {
+				reason = "energy"
+			}
+		case value, ok := <-car:
+			if !ok {
+				reason = "disconnected"
+				break
+			}
+			if state, err := toFloat(value); err == nil && !isPlugged(int(state)) {
+				reason = "unplugged"
+			}
+		}
+	}
+
+	err := session.SetProperty("frc", int(ForceOff))
+	w.recordDecision(Decision{
+		Source:   "guest",
+		Inputs:   map[string]interface{}{"guest": record.Guest, "energy": record.Energy},
+		Setpoint: int(ForceOff),
+		Action:   "set frc",
+		Reason:   fmt.Sprintf("guest session ended by %s", reason),
+		Error:    err,
+	})
+	w.endGuestSession(record, reason)
+}
+
+func (w *Wattpilot) endGuestSession(record *GuestSession, reason string) {
+	g := w._guest
+	g.mu.Lock()
+	record.Ended = w._clock.Now()
+	record.Reason = reason
+	g.active = nil
+	g.history = append(g.history, *record)
+	if len(g.history) > GUEST_HISTORY {
+		g.history = g.history[len(g.history)-GUEST_HISTORY:]
+	}
+	callbacks := append([]func(GuestSession){}, g.onEnd...)
+	g.mu.Unlock()
+
+	w.logEntry().Info("Guest session of ", record.Guest, " ended by ", reason)
+	w.emitEvent(EventGuestSessionEnded, map[string]interface{}{"session": *record})
+	w.completeGuest(*record)
+	for _, f := range callbacks {
+		f(*record)
+	}
+}
+
+// RunGuestCards starts a guest session when one of the RFID cards (the
+// transaction value trx, card index + 1) is used, until the context is
+// cancelled
+func (w *Wattpilot) RunGuestCards(ctx context.Context, cards map[int]GuestLimits) {
+	updates := w.GetNotifications("trx")
+	defer w._notifications.Unsubscribe("trx", updates)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case value, ok := <-updates:
+			if !ok {
+				return
+			}
+			trx, err := toFloat(value)
+			if err != nil {
+				continue
+			}
+			limits, isGuest := cards[int(trx)]
+			if !isGuest {
+				continue
+			}
+			guest := fmt.Sprintf("card %d", int(trx))
+			if err := w.StartGuestSession(guest, int(trx), limits); err != nil && !errors.Is(err, ErrGuestSessionActive) {
+				w.logEntry().Warn("Could not start guest session for ", guest, ": ", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package wattpilot_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// guestCharger connects a client on the fake clock to a mock charger with a
+// plugged car, paused until a guest session releases it
+func guestCharger(t *testing.T, clock *wattpilottest.FakeClock, options ...wattpilot.Option) (*wattpilot.Wattpilot, *wattpilottest.MockCharger) {
+	t.Helper()
+	mock, err := wattpilottest.NewMockCharger(testSerial, testPassword, map[string]interface{}{"car": 2, "frc": 1, "wh": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	client := wattpilot.New(mock.Addr(), testPassword, append(options, wattpilot.WithClock(clock))...)
+	t.Cleanup(func() { client.Close() })
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	waitForProperty(t, client, "wh", 100.0)
+	return client, mock
+}
+
+// waitForCharger waits until the mock charger applied the value
+func waitForCharger(t *testing.T, mock *wattpilottest.MockCharger, key string, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if value, _ := mock.Status()[key].(float64); value == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(key, " of the charger did not become ", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitForGuestEnergy waits until the active guest session counted the energy
+func waitForGuestEnergy(t *testing.T, client *wattpilot.Wattpilot, want float64) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if session, _ := client.ActiveGuestSession(); session.Energy == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("guest session did not count ", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func startGuest(t *testing.T, client *wattpilot.Wattpilot, guest string, card int, limits wattpilot.GuestLimits) {
+	t.Helper()
+	if err := client.StartGuestSession(guest, card, limits); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func nextRecord(t *testing.T, records <-chan wattpilot.GuestSession) wattpilot.GuestSession {
+	t.Helper()
+	select {
+	case record := <-records:
+		return record
+	case <-time.After(testTimeout):
+		t.Fatal("guest session did not end")
+	}
+	return wattpilot.GuestSession{}
+}
+
+func TestGuestSessionEnds(t *testing.T) {
+	start := time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		limits wattpilot.GuestLimits
+		end    func(t *testing.T, client *wattpilot.Wattpilot, mock *wattpilottest.MockCharger, clock *wattpilottest.FakeClock)
+		reason string
+		energy float64
+		ended  time.Time
+	}{
+		{
+			name:   "energy limit",
+			limits: wattpilot.GuestLimits{Energy: 1000, Duration: time.Hour},
+			end: func(t *testing.T, client *wattpilot.Wattpilot, mock *wattpilottest.MockCharger, clock *wattpilottest.FakeClock) {
+				mock.Update(map[string]interface{}{"wh": 600})
+				waitForGuestEnergy(t, client, 500)
+				mock.Update(map[string]interface{}{"wh": 1100})
+			},
+			reason: "energy",
+			energy: 1000,
+			ended:  start,
+		},
+		{
+			name:   "duration limit",
+			limits: wattpilot.GuestLimits{Energy: 1000, Duration: time.Hour},
+			end: func(t *testing.T, client *wattpilot.Wattpilot, mock *wattpilottest.MockCharger, clock *wattpilottest.FakeClock) {
+				mock.Update(map[string]interface{}{"wh": 400})
+				waitForGuestEnergy(t, client, 300)
+				clock.Advance(time.Hour)
+			},
+			reason: "duration",
+			energy: 300,
+			ended:  start.Add(time.Hour),
+		},
+		{
+			name:   "unplugged",
+			limits: wattpilot.GuestLimits{Duration: time.Hour},
+			end: func(t *testing.T, client *wattpilot.Wattpilot, mock *wattpilottest.MockCharger, clock *wattpilottest.FakeClock) {
+				mock.Update(map[string]interface{}{"car": 1})
+			},
+			reason: "unplugged",
+			ended:  start,
+		},
+		{
+			name:   "stopped",
+			limits: wattpilot.GuestLimits{Duration: time.Hour},
+			end: func(t *testing.T, client *wattpilot.Wattpilot, mock *wattpilottest.MockCharger, clock *wattpilottest.FakeClock) {
+				if err := client.StopGuestSession(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reason: "stopped",
+			ended:  start,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := wattpilottest.NewFakeClock(start)
+			client, mock := guestCharger(t, clock)
+			ended := client.GetEvents(wattpilot.EventGuestSessionEnded)
+
+			startGuest(t, client, "guest", 0, test.limits)
+			waitForCharger(t, mock, "frc", float64(wattpilot.ForceNeutral))
+			test.end(t, client, mock, clock)
+
+			event := nextEvent(t, ended)
+			record := event.Data["session"].(wattpilot.GuestSession)
+			if record.Reason != test.reason || record.Energy != test.energy || !record.Started.Equal(start) || !record.Ended.Equal(test.ended) {
+				t.Fatalf("unexpected record %+v", record)
+			}
+			waitForCharger(t, mock, "frc", float64(wattpilot.ForceOff))
+			if _, isActive := client.ActiveGuestSession(); isActive {
+				t.Fatal("guest session still active")
+			}
+		})
+	}
+}
+
+func TestGuestSessionsKeepSeparateRecords(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC))
+	client, mock := guestCharger(t, clock)
+	records := make(chan wattpilot.GuestSession, 2)
+	client.OnGuestSessionEnd(func(record wattpilot.GuestSession) {
+		records <- record
+	})
+
+	startGuest(t, client, "card 1", 1, wattpilot.GuestLimits{Energy: 1000, Duration: time.Hour})
+	mock.Update(map[string]interface{}{"wh": 1100})
+	first := nextRecord(t, records)
+
+	// the next session counts from the energy at its start
+	startGuest(t, client, "card 2", 2, wattpilot.GuestLimits{Duration: time.Hour})
+	mock.Update(map[string]interface{}{"wh": 1400})
+	waitForGuestEnergy(t, client, 300)
+	if err := client.StopGuestSession(); err != nil {
+		t.Fatal(err)
+	}
+	second := nextRecord(t, records)
+
+	if first.Guest != "card 1" || first.Card != 1 || first.Energy != 1000 || first.Reason != "energy" {
+		t.Fatalf("unexpected first record %+v", first)
+	}
+	if second.Guest != "card 2" || second.Card != 2 || second.Energy != 300 || second.Reason != "stopped" {
+		t.Fatalf("unexpected second record %+v", second)
+	}
+	history := client.GuestSessions()
+	if len(history) != 2 || history[0] != first || history[1] != second {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
+
+func TestGuestSessionRefused(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC))
+	client, mock := guestCharger(t, clock)
+
+	if err := client.StopGuestSession(); !errors.Is(err, wattpilot.ErrNoGuestSession) {
+		t.Fatal("expected ErrNoGuestSession, got ", err)
+	}
+	startGuest(t, client, "first", 0, wattpilot.GuestLimits{Duration: time.Hour})
+	waitForCharger(t, mock, "frc", float64(wattpilot.ForceNeutral))
+	if err := client.StartGuestSession("second", 0, wattpilot.GuestLimits{}); !errors.Is(err, wattpilot.ErrGuestSessionActive) {
+		t.Fatal("expected ErrGuestSessionActive, got ", err)
+	}
+
+	mock.Update(map[string]interface{}{"car": 1})
+	waitForCharger(t, mock, "frc", float64(wattpilot.ForceOff))
+	waitForProperty(t, client, "car", 1.0)
+	if err := client.StartGuestSession("second", 0, wattpilot.GuestLimits{}); !errors.Is(err, wattpilot.ErrNoCar) {
+		t.Fatal("expected ErrNoCar, got ", err)
+	}
+}
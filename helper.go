@@ -1,15 +1,10 @@
 package wattpilot
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
-	"math/rand"
-	"time"
+	"strconv"
 )
 
-var randomSource = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 func Keys[K comparable, V any](m map[K]V) []K {
 	keys := make([]K, 0, len(m))
 	for k := range m {
@@ -23,16 +18,46 @@ func hasKey(data map[string]interface{}, key string) bool {
 	return isKnown
 }
 
-func sha256sum(data string) string {
-	bs := sha256.Sum256([]byte(data))
-	return fmt.Sprintf("%x", bs)
+func remove[T comparable](l []T, item T) []T {
+	for i, other := range l {
+		if other == item {
+			return append(l[:i], l[i+1:]...)
+		}
+	}
+	return l
 }
-func randomHexString(n int) string {
-	b := make([]byte, (n+2)/2) // can be simplified to n/2 if n is always even
 
-	if _, err := randomSource.Read(b); err != nil {
-		panic(err)
+func toFloat(value interface{}) (float64, error) {
+	switch value := value.(type) {
+	case float64:
+		return value, nil
+	case int:
+		return float64(value), nil
+	case int64:
+		return float64(value), nil
+	case bool:
+		if value {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidValue, err)
 	}
+	return f, nil
+}
 
-	return hex.EncodeToString(b)[1 : n+1]
+func toBool(value interface{}) (bool, error) {
+	switch value := value.(type) {
+	case bool:
+		return value, nil
+	case float64:
+		return value != 0, nil
+	}
+	b, err := strconv.ParseBool(fmt.Sprintf("%v", value))
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	return b, nil
 }
@@ -0,0 +1,125 @@
+package wattpilot
+
+import "sort"
+
+type Locale string
+
+const (
+	LocaleEN Locale = "en"
+	LocaleDE Locale = "de"
+
+	DEFAULT_LOCALE = LocaleEN
+)
+
+type labels map[Locale]string
+
+var groupLabels = map[PropertyGroup]labels{
+	GroupCharging: {LocaleEN: "Charging", LocaleDE: "Laden"},
+	GroupEnergy:   {LocaleEN: "Energy", LocaleDE: "Energie"},
+	GroupNetwork:  {LocaleEN: "Network", LocaleDE: "Netzwerk"},
+	GroupLumina:   {LocaleEN: "LED", LocaleDE: "LED"},
+	GroupRfid:     {LocaleEN: "RFID", LocaleDE: "RFID"},
+	GroupSystem:   {LocaleEN: "System", LocaleDE: "System"},
+}
+
+// enumLabels holds the value labels of enumerated properties by key
+var enumLabels = map[string]map[int]labels{
+	"car": {
+		0: {LocaleEN: "Unknown", LocaleDE: "Unbekannt"},
+		1: {LocaleEN: "Idle", LocaleDE: "Bereit"},
+		2: {LocaleEN: "Charging", LocaleDE: "Lädt"},
+		3: {LocaleEN: "Waiting for car", LocaleDE: "Warte auf Fahrzeug"},
+		4: {LocaleEN: "Complete", LocaleDE: "Ladung beendet"},
+		5: {LocaleEN: "Error", LocaleDE: "Fehler"},
+	},
+	"frc": {
+		0: {LocaleEN: "Neutral", LocaleDE: "Neutral"},
+		1: {LocaleEN: "Off", LocaleDE: "Aus"},
+		2: {LocaleEN: "On", LocaleDE: "Ein"},
+	},
+	"acs": {
+		0: {LocaleEN: "Open", LocaleDE: "Offen"},
+		1: {LocaleEN: "Authentication required", LocaleDE: "Authentifizierung erforderlich"},
+	},
+	"ust": {
+		0: {LocaleEN: "Normal", LocaleDE: "Normal"},
+		1: {LocaleEN: "Auto unlock", LocaleDE: "Automatisch entriegeln"},
+		2: {LocaleEN: "Always locked", LocaleDE: "Immer verriegelt"},
+	},
+	"psm": {
+		0: {LocaleEN: "Automatic", LocaleDE: "Automatisch"},
+		1: {LocaleEN: "Single phase", LocaleDE: "Einphasig"},
+		2: {LocaleEN: "Three phases", LocaleDE: "Dreiphasig"},
+	},
+	"lmo": {
+		3: {LocaleEN: "Default", LocaleDE: "Standard"},
+		4: {LocaleEN: "Eco", LocaleDE: "Eco"},
+		5: {LocaleEN: "Next trip", LocaleDE: "Nächste Fahrt"},
+	},
+	"cus": {
+		0: {LocaleEN: "Unknown", LocaleDE: "Unbekannt"},
+		1: {LocaleEN: "Unlocked", LocaleDE: "Entriegelt"},
+		2: {LocaleEN: "Unlock failed", LocaleDE: "Entriegeln fehlgeschlagen"},
+		3: {LocaleEN: "Locked", LocaleDE: "Verriegelt"},
+		4: {LocaleEN: "Lock failed", LocaleDE: "Verriegeln fehlgeschlagen"},
+		5: {LocaleEN: "Unlocked on power outage", LocaleDE: "Bei Stromausfall entriegelt"},
+	},
+}
+
+func Locales() []Locale {
+	return []Locale{LocaleEN, LocaleDE}
+}
+
+func (l labels) get(locale Locale) (string, bool) {
+	if label, ok := l[locale]; ok {
+		return label, true
+	}
+	label, ok := l[DEFAULT_LOCALE]
+	return label, ok
+}
+
+func resolveKey(name string) string {
+	if key, isKnown := propertyMap[name]; isKnown {
+		return key
+	}
+	return name
+}
+
+// GroupLabel returns the localized name of a property group, unknown
+// locales fall back to english
+func GroupLabel(group PropertyGroup, locale Locale) string {
+	if label, ok := groupLabels[group].get(locale); ok {
+		return label
+	}
+	return string(group)
+}
+
+// DescriptionLabel returns the localized description of a property given
+// by key or alias, unknown locales fall back to english
+func DescriptionLabel(name string, locale Locale) (string, bool) {
+	return propertyDescriptions[resolveKey(name)].get(locale)
+}
+
+// EnumLabel returns the localized label of an enumerated property value,
+// the property can be given by key or alias
+func EnumLabel(name string, value interface{}, locale Locale) (string, bool) {
+	values, isEnum := enumLabels[resolveKey(name)]
+	if !isEnum {
+		return "", false
+	}
+	f, err := toFloat(value)
+	if err != nil {
+		return "", false
+	}
+	return values[int(f)].get(locale)
+}
+
+// EnumValues returns the known values of an enumerated property
+func EnumValues(name string) []int {
+	values := []int{}
+	for v := range enumLabels[resolveKey(name)] {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+	return values
+}
@@ -0,0 +1,35 @@
+package wattpilot
+
+import "testing"
+
+func TestDescriptionLabel(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale Locale
+		label  string
+		found  bool
+	}{
+		{"amp", LocaleEN, "Requested charging current in ampere", true},
+		{"chargingCurrent", LocaleDE, "Angeforderter Ladestrom in Ampere", true},
+		{"car", Locale("fr"), "State of the car connection", true},
+		{"unknownProperty", LocaleEN, "", false},
+	}
+	for _, test := range tests {
+		label, found := DescriptionLabel(test.name, test.locale)
+		if label != test.label || found != test.found {
+			t.Errorf("%s %s: got %q %v, want %q %v", test.name, test.locale, label, found, test.label, test.found)
+		}
+	}
+}
+
+// TestDescriptionsTranslated checks that every described property has a
+// description in each locale
+func TestDescriptionsTranslated(t *testing.T) {
+	for key, descriptions := range propertyDescriptions {
+		for _, locale := range Locales() {
+			if descriptions[locale] == "" {
+				t.Errorf("%s has no %s description", key, locale)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package wattpilot
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrInstallationSettingsLocked is returned when a safety relevant
+// installation setting is written without explicit opt-in
+var ErrInstallationSettingsLocked = errors.New("installation settings are locked")
+
+// installationKeys are safety relevant settings which are only writable after
+// EnableInstallationSettings has been called
+var installationKeys = map[string]bool{
+	"ama": true,
+	"nmo": true,
+	"rcd": true,
+	"cbl": true,
+	"pnp": true,
+}
+
+// EnableInstallationSettings opts in to writes of installation level
+// settings. These settings must match the electrical installation of the
+// charger, wrong values can lead to hazards.
+func (w *Wattpilot) EnableInstallationSettings(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&w._installationEnabled, v)
+}
+
+func (w *Wattpilot) isInstallationLocked(key string) bool {
+	return installationKeys[key] && atomic.LoadInt32(&w._installationEnabled) == 0
+}
+
+func (w *Wattpilot) GetCableCurrentLimit() (float64, error) {
+	return w.getFloatProperty("cbl")
+}
+
+func (w *Wattpilot) GetMaxCurrentLimit() (float64, error) {
+	return w.getFloatProperty("ama")
+}
+
+func (w *Wattpilot) SetMaxCurrentLimit(current float64) error {
+	return w.SetProperty("ama", current)
+}
+
+func (w *Wattpilot) GetGridPhases() (float64, error) {
+	return w.getFloatProperty("pnp")
+}
+
+// GetEarthCheckDisabled reports whether the norway mode is active which
+// disables the ground check for IT grids
+func (w *Wattpilot) GetEarthCheckDisabled() (bool, error) {
+	return w.getBoolProperty("nmo")
+}
+
+func (w *Wattpilot) SetEarthCheckDisabled(disabled bool) error {
+	return w.SetProperty("nmo", disabled)
+}
+
+func (w *Wattpilot) GetResidualCurrentDetection() (interface{}, error) {
+	return w.GetProperty("rcd")
+}
@@ -0,0 +1,32 @@
+package wattpilot
+
+// statusKeys holds one instance of every known property key. The keys of
+// decoded messages are replaced by these, so the status map does not keep
+// a fresh copy of each key alive per message.
+var statusKeys = func() map[string]string {
+	keys := make(map[string]string, len(propertyMap))
+	for _, key := range propertyMap {
+		keys[key] = key
+	}
+	return keys
+}()
+
+// IsKnownKey reports whether the key is part of the generated property
+// mapping
+func IsKnownKey(key string) bool {
+	_, isKnown := statusKeys[key]
+	return isKnown
+}
+
+func internKey(key string) string {
+	if interned, isKnown := statusKeys[key]; isKnown {
+		return interned
+	}
+	return key
+}
+
+// newStatusMap is sized for all known properties, as the full status
+// update fills nearly all of them
+func newStatusMap() map[string]interface{} {
+	return make(map[string]interface{}, len(statusKeys))
+}
@@ -0,0 +1,100 @@
+package wattpilot
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// INVERTER_SETTLE is the quiet time after an inverter message before
+	// the inverter list is published, in milliseconds
+	INVERTER_SETTLE = 1000
+
+	EventInvertersChanged EventType = "invertersChanged"
+)
+
+// Inverter holds the fields of the last updateInverter message of a paired
+// or discovered inverter
+type Inverter struct {
+	Id     string
+	Fields map[string]interface{}
+}
+
+type inverterState struct {
+	mu        sync.Mutex
+	inverters map[string]Inverter
+	timer     Timer
+}
+
+func (w *Wattpilot) onEventClearInverters(message map[string]interface{}) {
+	w.logEntry().Trace("clear inverters")
+
+	s := w._inverters
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.inverters = make(map[string]Inverter)
+	w.scheduleInvertersChanged()
+}
+
+func (w *Wattpilot) onEventUpdateInverter(message map[string]interface{}) {
+	w.logEntry().Trace("update inverters")
+
+	inverter := Inverter{Id: fmt.Sprint(message["id"]), Fields: make(map[string]interface{})}
+	for k, v := range message {
+		if k != "type" {
+			inverter.Fields[k] = v
+		}
+	}
+
+	s := w._inverters
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.inverters == nil {
+		s.inverters = make(map[string]Inverter)
+	}
+	s.inverters[inverter.Id] = inverter
+	w.scheduleInvertersChanged()
+}
+
+// scheduleInvertersChanged coalesces bursts of inverter messages during
+// re-pairing into a single event, it must be called with the mutex held
+func (w *Wattpilot) scheduleInvertersChanged() {
+	s := w._inverters
+	settle := time.Millisecond * INVERTER_SETTLE
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer.Reset(settle)
+		return
+	}
+	s.timer = w._clock.NewTimer(settle)
+	go func(timer Timer) {
+		<-timer.C()
+		s.mu.Lock()
+		s.timer = nil
+		inverters := s.list()
+		s.mu.Unlock()
+
+		w.emitEvent(EventInvertersChanged, map[string]interface{}{"inverters": inverters})
+	}(s.timer)
+}
+
+func (s *inverterState) list() []Inverter {
+	inverters := make([]Inverter, 0, len(s.inverters))
+	for _, inverter := range s.inverters {
+		inverters = append(inverters, inverter)
+	}
+	sort.Slice(inverters, func(i, j int) bool { return inverters[i].Id < inverters[j].Id })
+	return inverters
+}
+
+// Inverters returns the inverters known from the last inverter messages
+func (w *Wattpilot) Inverters() []Inverter {
+	w._inverters.mu.Lock()
+	defer w._inverters.mu.Unlock()
+
+	return w._inverters.list()
+}
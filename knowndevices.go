@@ -0,0 +1,130 @@
+package wattpilot
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const EventSerialMismatch EventType = "serialMismatch"
+
+var ErrSerialMismatch = errors.New("serial of the charger does not match the known device")
+
+// KnownDevices pins the serial number of the charger to its host, like the
+// known_hosts file of ssh. Each line of the file holds a host and a serial.
+type KnownDevices struct {
+	mu      sync.Mutex
+	path    string
+	devices map[string]string
+}
+
+// LoadKnownDevices reads the file, a missing file is created on the first pin
+func LoadKnownDevices(path string) (*KnownDevices, error) {
+	k := &KnownDevices{path: path, devices: make(map[string]string)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected host and serial", path, line)
+		}
+		k.devices[fields[0]] = fields[1]
+	}
+	return k, scanner.Err()
+}
+
+func (k *KnownDevices) Lookup(host string) (string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	serial, isKnown := k.devices[host]
+	return serial, isKnown
+}
+
+// Pin stores the serial for the host and saves the file
+func (k *KnownDevices) Pin(host string, serial string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.devices[host] = serial
+	return k.save()
+}
+
+// Forget removes the host, e.g. after replacing the charger
+func (k *KnownDevices) Forget(host string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	delete(k.devices, host)
+	return k.save()
+}
+
+func (k *KnownDevices) save() error {
+	if k.path == "" {
+		return nil
+	}
+	hosts := Keys(k.devices)
+	sort.Strings(hosts)
+	var sb strings.Builder
+	for _, host := range hosts {
+		fmt.Fprintf(&sb, "%s %s\n", host, k.devices[host])
+	}
+	return os.WriteFile(k.path, []byte(sb.String()), 0600)
+}
+
+// WithKnownDevices verifies the serial of the charger on every connect.
+// Unknown hosts are pinned on first use. On a mismatch EventSerialMismatch
+// is emitted and in strict mode the connection is refused before the
+// password hash is sent.
+func WithKnownDevices(known *KnownDevices, strict bool) Option {
+	return func(w *Wattpilot) {
+		w._knownDevices = known
+		w._strictPinning = strict
+		if serial, isKnown := known.Lookup(w._host); isKnown {
+			w.PrecomputeAuth(serial)
+		}
+	}
+}
+
+func (w *Wattpilot) verifySerial() error {
+	if w._knownDevices == nil {
+		return nil
+	}
+	pinned, isKnown := w._knownDevices.Lookup(w._host)
+	if !isKnown {
+		w.logEntry().Info("Pinning serial ", w._serial, " to ", w._host)
+		if err := w._knownDevices.Pin(w._host, w._serial); err != nil {
+			w.logEntry().Warn("Could not save known devices: ", err)
+		}
+		return nil
+	}
+	if pinned == w._serial {
+		return nil
+	}
+	w.logEntry().Warn("Serial ", w._serial, " does not match the known serial ", pinned)
+	w.emitEvent(EventSerialMismatch, map[string]interface{}{
+		"expected": pinned,
+		"serial":   w._serial,
+		"refused":  w._strictPinning,
+	})
+	if w._strictPinning {
+		return ErrSerialMismatch
+	}
+	return nil
+}
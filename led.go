@@ -0,0 +1,70 @@
+package wattpilot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LedState names the charger states with a configurable LED color
+type LedState string
+
+const (
+	LedIdle     LedState = "cid"
+	LedCharging LedState = "cch"
+	LedFinished LedState = "cfi"
+	LedWaitCar  LedState = "cwc"
+)
+
+type RGB struct {
+	R uint8
+	G uint8
+	B uint8
+}
+
+// ParseRGB parses colors in the "#rrggbb" notation used by the charger
+func ParseRGB(value string) (RGB, error) {
+	var c RGB
+	if len(value) != 7 || !strings.HasPrefix(value, "#") {
+		return c, fmt.Errorf("color %s: %w", value, ErrInvalidValue)
+	}
+	if _, err := fmt.Sscanf(value, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return c, fmt.Errorf("color %s: %w: %w", value, ErrInvalidValue, err)
+	}
+	return c, nil
+}
+
+func (c RGB) String() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+func (w *Wattpilot) GetLedColor(state LedState) (RGB, error) {
+	v, err := w.GetProperty(string(state))
+	if err != nil {
+		return RGB{}, err
+	}
+	return ParseRGB(fmt.Sprintf("%v", v))
+}
+
+func (w *Wattpilot) SetLedColor(state LedState, color RGB) error {
+	return w.SetProperty(string(state), color.String())
+}
+
+func (w *Wattpilot) GetLedBrightness() (uint8, error) {
+	v, err := w.getFloatProperty("lbr")
+	if err != nil {
+		return 0, err
+	}
+	return uint8(v), nil
+}
+
+func (w *Wattpilot) SetLedBrightness(brightness uint8) error {
+	return w.SetProperty("lbr", int(brightness))
+}
+
+func (w *Wattpilot) GetLedSaveEnergy() (bool, error) {
+	return w.getBoolProperty("lse")
+}
+
+func (w *Wattpilot) SetLedSaveEnergy(enabled bool) error {
+	return w.SetProperty("lse", enabled)
+}
@@ -0,0 +1,227 @@
+package wattpilot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type CableLockMode int
+
+const (
+	CableLockNormal CableLockMode = iota
+	CableLockAutoUnlock
+	CableLockAlwaysLocked
+)
+
+type AccessState int
+
+const (
+	AccessOpen AccessState = iota
+	AccessAuthRequired
+)
+
+type ForceState int
+
+const (
+	ForceNeutral ForceState = iota
+	ForceOff
+	ForceOn
+)
+
+func (w *Wattpilot) getFloatProperty(name string) (float64, error) {
+	v, err := w.GetProperty(name)
+	if err != nil {
+		return -1, err
+	}
+	return toFloat(v)
+}
+
+func (w *Wattpilot) getBoolProperty(name string) (bool, error) {
+	v, err := w.GetProperty(name)
+	if err != nil {
+		return false, err
+	}
+	return toBool(v)
+}
+
+func (w *Wattpilot) GetCableLockMode() (CableLockMode, error) {
+	v, err := w.getFloatProperty("ust")
+	return CableLockMode(v), err
+}
+
+func (w *Wattpilot) SetCableLockMode(mode CableLockMode) error {
+	return w.SetProperty("ust", int(mode))
+}
+
+func (w *Wattpilot) GetEffectiveLockMode() (CableLockMode, error) {
+	v, err := w.getFloatProperty("lck")
+	return CableLockMode(v), err
+}
+
+// GetButtonLock reports whether the button on the charger is prevented
+// from changing the charging current.
+func (w *Wattpilot) GetButtonLock() (bool, error) {
+	allowed, err := w.getBoolProperty("bac")
+	return !allowed, err
+}
+
+func (w *Wattpilot) SetButtonLock(locked bool) error {
+	return w.SetProperty("bac", !locked)
+}
+
+func (w *Wattpilot) GetUnlockOnPowerOutage() (bool, error) {
+	return w.getBoolProperty("upo")
+}
+
+func (w *Wattpilot) SetUnlockOnPowerOutage(unlock bool) error {
+	return w.SetProperty("upo", unlock)
+}
+
+func (w *Wattpilot) GetAccessState() (AccessState, error) {
+	v, err := w.getFloatProperty("acs")
+	return AccessState(v), err
+}
+
+func (w *Wattpilot) SetAccessState(state AccessState) error {
+	return w.SetProperty("acs", int(state))
+}
+
+func (w *Wattpilot) GetForceState() (ForceState, error) {
+	v, err := w.getFloatProperty("frc")
+	return ForceState(v), err
+}
+
+func (w *Wattpilot) SetForceState(state ForceState) error {
+	return w.SetProperty("frc", int(state))
+}
+
+// Lock pauses charging, requires authentication for new charging sessions
+// and disables the current change on the button.
+func (w *Wattpilot) Lock() error {
+	return errors.Join(
+		w.SetForceState(ForceOff),
+		w.SetAccessState(AccessAuthRequired),
+		w.SetButtonLock(true),
+	)
+}
+
+// Unlock reverts Lock
+func (w *Wattpilot) Unlock() error {
+	return errors.Join(
+		w.SetForceState(ForceNeutral),
+		w.SetAccessState(AccessOpen),
+		w.SetButtonLock(false),
+	)
+}
+
+// TimeWindow is a daily period between Start and End, both given as offset
+// since midnight, on the listed weekdays. An empty weekday list matches
+// every day.
+type TimeWindow struct {
+	Weekdays []time.Weekday
+	Start    time.Duration
+	End      time.Duration
+}
+
+func (tw TimeWindow) Contains(t time.Time) bool {
+	if len(tw.Weekdays) > 0 {
+		found := false
+		for _, day := range tw.Weekdays {
+			if day == t.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if tw.Start <= tw.End {
+		return offset >= tw.Start && offset < tw.End
+	}
+	// window wraps around midnight
+	return offset >= tw.Start || offset < tw.End
+}
+
+// LockSchedule locks the charger outside of the allowed time windows. On
+// days of the holiday calendar the holiday windows apply instead, without
+// holiday windows the charger stays locked all day.
+type LockSchedule struct {
+	Allowed        []TimeWindow
+	Holidays       *Calendar
+	HolidayWindows []TimeWindow
+	Interval       time.Duration
+	// OnChange is called after the lock state has been applied
+	OnChange func(locked bool, err error)
+}
+
+func (s *LockSchedule) IsLocked(t time.Time) bool {
+	windows := s.Allowed
+	if s.Holidays.Contains(t) {
+		windows = s.HolidayWindows
+	}
+	for _, tw := range windows {
+		if tw.Contains(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// RunLockSchedule applies the schedule until the context is cancelled
+func (w *Wattpilot) RunLockSchedule(ctx context.Context, schedule *LockSchedule) {
+
+	interval := schedule.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	timer := w._clock.NewTimer(0)
+	defer timer.Stop()
+
+	var current *bool
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(interval)
+			if !w.IsInitialized() {
+				continue
+			}
+			now := w._clock.Now()
+			locked := schedule.IsLocked(now)
+			if current != nil && *current == locked {
+				continue
+			}
+			w.logEntry().Info("Lock schedule sets locked: ", locked)
+			var err error
+			if locked {
+				err = w.Lock()
+			} else {
+				err = w.Unlock()
+			}
+			if err == nil {
+				current = &locked
+			}
+			decision := Decision{
+				Source:   "lockSchedule",
+				Inputs:   map[string]interface{}{"time": now},
+				Setpoint: locked,
+				Action:   "unlock",
+				Reason:   "inside allowed time window",
+				Error:    err,
+			}
+			if locked {
+				decision.Action = "lock"
+				decision.Reason = "outside allowed time windows"
+			}
+			w.recordDecision(decision)
+			if schedule.OnChange != nil {
+				schedule.OnChange(locked, err)
+			}
+		}
+	}
+}
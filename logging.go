@@ -0,0 +1,49 @@
+package wattpilot
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// WithLogger uses a shared logger instead of a logger per instance, the
+// entries are attributed by the device fields added to every entry.
+func WithLogger(logger *log.Logger) Option {
+	return func(w *Wattpilot) {
+		w._log = logger
+		w._logEntry.Store(nil)
+	}
+}
+
+// WithLogFields adds the given fields to every log entry of the instance
+func WithLogFields(fields map[string]interface{}) Option {
+	return func(w *Wattpilot) {
+		for k, v := range fields {
+			w._logFields[k] = v
+		}
+		w._logEntry.Store(nil)
+	}
+}
+
+// logEntry returns the entry with the device fields, it is cached as the
+// hot path logs on trace level for every message. The cache is built and
+// invalidated under the identity mutex, so an entry built of an outdated
+// serial or name never replaces the invalidation of onEventHello.
+func (w *Wattpilot) logEntry() *log.Entry {
+	if entry := w._logEntry.Load(); entry != nil {
+		return entry
+	}
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
+	fields := log.Fields{"wattpilot": w._host}
+	if w._serial != "" {
+		fields["serial"] = w._serial
+	}
+	if w._name != "" {
+		fields["name"] = w._name
+	}
+	for k, v := range w._logFields {
+		fields[k] = v
+	}
+	entry := w._log.WithFields(fields)
+	w._logEntry.Store(entry)
+	return entry
+}
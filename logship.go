@@ -0,0 +1,202 @@
+package wattpilot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LOG_SHIP_BUFFER   = 1024
+	LOG_SHIP_BATCH    = 100
+	LOG_SHIP_INTERVAL = 5 // seconds
+)
+
+// LogRecord is a formatted log entry handed to a LogSink
+type LogRecord struct {
+	Level log.Level
+	Line  []byte
+}
+
+// LogSink delivers log records to a remote system
+type LogSink interface {
+	Ship(records []LogRecord) error
+}
+
+// LogShipper is a logrus hook buffering entries and shipping them in
+// batches to a sink in the background. Logging never blocks on the sink,
+// entries exceeding the buffer are dropped and failed batches are retried.
+type LogShipper struct {
+	sink      LogSink
+	levels    []log.Level
+	formatter log.Formatter
+	records   chan LogRecord
+	flush     chan chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   uint64
+	failures  uint64
+}
+
+// NewLogShipper ships entries up to the given level to the sink
+func NewLogShipper(sink LogSink, level log.Level) *LogShipper {
+	s := &LogShipper{
+		sink:      sink,
+		levels:    log.AllLevels[:level+1],
+		formatter: &log.JSONFormatter{},
+		records:   make(chan LogRecord, LOG_SHIP_BUFFER),
+		flush:     make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// NewLogShipperURL creates a shipper for http(s):// endpoints receiving
+// JSON lines by POST, or syslog servers given as udp:// or tcp://
+func NewLogShipperURL(endpoint string, level log.Level) (*LogShipper, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var sink LogSink
+	switch u.Scheme {
+	case "http", "https":
+		sink = &HTTPLogSink{URL: endpoint}
+	case "udp", "tcp":
+		sink, err = NewSyslogLogSink(u.Scheme, u.Host, DEFAULT_USER_AGENT)
+	default:
+		err = errors.New("unsupported log shipping endpoint " + endpoint)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewLogShipper(sink, level), nil
+}
+
+// WithLogShipper adds the shipper to the logger of the instance, the
+// entries are redacted before shipping
+func WithLogShipper(s *LogShipper) Option {
+	return func(w *Wattpilot) {
+		w._log.AddHook(s)
+	}
+}
+
+func (s *LogShipper) Levels() []log.Level {
+	return s.levels
+}
+
+func (s *LogShipper) Fire(entry *log.Entry) error {
+	line, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	select {
+	case s.records <- LogRecord{Level: entry.Level, Line: line}:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+	return nil
+}
+
+// Dropped returns the number of entries lost due to a full buffer
+func (s *LogShipper) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Failures returns the number of failed shipping attempts
+func (s *LogShipper) Failures() uint64 {
+	return atomic.LoadUint64(&s.failures)
+}
+
+// Flush ships all buffered entries
+func (s *LogShipper) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case s.flush <- flushed:
+		<-flushed
+	case <-s.done:
+	}
+}
+
+// Close ships the remaining entries and stops the shipper
+func (s *LogShipper) Close() {
+	s.Flush()
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+func (s *LogShipper) run() {
+	ticker := time.NewTicker(time.Second * LOG_SHIP_INTERVAL)
+	defer ticker.Stop()
+
+	pending := []LogRecord{}
+	ship := func() {
+		for len(s.records) > 0 && len(pending) < LOG_SHIP_BUFFER {
+			pending = append(pending, <-s.records)
+		}
+		for len(pending) > 0 {
+			n := len(pending)
+			if n > LOG_SHIP_BATCH {
+				n = LOG_SHIP_BATCH
+			}
+			if err := s.sink.Ship(pending[:n]); err != nil {
+				atomic.AddUint64(&s.failures, 1)
+				return
+			}
+			pending = pending[n:]
+		}
+	}
+	for {
+		select {
+		case <-s.done:
+			return
+		case record := <-s.records:
+			if len(pending) >= LOG_SHIP_BUFFER {
+				pending = pending[1:]
+				atomic.AddUint64(&s.dropped, 1)
+			}
+			pending = append(pending, record)
+			if len(pending) >= LOG_SHIP_BATCH {
+				ship()
+			}
+		case <-ticker.C:
+			ship()
+		case flushed := <-s.flush:
+			ship()
+			close(flushed)
+		}
+	}
+}
+
+// HTTPLogSink posts the records as JSON lines
+type HTTPLogSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *HTTPLogSink) Ship(records []LogRecord) error {
+	var body bytes.Buffer
+	for _, record := range records {
+		body.Write(record.Line)
+	}
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * CONTEXT_TIMEOUT}
+	}
+	resp, err := client.Post(h.URL, "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("log shipping failed with status %s", resp.Status)
+	}
+	return nil
+}
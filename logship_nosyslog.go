@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package wattpilot
+
+import "errors"
+
+type SyslogLogSink struct{}
+
+func NewSyslogLogSink(network string, addr string, tag string) (*SyslogLogSink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
+
+func (s *SyslogLogSink) Ship(records []LogRecord) error {
+	return errors.New("syslog is not supported on this platform")
+}
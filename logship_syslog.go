@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package wattpilot
+
+import (
+	"log/syslog"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SyslogLogSink forwards the records to a remote syslog server
+type SyslogLogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogLogSink(network string, addr string, tag string) (*SyslogLogSink, error) {
+	writer, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &SyslogLogSink{writer: writer}, nil
+}
+
+func (s *SyslogLogSink) Ship(records []LogRecord) error {
+	for _, record := range records {
+		line := string(record.Line)
+		var err error
+		switch record.Level {
+		case log.PanicLevel, log.FatalLevel:
+			err = s.writer.Crit(line)
+		case log.ErrorLevel:
+			err = s.writer.Err(line)
+		case log.WarnLevel:
+			err = s.writer.Warning(line)
+		case log.InfoLevel:
+			err = s.writer.Info(line)
+		default:
+			err = s.writer.Debug(line)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package wattpilot
+
+import (
+	"context"
+	"errors"
+)
+
+// A factory reset is not offered: the key of the factory reset command of
+// the firmware is not part of the known properties, and a guessed key is
+// not acceptable for a command which erases the configuration.
+
+// ErrNotConfirmed is returned by disruptive maintenance commands called
+// without confirmation
+var ErrNotConfirmed = errors.New("maintenance command not confirmed")
+
+// sendCommand writes keys which are commands and therefore not part of the
+// status reported by the charger
+func (w *Wattpilot) sendCommand(ctx context.Context, key string, value interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !w._isInitialized.Load() {
+		return ErrNotConnected
+	}
+	w.logEntry().Info("Sending command ", key)
+
+	err := w.sendUpdate(key, value)
+	w.audit(SOURCE_API, key, nil, w.transformValue(value), err)
+	return err
+}
+
+// Reboot restarts the charger, the connection is lost and re-established
+// by the process loop afterwards. A running charging session is
+// interrupted, so the caller has to confirm the reboot explicitly.
+func (w *Wattpilot) Reboot(ctx context.Context, confirm bool) error {
+	if !confirm {
+		return ErrNotConfirmed
+	}
+	return w.sendCommand(ctx, "rst", 1)
+}
+
+// SimulateUnplugging lets the car see an unplug and replug of the cable,
+// which recovers cars that stopped to request power
+func (w *Wattpilot) SimulateUnplugging(ctx context.Context) error {
+	return w.sendCommand(ctx, "su", true)
+}
@@ -0,0 +1,233 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FLEET_PARALLELISM is the default number of devices a fleet operation
+// works on at the same time
+const FLEET_PARALLELISM = 4
+
+// Manager runs operations on a fleet of chargers
+type Manager struct {
+	mu          sync.RWMutex
+	devices     []*Wattpilot
+	parallelism int
+	bus         *Pubsub
+	tags        map[*Wattpilot][]string
+	limits      map[string]float64
+	policies    map[string]FleetPolicy
+	configs     map[*Wattpilot]DeviceConfig
+	clock       Clock
+}
+
+// FleetError collects the errors of a fleet operation keyed by the serial
+// of the device, or its host while the serial is not known yet
+type FleetError map[string]error
+
+func (e FleetError) Error() string {
+	ids := Keys(e)
+	sort.Strings(ids)
+	messages := make([]string, 0, len(ids))
+	for _, id := range ids {
+		messages = append(messages, fmt.Sprintf("%s: %v", id, e[id]))
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e FleetError) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, err := range e {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func NewManager(devices ...*Wattpilot) *Manager {
+	m := &Manager{
+		parallelism: FLEET_PARALLELISM,
+		bus:         NewPubsub(),
+		tags:        make(map[*Wattpilot][]string),
+		limits:      make(map[string]float64),
+		policies:    make(map[string]FleetPolicy),
+		configs:     make(map[*Wattpilot]DeviceConfig),
+		clock:       realClock{},
+	}
+	for _, w := range devices {
+		m.Add(w)
+	}
+	return m
+}
+
+// SetParallelism limits the number of devices worked on concurrently
+func (m *Manager) SetParallelism(parallelism int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if parallelism > 0 {
+		m.parallelism = parallelism
+	}
+}
+
+// SetClock replaces the clock of the fleet schedulers
+func (m *Manager) SetClock(clock Clock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = clock
+}
+
+// Add manages the device, optionally as member of the groups in tags
+func (m *Manager) Add(w *Wattpilot, tags ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.devices = append(m.devices, w)
+	m.tags[w] = append([]string{}, tags...)
+	w._fleet.Store(m)
+}
+
+func (m *Manager) Remove(w *Wattpilot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.devices = remove(m.devices, w)
+	delete(m.tags, w)
+	delete(m.configs, w)
+	w._fleet.CompareAndSwap(m, nil)
+}
+
+func (m *Manager) Devices() []*Wattpilot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*Wattpilot{}, m.devices...)
+}
+
+// Device looks up a managed charger by serial or host
+func (m *Manager) Device(id string) (*Wattpilot, bool) {
+	for _, w := range m.Devices() {
+		if deviceId(w) == id || w.GetHost() == id {
+			return w, true
+		}
+	}
+	return nil, false
+}
+
+func deviceId(w *Wattpilot) string {
+	if serial := w.GetSerial(); serial != "" {
+		return serial
+	}
+	if w._cloudSerial != "" {
+		return w._cloudSerial
+	}
+	return w.GetHost()
+}
+
+// ForEach calls fn for all devices with bounded parallelism. Devices not
+// started before the context ends fail with the error of the context.
+func (m *Manager) ForEach(ctx context.Context, fn func(ctx context.Context, w *Wattpilot) error) error {
+	return m.forEach(ctx, m.Devices(), fn)
+}
+
+func (m *Manager) forEach(ctx context.Context, devices []*Wattpilot, fn func(ctx context.Context, w *Wattpilot) error) error {
+	m.mu.RLock()
+	slots := make(chan struct{}, m.parallelism)
+	m.mu.RUnlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := FleetError{}
+	fail := func(w *Wattpilot, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs[deviceId(w)] = err
+	}
+
+	for _, w := range devices {
+		select {
+		case <-ctx.Done():
+			fail(w, ctx.Err())
+			continue
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(w *Wattpilot) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := fn(ctx, w); err != nil {
+				fail(w, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Connect connects all devices which are not connected yet, devices added
+// with a configuration are deduplicated by serial afterwards
+func (m *Manager) Connect(ctx context.Context) error {
+	err := m.ForEach(ctx, func(ctx context.Context, w *Wattpilot) error {
+		return w.Connect()
+	})
+	merged := m.deduplicate()
+	if len(merged) == 0 {
+		return err
+	}
+	errs, _ := err.(FleetError)
+	if errs == nil {
+		errs = FleetError{}
+	}
+	// failures of the replaced devices are superseded by the merged ones
+	for _, w := range merged {
+		delete(errs, deviceId(w))
+		delete(errs, w.GetHost())
+	}
+	if mergeErr, ok := m.forEach(ctx, merged, func(ctx context.Context, w *Wattpilot) error {
+		return w.Connect()
+	}).(FleetError); ok {
+		for id, err := range mergeErr {
+			errs[id] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Disconnect closes the connections of all devices. Unlike
+// Wattpilot.Disconnect it does not wait for an interrupt, the devices can
+// be connected again with Connect.
+func (m *Manager) Disconnect() {
+	for _, w := range m.Devices() {
+		w.disconnectImpl()
+	}
+}
+
+// SetProperty writes the property on all devices, e.g. to push a
+// configuration to the fleet
+func (m *Manager) SetProperty(ctx context.Context, name string, value interface{}) error {
+	return m.ForEach(ctx, func(ctx context.Context, w *Wattpilot) error {
+		return w.SetProperty(name, value)
+	})
+}
+
+// Reboot restarts all devices, e.g. after a firmware rollout, it needs the
+// confirmation like Wattpilot.Reboot
+func (m *Manager) Reboot(ctx context.Context, confirm bool) error {
+	if !confirm {
+		return ErrNotConfirmed
+	}
+	return m.ForEach(ctx, func(ctx context.Context, w *Wattpilot) error {
+		return w.Reboot(ctx, confirm)
+	})
+}
@@ -0,0 +1,46 @@
+package wattpilot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+func TestManagerDisconnect(t *testing.T) {
+	manager := wattpilot.NewManager()
+	for _, serial := range []string{"90000001", "90000002"} {
+		mock, err := wattpilottest.NewMockCharger(serial, testPassword, map[string]interface{}{"car": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(mock.Close)
+		client := wattpilot.New(mock.Addr(), testPassword)
+		t.Cleanup(func() { client.Close() })
+		manager.Add(client)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := manager.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		manager.Disconnect()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("Disconnect did not return")
+	}
+	for _, w := range manager.Devices() {
+		if w.IsInitialized() {
+			t.Error(w.GetSerial(), " still connected")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package wattpilot
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// WEAK_SIGNAL_RSSI is the default threshold for weak signal warnings in dBm
+	WEAK_SIGNAL_RSSI = -75
+	// SIGNAL_HYSTERESIS in dB the signal has to recover above the threshold
+	SIGNAL_HYSTERESIS = 5
+
+	EventWeakSignal EventType = "weakSignal"
+)
+
+var ErrNoWifi = errors.New("charger is not connected by wifi")
+
+// NetworkDiagnostics collects the network state reported by the charger and
+// the quality of the connection of this client
+type NetworkDiagnostics struct {
+	Rssi       float64
+	Ssid       string
+	WifiStatus int
+	Errors     int
+	LastError  string
+	WeakSignal bool
+	Connection ConnectionQuality
+}
+
+type signalTracker struct {
+	mu        sync.Mutex
+	threshold float64
+	weak      bool
+}
+
+// WithWeakSignalThreshold sets the rssi in dBm below which EventWeakSignal
+// is emitted
+func WithWeakSignalThreshold(rssi float64) Option {
+	return func(w *Wattpilot) {
+		w._signal.threshold = rssi
+	}
+}
+
+// GetRssi returns the wifi signal strength in dBm
+func (w *Wattpilot) GetRssi() (float64, error) {
+	v, err := w.GetProperty("rssi")
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, ErrNoWifi
+	}
+	return toFloat(v)
+}
+
+func (w *Wattpilot) GetWifiSsid() (string, error) {
+	v, err := w.GetProperty("wss")
+	if err != nil {
+		return "", err
+	}
+	ssid, _ := v.(string)
+	return ssid, nil
+}
+
+// IsWeakSignal reports whether the rssi is below the weak signal threshold
+func (w *Wattpilot) IsWeakSignal() bool {
+	w._signal.mu.Lock()
+	defer w._signal.mu.Unlock()
+	return w._signal.weak
+}
+
+func (w *Wattpilot) NetworkDiagnostics() (NetworkDiagnostics, error) {
+	diagnostics := NetworkDiagnostics{
+		WeakSignal: w.IsWeakSignal(),
+		Connection: w.ConnectionQuality(),
+	}
+	rssi, err := w.GetRssi()
+	if err != nil {
+		return diagnostics, err
+	}
+	diagnostics.Rssi = rssi
+	diagnostics.Ssid, _ = w.GetWifiSsid()
+	if status, err := w.getFloatProperty("wst"); err == nil {
+		diagnostics.WifiStatus = int(status)
+	}
+	if count, err := w.getFloatProperty("wsc"); err == nil {
+		diagnostics.Errors = int(count)
+	}
+	if v, err := w.GetProperty("wsm"); err == nil {
+		diagnostics.LastError, _ = v.(string)
+	}
+	return diagnostics, nil
+}
+
+// trackSignal is called with the read mutex held for every status update
+func (w *Wattpilot) trackSignal(updates map[string]interface{}) {
+	v, ok := updates["rssi"]
+	if !ok || v == nil {
+		return
+	}
+	rssi, err := toFloat(v)
+	if err != nil {
+		return
+	}
+
+	t := w._signal
+	t.mu.Lock()
+	weak := t.weak
+	if rssi < t.threshold {
+		weak = true
+	} else if rssi >= t.threshold+SIGNAL_HYSTERESIS {
+		weak = false
+	}
+	changed := weak != t.weak
+	t.weak = weak
+	t.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if weak {
+		w.logEntry().Warn("Weak wifi signal: ", rssi, " dBm")
+	}
+	w.emitEvent(EventWeakSignal, map[string]interface{}{
+		"rssi": rssi,
+		"weak": weak,
+	})
+}
@@ -0,0 +1,94 @@
+package wattpilot
+
+import "errors"
+
+var ErrObserverMode = errors.New("observer connections can not write")
+
+// Observer is the read-only view on a charger used by dashboards and
+// kiosks. It only consumes the status and has no methods creating write
+// frames, so consumers can not change the charger by accident.
+type Observer interface {
+	Connect() error
+	Disconnect()
+	IsInitialized() bool
+	GetName() string
+	GetSerial() string
+	GetHost() string
+	Properties() []string
+	Alias() []string
+	LookupAlias(name string) string
+	GetProperty(name string) (interface{}, error)
+	GetNotifications(prop string) <-chan interface{}
+	GetEvents(eventType EventType) <-chan interface{}
+	Snapshot() Snapshot
+	Close() error
+}
+
+type observer struct {
+	w *Wattpilot
+}
+
+// NewObserver connects read-only, an empty password is allowed for
+// chargers without authentication
+func NewObserver(host string, password string, options ...Option) Observer {
+	options = append([]Option{func(w *Wattpilot) { w._observer = true }}, options...)
+	return &observer{w: New(host, password, options...)}
+}
+
+func (o *observer) Connect() error {
+	return o.w.Connect()
+}
+
+func (o *observer) Disconnect() {
+	o.w.Disconnect()
+}
+
+// Close stops the background processing of the underlying charger
+// connection, the observer can not be used afterwards
+func (o *observer) Close() error {
+	return o.w.Close()
+}
+
+func (o *observer) IsInitialized() bool {
+	return o.w.IsInitialized()
+}
+
+func (o *observer) GetName() string {
+	return o.w.GetName()
+}
+
+func (o *observer) GetSerial() string {
+	return o.w.GetSerial()
+}
+
+func (o *observer) GetHost() string {
+	return o.w.GetHost()
+}
+
+func (o *observer) Properties() []string {
+	return o.w.Properties()
+}
+
+func (o *observer) Alias() []string {
+	return o.w.Alias()
+}
+
+func (o *observer) LookupAlias(name string) string {
+	return o.w.LookupAlias(name)
+}
+
+func (o *observer) GetProperty(name string) (interface{}, error) {
+	return o.w.GetProperty(name)
+}
+
+func (o *observer) GetNotifications(prop string) <-chan interface{} {
+	return o.w.GetNotifications(prop)
+}
+
+func (o *observer) GetEvents(eventType EventType) <-chan interface{} {
+	return o.w.GetEvents(eventType)
+}
+
+func (o *observer) Snapshot() Snapshot {
+	return o.w.Snapshot()
+}
@@ -0,0 +1,18 @@
+package wattpilot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestObserverCloseStopsProcessing(t *testing.T) {
+	o := NewObserver("localhost", "")
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-o.(*observer).w._loopDone:
+	case <-time.After(time.Second):
+		t.Fatal("process loop still running after Close")
+	}
+}
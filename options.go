@@ -0,0 +1,70 @@
+package wattpilot
+
+import "time"
+
+// Option configures a Wattpilot instance on creation
+type Option func(*Wattpilot)
+
+// WithClock replaces the clock used for polling and reconnect delays
+func WithClock(clock Clock) Option {
+	return func(w *Wattpilot) {
+		w._clock = clock
+	}
+}
+
+// WithDryRun logs all writes instead of sending them to the charger
+func WithDryRun() Option {
+	return func(w *Wattpilot) {
+		w.SetDryRun(true)
+	}
+}
+
+// WithUserAgent sets the User-Agent sent in the websocket handshake, so
+// the charger logs can tell the clients apart
+func WithUserAgent(userAgent string) Option {
+	return func(w *Wattpilot) {
+		w._userAgent = userAgent
+	}
+}
+
+// WithReadTimeout sets the duration of silence after which the connection
+// is considered dead, zero disables the deadline
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(w *Wattpilot) {
+		w._readTimeout = timeout
+	}
+}
+
+// WithFullDump disables the redaction of authentication material in logs
+func WithFullDump() Option {
+	return func(w *Wattpilot) {
+		w.SetFullDump(true)
+	}
+}
+
+// WithNotificationBuffer sets the number of updates queued per subscriber
+// before further updates are dropped for it, see SubscriberStats
+func WithNotificationBuffer(size int) Option {
+	return func(w *Wattpilot) {
+		w._notifications.SetBufferSize(size)
+	}
+}
+
+// WithNotificationWorkers delivers the notifications by a pool of workers
+// with a queue of the given length each, instead of on the receive loop.
+// The receive loop waits while a queue is full, see DispatchStats.
+func WithNotificationWorkers(workers int, queue int) Option {
+	return func(w *Wattpilot) {
+		w._notifications.SetWorkers(workers, queue)
+	}
+}
+
+// WithFrameCapture passes every message received from the charger and
+// every message sent to it to f, on the receive loop and the sending
+// goroutine. Secured messages are passed as sent, outgoing ones before
+// they are wrapped. The messages contain authentication material.
+func WithFrameCapture(f func(incoming bool, message map[string]interface{})) Option {
+	return func(w *Wattpilot) {
+		w._capture = f
+	}
+}
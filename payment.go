@@ -0,0 +1,97 @@
+package wattpilot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var ErrNotAuthorized = errors.New("guest session not authorized")
+
+// Authorizer gates guest sessions on an external system, e.g. a payment
+// provider. Authorize is asked before the charger is released, Complete
+// receives the record of the finished session with the consumed energy.
+type Authorizer interface {
+	Authorize(ctx context.Context, session GuestSession) error
+	Complete(ctx context.Context, session GuestSession) error
+}
+
+// WithGuestAuthorizer gates all guest sessions on the authorizer
+func WithGuestAuthorizer(a Authorizer) Option {
+	return func(w *Wattpilot) {
+		w._guest.authorizer = a
+	}
+}
+
+func (w *Wattpilot) authorizeGuest(record GuestSession) error {
+	a := w._guest.authorizer
+	if a == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*CONTEXT_TIMEOUT)
+	defer cancel()
+	if err := a.Authorize(ctx, record); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotAuthorized, err)
+	}
+	return nil
+}
+
+func (w *Wattpilot) completeGuest(record GuestSession) {
+	a := w._guest.authorizer
+	if a == nil || record.Reason == "failed" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*CONTEXT_TIMEOUT)
+	defer cancel()
+	if err := a.Complete(ctx, record); err != nil {
+		w.logEntry().Warn("Could not post guest session of ", record.Guest, ": ", err)
+	}
+}
+
+// WebhookAuthorizer posts the guest session as JSON to the URLs, a status
+// below 300 authorizes the session
+type WebhookAuthorizer struct {
+	AuthorizeURL string
+	CompleteURL  string
+	Client       *http.Client
+}
+
+func (h *WebhookAuthorizer) Authorize(ctx context.Context, session GuestSession) error {
+	return h.post(ctx, h.AuthorizeURL, session)
+}
+
+func (h *WebhookAuthorizer) Complete(ctx context.Context, session GuestSession) error {
+	if h.CompleteURL == "" {
+		return nil
+	}
+	return h.post(ctx, h.CompleteURL, session)
+}
+
+func (h *WebhookAuthorizer) post(ctx context.Context, url string, session GuestSession) error {
+	body, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s answered %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package wattpilot_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+type webhookCall struct {
+	path    string
+	session wattpilot.GuestSession
+}
+
+// webhook answers the authorization with the status and reports the posted
+// guest sessions
+func webhook(t *testing.T, status int) (*wattpilot.WebhookAuthorizer, <-chan webhookCall) {
+	t.Helper()
+	calls := make(chan webhookCall, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var session wattpilot.GuestSession
+		if err := json.NewDecoder(r.Body).Decode(&session); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		calls <- webhookCall{path: r.URL.Path, session: session}
+		if r.URL.Path == "/authorize" {
+			rw.WriteHeader(status)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &wattpilot.WebhookAuthorizer{AuthorizeURL: server.URL + "/authorize", CompleteURL: server.URL + "/complete"}, calls
+}
+
+func nextCall(t *testing.T, calls <-chan webhookCall, path string) wattpilot.GuestSession {
+	t.Helper()
+	select {
+	case call := <-calls:
+		if call.path != path {
+			t.Fatalf("expected a call of %s, got %s", path, call.path)
+		}
+		return call.session
+	case <-time.After(testTimeout):
+		t.Fatal("webhook ", path, " not called")
+	}
+	return wattpilot.GuestSession{}
+}
+
+func TestWebhookAuthorizesGuestSession(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC))
+	authorizer, calls := webhook(t, http.StatusOK)
+	client, mock := guestCharger(t, clock, wattpilot.WithGuestAuthorizer(authorizer))
+
+	limits := wattpilot.GuestLimits{Energy: 5000}
+	startGuest(t, client, "guest", 3, limits)
+	if session := nextCall(t, calls, "/authorize"); session.Guest != "guest" || session.Card != 3 || session.Limits != limits {
+		t.Fatalf("unexpected authorization %+v", session)
+	}
+	waitForCharger(t, mock, "frc", float64(wattpilot.ForceNeutral))
+
+	// the completion bills the charged energy
+	mock.Update(map[string]interface{}{"wh": 2100})
+	waitForGuestEnergy(t, client, 2000)
+	if err := client.StopGuestSession(); err != nil {
+		t.Fatal(err)
+	}
+	if session := nextCall(t, calls, "/complete"); session.Guest != "guest" || session.Energy != 2000 || session.Reason != "stopped" || session.Ended.IsZero() {
+		t.Fatalf("unexpected completion %+v", session)
+	}
+}
+
+func TestWebhookRefusesGuestSession(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 18, 0, 0, 0, time.UTC))
+	authorizer, calls := webhook(t, http.StatusPaymentRequired)
+	client, mock := guestCharger(t, clock, wattpilot.WithGuestAuthorizer(authorizer))
+
+	if err := client.StartGuestSession("guest", 0, wattpilot.GuestLimits{}); !errors.Is(err, wattpilot.ErrNotAuthorized) {
+		t.Fatal("expected ErrNotAuthorized, got ", err)
+	}
+	nextCall(t, calls, "/authorize")
+	if _, isActive := client.ActiveGuestSession(); isActive {
+		t.Fatal("refused guest session active")
+	}
+	if len(client.GuestSessions()) != 0 {
+		t.Fatal("refused guest session recorded")
+	}
+	// the charger stays paused and nothing is billed
+	if frc := mock.Status()["frc"]; frc != 1 {
+		t.Fatal("charger released: frc ", frc)
+	}
+	select {
+	case call := <-calls:
+		t.Fatal("unexpected webhook call ", call.path)
+	default:
+	}
+}
@@ -0,0 +1,121 @@
+package wattpilot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	PLUG_DEBOUNCE = 5 // seconds
+
+	EventCarConnected    EventType = "carConnected"
+	EventCarDisconnected EventType = "carDisconnected"
+)
+
+// CarSession describes the period a car is plugged in
+type CarSession struct {
+	Connected     time.Time
+	Disconnected  time.Time
+	Card          int
+	CarIdentifier string
+}
+
+type plugWatcher struct {
+	once         sync.Once
+	mu           sync.Mutex
+	debounce     time.Duration
+	session      CarSession
+	connected    []func(CarSession)
+	disconnected []func(CarSession)
+}
+
+// WithPlugDebounce sets how long the car state has to be stable before a
+// plug-in or unplug is reported
+func WithPlugDebounce(debounce time.Duration) Option {
+	return func(w *Wattpilot) {
+		w._plug.debounce = debounce
+	}
+}
+
+// OnCarConnected registers a callback for debounced plug-in events
+func (w *Wattpilot) OnCarConnected(f func(CarSession)) {
+	w._plug.mu.Lock()
+	w._plug.connected = append(w._plug.connected, f)
+	w._plug.mu.Unlock()
+	w._plug.once.Do(func() { w.goBackground(w.watchPlug) })
+}
+
+// OnCarDisconnected registers a callback for debounced unplug events
+func (w *Wattpilot) OnCarDisconnected(f func(CarSession)) {
+	w._plug.mu.Lock()
+	w._plug.disconnected = append(w._plug.disconnected, f)
+	w._plug.mu.Unlock()
+	w._plug.once.Do(func() { w.goBackground(w.watchPlug) })
+}
+
+// watchPlug debounces the car state until the context is cancelled by Close
+func (w *Wattpilot) watchPlug(ctx context.Context) {
+	updates := w.GetNotifications("car")
+	defer w._notifications.Unsubscribe("car", updates)
+	timer := w._clock.NewTimer(w._plug.debounce)
+	if !timer.Stop() {
+		<-timer.C()
+	}
+	defer timer.Stop()
+
+	plugged, candidate := false, false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case value, ok := <-updates:
+			if !ok {
+				return
+			}
+			state, err := toFloat(value)
+			if err != nil {
+				continue
+			}
+			candidate = isPlugged(int(state))
+			// a tick already delivered would end the new debounce at once
+			if !timer.Stop() {
+				select {
+				case <-timer.C():
+				default:
+				}
+			}
+			timer.Reset(w._plug.debounce)
+		case <-timer.C():
+			if candidate == plugged {
+				continue
+			}
+			plugged = candidate
+			w.onPlugChange(plugged)
+		}
+	}
+}
+
+func (w *Wattpilot) onPlugChange(plugged bool) {
+	w._plug.mu.Lock()
+	now := w._clock.Now()
+	var callbacks []func(CarSession)
+	eventType := EventCarConnected
+	if plugged {
+		card, carIdentifier, _ := w.currentCar()
+		w._plug.session = CarSession{Connected: now, Card: card, CarIdentifier: carIdentifier}
+		callbacks = append(callbacks, w._plug.connected...)
+	} else {
+		w._plug.session.Disconnected = now
+		callbacks = append(callbacks, w._plug.disconnected...)
+		eventType = EventCarDisconnected
+	}
+	session := w._plug.session
+	w._plug.mu.Unlock()
+
+	w.logEntry().Info("Car plugged: ", plugged)
+	w.emitEvent(eventType, map[string]interface{}{"session": session})
+	for _, f := range callbacks {
+		f(session)
+	}
+}
@@ -0,0 +1,26 @@
+package wattpilot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlugWatcherEndsWithClose(t *testing.T) {
+	w := New("localhost", "")
+	w.OnCarConnected(func(CarSession) {})
+	deadline := time.Now().Add(time.Second)
+	for len(w.SubscriberStats()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("plug watcher did not subscribe to the car state")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	w.Close()
+
+	for len(w.SubscriberStats()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("plug watcher still subscribed after Close: ", w.SubscriberStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,245 @@
+package wattpilot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	SOURCE_PRESET = "preset"
+
+	EventPresetApplied EventType = "presetApplied"
+)
+
+var ErrUnknownPreset = errors.New("unknown preset")
+
+// logic modes of lmo
+const (
+	logicModeDefault = 3
+	logicModeEco     = 4
+)
+
+// Preset is a named bundle of property writes and controller settings,
+// e.g. "fast" or "pv-only", applied at once with ApplyPreset
+type Preset struct {
+	Name string `json:"name"`
+	// Properties are written by key or alias
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	// Controllers enables or disables controllers run by RunControllers by
+	// name, controllers not listed keep their state
+	Controllers map[string]bool `json:"controllers,omitempty"`
+}
+
+// DefaultPresets are available without a preset file
+func DefaultPresets() []Preset {
+	return []Preset{
+		{
+			Name:       "fast",
+			Properties: map[string]interface{}{"lmo": logicModeDefault, "fup": false, "psm": phaseSwitchThree, "amp": 16, "frc": int(ForceNeutral)},
+		},
+		{
+			Name:       "pv-only",
+			Properties: map[string]interface{}{"lmo": logicModeEco, "fup": true, "psm": phaseSwitchAuto, "frc": int(ForceNeutral)},
+		},
+		{
+			Name:        "night",
+			Properties:  map[string]interface{}{"lmo": logicModeDefault, "fup": false, "psm": phaseSwitchSingle, "amp": 10},
+			Controllers: map[string]bool{"quietHours": true},
+		},
+		{
+			Name:       "visitor 10A",
+			Properties: map[string]interface{}{"lmo": logicModeDefault, "fup": false, "amp": 10, "frc": int(ForceNeutral)},
+		},
+	}
+}
+
+// PresetStore holds the presets, persisted as JSON in the file given to
+// LoadPresets
+type PresetStore struct {
+	mu      sync.Mutex
+	path    string
+	presets map[string]Preset
+}
+
+func newPresetStore(path string) *PresetStore {
+	s := &PresetStore{path: path, presets: make(map[string]Preset)}
+	for _, p := range DefaultPresets() {
+		s.presets[p.Name] = p
+	}
+	return s
+}
+
+// LoadPresets reads the presets, a missing file starts with the defaults
+func LoadPresets(path string) (*PresetStore, error) {
+	s := newPresetStore(path)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	presets := []Preset{}
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	s.presets = make(map[string]Preset)
+	for _, p := range presets {
+		s.presets[p.Name] = p
+	}
+	return s, nil
+}
+
+func (s *PresetStore) Get(name string) (Preset, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, isKnown := s.presets[name]
+	return p, isKnown
+}
+
+func (s *PresetStore) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := Keys(s.presets)
+	sort.Strings(names)
+	return names
+}
+
+// Save adds or replaces the preset and saves the file
+func (s *PresetStore) Save(preset Preset) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.presets[preset.Name] = preset
+	return s.save()
+}
+
+func (s *PresetStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.presets, name)
+	return s.save()
+}
+
+func (s *PresetStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	names := Keys(s.presets)
+	sort.Strings(names)
+	presets := make([]Preset, 0, len(names))
+	for _, name := range names {
+		presets = append(presets, s.presets[name])
+	}
+	data, err := json.MarshalIndent(presets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// WithPresets replaces the default presets by the ones of the store
+func WithPresets(store *PresetStore) Option {
+	return func(w *Wattpilot) {
+		w._presets = store
+	}
+}
+
+func (w *Wattpilot) Presets() *PresetStore {
+	return w._presets
+}
+
+// ApplyPreset writes all properties of the preset or none: the writes are
+// checked upfront, and when a write fails the ones before are reverted.
+// The controller settings are applied once all writes succeeded.
+func (w *Wattpilot) ApplyPreset(name string) error {
+	return w.activatePreset(name, "preset "+name+" requested")
+}
+
+func (w *Wattpilot) activatePreset(name string, reason string) error {
+	preset, isKnown := w._presets.Get(name)
+	if !isKnown {
+		return fmt.Errorf("%s: %w", name, ErrUnknownPreset)
+	}
+	err := w.applyPreset(preset)
+	w.recordDecision(Decision{
+		Source:   SOURCE_PRESET,
+		Inputs:   map[string]interface{}{"properties": preset.Properties, "controllers": preset.Controllers},
+		Setpoint: preset.Name,
+		Action:   "applyPreset",
+		Reason:   reason,
+		Error:    err,
+	})
+	if err != nil {
+		return fmt.Errorf("preset %s: %w", name, err)
+	}
+	for _, controller := range Keys(preset.Controllers) {
+		w.EnableController(controller, preset.Controllers[controller])
+	}
+	w.emitEvent(EventPresetApplied, map[string]interface{}{"preset": preset.Name})
+	return nil
+}
+
+func (w *Wattpilot) applyPreset(preset Preset) error {
+	if !w._isInitialized.Load() {
+		return ErrNotConnected
+	}
+	keys := make([]string, 0, len(preset.Properties))
+	values := make(map[string]interface{}, len(preset.Properties))
+	for name, value := range preset.Properties {
+		key := resolveKey(name)
+		keys = append(keys, key)
+		values[key] = value
+	}
+	sort.Strings(keys)
+
+	previous := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, err := w.checkPresetWrite(key)
+		if err != nil {
+			return err
+		}
+		previous[key] = value
+	}
+
+	for i, key := range keys {
+		err := w.setProperty(SOURCE_PRESET, key, values[key])
+		if err == nil {
+			continue
+		}
+		var errs []error
+		for _, written := range keys[:i] {
+			if rollbackErr := w.setProperty(SOURCE_PRESET, written, previous[written]); rollbackErr != nil {
+				errs = append(errs, fmt.Errorf("reverting %s: %w", written, rollbackErr))
+			}
+		}
+		return errors.Join(append([]error{fmt.Errorf("writing %s: %w", key, err)}, errs...)...)
+	}
+	return nil
+}
+
+// checkPresetWrite returns the current value of the key, or the error a
+// write of the key would fail with
+func (w *Wattpilot) checkPresetWrite(key string) (interface{}, error) {
+	if w.isInstallationLocked(key) {
+		return nil, fmt.Errorf("%s: %w", key, ErrInstallationSettingsLocked)
+	}
+	w._readMutex.Lock()
+	value, isKnown := w._status[key]
+	w._readMutex.Unlock()
+	if !isKnown {
+		return nil, fmt.Errorf("could not find reference for update on %s: %w", key, ErrPropertyNotFound)
+	}
+	if !w.mayControl(SOURCE_PRESET, key) {
+		owner, _ := w.ControlOwner(key)
+		return nil, fmt.Errorf("%s controlled by %s: %w", key, owner, ErrControlledByOtherSource)
+	}
+	return value, nil
+}
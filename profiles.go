@@ -0,0 +1,125 @@
+package wattpilot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// carStateIdle is reported while no car is plugged in
+const carStateIdle = 1
+
+// isPlugged reports whether the car state needs a plugged car, the states
+// 2-5 (charging, waiting, complete, error). The unknown state 0 does not.
+func isPlugged(car int) bool {
+	return car > carStateIdle
+}
+
+// CarProfile holds the charging settings of a vehicle. It is selected by
+// the RFID card (the transaction value trx, card index + 1) or by the car
+// identifier cak reported by the charger.
+type CarProfile struct {
+	Name          string
+	Card          int
+	CarIdentifier string
+	// MaxCurrent in ampere, zero keeps the current setting
+	MaxCurrent float64
+	// EnergyLimit in Wh, zero keeps the current setting
+	EnergyLimit float64
+	// Properties are additional raw keys to write
+	Properties map[string]interface{}
+}
+
+func (p CarProfile) matches(card int, carIdentifier string) bool {
+	if p.Card > 0 && p.Card == card {
+		return true
+	}
+	return p.CarIdentifier != "" && p.CarIdentifier == carIdentifier
+}
+
+func (p CarProfile) apply(s *Session) error {
+	var errs []error
+	if p.MaxCurrent > 0 {
+		errs = append(errs, s.SetProperty("amp", p.MaxCurrent))
+	}
+	if p.EnergyLimit > 0 {
+		errs = append(errs, s.SetProperty("dwo", p.EnergyLimit))
+	}
+	keys := Keys(p.Properties)
+	sort.Strings(keys)
+	for _, key := range keys {
+		errs = append(errs, s.SetProperty(key, p.Properties[key]))
+	}
+	return errors.Join(errs...)
+}
+
+func (w *Wattpilot) currentCar() (int, string, bool) {
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	state, err := toFloat(w._status["car"])
+	if err != nil || !isPlugged(int(state)) {
+		return 0, "", false
+	}
+	card := 0
+	if trx, err := toFloat(w._status["trx"]); err == nil {
+		card = int(trx)
+	}
+	carIdentifier := ""
+	if cak, ok := w._status["cak"].(string); ok {
+		carIdentifier = cak
+	}
+	return card, carIdentifier, true
+}
+
+// RunCarProfiles applies the matching profile once per plug-in as soon as
+// the card or car identifier is known, until the context is cancelled.
+func (w *Wattpilot) RunCarProfiles(ctx context.Context, profiles []CarProfile, onApply func(CarProfile, error)) {
+	session := w.NewSession("carProfiles", false)
+	defer session.Close()
+
+	updates := []<-chan interface{}{
+		session.GetNotifications("car"),
+		session.GetNotifications("trx"),
+		session.GetNotifications("cak"),
+	}
+	applied := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-updates[0]:
+		case <-updates[1]:
+		case <-updates[2]:
+		}
+		card, carIdentifier, plugged := w.currentCar()
+		if !plugged {
+			applied = false
+			continue
+		}
+		if applied {
+			continue
+		}
+		for _, profile := range profiles {
+			if !profile.matches(card, carIdentifier) {
+				continue
+			}
+			w.logEntry().Info("Applying car profile ", profile.Name)
+			err := profile.apply(session)
+			applied = true
+			w.recordDecision(Decision{
+				Source:   "carProfiles",
+				Inputs:   map[string]interface{}{"trx": card, "cak": carIdentifier},
+				Setpoint: profile.Name,
+				Action:   "applyProfile",
+				Reason:   fmt.Sprintf("car matched profile %s", profile.Name),
+				Error:    err,
+			})
+			if onApply != nil {
+				onApply(profile, err)
+			}
+			break
+		}
+	}
+}
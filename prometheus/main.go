@@ -20,6 +20,7 @@ const wattpilotPrefix = "wattpilot_%s"
 type wattpilotCollector struct {
 	charger *wattpilot.Wattpilot
 	metrics map[string]*prometheus.Desc
+	quality map[string]*prometheus.Desc
 }
 
 func remove[T comparable](l []T, item T) []T {
@@ -74,13 +75,29 @@ func newWattpilotCollector(charger *wattpilot.Wattpilot) *wattpilotCollector {
 			nil, constLabels,
 		)
 	}
-	return &wattpilotCollector{metrics: metrics, charger: charger}
+
+	quality := make(map[string]*prometheus.Desc)
+	for key, help := range map[string]string{
+		"connection_rtt_seconds":     "Average round trip time of requests",
+		"connection_rtt_max_seconds": "Maximum round trip time of requests",
+		"connection_gap_seconds":     "Average gap between received messages",
+		"connection_gap_max_seconds": "Maximum gap between received messages",
+		"connection_lost_requests":   "Requests which were never answered",
+		"relay_contactor_cycles":     "Contactor cycles counted since the wear file was created",
+		"relay_phase_switches":       "Phase switches counted since the wear file was created",
+	} {
+		quality[key] = prometheus.NewDesc(fmt.Sprintf(wattpilotPrefix, key), help, nil, constLabels)
+	}
+	return &wattpilotCollector{metrics: metrics, quality: quality, charger: charger}
 }
 
 func (collector *wattpilotCollector) Describe(ch chan<- *prometheus.Desc) {
 	for _, m := range collector.metrics {
 		ch <- m
 	}
+	for _, m := range collector.quality {
+		ch <- m
+	}
 }
 
 // Collect implements required collect function for all promehteus collectors
@@ -118,6 +135,22 @@ func (collector *wattpilotCollector) Collect(ch chan<- prometheus.Metric) {
 		metric := prometheus.NewMetricWithTimestamp(time.Now(), m)
 		ch <- metric
 	}
+
+	quality := collector.charger.ConnectionQuality()
+	for key, value := range map[string]float64{
+		"connection_rtt_seconds":     quality.RTTAvg.Seconds(),
+		"connection_rtt_max_seconds": quality.RTTMax.Seconds(),
+		"connection_gap_seconds":     quality.GapAvg.Seconds(),
+		"connection_gap_max_seconds": quality.GapMax.Seconds(),
+		"connection_lost_requests":   float64(quality.Lost),
+	} {
+		ch <- prometheus.MustNewConstMetric(collector.quality[key], prometheus.GaugeValue, value)
+	}
+
+	if wear, isTracked := collector.charger.RelayWear(); isTracked {
+		ch <- prometheus.MustNewConstMetric(collector.quality["relay_contactor_cycles"], prometheus.CounterValue, float64(wear.ContactorCycles))
+		ch <- prometheus.MustNewConstMetric(collector.quality["relay_phase_switches"], prometheus.CounterValue, float64(wear.PhaseSwitches))
+	}
 }
 
 func main() {
@@ -132,9 +165,18 @@ func main() {
 		level = "WARN"
 	}
 
-	charger := wattpilot.New(host, pwd)
+	options := []wattpilot.Option{}
+	if path := os.Getenv("WATTPILOT_WEAR_FILE"); path != "" {
+		wear, err := wattpilot.LoadWearCounter(path)
+		if err != nil {
+			log.Fatalln("Could not load relay wear", err)
+		}
+		options = append(options, wattpilot.WithRelayWear(wear))
+	}
+
+	charger := wattpilot.New(host, pwd, options...)
 	if err := charger.ParseLogLevel(level); err != nil {
-		log.Fatalf("Could not update loglevel to %s: %w", level, err)
+		log.Fatalf("Could not update loglevel to %s: %v", level, err)
 	}
 
 	charger.Connect()
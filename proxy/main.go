@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	api "github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/auth"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// wattpilot-proxy holds the single connection to the charger and serves
+// the same websocket protocol on /ws, so the app or further instances of
+// the library connect through it instead of using up the client slots of
+// the charger.
+
+const PROXY_BUFFER = 1024
+
+type proxy struct {
+	charger  *api.Wattpilot
+	manager  *api.Manager
+	password string
+	hashed   string
+}
+
+type client struct {
+	conn    net.Conn
+	mu      sync.Mutex
+	session *api.Session
+	hashed  string
+}
+
+func (c *client) send(message map[string]interface{}) error {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return wsutil.WriteServerText(c.conn, data)
+}
+
+func (c *client) receive() (map[string]interface{}, error) {
+	for {
+		data, op, err := wsutil.ReadClientData(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if op != ws.OpText && op != ws.OpBinary {
+			continue
+		}
+		message := make(map[string]interface{})
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, err
+		}
+		return message, nil
+	}
+}
+
+func (p *proxy) serve(rw http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, rw)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	c := &client{conn: conn, hashed: p.hashed}
+	if err := p.handshake(c); err != nil {
+		log.Println("client", r.RemoteAddr, "rejected:", err)
+		return
+	}
+	c.session = p.charger.NewSession("proxy:"+r.RemoteAddr, false)
+	defer c.session.Close()
+
+	// subscribe before the full status is taken, so no update is missed
+	updates := p.manager.GetNotifications()
+	defer p.manager.Unsubscribe(updates)
+	if err := c.send(map[string]interface{}{
+		"type":    "fullStatus",
+		"partial": false,
+		"status":  p.charger.RawStatus(),
+	}); err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go c.forward(ctx, updates)
+
+	for {
+		message, err := c.receive()
+		if err != nil {
+			return
+		}
+		if err := c.handle(message); err != nil {
+			log.Println("client", r.RemoteAddr, "closed:", err)
+			return
+		}
+	}
+}
+
+func (p *proxy) handshake(c *client) error {
+	info := p.charger.DeviceInfo()
+	if err := c.send(map[string]interface{}{
+		"type":          "hello",
+		"serial":        info.Serial,
+		"hostname":      info.Hostname,
+		"friendly_name": info.FriendlyName,
+		"manufacturer":  info.Manufacturer,
+		"devicetype":    info.DeviceType,
+		"version":       info.Version,
+		"protocol":      info.Protocol,
+		"secured":       info.Secured,
+	}); err != nil {
+		return err
+	}
+	if p.password == "" {
+		return nil
+	}
+
+	token1, err := auth.Token3()
+	if err != nil {
+		return err
+	}
+	token2, err := auth.Token3()
+	if err != nil {
+		return err
+	}
+	if err := c.send(map[string]interface{}{"type": "authRequired", "token1": token1, "token2": token2}); err != nil {
+		return err
+	}
+	message, err := c.receive()
+	if err != nil {
+		return err
+	}
+	token3, _ := message["token3"].(string)
+	hash, _ := message["hash"].(string)
+	if message["type"] != "auth" || !auth.VerifyResponse(token1, token2, token3, p.hashed, hash) {
+		_ = c.send(map[string]interface{}{"type": "authError", "message": "Wrong password"})
+		return errors.New("wrong password")
+	}
+	return c.send(map[string]interface{}{"type": "authSuccess"})
+}
+
+func (c *client) forward(ctx context.Context, updates <-chan interface{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			event := update.(api.FleetEvent)
+			if event.Kind != api.FleetPropertyChanged {
+				continue
+			}
+			if err := c.send(map[string]interface{}{
+				"type":   "deltaStatus",
+				"status": map[string]interface{}{event.Key: event.Value},
+			}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *client) handle(message map[string]interface{}) error {
+	switch message["type"] {
+	case "securedMsg":
+		data, _ := message["data"].(string)
+		signature, _ := message["hmac"].(string)
+		if c.hashed == "" || !auth.Verify(c.hashed, []byte(data), signature) {
+			return errors.New("hmac of secured message does not match")
+		}
+		inner := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(data), &inner); err != nil {
+			return err
+		}
+		if inner["type"] == "securedMsg" {
+			return errors.New("nested secured message")
+		}
+		// responses refer to the id of the secured message
+		inner["requestId"] = message["requestId"]
+		return c.handle(inner)
+	case "setValue":
+		key, _ := message["key"].(string)
+		response := map[string]interface{}{
+			"type":      "response",
+			"requestId": message["requestId"],
+			"success":   true,
+			"status":    map[string]interface{}{key: message["value"]},
+		}
+		if err := c.session.SetProperty(key, message["value"]); err != nil {
+			response["success"] = false
+			response["message"] = err.Error()
+			delete(response, "status")
+		}
+		return c.send(response)
+	}
+	return nil
+}
+
+// openCapture creates a transcript file in dir and appends the unknown
+// keys to discovered.txt, the input of the property generator
+func openCapture(dir string) (*wattpilottest.Recorder, func(), error) {
+	name := filepath.Join(dir, "capture-"+time.Now().Format("20060102-150405")+".jsonl")
+	out, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	discovered, err := os.OpenFile(filepath.Join(dir, "discovered.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		out.Close()
+		return nil, nil, err
+	}
+	recorder := wattpilottest.NewRecorder(out)
+	recorder.Discovered = discovered
+	log.Println("Capturing to", name)
+	return recorder, func() {
+		out.Close()
+		discovered.Close()
+	}, nil
+}
+
+func main() {
+	host := os.Getenv("WATTPILOT_HOST")
+	pwd := os.Getenv("WATTPILOT_PASSWORD")
+	level := os.Getenv("WATTPILOT_LOG")
+	listen := os.Getenv("PROXY_LISTEN")
+	if pwd == "" {
+		if store, err := api.DefaultCredentialStore(); err == nil {
+			pwd, _ = store.Get(host)
+		}
+	}
+	if host == "" || pwd == "" {
+		return
+	}
+	if level == "" {
+		level = "WARN"
+	}
+	if listen == "" {
+		listen = ":8081"
+	}
+	// clients use the charger password unless the proxy has its own
+	proxyPwd, isSet := os.LookupEnv("PROXY_PASSWORD")
+	if !isSet {
+		proxyPwd = pwd
+	}
+
+	options := []api.Option{}
+	if dir := os.Getenv("PROXY_CAPTURE"); dir != "" {
+		recorder, closeCapture, err := openCapture(dir)
+		if err != nil {
+			log.Fatalln("Could not open capture", err)
+		}
+		defer closeCapture()
+		if keys := os.Getenv("PROXY_CAPTURE_REDACT"); keys != "" {
+			recorder.RedactKeys = strings.Split(keys, ",")
+		}
+		options = append(options, api.WithFrameCapture(recorder.Capture))
+	}
+
+	charger := api.New(host, pwd, options...)
+	if err := charger.ParseLogLevel(level); err != nil {
+		log.Fatalf("Could not update loglevel to %s: %v", level, err)
+	}
+	if err := charger.Connect(); err != nil {
+		log.Fatalln("Could not connect", err)
+	}
+	manager := api.NewManager(charger)
+	manager.SetNotificationBuffer(PROXY_BUFFER)
+
+	p := &proxy{
+		charger:  charger,
+		manager:  manager,
+		password: proxyPwd,
+	}
+	if proxyPwd != "" {
+		p.hashed = auth.HashPassword(proxyPwd, charger.GetSerial())
+	}
+
+	http.HandleFunc("/ws", p.serve)
+	log.Fatal(http.ListenAndServe(listen, nil))
+}
@@ -1,19 +1,164 @@
 package wattpilot
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
-type Pubsub struct {
+const (
+	PUBSUB_BUFFER_SIZE    = 16
+	SLOW_SUBSCRIBER_DROPS = 10
+)
+
+type SubscriberStats struct {
+	Topic      string
+	QueueDepth int
+	Capacity   int
+	Delivered  uint64
+	Dropped    uint64
+}
+
+type subscriber struct {
+	ch        chan interface{}
+	delivered uint64
+	dropped   uint64
+}
+
+// DispatchStats describe the worker pool delivering the notifications
+type DispatchStats struct {
+	Workers    int
+	QueueDepth int
+	Capacity   int
+	// Waited counts the messages whose publisher waited for a full queue
+	Waited uint64
+}
+
+type dispatchJob struct {
+	topic string
+	msg   interface{}
+}
+
+// dispatcher delivers the messages of a topic always by the same worker,
+// so they keep their order
+type dispatcher struct {
 	mu     sync.RWMutex
-	subs   map[string][]chan interface{}
 	closed bool
+	queues []chan dispatchJob
+	done   sync.WaitGroup
+	waited uint64
+}
+
+type Pubsub struct {
+	mu         sync.RWMutex
+	subs       map[string][]*subscriber
+	closed     bool
+	bufferSize int
+	dispatch   atomic.Pointer[dispatcher]
+
+	// OnSlowSubscriber is called every SLOW_SUBSCRIBER_DROPS dropped
+	// messages of a subscriber which does not keep up with the publisher
+	OnSlowSubscriber func(stats SubscriberStats)
 }
 
 func NewPubsub() *Pubsub {
-	ps := &Pubsub{}
-	ps.subs = make(map[string][]chan interface{})
+	ps := &Pubsub{bufferSize: PUBSUB_BUFFER_SIZE}
+	ps.subs = make(map[string][]*subscriber)
 	return ps
 }
 
+// SetBufferSize sets the queue length of subscriptions created afterwards
+func (ps *Pubsub) SetBufferSize(size int) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if size > 0 {
+		ps.bufferSize = size
+	}
+}
+
+// SetWorkers delivers the published messages by a pool of workers, each
+// with a queue of the given length. Publish only waits while the queue of
+// the worker is full, so bursts of updates neither block the publisher on
+// the delivery nor start a goroutine per message. Without workers Publish
+// delivers the messages itself. It has to be called before the first
+// Publish.
+func (ps *Pubsub) SetWorkers(workers int, queue int) {
+	if workers <= 0 {
+		return
+	}
+	if queue <= 0 {
+		queue = PUBSUB_BUFFER_SIZE
+	}
+	d := &dispatcher{queues: make([]chan dispatchJob, workers)}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchJob, queue)
+		d.done.Add(1)
+		go func(queue <-chan dispatchJob) {
+			defer d.done.Done()
+			for job := range queue {
+				ps.deliver(job.topic, job.msg)
+			}
+		}(d.queues[i])
+	}
+	if previous := ps.dispatch.Swap(d); previous != nil {
+		previous.close()
+	}
+}
+
+func (d *dispatcher) enqueue(topic string, msg interface{}) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return
+	}
+	// FNV-1a of the topic selects the worker
+	hash := uint32(2166136261)
+	for i := 0; i < len(topic); i++ {
+		hash ^= uint32(topic[i])
+		hash *= 16777619
+	}
+	queue := d.queues[hash%uint32(len(d.queues))]
+	job := dispatchJob{topic: topic, msg: msg}
+	select {
+	case queue <- job:
+	default:
+		atomic.AddUint64(&d.waited, 1)
+		queue <- job
+	}
+}
+
+// close delivers the queued messages and stops the workers
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	}
+	d.mu.Unlock()
+	d.done.Wait()
+}
+
+func (d *dispatcher) stats() DispatchStats {
+	stats := DispatchStats{Workers: len(d.queues), Waited: atomic.LoadUint64(&d.waited)}
+	for _, queue := range d.queues {
+		stats.QueueDepth += len(queue)
+		stats.Capacity += cap(queue)
+	}
+	return stats
+}
+
+// DispatchStats returns the state of the worker pool, zero values without
+// workers
+func (ps *Pubsub) DispatchStats() DispatchStats {
+	if d := ps.dispatch.Load(); d != nil {
+		return d.stats()
+	}
+	return DispatchStats{}
+}
+
 func (ps *Pubsub) IsEmpty() bool {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
@@ -25,34 +170,115 @@ func (ps *Pubsub) Subscribe(topic string) <-chan interface{} {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	ch := make(chan interface{}, 1)
-	ps.subs[topic] = append(ps.subs[topic], ch)
-	return ch
+	sub := &subscriber{ch: make(chan interface{}, ps.bufferSize)}
+	if ps.closed {
+		close(sub.ch)
+		return sub.ch
+	}
+	ps.subs[topic] = append(ps.subs[topic], sub)
+	return sub.ch
+}
+
+// Unsubscribe closes the channel, after Close all channels are closed
+// already
+func (ps *Pubsub) Unsubscribe(topic string, ch <-chan interface{}) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if ps.closed {
+		return
+	}
+	subs := ps.subs[topic]
+	for i, sub := range subs {
+		if sub.ch == ch {
+			close(sub.ch)
+			ps.subs[topic] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(ps.subs[topic]) == 0 {
+		delete(ps.subs, topic)
+	}
+}
+
+// hasSubscribers allows publishers to skip building messages nobody reads
+func (ps *Pubsub) hasSubscribers(topic string) bool {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	return len(ps.subs[topic]) > 0
 }
 
+// Publish delivers the message to all subscribers of the topic without
+// blocking, messages for subscribers with a full queue are dropped. With
+// workers the message is queued for its worker instead.
 func (ps *Pubsub) Publish(topic string, msg interface{}) {
+	if d := ps.dispatch.Load(); d != nil {
+		d.enqueue(topic, msg)
+		return
+	}
+	ps.deliver(topic, msg)
+}
+
+func (ps *Pubsub) deliver(topic string, msg interface{}) {
 	ps.mu.RLock()
 	defer ps.mu.RUnlock()
 
 	if ps.closed {
 		return
 	}
-	for _, ch := range ps.subs[topic] {
-		go func(ch chan interface{}) {
-			ch <- msg
-		}(ch)
+	for _, sub := range ps.subs[topic] {
+		select {
+		case sub.ch <- msg:
+			atomic.AddUint64(&sub.delivered, 1)
+		default:
+			dropped := atomic.AddUint64(&sub.dropped, 1)
+			if ps.OnSlowSubscriber != nil && dropped%SLOW_SUBSCRIBER_DROPS == 0 {
+				ps.OnSlowSubscriber(sub.stats(topic))
+			}
+		}
+	}
+}
+
+func (sub *subscriber) stats(topic string) SubscriberStats {
+	return SubscriberStats{
+		Topic:      topic,
+		QueueDepth: len(sub.ch),
+		Capacity:   cap(sub.ch),
+		Delivered:  atomic.LoadUint64(&sub.delivered),
+		Dropped:    atomic.LoadUint64(&sub.dropped),
+	}
+}
+
+func (ps *Pubsub) SubscriberStats() []SubscriberStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	stats := []SubscriberStats{}
+	for topic, subs := range ps.subs {
+		for _, sub := range subs {
+			stats = append(stats, sub.stats(topic))
+		}
 	}
+	return stats
 }
+
+// Close delivers the messages queued for the workers and closes all
+// subscriptions
 func (ps *Pubsub) Close() {
+	if d := ps.dispatch.Load(); d != nil {
+		d.close()
+	}
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
 	if !ps.closed {
 		ps.closed = true
 		for _, subs := range ps.subs {
-			for _, ch := range subs {
-				close(ch)
+			for _, sub := range subs {
+				close(sub.ch)
 			}
 		}
+		ps.subs = make(map[string][]*subscriber)
 	}
 }
@@ -0,0 +1,80 @@
+package wattpilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPubsubWorkersKeepOrder(t *testing.T) {
+	ps := NewPubsub()
+	ps.SetBufferSize(100)
+	ps.SetWorkers(4, 2)
+	if stats := ps.DispatchStats(); stats.Workers != 4 || stats.Capacity != 8 {
+		t.Fatal("unexpected stats ", stats)
+	}
+
+	topics := []<-chan interface{}{}
+	for i := 0; i < 8; i++ {
+		topics = append(topics, ps.Subscribe(fmt.Sprint("topic", i)))
+	}
+	for n := 0; n < 100; n++ {
+		for i := range topics {
+			ps.Publish(fmt.Sprint("topic", i), n)
+		}
+	}
+	// Close delivers the queued messages before closing the subscriptions
+	ps.Close()
+
+	for i, ch := range topics {
+		n := 0
+		for msg := range ch {
+			if msg != n {
+				t.Fatalf("topic%d: got %v, want %d", i, msg, n)
+			}
+			n++
+		}
+		if n != 100 {
+			t.Fatalf("topic%d: got %d messages", i, n)
+		}
+	}
+}
+
+func TestPubsubWorkersQueueDepth(t *testing.T) {
+	ps := NewPubsub()
+	defer ps.Close()
+	ps.SetWorkers(1, 1)
+	ch := ps.Subscribe("amp")
+
+	// the worker waits on the delivery of the first message, the second
+	// fills the queue and the publisher of the third waits
+	ps.mu.Lock()
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			ps.Publish("amp", i)
+		}
+		close(published)
+	}()
+	deadline := time.Now().Add(time.Second)
+	for stats := ps.DispatchStats(); stats.QueueDepth != 1 || stats.Waited == 0; stats = ps.DispatchStats() {
+		if time.Now().After(deadline) {
+			ps.mu.Unlock()
+			t.Fatal("unexpected stats ", ps.DispatchStats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ps.mu.Unlock()
+
+	<-published
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-ch:
+			if msg != i {
+				t.Fatalf("got %v, want %d", msg, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("message not delivered")
+		}
+	}
+}
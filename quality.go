@@ -0,0 +1,119 @@
+package wattpilot
+
+import (
+	"sync"
+	"time"
+)
+
+// QUALITY_SAMPLES is the number of round trips and message gaps kept
+const QUALITY_SAMPLES = 64
+
+// ConnectionQuality summarizes the recent round trip times of requests and
+// the gaps between received messages
+type ConnectionQuality struct {
+	RTTLast     time.Duration
+	RTTAvg      time.Duration
+	RTTMax      time.Duration
+	GapAvg      time.Duration
+	GapMax      time.Duration
+	LastMessage time.Time
+	Messages    uint64
+	// Lost counts requests which were never answered
+	Lost uint64
+}
+
+type durationRing struct {
+	values []time.Duration
+	next   int
+}
+
+func (r *durationRing) add(d time.Duration) {
+	if len(r.values) < QUALITY_SAMPLES {
+		r.values = append(r.values, d)
+		return
+	}
+	r.values[r.next] = d
+	r.next = (r.next + 1) % QUALITY_SAMPLES
+}
+
+func (r *durationRing) last() time.Duration {
+	if len(r.values) == 0 {
+		return 0
+	}
+	if len(r.values) < QUALITY_SAMPLES {
+		return r.values[len(r.values)-1]
+	}
+	return r.values[(r.next+QUALITY_SAMPLES-1)%QUALITY_SAMPLES]
+}
+
+func (r *durationRing) stats() (time.Duration, time.Duration) {
+	if len(r.values) == 0 {
+		return 0, 0
+	}
+	var sum, max time.Duration
+	for _, v := range r.values {
+		sum += v
+		if v > max {
+			max = v
+		}
+	}
+	return sum / time.Duration(len(r.values)), max
+}
+
+type qualityTracker struct {
+	mu          sync.Mutex
+	rtts        durationRing
+	gaps        durationRing
+	lastMessage time.Time
+	messages    uint64
+	lost        uint64
+}
+
+func (w *Wattpilot) recordMessage() {
+	now := w._clock.Now()
+	q := w._quality
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.lastMessage.IsZero() {
+		q.gaps.add(now.Sub(q.lastMessage))
+	}
+	q.lastMessage = now
+	q.messages++
+}
+
+// resetMessageGap starts measuring the gaps anew for a new connection
+func (w *Wattpilot) resetMessageGap() {
+	w._quality.mu.Lock()
+	defer w._quality.mu.Unlock()
+	w._quality.lastMessage = time.Time{}
+}
+
+func (w *Wattpilot) recordRoundTrip(info requestInfo) {
+	rtt := w._clock.Now().Sub(info.sent)
+	w._quality.mu.Lock()
+	defer w._quality.mu.Unlock()
+	w._quality.rtts.add(rtt)
+}
+
+func (w *Wattpilot) recordLostRequest() {
+	w._quality.mu.Lock()
+	defer w._quality.mu.Unlock()
+	w._quality.lost++
+}
+
+func (w *Wattpilot) ConnectionQuality() ConnectionQuality {
+	q := w._quality
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	quality := ConnectionQuality{
+		RTTLast:     q.rtts.last(),
+		LastMessage: q.lastMessage,
+		Messages:    q.messages,
+		Lost:        q.lost,
+	}
+	quality.RTTAvg, quality.RTTMax = q.rtts.stats()
+	quality.GapAvg, quality.GapMax = q.gaps.stats()
+	return quality
+}
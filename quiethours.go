@@ -0,0 +1,110 @@
+package wattpilot
+
+import (
+	"context"
+	"time"
+)
+
+// QUIET_HOURS_PRIORITY lets the quiet hours win over charging strategies
+const QUIET_HOURS_PRIORITY = 100
+
+const (
+	phaseSwitchAuto   = 0
+	phaseSwitchSingle = 1
+	phaseSwitchThree  = 2
+)
+
+// QuietHours is a controller reducing relay and contactor noise within the
+// time windows, e.g. at night in attached garages. It pins the phase
+// switch mode psm to the phases in use, so the charger does not switch,
+// and either pauses charging or caps the current at MaxCurrent. The
+// values of psm, frc and amp found before changing them are restored when
+// the window ends.
+type QuietHours struct {
+	Windows []TimeWindow
+	// MaxCurrent in A during quiet hours, zero leaves the current alone
+	MaxCurrent float64
+	// Pause stops charging during quiet hours instead of capping it
+	Pause bool
+	Check time.Duration
+
+	// prior holds the values of the keys changed within the window
+	prior map[string]interface{}
+}
+
+func NewQuietHours(windows ...TimeWindow) *QuietHours {
+	return &QuietHours{Windows: windows, Check: time.Minute, prior: make(map[string]interface{})}
+}
+
+func (q *QuietHours) Name() string {
+	return "quietHours"
+}
+
+func (q *QuietHours) Interval() time.Duration {
+	return q.Check
+}
+
+func (q *QuietHours) Priority() int {
+	return QUIET_HOURS_PRIORITY
+}
+
+func (q *QuietHours) IsQuiet(t time.Time) bool {
+	for _, tw := range q.Windows {
+		if tw.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *QuietHours) Evaluate(ctx context.Context, snapshot Snapshot) []Action {
+	if !q.IsQuiet(snapshot.Time) {
+		return q.restore()
+	}
+
+	actions := []Action{}
+	if mode, err := snapshot.Float("psm"); err == nil && int(mode) == phaseSwitchAuto {
+		pin := phaseSwitchThree
+		if phases, _ := snapshot.Get("phaseCount"); phases == 1 {
+			pin = phaseSwitchSingle
+		}
+		q.remember(snapshot, "psm")
+		actions = append(actions, Action{Key: "psm", Value: pin, Reason: "quiet hours, no phase switching"})
+	}
+	if q.Pause {
+		if state, err := snapshot.Float("frc"); err == nil && ForceState(state) != ForceOff {
+			q.remember(snapshot, "frc")
+			actions = append(actions, Action{Key: "frc", Value: int(ForceOff), Reason: "quiet hours"})
+		}
+		return actions
+	}
+	if current, err := snapshot.Float("amp"); err == nil && q.MaxCurrent > 0 && current > q.MaxCurrent {
+		q.remember(snapshot, "amp")
+		actions = append(actions, Action{Key: "amp", Value: q.MaxCurrent, Reason: "quiet hours current limit"})
+	}
+	return actions
+}
+
+// remember records the value of the key before the quiet hours change it
+// the first time within the window
+func (q *QuietHours) remember(snapshot Snapshot, key string) {
+	if q.prior == nil {
+		q.prior = make(map[string]interface{})
+	}
+	if _, isKnown := q.prior[key]; isKnown {
+		return
+	}
+	q.prior[key], _ = snapshot.Get(key)
+}
+
+// restore writes back the values recorded within the window
+func (q *QuietHours) restore() []Action {
+	actions := []Action{}
+	for _, key := range []string{"psm", "frc", "amp"} {
+		if value, isKnown := q.prior[key]; isKnown {
+			delete(q.prior, key)
+			actions = append(actions, Action{Key: key, Value: value, Reason: "quiet hours ended"})
+		}
+	}
+	return actions
+}
@@ -0,0 +1,65 @@
+package wattpilot_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func TestQuietHoursRestoresPriorValues(t *testing.T) {
+	q := wattpilot.NewQuietHours(wattpilot.TimeWindow{Start: 22 * time.Hour, End: 6 * time.Hour})
+	q.MaxCurrent = 10
+	night := time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)
+	status := map[string]interface{}{"psm": 0.0, "frc": 2.0, "amp": 16.0}
+
+	actions := q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night, Status: status})
+	want := []wattpilot.Action{
+		{Key: "psm", Value: 2, Reason: "quiet hours, no phase switching"},
+		{Key: "amp", Value: 10.0, Reason: "quiet hours current limit"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("entering: got %v, want %v", actions, want)
+	}
+
+	// the charger applied the writes, the next evaluations keep the prior values
+	status = map[string]interface{}{"psm": 2.0, "frc": 2.0, "amp": 10.0}
+	if actions := q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night.Add(time.Hour), Status: status}); len(actions) != 0 {
+		t.Fatal("unexpected actions within the window: ", actions)
+	}
+
+	actions = q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night.Add(8 * time.Hour), Status: status})
+	want = []wattpilot.Action{
+		{Key: "psm", Value: 0.0, Reason: "quiet hours ended"},
+		{Key: "amp", Value: 16.0, Reason: "quiet hours ended"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("restoring: got %v, want %v", actions, want)
+	}
+	if actions := q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night.Add(9 * time.Hour), Status: status}); len(actions) != 0 {
+		t.Fatal("restored twice: ", actions)
+	}
+}
+
+func TestQuietHoursPauseRestoresForceState(t *testing.T) {
+	q := wattpilot.NewQuietHours(wattpilot.TimeWindow{Start: 22 * time.Hour, End: 6 * time.Hour})
+	q.Pause = true
+	night := time.Date(2026, 10, 17, 23, 0, 0, 0, time.UTC)
+	// force on and a pinned phase mode set by the user before the window
+	status := map[string]interface{}{"psm": 1.0, "frc": float64(wattpilot.ForceOn), "amp": 16.0}
+
+	actions := q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night, Status: status})
+	want := []wattpilot.Action{{Key: "frc", Value: int(wattpilot.ForceOff), Reason: "quiet hours"}}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("entering: got %v, want %v", actions, want)
+	}
+
+	status["frc"] = float64(wattpilot.ForceOff)
+	actions = q.Evaluate(context.Background(), wattpilot.Snapshot{Time: night.Add(8 * time.Hour), Status: status})
+	want = []wattpilot.Action{{Key: "frc", Value: float64(wattpilot.ForceOn), Reason: "quiet hours ended"}}
+	if !reflect.DeepEqual(actions, want) {
+		t.Fatalf("restoring: got %v, want %v", actions, want)
+	}
+}
@@ -0,0 +1,161 @@
+package wattpilot
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const REDACTED = "***"
+
+// sensitiveFields are message fields carrying the password derived
+// authentication material
+var sensitiveFields = []string{"hash", "hmac", "token1", "token2", "token3", "password", "hashedpassword"}
+
+var sensitivePattern = regexp.MustCompile(`(?i)("?(?:` + strings.Join(sensitiveFields, "|") + `)"?\s*[:=]\s*)("[^"]*"|[^\s,}\]]+)`)
+
+// redactHook removes authentication material from all log entries of a
+// logger. A logger shared by several instances has a single hook holding
+// all of them, so the secrets of each instance are removed from every
+// entry. Entries of an instance in the full dump mode, attributed by the
+// wattpilot field of its log entries, are left as they are.
+type redactHook struct {
+	mu        sync.RWMutex
+	instances map[*Wattpilot]bool
+}
+
+// redactHooks are the hooks by logger
+var redactHooks = struct {
+	sync.Mutex
+	hooks map[*log.Logger]*redactHook
+}{hooks: make(map[*log.Logger]*redactHook)}
+
+// installRedactHook adds the instance to the hook of the logger, the hook
+// is added to the logger on its first instance
+func installRedactHook(logger *log.Logger, w *Wattpilot) {
+	redactHooks.Lock()
+	defer redactHooks.Unlock()
+
+	h, isKnown := redactHooks.hooks[logger]
+	if !isKnown {
+		h = &redactHook{instances: make(map[*Wattpilot]bool)}
+		redactHooks.hooks[logger] = h
+		logger.AddHook(h)
+	}
+	h.mu.Lock()
+	h.instances[w] = true
+	h.mu.Unlock()
+}
+
+// removeRedactHook removes the instance from the hook of the logger, the
+// hook is removed from the logger with its last instance
+func removeRedactHook(logger *log.Logger, w *Wattpilot) {
+	redactHooks.Lock()
+	defer redactHooks.Unlock()
+
+	h, isKnown := redactHooks.hooks[logger]
+	if !isKnown {
+		return
+	}
+	h.mu.Lock()
+	delete(h.instances, w)
+	empty := len(h.instances) == 0
+	h.mu.Unlock()
+	if !empty {
+		return
+	}
+	delete(redactHooks.hooks, logger)
+	hooks := make(log.LevelHooks)
+	for level, levelHooks := range logger.Hooks {
+		for _, hook := range levelHooks {
+			if hook != log.Hook(h) {
+				hooks[level] = append(hooks[level], hook)
+			}
+		}
+	}
+	logger.ReplaceHooks(hooks)
+}
+
+func (h *redactHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *redactHook) Fire(entry *log.Entry) error {
+	h.mu.RLock()
+	instances := make([]*Wattpilot, 0, len(h.instances))
+	for w := range h.instances {
+		instances = append(instances, w)
+	}
+	h.mu.RUnlock()
+
+	host, _ := entry.Data["wattpilot"].(string)
+	for _, w := range instances {
+		if w._host == host && atomic.LoadInt32(&w._fullDump) == 1 {
+			return nil
+		}
+	}
+	entry.Message = redact(entry.Message, instances)
+	for k, v := range entry.Data {
+		if isSensitiveField(k) {
+			entry.Data[k] = REDACTED
+		} else if s, ok := v.(string); ok {
+			entry.Data[k] = redact(s, instances)
+		}
+	}
+	return nil
+}
+
+func isSensitiveField(key string) bool {
+	for _, field := range sensitiveFields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// authSecrets is the authentication material of the current connection, it
+// is replaced as a whole by the handshake while log entries of other
+// goroutines are redacted
+type authSecrets struct {
+	hashedPassword string
+	token3         string
+}
+
+func (w *Wattpilot) hashedPassword() string {
+	if secrets := w._authSecrets.Load(); secrets != nil {
+		return secrets.hashedPassword
+	}
+	return ""
+}
+
+// redact removes the sensitive fields and the secrets of the instances
+func redact(s string, instances []*Wattpilot) string {
+	s = sensitivePattern.ReplaceAllString(s, "${1}"+REDACTED)
+	for _, w := range instances {
+		secrets := []string{w._password}
+		if auth := w._authSecrets.Load(); auth != nil {
+			secrets = append(secrets, auth.hashedPassword, auth.token3)
+		}
+		for _, secret := range secrets {
+			if len(secret) > 3 {
+				s = strings.ReplaceAll(s, secret, REDACTED)
+			}
+		}
+	}
+	return s
+}
+
+// SetFullDump disables the redaction of authentication material in the log
+// output. Only use it for local debugging, the logs then allow to attack
+// the charger password.
+func (w *Wattpilot) SetFullDump(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&w._fullDump, v)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// replay runs protocol transcripts against the client and fails when the
+// client deviates from one of them. Without arguments the transcripts in
+// wattpilottest/testdata are used. With -strict unknown keys and values
+// changing their type fail the transcript, to validate new firmware.
+func main() {
+	strict := flag.Bool("strict", false, "fail on drift from the property schema")
+	flag.Parse()
+	files := flag.Args()
+	if len(files) == 0 {
+		files, _ = filepath.Glob("wattpilottest/testdata/*.jsonl")
+	}
+	failed := 0
+	for _, file := range files {
+		var client *wattpilot.Wattpilot
+		options := []wattpilot.Option{}
+		if *strict {
+			options = append(options, wattpilot.WithSchemaPolicy(wattpilot.SchemaWarn), func(w *wattpilot.Wattpilot) {
+				client = w
+			})
+		}
+		t, err := wattpilottest.LoadTranscriptFile(file)
+		if err == nil {
+			err = wattpilottest.Replay(context.Background(), t, options...)
+		}
+		if err == nil && client != nil {
+			err = errors.Join(client.SchemaErrors()...)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", file, err)
+			continue
+		}
+		fmt.Printf("ok   %s (firmware %s)\n", file, t.Firmware)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
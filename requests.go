@@ -0,0 +1,166 @@
+package wattpilot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PENDING_TIMEOUT is the age after which unanswered requests are forgotten
+const PENDING_TIMEOUT = 60 // seconds
+
+type PendingRequest struct {
+	Id   int64
+	Type string
+	Key  string
+	Age  time.Duration
+}
+
+type requestInfo struct {
+	id   int64
+	typ  string
+	key  string
+	sent time.Time
+}
+
+type requestTracker struct {
+	mu      sync.Mutex
+	pending map[int64]requestInfo
+	queued  map[int64]requestInfo
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{
+		pending: make(map[int64]requestInfo),
+		queued:  make(map[int64]requestInfo),
+	}
+}
+
+func newRequestInfo(message map[string]interface{}, now time.Time) (requestInfo, bool) {
+	id, ok := message["requestId"].(int64)
+	if !ok {
+		return requestInfo{}, false
+	}
+	info := requestInfo{id: id, sent: now}
+	info.typ, _ = message["type"].(string)
+	info.key, _ = message["key"].(string)
+	return info, true
+}
+
+// parseRequestId handles the plain ids and the "<id>sm" ids of secured messages
+func parseRequestId(value interface{}) (int64, bool) {
+	var id int64
+	s := strings.TrimSuffix(fmt.Sprintf("%v", value), "sm")
+	if _, err := fmt.Sscanf(s, "%d", &id); err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func listRequests(requests map[int64]requestInfo, now time.Time) []PendingRequest {
+	result := []PendingRequest{}
+	for _, r := range requests {
+		result = append(result, PendingRequest{Id: r.id, Type: r.typ, Key: r.key, Age: now.Sub(r.sent)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Id < result[j].Id })
+	return result
+}
+
+func (w *Wattpilot) queueRequest(message map[string]interface{}) {
+	info, ok := newRequestInfo(message, w._clock.Now())
+	if !ok {
+		return
+	}
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	w._requests.queued[info.id] = info
+}
+
+func (w *Wattpilot) dequeueRequest(message map[string]interface{}) {
+	id, ok := message["requestId"].(int64)
+	if !ok {
+		return
+	}
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	delete(w._requests.queued, id)
+}
+
+func (w *Wattpilot) trackRequest(message map[string]interface{}) {
+	now := w._clock.Now()
+	info, ok := newRequestInfo(message, now)
+	if !ok {
+		return
+	}
+	w._requests.mu.Lock()
+	lost := 0
+	for id, r := range w._requests.pending {
+		if now.Sub(r.sent) > time.Second*PENDING_TIMEOUT {
+			delete(w._requests.pending, id)
+			lost++
+		}
+	}
+	w._requests.pending[info.id] = info
+	w._requests.mu.Unlock()
+
+	for ; lost > 0; lost-- {
+		w.recordLostRequest()
+	}
+}
+
+// completeRequest removes the request answered by a response and returns it
+func (w *Wattpilot) completeRequest(requestId interface{}) (requestInfo, bool) {
+	id, ok := parseRequestId(requestId)
+	if !ok {
+		return requestInfo{}, false
+	}
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	info, ok := w._requests.pending[id]
+	delete(w._requests.pending, id)
+	return info, ok
+}
+
+// completeStatusRequest removes the oldest pending requestFullStatus. The
+// charger answers it with fullStatus frames which carry no requestId, so
+// the final frame completes the request sent first.
+func (w *Wattpilot) completeStatusRequest() (requestInfo, bool) {
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	var oldest requestInfo
+	found := false
+	for _, r := range w._requests.pending {
+		if r.typ == "requestFullStatus" && (!found || r.id < oldest.id) {
+			oldest = r
+			found = true
+		}
+	}
+	if found {
+		delete(w._requests.pending, oldest.id)
+	}
+	return oldest, found
+}
+
+// PendingRequests returns the requests sent to the charger which have not
+// been answered yet
+func (w *Wattpilot) PendingRequests() []PendingRequest {
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	return listRequests(w._requests.pending, w._clock.Now())
+}
+
+// QueuedWrites returns the requests which are waiting to be written to the
+// connection, e.g. while reconnecting for a retry
+func (w *Wattpilot) QueuedWrites() []PendingRequest {
+	w._requests.mu.Lock()
+	defer w._requests.mu.Unlock()
+
+	return listRequests(w._requests.queued, w._clock.Now())
+}
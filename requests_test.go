@@ -0,0 +1,48 @@
+package wattpilot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+func TestFullStatusCompletesStatusRequest(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, _, conn := connect(t, clock)
+
+	if err := client.RequestStatusUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if message := receive(t, conn); message["type"] != "requestFullStatus" {
+		t.Fatal("expected requestFullStatus, got ", message)
+	}
+	if pending := client.PendingRequests(); len(pending) != 1 || pending[0].Type != "requestFullStatus" {
+		t.Fatal("expected the status request to be pending, got ", pending)
+	}
+
+	// the answer carries no requestId
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": true, "status": map[string]interface{}{"car": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": map[string]interface{}{"amp": 16}}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(testTimeout)
+	for len(client.PendingRequests()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("status request still pending: ", client.PendingRequests())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a later poll does not count the answered one as lost
+	clock.Advance(time.Second * (wattpilot.PENDING_TIMEOUT + 1))
+	if err := client.RequestStatusUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	if lost := client.ConnectionQuality().Lost; lost != 0 {
+		t.Fatal("expected no lost requests, got ", lost)
+	}
+}
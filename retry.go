@@ -0,0 +1,59 @@
+package wattpilot
+
+import (
+	"time"
+)
+
+// RetryPolicy controls how often a failed write is retried after
+// reconnecting to the charger
+type RetryPolicy struct {
+	MaxAttempts int
+	Delay       time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Delay:       time.Second,
+	}
+}
+
+// WithRetryPolicy replaces the default retry policy, a policy with a single
+// attempt disables retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(w *Wattpilot) {
+		w._retryPolicy = policy
+	}
+}
+
+// sendWithRetry sends the message and reconnects on write failures until
+// the retry policy is exhausted. The reconnect runs in the background, the
+// wait for it is bounded, so a send from within the receive handler does not
+// deadlock on the handler waiting for its own end.
+func (w *Wattpilot) sendWithRetry(secured bool, message map[string]interface{}) error {
+	w.queueRequest(message)
+	defer w.dequeueRequest(message)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		c := w._currentConnection.Load()
+		if err = w.onSendResponse(secured, message); err == nil {
+			return nil
+		}
+		if attempt >= w._retryPolicy.MaxAttempts {
+			return err
+		}
+		w.logEntry().Warn("Sending failed, reconnecting for attempt ", attempt+1, ": ", err)
+		done := w.reconnect(c, w._retryPolicy.Delay)
+		timeout := w._clock.NewTimer(w._retryPolicy.Delay + time.Second*CONTEXT_TIMEOUT)
+		select {
+		case <-done:
+		case <-timeout.C():
+			w.logEntry().Debug("Reconnect for retry did not finish in time")
+		case <-w._context.Done():
+			timeout.Stop()
+			return err
+		}
+		timeout.Stop()
+	}
+}
@@ -0,0 +1,195 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rule applies its actions while the condition holds
+type Rule struct {
+	When    *Expression
+	Actions []Action
+}
+
+// RuleController is a Controller evaluating conditional rules, all rules
+// whose condition is true contribute their actions
+type RuleController struct {
+	name     string
+	interval time.Duration
+	rules    []Rule
+	triggers []*presetTrigger
+	// OnError is called for rules failing to evaluate
+	OnError func(rule Rule, err error)
+
+	mu       sync.Mutex
+	messages map[string]string
+}
+
+// PresetTrigger activates a preset when its conditions start to hold, e.g.
+// when a specific car plugs in. All set conditions must hold. The preset is
+// applied once per activation, so manual changes afterwards are kept.
+type PresetTrigger struct {
+	Preset string
+	// Window holds while the time is within the window
+	Window *TimeWindow
+	// CarIdentifier (cak) and Card (trx, card index + 1) hold while the car
+	// or card is plugged in
+	CarIdentifier string
+	Card          int
+	// Message holds when a message of the topic arrived since the last
+	// evaluation, e.g. an MQTT message passed to Message. An empty Payload
+	// matches any payload.
+	Message string
+	Payload string
+	// When is an additional condition
+	When *Expression
+}
+
+func (t PresetTrigger) String() string {
+	conditions := []string{}
+	if t.Window != nil {
+		conditions = append(conditions, fmt.Sprintf("time %v-%v", t.Window.Start, t.Window.End))
+	}
+	if t.CarIdentifier != "" {
+		conditions = append(conditions, "car "+t.CarIdentifier)
+	}
+	if t.Card > 0 {
+		conditions = append(conditions, fmt.Sprintf("card %d", t.Card))
+	}
+	if t.Message != "" {
+		conditions = append(conditions, "message "+t.Message)
+	}
+	if t.When != nil {
+		conditions = append(conditions, t.When.String())
+	}
+	return strings.Join(conditions, " and ")
+}
+
+type presetTrigger struct {
+	PresetTrigger
+	holds bool
+}
+
+// NewRuleController compiles the conditions, mapping each expression to
+// the actions to apply while it holds. Rules are evaluated in the order of
+// their conditions.
+func NewRuleController(name string, interval time.Duration, rules map[string][]Action) (*RuleController, error) {
+	c := &RuleController{name: name, interval: interval}
+	conditions := Keys(rules)
+	sort.Strings(conditions)
+	for _, condition := range conditions {
+		expr, err := CompileExpression(condition)
+		if err != nil {
+			return nil, err
+		}
+		c.rules = append(c.rules, Rule{When: expr, Actions: rules[condition]})
+	}
+	return c, nil
+}
+
+func (c *RuleController) Name() string {
+	return c.name
+}
+
+func (c *RuleController) Interval() time.Duration {
+	return c.interval
+}
+
+// AddPresetTrigger activates the preset of the trigger by the controller
+func (c *RuleController) AddPresetTrigger(trigger PresetTrigger) {
+	c.triggers = append(c.triggers, &presetTrigger{PresetTrigger: trigger})
+}
+
+// Message passes an external message, e.g. received by MQTT, to the
+// triggers waiting for its topic
+func (c *RuleController) Message(topic string, payload string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messages == nil {
+		c.messages = make(map[string]string)
+	}
+	c.messages[topic] = payload
+}
+
+func (c *RuleController) takeMessages() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	messages := c.messages
+	c.messages = nil
+	return messages
+}
+
+func (t *presetTrigger) check(snapshot Snapshot, messages map[string]string) (bool, error) {
+	if t.Window != nil && !t.Window.Contains(snapshot.Time) {
+		return false, nil
+	}
+	if t.CarIdentifier != "" || t.Card > 0 {
+		state, err := snapshot.Float("car")
+		if err != nil || !isPlugged(int(state)) {
+			return false, err
+		}
+	}
+	if t.CarIdentifier != "" {
+		if cak, _ := snapshot.Get("cak"); cak != t.CarIdentifier {
+			return false, nil
+		}
+	}
+	if t.Card > 0 {
+		if trx, err := snapshot.Float("trx"); err != nil || int(trx) != t.Card {
+			return false, nil
+		}
+	}
+	if t.Message != "" {
+		payload, received := messages[t.Message]
+		if !received || (t.Payload != "" && payload != t.Payload) {
+			return false, nil
+		}
+	}
+	if t.When != nil {
+		return t.When.EvalBool(snapshot)
+	}
+	return true, nil
+}
+
+func (c *RuleController) Evaluate(ctx context.Context, snapshot Snapshot) []Action {
+	actions := []Action{}
+	messages := c.takeMessages()
+	for _, t := range c.triggers {
+		holds, err := t.check(snapshot, messages)
+		if err != nil {
+			if c.OnError != nil {
+				c.OnError(Rule{When: t.When}, err)
+			}
+			continue
+		}
+		if holds && !t.holds {
+			actions = append(actions, Action{Preset: t.Preset, Reason: t.String()})
+		}
+		t.holds = holds
+	}
+	for _, rule := range c.rules {
+		ok, err := rule.When.EvalBool(snapshot)
+		if err != nil {
+			if c.OnError != nil {
+				c.OnError(rule, err)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		for _, action := range rule.Actions {
+			if action.Reason == "" {
+				action.Reason = rule.When.String()
+			}
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
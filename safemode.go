@@ -0,0 +1,101 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const EventSafeMode EventType = "safeMode"
+
+// SafeMode configures what happens when a controller fails, either by a
+// panic, by reporting an unhealthy data source or because the charger
+// status is older than MaxDataAge. The fallback actions are applied once
+// on entering safe mode, the controller resumes when it recovers.
+type SafeMode struct {
+	Fallback []Action
+	// MaxDataAge of the charger status, zero disables the check
+	MaxDataAge time.Duration
+}
+
+// HealthChecker can be implemented by controllers depending on external
+// data, a non-nil error puts the controller into safe mode
+type HealthChecker interface {
+	Healthy() error
+}
+
+type safeModeConfig struct {
+	mu   sync.RWMutex
+	mode SafeMode
+}
+
+// DefaultSafeMode pauses charging when no status arrived for five minutes
+func DefaultSafeMode() SafeMode {
+	return SafeMode{
+		Fallback:   []Action{{Key: "frc", Value: ForceOff, Reason: "safe mode"}},
+		MaxDataAge: 5 * time.Minute,
+	}
+}
+
+func WithSafeMode(mode SafeMode) Option {
+	return func(w *Wattpilot) {
+		w.SetSafeMode(mode)
+	}
+}
+
+func (w *Wattpilot) SetSafeMode(mode SafeMode) {
+	w._safeMode.mu.Lock()
+	defer w._safeMode.mu.Unlock()
+	w._safeMode.mode = mode
+}
+
+func (w *Wattpilot) getSafeMode() SafeMode {
+	w._safeMode.mu.RLock()
+	defer w._safeMode.mu.RUnlock()
+	return w._safeMode.mode
+}
+
+// checkController returns why the controller can not be trusted
+func (w *Wattpilot) checkController(c Controller, snapshot Snapshot) error {
+	if h, ok := c.(HealthChecker); ok {
+		if err := h.Healthy(); err != nil {
+			return err
+		}
+	}
+	maxAge := w.getSafeMode().MaxDataAge
+	if maxAge > 0 && snapshot.Time.Sub(snapshot.Updated) > maxAge {
+		return fmt.Errorf("charger status is stale since %s", snapshot.Updated.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// evaluate runs the controller and turns a panic into an error
+func evaluate(ctx context.Context, c Controller, snapshot Snapshot) (actions []Action, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("controller panicked: %v", r)
+		}
+	}()
+	return c.Evaluate(ctx, snapshot), nil
+}
+
+func (w *Wattpilot) enterSafeMode(session *Session, c Controller, reason error) {
+	w.logEntry().Error("Controller ", c.Name(), " enters safe mode: ", reason)
+	w.emitEvent(EventSafeMode, map[string]interface{}{
+		"controller": c.Name(),
+		"active":     true,
+		"reason":     reason.Error(),
+	})
+	for _, action := range w.getSafeMode().Fallback {
+		w.applyAction(session, c, action)
+	}
+}
+
+func (w *Wattpilot) leaveSafeMode(c Controller) {
+	w.logEntry().Info("Controller ", c.Name(), " recovered from safe mode")
+	w.emitEvent(EventSafeMode, map[string]interface{}{
+		"controller": c.Name(),
+		"active":     false,
+	})
+}
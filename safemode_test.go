@@ -0,0 +1,145 @@
+package wattpilot_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// scripted is a controller running the evaluate function
+type scripted struct {
+	interval time.Duration
+	evaluate func(snapshot wattpilot.Snapshot) []wattpilot.Action
+}
+
+func (c *scripted) Name() string {
+	return "scripted"
+}
+
+func (c *scripted) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *scripted) Evaluate(ctx context.Context, snapshot wattpilot.Snapshot) []wattpilot.Action {
+	return c.evaluate(snapshot)
+}
+
+// controlled connects a client on the fake clock with a status the
+// controllers can write to
+func controlled(t *testing.T, clock *wattpilottest.FakeClock, mode wattpilot.SafeMode) (*wattpilot.Wattpilot, *wattpilottest.Conn) {
+	t.Helper()
+	client, _, conn := connect(t, clock)
+	client.SetSafeMode(mode)
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": map[string]interface{}{"car": 2, "amp": 10, "frc": 0}}); err != nil {
+		t.Fatal(err)
+	}
+	waitForProperty(t, client, "amp", 10.0)
+	return client, conn
+}
+
+func waitForProperty(t *testing.T, client *wattpilot.Wattpilot, key string, want interface{}) {
+	t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		if value, err := client.GetProperty(key); err == nil && value == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(key, " did not become ", want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func expectWrite(t *testing.T, conn *wattpilottest.Conn, key string, value float64) {
+	t.Helper()
+	message := receive(t, conn)
+	if message["type"] != "setValue" || message["key"] != key || message["value"] != value {
+		t.Fatalf("expected %s set to %v, got %v", key, value, message)
+	}
+}
+
+func expectSafeMode(t *testing.T, events <-chan interface{}, active bool) map[string]interface{} {
+	t.Helper()
+	select {
+	case event := <-events:
+		data := event.(wattpilot.Event).Data
+		if data["active"] != active || data["controller"] != "scripted" {
+			t.Fatalf("expected safe mode active %v, got %v", active, data)
+		}
+		return data
+	case <-time.After(testTimeout):
+		t.Fatal("no safe mode event")
+	}
+	return nil
+}
+
+var fallback = []wattpilot.Action{{Key: "amp", Value: 6, Reason: "safe mode"}}
+
+func TestSafeModeOnPanic(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, conn := controlled(t, clock, wattpilot.SafeMode{Fallback: fallback})
+	events := client.GetEvents(wattpilot.EventSafeMode)
+
+	evaluations := 0
+	c := &scripted{interval: time.Second, evaluate: func(wattpilot.Snapshot) []wattpilot.Action {
+		evaluations++
+		if evaluations == 1 {
+			panic("division by zero")
+		}
+		return nil
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.RunControllers(ctx, c)
+
+	expectWrite(t, conn, "amp", 6)
+	if data := expectSafeMode(t, events, true); !strings.Contains(data["reason"].(string), "division by zero") {
+		t.Fatal("panic not reported: ", data)
+	}
+
+	// process loop, watchdog and controller
+	clock.BlockUntil(3)
+	clock.Advance(time.Second)
+	expectSafeMode(t, events, false)
+}
+
+func TestSafeModeOnStaleStatus(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	client, conn := controlled(t, clock, wattpilot.SafeMode{Fallback: fallback, MaxDataAge: 5 * time.Second})
+	events := client.GetEvents(wattpilot.EventSafeMode)
+
+	c := &scripted{interval: 3 * time.Second, evaluate: func(wattpilot.Snapshot) []wattpilot.Action {
+		return []wattpilot.Action{{Key: "amp", Value: 16, Reason: "surplus"}}
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.RunControllers(ctx, c)
+
+	expectWrite(t, conn, "amp", 16)
+	clock.BlockUntil(3)
+	clock.Advance(3 * time.Second)
+	expectWrite(t, conn, "amp", 16)
+
+	// no status since six seconds
+	clock.BlockUntil(3)
+	clock.Advance(3 * time.Second)
+	expectWrite(t, conn, "amp", 6)
+	if data := expectSafeMode(t, events, true); !strings.Contains(data["reason"].(string), "stale") {
+		t.Fatal("stale status not reported: ", data)
+	}
+
+	// a fresh status ends the safe mode
+	if err := conn.Send(map[string]interface{}{"type": "deltaStatus", "status": map[string]interface{}{"amp": 6}}); err != nil {
+		t.Fatal(err)
+	}
+	waitForProperty(t, client, "amp", 6.0)
+	clock.BlockUntil(3)
+	clock.Advance(3 * time.Second)
+	expectSafeMode(t, events, false)
+	expectWrite(t, conn, "amp", 16)
+}
@@ -0,0 +1,123 @@
+package wattpilot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	SCHEMA_ERRORS = 256
+
+	EventSchemaDrift EventType = "schemaDrift"
+)
+
+var ErrSchemaDrift = errors.New("status differs from the known schema")
+
+// SchemaPolicy selects the handling of unknown keys and of values which
+// change their JSON type
+type SchemaPolicy int
+
+const (
+	// SchemaLenient stores all values silently
+	SchemaLenient SchemaPolicy = iota
+	// SchemaWarn stores all values but logs, records and emits the drift
+	SchemaWarn
+	// SchemaReject drops the drifting values after recording them
+	SchemaReject
+)
+
+// SchemaError describes one drift of the status from the schema
+type SchemaError struct {
+	Key      string
+	Firmware string
+	Problem  string
+	Value    interface{}
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s on firmware %s: %s", e.Key, e.Firmware, e.Problem)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaDrift
+}
+
+type schemaChecker struct {
+	policy  SchemaPolicy
+	mu      sync.Mutex
+	errors  []error
+	unknown map[string]bool
+}
+
+// WithSchemaPolicy enables the validation of the status against the
+// generated mapping, e.g. in CI checks of a new firmware release
+func WithSchemaPolicy(policy SchemaPolicy) Option {
+	return func(w *Wattpilot) {
+		w._schema.policy = policy
+	}
+}
+
+// SchemaErrors returns the recorded drifts, the oldest are dropped after
+// SCHEMA_ERRORS entries
+func (w *Wattpilot) SchemaErrors() []error {
+	w._schema.mu.Lock()
+	defer w._schema.mu.Unlock()
+
+	return append([]error{}, w._schema.errors...)
+}
+
+func jsonKind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// checkSchema is called with the read mutex held, it reports whether the
+// value is stored
+func (w *Wattpilot) checkSchema(key string, value interface{}) bool {
+	s := w._schema
+	problem := ""
+	if !IsKnownKey(key) {
+		if s.unknown[key] {
+			// unknown keys are reported once
+			return s.policy != SchemaReject
+		}
+		s.unknown[key] = true
+		problem = "unknown key"
+	} else if old := w._status[key]; old != nil && value != nil && jsonKind(old) != jsonKind(value) {
+		problem = fmt.Sprintf("type changed from %s to %s", jsonKind(old), jsonKind(value))
+	}
+	if problem == "" {
+		return true
+	}
+
+	err := &SchemaError{Key: key, Firmware: w.firmwareVersion(), Problem: problem, Value: value}
+	s.mu.Lock()
+	s.errors = append(s.errors, err)
+	if len(s.errors) > SCHEMA_ERRORS {
+		s.errors = s.errors[len(s.errors)-SCHEMA_ERRORS:]
+	}
+	s.mu.Unlock()
+
+	w.logEntry().Warn("Schema drift: ", err)
+	w.emitEvent(EventSchemaDrift, map[string]interface{}{
+		"key":      key,
+		"problem":  problem,
+		"value":    value,
+		"rejected": s.policy == SchemaReject,
+	})
+	return s.policy != SchemaReject
+}
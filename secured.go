@@ -0,0 +1,43 @@
+package wattpilot
+
+import (
+	"encoding/json"
+
+	"github.com/mabunixda/wattpilot/auth"
+)
+
+const EventSecurityViolation EventType = "securityViolation"
+
+// onEventSecuredMsg verifies the hmac of a secured message of the charger
+// and handles the wrapped message, tampered frames are dropped
+func (w *Wattpilot) onEventSecuredMsg(message map[string]interface{}) {
+	payload, _ := message["data"].(string)
+	received, _ := message["hmac"].(string)
+
+	if !auth.Verify(w.hashedPassword(), []byte(payload), received) {
+		w.rejectSecuredMsg(message, "hmac mismatch")
+		return
+	}
+
+	inner := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(payload), &inner); err != nil {
+		w.rejectSecuredMsg(message, "invalid payload")
+		return
+	}
+	msgType, _ := inner["type"].(string)
+	if msgType == "securedMsg" {
+		w.rejectSecuredMsg(message, "nested secured message")
+		return
+	}
+	if handler, isKnown := w._eventHandler[msgType]; isKnown {
+		handler(inner)
+	}
+}
+
+func (w *Wattpilot) rejectSecuredMsg(message map[string]interface{}, reason string) {
+	w.logEntry().Warn("Rejected secured message: ", reason)
+	w.emitEvent(EventSecurityViolation, map[string]interface{}{
+		"reason":    reason,
+		"requestId": message["requestId"],
+	})
+}
@@ -0,0 +1,98 @@
+package wattpilot
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Session is a lightweight handle on a shared Wattpilot connection. The
+// charger only accepts a few concurrent websocket clients, so independent
+// consumers within one process share a single connection through sessions,
+// each with its own subscriptions and write permission.
+type Session struct {
+	_name     string
+	_readOnly bool
+	_charger  *Wattpilot
+	_mutex    sync.Mutex
+	_subs     map[string][]<-chan interface{}
+	_closed   bool
+}
+
+func (w *Wattpilot) NewSession(name string, readOnly bool) *Session {
+	w.logEntry().Debug("New session ", name, " read-only: ", readOnly)
+
+	return &Session{
+		_name:     name,
+		_readOnly: readOnly,
+		_charger:  w,
+		_subs:     make(map[string][]<-chan interface{}),
+	}
+}
+
+func (s *Session) GetName() string {
+	return s._name
+}
+
+func (s *Session) IsReadOnly() bool {
+	return s._readOnly
+}
+
+func (s *Session) GetProperty(name string) (interface{}, error) {
+	if s.isClosed() {
+		return nil, ErrSessionClosed
+	}
+	return s._charger.GetProperty(name)
+}
+
+func (s *Session) SetProperty(name string, value interface{}) error {
+	if s.isClosed() {
+		return ErrSessionClosed
+	}
+	if s._readOnly {
+		return fmt.Errorf("session %s: %w", s._name, ErrSessionReadOnly)
+	}
+	return s._charger.setProperty(s._name, name, value)
+}
+
+func (s *Session) GetNotifications(prop string) <-chan interface{} {
+	s._mutex.Lock()
+	defer s._mutex.Unlock()
+
+	ch := s._charger._notifications.Subscribe(prop)
+	s._subs[prop] = append(s._subs[prop], ch)
+	return ch
+}
+
+func (s *Session) Unsubscribe(prop string, ch <-chan interface{}) {
+	s._mutex.Lock()
+	defer s._mutex.Unlock()
+
+	s._charger._notifications.Unsubscribe(prop, ch)
+	s._subs[prop] = remove(s._subs[prop], ch)
+}
+
+// Close releases all subscriptions of the session, the shared connection
+// stays open for the remaining sessions.
+func (s *Session) Close() {
+	s._mutex.Lock()
+	defer s._mutex.Unlock()
+
+	if s._closed {
+		return
+	}
+	// the subscription channels get closed by the unsubscribe
+	for prop, subs := range s._subs {
+		for _, ch := range subs {
+			s._charger._notifications.Unsubscribe(prop, ch)
+		}
+	}
+	s._subs = make(map[string][]<-chan interface{})
+	s._closed = true
+}
+
+func (s *Session) isClosed() bool {
+	s._mutex.Lock()
+	defer s._mutex.Unlock()
+
+	return s._closed
+}
@@ -24,6 +24,26 @@ var inputs = map[string]InputFunc{
 	"dump":       dumpData,
 	"log":        setLevel,
 	"update":     inUpdateStatus,
+	"store":      inStorePassword,
+}
+
+func credentialStore() (api.CredentialStore, error) {
+	return api.DefaultCredentialStore()
+}
+
+// inStorePassword saves the password encrypted, so later starts do not
+// need WATTPILOT_PASSWORD
+func inStorePassword(w *api.Wattpilot, data []string) {
+	if len(data) == 0 {
+		return
+	}
+	store, err := credentialStore()
+	if err == nil {
+		err = store.Set(w.GetHost(), data[0])
+	}
+	if err != nil {
+		fmt.Println("error on storing password: ", err)
+	}
 }
 
 func setLevel(w *api.Wattpilot, data []string) {
@@ -105,7 +125,14 @@ func dumpData(w *api.Wattpilot, data []string) {
 	keys := remove(w.Properties(), "wsm")
 	sort.Strings(keys)
 
+	formatter, err := api.NewFormatter(api.Locale(os.Getenv("WATTPILOT_LOCALE")), os.Getenv("WATTPILOT_TZ"))
+	if err != nil {
+		fmt.Println("Could not load timezone: ", err)
+		return
+	}
+
 	writer := csv.NewWriter(csvFile)
+	writer.Comma = formatter.CSVSeparator()
 	if dumpHeader {
 		if err := writer.Write(keys); err != nil {
 			log.Fatalln("Could not create csv file dump")
@@ -116,7 +143,7 @@ func dumpData(w *api.Wattpilot, data []string) {
 	for idx := 0; idx < len(keys); idx += 1 {
 		alias := keys[idx]
 		value, _ := w.GetProperty(alias)
-		dataSet = append(dataSet, fmt.Sprint(value))
+		dataSet = append(dataSet, formatter.FormatValue(value))
 	}
 	if err := writer.Write(dataSet); err != nil {
 		log.Fatalln("error writing csv-data:", err)
@@ -155,6 +182,11 @@ func main() {
 	host := os.Getenv("WATTPILOT_HOST")
 	pwd := os.Getenv("WATTPILOT_PASSWORD")
 	level := os.Getenv("WATTPILOT_LOG")
+	if pwd == "" {
+		if store, err := credentialStore(); err == nil {
+			pwd, _ = store.Get(host)
+		}
+	}
 	if host == "" || pwd == "" {
 		return
 	}
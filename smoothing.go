@@ -0,0 +1,120 @@
+package wattpilot
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Filter smooths a noisy input signal, Add takes the next sample and
+// returns the smoothed value including it
+type Filter interface {
+	Add(sample float64) float64
+}
+
+// EWMA is an exponentially weighted moving average. Alpha in (0, 1] is the
+// weight of the newest sample, small values smooth more but follow changes
+// slower, 1 disables the smoothing.
+type EWMA struct {
+	Alpha float64
+
+	value  float64
+	primed bool
+}
+
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{Alpha: alpha}
+}
+
+func (f *EWMA) Add(sample float64) float64 {
+	if !f.primed || f.Alpha <= 0 || f.Alpha >= 1 {
+		f.value = sample
+		f.primed = true
+		return f.value
+	}
+	f.value += f.Alpha * (sample - f.value)
+	return f.value
+}
+
+// MedianWindow is the median of the last Size samples, it drops single
+// outliers like a cloud edge passing the PV system completely
+type MedianWindow struct {
+	Size int
+
+	samples []float64
+}
+
+func NewMedianWindow(size int) *MedianWindow {
+	return &MedianWindow{Size: size}
+}
+
+func (f *MedianWindow) Add(sample float64) float64 {
+	f.samples = append(f.samples, sample)
+	if size := f.Size; size > 0 && len(f.samples) > size {
+		f.samples = f.samples[len(f.samples)-size:]
+	}
+	sorted := append([]float64(nil), f.samples...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// ControllerWrapper is implemented by controllers adding behaviour to
+// another controller, Unwrap returns the wrapped one
+type ControllerWrapper interface {
+	Unwrap() Controller
+}
+
+// SmoothedController passes the wrapped controller a snapshot with the
+// input Key replaced by its smoothed value, e.g. the grid power a surplus
+// controller follows, so the noise of the input does not make the current
+// oscillate. Snapshots without the input are passed unchanged.
+type SmoothedController struct {
+	Controller
+	Key    string
+	Filter Filter
+
+	mu sync.Mutex
+}
+
+// Smooth wraps the controller, smoothing the input key with the filter
+func Smooth(c Controller, key string, filter Filter) *SmoothedController {
+	return &SmoothedController{Controller: c, Key: key, Filter: filter}
+}
+
+func (s *SmoothedController) Evaluate(ctx context.Context, snapshot Snapshot) []Action {
+	sample, err := snapshot.Float(s.Key)
+	if err != nil {
+		return s.Controller.Evaluate(ctx, snapshot)
+	}
+	s.mu.Lock()
+	value := s.Filter.Add(sample)
+	s.mu.Unlock()
+
+	status := make(map[string]interface{}, len(snapshot.Status))
+	for k, v := range snapshot.Status {
+		status[k] = v
+	}
+	status[resolveKey(s.Key)] = value
+	snapshot.Status = status
+	return s.Controller.Evaluate(ctx, snapshot)
+}
+
+func (s *SmoothedController) Unwrap() Controller {
+	return s.Controller
+}
+
+// Priority and Healthy pass on those of the wrapped controller
+func (s *SmoothedController) Priority() int {
+	return controllerPriority(s.Controller)
+}
+
+func (s *SmoothedController) Healthy() error {
+	if h, ok := s.Controller.(HealthChecker); ok {
+		return h.Healthy()
+	}
+	return nil
+}
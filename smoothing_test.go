@@ -0,0 +1,81 @@
+package wattpilot_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+func TestFilters(t *testing.T) {
+	// PV feed-in with a cloud edge in the fourth sample
+	samples := []float64{-6000, -6200, -5800, -500, -6000, -6100}
+	tests := []struct {
+		name   string
+		filter wattpilot.Filter
+		want   []float64
+	}{
+		{"ewma", wattpilot.NewEWMA(0.5), []float64{-6000, -6100, -5950, -3225, -4612.5, -5356.25}},
+		{"ewma disabled", wattpilot.NewEWMA(1), samples},
+		{"median of 3", wattpilot.NewMedianWindow(3), []float64{-6000, -6100, -6000, -5800, -5800, -6000}},
+		{"median of 1", wattpilot.NewMedianWindow(1), samples},
+	}
+	for _, test := range tests {
+		for i, sample := range samples {
+			if got := test.filter.Add(sample); math.Abs(got-test.want[i]) > 1e-9 {
+				t.Errorf("%s: sample %d smoothed to %v, want %v", test.name, i, got, test.want[i])
+			}
+		}
+	}
+}
+
+// gridController records the grid power it was evaluated with
+type gridController struct {
+	seen []interface{}
+}
+
+func (c *gridController) Name() string {
+	return "grid"
+}
+
+func (c *gridController) Interval() time.Duration {
+	return time.Second
+}
+
+func (c *gridController) Priority() int {
+	return 7
+}
+
+func (c *gridController) Evaluate(ctx context.Context, snapshot wattpilot.Snapshot) []wattpilot.Action {
+	value, _ := snapshot.Get("grid_power")
+	c.seen = append(c.seen, value)
+	return nil
+}
+
+func TestSmoothedController(t *testing.T) {
+	inner := &gridController{}
+	c := wattpilot.Smooth(inner, "grid_power", wattpilot.NewMedianWindow(3))
+	if c.Name() != "grid" || c.Priority() != 7 || c.Unwrap() != inner {
+		t.Fatal("wrapper does not pass on the wrapped controller")
+	}
+	status := map[string]interface{}{"amp": 16.0}
+	for _, power := range []interface{}{-6000.0, -500.0, -6200.0, nil} {
+		if power != nil {
+			status["grid_power"] = power
+		} else {
+			delete(status, "grid_power")
+		}
+		c.Evaluate(context.Background(), wattpilot.Snapshot{Time: time.Now(), Status: status})
+		if status["grid_power"] != power {
+			t.Fatal("snapshot of the caller modified")
+		}
+	}
+	want := []interface{}{-6000.0, -3250.0, -6000.0, nil}
+	for i := range want {
+		if inner.seen[i] != want[i] {
+			t.Errorf("evaluation %d saw %v, want %v", i, inner.seen[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package wattpilot
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// EventSessionTakenOver is emitted when the charger is assumed to have dropped
+// this client for another one. The detection is heuristic, see isTakeover.
+const EventSessionTakenOver EventType = "sessionTakenOver"
+
+// ws status code 1013 "try again later" is not defined by gobwas/ws
+const statusTryAgainLater ws.StatusCode = 1013
+
+// TakeoverStance defines the reaction when the charger drops this client in
+// favour of a newer one, e.g. when the mobile app is opened
+type TakeoverStance int
+
+const (
+	// TakeoverReconnect reconnects immediately and pushes the other client out
+	TakeoverReconnect TakeoverStance = iota
+	// TakeoverBackoff waits before reconnecting to let the other client work
+	TakeoverBackoff
+)
+
+// WithTakeoverStance sets the reaction to a takeover. As takeovers are only
+// guessed from the close frame, other closes with the same status may
+// trigger the stance as well.
+func WithTakeoverStance(stance TakeoverStance, backoff time.Duration) Option {
+	return func(w *Wattpilot) {
+		w._takeoverStance = stance
+		w._takeoverBackoff = backoff
+	}
+}
+
+// isTakeover reports whether the peer closed the connection presumably
+// because the charger reached its client limit. This is a heuristic: no close
+// frame of a takeover has been captured yet, so policy violation and
+// try-again-later statuses as well as reasons mentioning clients or the
+// connection limit are taken as a takeover.
+func isTakeover(closed wsutil.ClosedError) bool {
+	if closed.Code == ws.StatusPolicyViolation || closed.Code == statusTryAgainLater {
+		return true
+	}
+	reason := strings.ToLower(closed.Reason)
+	return strings.Contains(reason, "client") || strings.Contains(reason, "connection limit")
+}
+
+// onReceiveError is called by the receive handler of the connection c when
+// reading failed, reconnects run in the background as they wait for the
+// receive handler to end
+func (w *Wattpilot) onReceiveError(c *connection, err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		w.logEntry().Warn("No data received within ", w._readTimeout, ", reconnecting")
+		w.reconnect(c, time.Second*RECONNECT_TIMEOUT)
+		return
+	}
+
+	w.onPeerClosed(err)
+
+	var closed wsutil.ClosedError
+	if !errors.As(err, &closed) || atomic.LoadInt32(&w._closing) == 1 || !isTakeover(closed) {
+		return
+	}
+
+	w.logEntry().Warn("Session taken over by another client: ", closed.Code, " ", closed.Reason)
+	w.emitEvent(EventSessionTakenOver, map[string]interface{}{
+		"code":   int(closed.Code),
+		"reason": closed.Reason,
+		"stance": w._takeoverStance,
+	})
+
+	delay := time.Second * RECONNECT_TIMEOUT
+	if w._takeoverStance == TakeoverBackoff {
+		delay = w._takeoverBackoff
+	}
+	w.reconnect(c, delay)
+}
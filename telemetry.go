@@ -0,0 +1,77 @@
+package wattpilot
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+)
+
+// TELEMETRY_INTERVAL is the default interval of the datagrams, in seconds
+const TELEMETRY_INTERVAL = 10
+
+// TelemetryKeys are sent when no keys are given to RunTelemetry
+var TelemetryKeys = []string{"car", "alw", "amp", "frc", "power", "eto"}
+
+// telemetryDatagram builds the JSON datagram of the keys. Post processed
+// properties are sent as numbers, unknown keys are left out.
+func (w *Wattpilot) telemetryDatagram(keys []string) ([]byte, error) {
+	datagram := map[string]interface{}{
+		"serial": w.GetSerial(),
+		"time":   w._clock.Now().Unix(),
+	}
+	for _, key := range keys {
+		value, err := w.GetProperty(key)
+		if err != nil {
+			continue
+		}
+		if text, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				value = f
+			}
+		}
+		datagram[key] = value
+	}
+	return json.Marshal(datagram)
+}
+
+// RunTelemetry sends a JSON datagram with the values of the keys to the
+// UDP address every interval until the context is cancelled, e.g. for
+// microcontroller displays which should not keep a TCP session. Broadcast
+// addresses are allowed. Send errors are logged and do not end the loop.
+func (w *Wattpilot) RunTelemetry(ctx context.Context, addr string, interval time.Duration, keys ...string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if interval <= 0 {
+		interval = time.Second * TELEMETRY_INTERVAL
+	}
+	if len(keys) == 0 {
+		keys = TelemetryKeys
+	}
+	timer := w._clock.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C():
+			timer.Reset(interval)
+		}
+		if !w.IsInitialized() {
+			continue
+		}
+		datagram, err := w.telemetryDatagram(keys)
+		if err != nil {
+			w.logEntry().Warn("Could not encode telemetry: ", err)
+			continue
+		}
+		if _, err := conn.Write(datagram); err != nil {
+			w.logEntry().Debug("Could not send telemetry to ", addr, ": ", err)
+		}
+	}
+}
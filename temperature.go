@@ -0,0 +1,249 @@
+package wattpilot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// TEMPERATURE_WARNING and TEMPERATURE_MAX are the default thresholds in
+	// °C for the hottest sensor
+	TEMPERATURE_WARNING = 60
+	TEMPERATURE_MAX     = 80
+	// TEMPERATURE_HYSTERESIS in °C the sensors have to cool below a
+	// threshold before it is reported as left
+	TEMPERATURE_HYSTERESIS = 5
+	// TEMPERATURE_HISTORY readings are kept, one per sample interval
+	TEMPERATURE_HISTORY         = 1440
+	TEMPERATURE_SAMPLE_INTERVAL = time.Minute
+
+	EventTemperature EventType = "temperature"
+	EventDerating    EventType = "derating"
+	// EventSetpointDerated is emitted when the current requested by a
+	// controller starts and stops being capped to the derated limit
+	EventSetpointDerated EventType = "setpointDerated"
+)
+
+// TemperatureThresholds in °C, Warning and Max are compared to the hottest
+// sensor
+type TemperatureThresholds struct {
+	Warning float64
+	Max     float64
+}
+
+// TemperatureLevel is the highest threshold exceeded by the hottest sensor
+type TemperatureLevel int
+
+const (
+	TemperatureNormal TemperatureLevel = iota
+	TemperatureWarning
+	TemperatureMax
+)
+
+func (l TemperatureLevel) String() string {
+	switch l {
+	case TemperatureWarning:
+		return "warning"
+	case TemperatureMax:
+		return "max"
+	}
+	return "normal"
+}
+
+// TemperatureReading is a sample of the temperature sensors tma with the
+// current limit amt the charger applies because of heat
+type TemperatureReading struct {
+	Time         time.Time `json:"time"`
+	Sensors      []float64 `json:"sensors"`
+	Max          float64   `json:"max"`
+	CurrentLimit float64   `json:"currentLimit"`
+	Derating     bool      `json:"derating"`
+}
+
+type temperatureTracker struct {
+	mu         sync.Mutex
+	thresholds TemperatureThresholds
+	level      TemperatureLevel
+	derating   bool
+	history    []TemperatureReading
+	capped     map[string]bool
+}
+
+// WithTemperatureThresholds replaces the default warning and max thresholds
+func WithTemperatureThresholds(thresholds TemperatureThresholds) Option {
+	return func(w *Wattpilot) {
+		w._temperature.thresholds = thresholds
+	}
+}
+
+// GetTemperatures returns the readings of the temperature sensors in °C
+func (w *Wattpilot) GetTemperatures() ([]float64, error) {
+	v, err := w.GetProperty("tma")
+	if err != nil {
+		return nil, err
+	}
+	return convertValue[[]float64](v)
+}
+
+// GetTemperatureCurrentLimit returns the current limit in A the charger
+// applies because of its temperature
+func (w *Wattpilot) GetTemperatureCurrentLimit() (float64, error) {
+	return w.getFloatProperty("amt")
+}
+
+// IsDerating reports whether the charger limits the current below the
+// requested one or the configured maximum because of heat
+func (w *Wattpilot) IsDerating() bool {
+	w._temperature.mu.Lock()
+	defer w._temperature.mu.Unlock()
+	return w._temperature.derating
+}
+
+// TemperatureLevel returns the highest threshold exceeded
+func (w *Wattpilot) TemperatureLevel() TemperatureLevel {
+	w._temperature.mu.Lock()
+	defer w._temperature.mu.Unlock()
+	return w._temperature.level
+}
+
+// TemperatureHistory returns the sampled readings, oldest first
+func (w *Wattpilot) TemperatureHistory() []TemperatureReading {
+	w._temperature.mu.Lock()
+	defer w._temperature.mu.Unlock()
+	return append([]TemperatureReading{}, w._temperature.history...)
+}
+
+func (t *temperatureTracker) levelOf(max float64) TemperatureLevel {
+	level := t.level
+	switch {
+	case max >= t.thresholds.Max:
+		level = TemperatureMax
+	case max >= t.thresholds.Warning:
+		if level < TemperatureWarning || max < t.thresholds.Max-TEMPERATURE_HYSTERESIS {
+			level = TemperatureWarning
+		}
+	case max < t.thresholds.Warning-TEMPERATURE_HYSTERESIS:
+		level = TemperatureNormal
+	case level == TemperatureMax:
+		level = TemperatureWarning
+	}
+	return level
+}
+
+// isDerated reports whether the temperature current limit is below the
+// requested current or the configured maximum, the latter still holds
+// when controllers cap their requests to the limit
+func isDerated(limit float64, status map[string]interface{}) bool {
+	for _, key := range []string{"amp", "ama"} {
+		if current, err := toFloat(status[key]); err == nil && limit < current {
+			return true
+		}
+	}
+	return false
+}
+
+// capDerated lowers the current requested by a controller to the
+// temperature current limit, as the charger would not deliver more, and
+// emits EventSetpointDerated when the capping of a controller starts and
+// ends
+func (w *Wattpilot) capDerated(c Controller, snapshot Snapshot, action Action) Action {
+	if resolveKey(action.Key) != "amp" {
+		return action
+	}
+	requested, err := toFloat(action.Value)
+	if err != nil {
+		return action
+	}
+	limit, err := snapshot.Float("amt")
+	capped := err == nil && requested > limit
+
+	t := w._temperature
+	t.mu.Lock()
+	changed := capped != t.capped[c.Name()]
+	t.capped[c.Name()] = capped
+	t.mu.Unlock()
+
+	if changed {
+		data := map[string]interface{}{
+			"controller": c.Name(),
+			"active":     capped,
+			"requested":  requested,
+		}
+		if capped {
+			data["limit"] = limit
+			w.logEntry().Info("Controller ", c.Name(), " requests ", requested, " A, capped to ", limit, " A because of heat")
+		}
+		w.emitEvent(EventSetpointDerated, data)
+	}
+	if !capped {
+		return action
+	}
+	action.Value = limit
+	note := fmt.Sprintf("capped from %.0f A by temperature derating", requested)
+	if action.Reason == "" {
+		action.Reason = note
+	} else {
+		action.Reason += ", " + note
+	}
+	return action
+}
+
+// trackTemperature is called with the read mutex held for every status update
+func (w *Wattpilot) trackTemperature(updates map[string]interface{}) {
+	_, sensorsUpdated := updates["tma"]
+	_, limitUpdated := updates["amt"]
+	_, currentUpdated := updates["amp"]
+	_, maxUpdated := updates["ama"]
+	if !sensorsUpdated && !limitUpdated && !currentUpdated && !maxUpdated {
+		return
+	}
+	sensors, err := convertValue[[]float64](w._status["tma"])
+	if err != nil || len(sensors) == 0 {
+		return
+	}
+	reading := TemperatureReading{Time: w._clock.Now(), Sensors: sensors, Max: sensors[0]}
+	for _, s := range sensors {
+		if s > reading.Max {
+			reading.Max = s
+		}
+	}
+	if limit, err := toFloat(w._status["amt"]); err == nil {
+		reading.CurrentLimit = limit
+		reading.Derating = isDerated(limit, w._status)
+	}
+
+	t := w._temperature
+	t.mu.Lock()
+	level := t.levelOf(reading.Max)
+	levelChanged := level != t.level
+	deratingChanged := reading.Derating != t.derating
+	t.level = level
+	t.derating = reading.Derating
+	if len(t.history) == 0 || deratingChanged || reading.Time.Sub(t.history[len(t.history)-1].Time) >= TEMPERATURE_SAMPLE_INTERVAL {
+		t.history = append(t.history, reading)
+		if len(t.history) > TEMPERATURE_HISTORY {
+			t.history = t.history[len(t.history)-TEMPERATURE_HISTORY:]
+		}
+	}
+	t.mu.Unlock()
+
+	if levelChanged {
+		if level > TemperatureNormal {
+			w.logEntry().Warn("Charger temperature ", reading.Max, " °C reached the ", level, " threshold")
+		}
+		w.emitEvent(EventTemperature, map[string]interface{}{
+			"level":   level.String(),
+			"reading": reading,
+		})
+	}
+	if deratingChanged {
+		if reading.Derating {
+			w.logEntry().Info("Charging current reduced to ", reading.CurrentLimit, " A because of heat")
+		}
+		w.emitEvent(EventDerating, map[string]interface{}{
+			"active":  reading.Derating,
+			"reading": reading,
+		})
+	}
+}
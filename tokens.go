@@ -0,0 +1,51 @@
+package wattpilot
+
+import (
+	"errors"
+	"sync"
+)
+
+// SEEN_TOKENS is the number of token2 values remembered per instance
+const SEEN_TOKENS = 256
+
+var ErrTokenReplayed = errors.New("authentication token of the charger was reused")
+
+// tokenHistory remembers the recent token2 values of the charger. A reused
+// token is a sign of a replayed handshake, answering it would hand out a
+// second hash for the same challenge.
+type tokenHistory struct {
+	mu     sync.Mutex
+	seen   map[string]bool
+	tokens []string
+}
+
+func (h *tokenHistory) add(token string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.seen == nil {
+		h.seen = make(map[string]bool)
+	}
+	if h.seen[token] {
+		return false
+	}
+	if len(h.tokens) >= SEEN_TOKENS {
+		delete(h.seen, h.tokens[0])
+		h.tokens = h.tokens[1:]
+	}
+	h.seen[token] = true
+	h.tokens = append(h.tokens, token)
+	return true
+}
+
+// checkToken2 refuses the handshake for a reused token2
+func (w *Wattpilot) checkToken2(token2 string) error {
+	if w._tokens.add(token2) {
+		return nil
+	}
+	w.logEntry().Warn("Refusing authentication, token2 was used before")
+	w.emitEvent(EventSecurityViolation, map[string]interface{}{
+		"reason": "token2 replayed",
+	})
+	return ErrTokenReplayed
+}
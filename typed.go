@@ -0,0 +1,104 @@
+package wattpilot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+const EventConversionError EventType = "conversionError"
+
+func convertValue[T any](value interface{}) (T, error) {
+	var result T
+	if v, ok := value.(T); ok {
+		return v, nil
+	}
+	var err error
+	switch target := any(&result).(type) {
+	case *string:
+		*target = fmt.Sprintf("%v", value)
+	case *float64:
+		*target, err = toFloat(value)
+	case *int:
+		var f float64
+		f, err = toFloat(value)
+		*target = int(f)
+	case *int64:
+		var f float64
+		f, err = toFloat(value)
+		*target = int64(f)
+	case *bool:
+		*target, err = toBool(value)
+	default:
+		var data []byte
+		if data, err = json.Marshal(value); err == nil {
+			err = json.Unmarshal(data, target)
+		}
+	}
+	if err != nil {
+		return result, fmt.Errorf("could not convert %v to %T: %w", value, result, err)
+	}
+	return result, nil
+}
+
+// SubscribeTyped subscribes to updates of a property or alias and converts
+// the values to T. Values which cannot be converted are skipped and
+// published as EventConversionError. The returned function cancels the
+// subscription and closes the channel.
+func SubscribeTyped[T any](w *Wattpilot, prop string) (<-chan T, func(), error) {
+	key := prop
+	if v, isKnown := propertyMap[prop]; isKnown {
+		key = v
+	}
+	m, post := PostProcess[prop]
+	if post {
+		key = m.key
+	}
+	if key == "" {
+		return nil, nil, fmt.Errorf("invalid property %s: %w", prop, ErrPropertyNotFound)
+	}
+
+	in := w._notifications.Subscribe(key)
+	out := make(chan T, PUBSUB_BUFFER_SIZE)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-done:
+				return
+			case value, ok := <-in:
+				if !ok {
+					return
+				}
+				if post {
+					value, _ = m.f(value)
+				}
+				typed, err := convertValue[T](value)
+				if err != nil {
+					w.emitEvent(EventConversionError, map[string]interface{}{
+						"property": prop,
+						"value":    value,
+						"error":    err.Error(),
+					})
+					continue
+				}
+				select {
+				case out <- typed:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			w._notifications.Unsubscribe(key, in)
+		})
+	}
+	return out, cancel, nil
+}
@@ -0,0 +1,106 @@
+package wattpilot
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Unit string
+
+const (
+	UnitWatt         Unit = "W"
+	UnitKilowatt     Unit = "kW"
+	UnitWattSecond   Unit = "Ws"
+	UnitWattHour     Unit = "Wh"
+	UnitKilowattHour Unit = "kWh"
+	UnitVolt         Unit = "V"
+	UnitAmpere       Unit = "A"
+	UnitHertz        Unit = "Hz"
+	UnitCelsius      Unit = "°C"
+)
+
+// unitScale maps every unit to its dimension and the factor to the base
+// unit of the dimension
+var unitScale = map[Unit]struct {
+	dimension string
+	factor    float64
+}{
+	UnitWatt:         {"power", 1},
+	UnitKilowatt:     {"power", 1000},
+	UnitWattSecond:   {"energy", 1.0 / 3600},
+	UnitWattHour:     {"energy", 1},
+	UnitKilowattHour: {"energy", 1000},
+	UnitVolt:         {"voltage", 1},
+	UnitAmpere:       {"current", 1},
+	UnitHertz:        {"frequency", 1},
+	UnitCelsius:      {"temperature", 1},
+}
+
+// propertyUnits holds the unit the charger reports a property in, by key
+// or by the name of post processed and virtual properties
+var propertyUnits = map[string]Unit{
+	"eto":             UnitWattHour,
+	"wh":              UnitWattHour,
+	"dwo":             UnitWattHour,
+	"amp":             UnitAmpere,
+	"ama":             UnitAmpere,
+	"cbl":             UnitAmpere,
+	"mca":             UnitAmpere,
+	"amt":             UnitAmpere,
+	"fhz":             UnitHertz,
+	"fst":             UnitWatt,
+	"pgrid":           UnitWatt,
+	"ppv":             UnitWatt,
+	"pakku":           UnitWatt,
+	"voltage1":        UnitVolt,
+	"voltage2":        UnitVolt,
+	"voltage3":        UnitVolt,
+	"voltageN":        UnitVolt,
+	"amps1":           UnitAmpere,
+	"amps2":           UnitAmpere,
+	"amps3":           UnitAmpere,
+	"power1":          UnitWatt,
+	"power2":          UnitWatt,
+	"power3":          UnitWatt,
+	"powerM":          UnitWatt,
+	"power":           UnitWatt,
+	"totalCurrent":    UnitAmpere,
+	"chargingPowerKW": UnitKilowatt,
+}
+
+// PropertyUnit returns the unit of a property given by key, alias or name
+func PropertyUnit(name string) (Unit, bool) {
+	if unit, isKnown := propertyUnits[name]; isKnown {
+		return unit, true
+	}
+	unit, isKnown := propertyUnits[resolveKey(name)]
+	return unit, isKnown
+}
+
+func ConvertUnit(value float64, from Unit, to Unit) (float64, error) {
+	f, isKnown := unitScale[from]
+	if !isKnown {
+		return 0, errors.New("unknown unit " + string(from))
+	}
+	t, isKnown := unitScale[to]
+	if !isKnown {
+		return 0, errors.New("unknown unit " + string(to))
+	}
+	if f.dimension != t.dimension {
+		return 0, fmt.Errorf("cannot convert %s to %s", from, to)
+	}
+	return value * f.factor / t.factor, nil
+}
+
+// GetPropertyAs reads a numeric property and converts it to the given unit
+func (w *Wattpilot) GetPropertyAs(name string, unit Unit) (float64, error) {
+	from, isKnown := PropertyUnit(name)
+	if !isKnown {
+		return 0, errors.New("unit of " + name + " is unknown")
+	}
+	value, err := w.getFloatProperty(name)
+	if err != nil {
+		return 0, err
+	}
+	return ConvertUnit(value, from, unit)
+}
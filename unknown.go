@@ -0,0 +1,73 @@
+package wattpilot
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// UnknownProperty is a key reported by the charger which is missing in the
+// generated property mapping
+type UnknownProperty struct {
+	Key       string
+	Sample    interface{}
+	Firmware  string
+	FirstSeen time.Time
+	Updates   int
+}
+
+type unknownTracker struct {
+	mu     sync.Mutex
+	keys   map[string]*UnknownProperty
+	report func(UnknownProperty)
+}
+
+// WithUnknownPropertyReporter calls f once for every unknown key with its
+// first value, e.g. to report it for the next schema update
+func WithUnknownPropertyReporter(f func(UnknownProperty)) Option {
+	return func(w *Wattpilot) {
+		w._unknown.report = f
+	}
+}
+
+// UnknownProperties returns the unknown keys seen since the start, sorted
+// by key, with their last value
+func (w *Wattpilot) UnknownProperties() []UnknownProperty {
+	t := w._unknown
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	properties := make([]UnknownProperty, 0, len(t.keys))
+	for _, p := range t.keys {
+		properties = append(properties, *p)
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		return properties[i].Key < properties[j].Key
+	})
+	return properties
+}
+
+// trackUnknown is called with the read mutex held for every status update
+func (w *Wattpilot) trackUnknown(updates map[string]interface{}) {
+	t := w._unknown
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, value := range updates {
+		if IsKnownKey(key) {
+			continue
+		}
+		if p, isSeen := t.keys[key]; isSeen {
+			p.Sample = value
+			p.Updates++
+			continue
+		}
+		p := &UnknownProperty{Key: key, Sample: value, Firmware: w.firmwareVersion(), FirstSeen: w._clock.Now(), Updates: 1}
+		t.keys[key] = p
+		w.logEntry().Debug("Unknown property ", key)
+		if t.report != nil {
+			// the callback must not run with the status locked
+			go t.report(*p)
+		}
+	}
+}
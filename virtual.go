@@ -0,0 +1,149 @@
+package wattpilot
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// PHASE_ACTIVE_CURRENT is the current in ampere above a phase counts as used
+const PHASE_ACTIVE_CURRENT = 0.5
+
+// VirtualFunction computes a virtual property, lookup returns the current
+// value of a native property by key. It runs with the status lock held, so
+// it has to read the status with lookup: GetProperty and the other getters
+// of the client would wait for the lock and deadlock.
+type VirtualFunction func(lookup func(key string) (interface{}, bool)) (interface{}, error)
+
+type virtualProperty struct {
+	deps []string
+	f    VirtualFunction
+}
+
+// RegisterVirtualProperty adds a property computed from the given native
+// properties (keys or aliases). It can be read with GetProperty and is
+// published whenever one of its dependencies changes.
+func (w *Wattpilot) RegisterVirtualProperty(name string, deps []string, f VirtualFunction) error {
+	if _, isAlias := propertyMap[name]; isAlias || IsKnownKey(name) {
+		return errors.New("virtual property " + name + " shadows a native property")
+	}
+	keys := []string{}
+	for _, dep := range deps {
+		keys = append(keys, resolveKey(dep))
+	}
+
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	w._virtuals[name] = virtualProperty{deps: keys, f: f}
+	return nil
+}
+
+func (w *Wattpilot) VirtualProperties() []string {
+	w._readMutex.Lock()
+	defer w._readMutex.Unlock()
+
+	return Keys(w._virtuals)
+}
+
+// computeVirtual must be called with the read mutex held
+func (w *Wattpilot) computeVirtual(v virtualProperty) (interface{}, error) {
+	return v.f(func(key string) (interface{}, bool) {
+		value, isKnown := w._status[key]
+		return value, isKnown
+	})
+}
+
+// publishVirtuals must be called with the read mutex held
+func (w *Wattpilot) publishVirtuals(updates map[string]interface{}) {
+	for name, v := range w._virtuals {
+		if !w._notifications.hasSubscribers(name) {
+			continue
+		}
+		for _, dep := range v.deps {
+			if !hasKey(updates, dep) {
+				continue
+			}
+			if value, err := w.computeVirtual(v); err == nil {
+				w._notifications.Publish(name, value)
+			}
+			break
+		}
+	}
+}
+
+func nrgValue(lookup func(string) (interface{}, bool), idx int) (float64, error) {
+	data, isKnown := lookup("nrg")
+	if !isKnown {
+		return 0, fmt.Errorf("could not find value of nrg: %w", ErrPropertyNotFound)
+	}
+	values, ok := data.([]interface{})
+	if !ok || len(values) <= idx {
+		return 0, fmt.Errorf("nrg data: %w", ErrInvalidValue)
+	}
+	return toFloat(values[idx])
+}
+
+func totalCurrent(lookup func(string) (interface{}, bool)) (interface{}, error) {
+	total := 0.0
+	for idx := 4; idx <= 6; idx++ {
+		v, err := nrgValue(lookup, idx)
+		if err != nil {
+			return nil, err
+		}
+		total += v
+	}
+	return total, nil
+}
+
+func phaseCount(lookup func(string) (interface{}, bool)) (interface{}, error) {
+	count := 0
+	for idx := 4; idx <= 6; idx++ {
+		v, err := nrgValue(lookup, idx)
+		if err != nil {
+			return nil, err
+		}
+		if v > PHASE_ACTIVE_CURRENT {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func chargingPowerKW(lookup func(string) (interface{}, bool)) (interface{}, error) {
+	v, err := nrgValue(lookup, 11)
+	if err != nil {
+		return nil, err
+	}
+	return v / 1000, nil
+}
+
+// pvShare is the part of the charging power which is not imported from the grid
+func pvShare(lookup func(string) (interface{}, bool)) (interface{}, error) {
+	power, err := nrgValue(lookup, 11)
+	if err != nil {
+		return nil, err
+	}
+	if power <= 0 {
+		return 0.0, nil
+	}
+	data, isKnown := lookup("pgrid")
+	if !isKnown || data == nil {
+		return nil, fmt.Errorf("could not find value of pgrid: %w", ErrPropertyNotFound)
+	}
+	grid, err := toFloat(data)
+	if err != nil {
+		return nil, err
+	}
+	return math.Min(1, math.Max(0, 1-math.Max(grid, 0)/power)), nil
+}
+
+func (w *Wattpilot) registerBuiltinVirtuals() {
+	w._virtuals = map[string]virtualProperty{
+		"totalCurrent":     {deps: []string{"nrg"}, f: totalCurrent},
+		"phaseCount":       {deps: []string{"nrg"}, f: phaseCount},
+		"chargingPowerKW":  {deps: []string{"nrg"}, f: chargingPowerKW},
+		"pvShare":          {deps: []string{"nrg", "pgrid"}, f: pvShare},
+		"voltageImbalance": {deps: []string{"nrg"}, f: voltageImbalance},
+	}
+}
@@ -0,0 +1,62 @@
+package wattpilot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+	"github.com/mabunixda/wattpilot/wattpilottest"
+)
+
+// chargingWatts computes the three phase power from the charging current
+func chargingWatts(lookup func(key string) (interface{}, bool)) (interface{}, error) {
+	amp, isKnown := lookup("amp")
+	if !isKnown {
+		return nil, wattpilot.ErrPropertyNotFound
+	}
+	return amp.(float64) * 230 * 3, nil
+}
+
+func TestVirtualPropertyRecomputes(t *testing.T) {
+	clock := wattpilottest.NewFakeClock(time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC))
+	client, _, conn := connect(t, clock)
+	// the dependency is given by its alias
+	if err := client.RegisterVirtualProperty("chargingWatts", []string{"chargingCurrent"}, chargingWatts); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"amp", "chargingCurrent"} {
+		if err := client.RegisterVirtualProperty(name, []string{"amp"}, chargingWatts); err == nil {
+			t.Fatal("virtual property shadows ", name)
+		}
+	}
+	updates := client.GetNotifications("chargingWatts")
+
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": map[string]interface{}{"amp": 6, "car": 2}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range []map[string]interface{}{{"car": 1}, {"amp": 10}} {
+		if err := conn.Send(map[string]interface{}{"type": "deltaStatus", "status": status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the change of car is not published, it is no dependency
+	for _, want := range []float64{4140, 6900} {
+		select {
+		case value := <-updates:
+			if value != want {
+				t.Fatalf("published %v, want %v", value, want)
+			}
+		case <-time.After(testTimeout):
+			t.Fatal("virtual property not published")
+		}
+	}
+	if value, err := client.GetProperty("chargingWatts"); err != nil || value != 6900.0 {
+		t.Fatal("unexpected value ", value, err)
+	}
+	select {
+	case value := <-updates:
+		t.Fatal("unexpected update ", value)
+	default:
+	}
+}
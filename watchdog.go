@@ -0,0 +1,141 @@
+package wattpilot
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// WATCHDOG_INTERVAL in seconds between the checks of the supervisor
+	WATCHDOG_INTERVAL = 10
+	// WATCHDOG_STUCK is the number of missed process loop ticks after which
+	// the loop is considered stuck
+	WATCHDOG_STUCK = 3
+	// WATCHDOG_STACK_SIZE bounds the goroutine dump of the diagnostics
+	WATCHDOG_STACK_SIZE = 64 * 1024
+
+	EventWatchdog EventType = "watchdog"
+)
+
+// WatchdogStatus reports the heartbeats of the internal goroutines
+type WatchdogStatus struct {
+	Receiving     bool
+	LastMessage   time.Time
+	LastLoopCycle time.Time
+	Restarts      uint64
+	Panics        uint64
+}
+
+type watchdog struct {
+	receiving   atomic.Bool
+	lastMessage atomic.Int64
+	stopped     atomic.Int64
+	lastLoop    atomic.Int64
+	generation  atomic.Uint64
+	restarts    atomic.Uint64
+	panics      atomic.Uint64
+}
+
+func (w *Wattpilot) WatchdogStatus() WatchdogStatus {
+	d := w._watchdog
+	return WatchdogStatus{
+		Receiving:     d.receiving.Load(),
+		LastMessage:   unixTime(d.lastMessage.Load()),
+		LastLoopCycle: unixTime(d.lastLoop.Load()),
+		Restarts:      d.restarts.Load(),
+		Panics:        d.panics.Load(),
+	}
+}
+
+func unixTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// dispatch calls the handler of a message, a panic drops the message
+// instead of ending the receive handler and with it all updates
+func (w *Wattpilot) dispatch(msgType string, f eventFunc, data map[string]interface{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			w._watchdog.panics.Add(1)
+			w.logEntry().Error("Handler of ", msgType, " panicked: ", r)
+			w.emitEvent(EventWatchdog, map[string]interface{}{
+				"component": "receiveHandler",
+				"reason":    fmt.Sprintf("handler of %s panicked: %v", msgType, r),
+				"stack":     string(debug.Stack()),
+			})
+		}
+	}()
+	f(data)
+	w._watchdog.lastMessage.Store(w._clock.Now().UnixNano())
+}
+
+func (w *Wattpilot) receiveStarted() {
+	w._watchdog.receiving.Store(true)
+}
+
+func (w *Wattpilot) receiveStopped() {
+	w._watchdog.stopped.Store(w._clock.Now().UnixNano())
+	w._watchdog.receiving.Store(false)
+}
+
+// supervise restarts the connection when the receive handler ended without
+// a reconnect following, and replaces a stuck process loop
+func (w *Wattpilot) supervise(ctx context.Context) {
+	interval := time.Second * WATCHDOG_INTERVAL
+	timer := w._clock.NewTimer(interval)
+	defer timer.Stop()
+
+	stuck := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C():
+			timer.Reset(interval)
+		}
+		d := w._watchdog
+		now := w._clock.Now()
+
+		stopped := unixTime(d.stopped.Load())
+		if w._isInitialized.Load() && !d.receiving.Load() && now.Sub(stopped) >= interval {
+			w.restartComponent("receiveHandler", "receive handler ended at "+stopped.Format(time.RFC3339)+" without reconnect", false)
+			w.reconnect(nil, time.Second*RECONNECT_TIMEOUT)
+		}
+
+		lastLoop := unixTime(d.lastLoop.Load())
+		if lastLoop.IsZero() || now.Sub(lastLoop) < time.Second*CONTEXT_TIMEOUT*WATCHDOG_STUCK {
+			stuck = false
+			continue
+		}
+		if stuck {
+			continue
+		}
+		// the stuck loop ends as soon as it is unblocked, as its generation
+		// is outdated
+		stuck = true
+		w.restartComponent("processLoop", "process loop did not cycle since "+lastLoop.Format(time.RFC3339), true)
+		generation := d.generation.Add(1)
+		go w.processLoop(ctx, generation)
+	}
+}
+
+func (w *Wattpilot) restartComponent(component string, reason string, dump bool) {
+	w._watchdog.restarts.Add(1)
+	w.logEntry().Error("Watchdog restarts ", component, ": ", reason)
+	data := map[string]interface{}{
+		"component": component,
+		"reason":    reason,
+	}
+	if dump {
+		stack := make([]byte, WATCHDOG_STACK_SIZE)
+		data["stack"] = string(stack[:runtime.Stack(stack, true)])
+	}
+	w.emitEvent(EventWatchdog, data)
+}
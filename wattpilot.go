@@ -1,16 +1,13 @@
 package wattpilot
 
 import (
+	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/base64"
-	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
@@ -20,13 +17,16 @@ import (
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
+	"github.com/mabunixda/wattpilot/auth"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
 	CONTEXT_TIMEOUT   = 30 // seconds
 	RECONNECT_TIMEOUT = 5  // seconds
+
+	DEFAULT_USER_AGENT = "go-wattpilot"
+	READ_TIMEOUT       = 90 // seconds
 )
 
 //go:generate go run gen/generate.go
@@ -49,14 +49,15 @@ type Wattpilot struct {
 	_readCancel   context.CancelFunc
 	_readMutex    sync.Mutex
 
-	_token3         string
-	_hashedpassword string
-	_host           string
-	_password       string
-	_isInitialized  bool
-	_isConnected    bool
-	_status         map[string]interface{}
-	_eventHandler   map[string]eventFunc
+	_authSecrets   atomic.Pointer[authSecrets]
+	_authHash      *pendingHash
+	_hashes        *hashCache
+	_host          string
+	_password      string
+	_isInitialized atomic.Bool
+	_isConnected   atomic.Bool
+	_status        map[string]interface{}
+	_eventHandler  map[string]eventFunc
 
 	_sendResponse chan string
 	_interrupt    chan os.Signal
@@ -64,10 +65,72 @@ type Wattpilot struct {
 
 	_notifications     *Pubsub
 	_log               *log.Logger
-	_currentConnection *net.Conn
+	_currentConnection atomic.Pointer[connection]
+	_connMutex         sync.Mutex
+	_clock             Clock
+
+	_cloudSerial   string
+	_failover      *FailoverPolicy
+	_path          ConnectionPath
+	_pathSince     time.Time
+	_pathMutex     sync.Mutex
+	_localFailures int
+
+	_installationEnabled int32
+	_dryRun              int32
+	_decisions           *decisionLog
+	_auditLog            AuditLog
+	_virtuals            map[string]virtualProperty
+	_retryPolicy         RetryPolicy
+	_takeoverStance      TakeoverStance
+	_takeoverBackoff     time.Duration
+	_userAgent           string
+	_readTimeout         time.Duration
+	_closing             int32
+	_compression         bool
+	_deflate             bool
+	_requests            *requestTracker
+	_fullDump            int32
+	_logFields           log.Fields
+	_plug                *plugWatcher
+	_completion          *completionTracker
+	_calibration         calibrationStore
+	_arbiter             *arbiter
+	_safeMode            safeModeConfig
+	_lastStatusUpdate    time.Time
+	_quality             *qualityTracker
+	_signal              *signalTracker
+	_observer            bool
+	_context             context.Context
+	_stop                context.CancelFunc
+	_reconnectMutex      sync.Mutex
+	_reconnectDone       chan struct{}
+	_loopDone            chan struct{}
+	_background          sync.WaitGroup
+	_logEntry            atomic.Pointer[log.Entry]
+	_identityMutex       sync.RWMutex
+	_fastDecode          bool
+	_inverters           *inverterState
+	_knownDevices        *KnownDevices
+	_fleet               atomic.Pointer[Manager]
+	_strictPinning       bool
+	_connectError        error
+	_tokens              tokenHistory
+	_guest               *guestState
+	_capture             func(incoming bool, message map[string]interface{})
+	_unknown             *unknownTracker
+	_anomalies           *anomalyTracker
+	_faults              *faultTracker
+	_temperature         *temperatureTracker
+	_budget              *budgetState
+	_wear                *WearCounter
+	_watchdog            *watchdog
+	_schema              *schemaChecker
+	_presets             *PresetStore
+	_controllers         *controllerSwitch
 }
 
-func New(host string, password string) *Wattpilot {
+func New(host string, password string, options ...Option) *Wattpilot {
 
 	w := &Wattpilot{
 		_host:     host,
@@ -79,11 +142,34 @@ func New(host string, password string) *Wattpilot {
 		_done:         make(chan interface{}),
 		_interrupt:    make(chan os.Signal),
 
-		_currentConnection: nil,
-		_isConnected:       false,
-		_isInitialized:     false,
-		_requestId:         0,
-		_status:            make(map[string]interface{}),
+		_requestId:   0,
+		_hashes:      newHashCache(),
+		_status:      newStatusMap(),
+		_clock:       realClock{},
+		_path:        PathLocal,
+		_decisions:   newDecisionLog(DECISION_LOG_SIZE),
+		_retryPolicy: DefaultRetryPolicy(),
+		_userAgent:   DEFAULT_USER_AGENT,
+		_readTimeout: time.Second * READ_TIMEOUT,
+		_requests:    newRequestTracker(),
+		_logFields:   log.Fields{},
+		_plug:        &plugWatcher{debounce: time.Second * PLUG_DEBOUNCE},
+		_completion:  &completionTracker{},
+		_arbiter:     newArbiter(),
+		_safeMode:    safeModeConfig{mode: DefaultSafeMode()},
+		_quality:     &qualityTracker{},
+		_signal:      &signalTracker{threshold: WEAK_SIGNAL_RSSI},
+		_inverters:   &inverterState{},
+		_guest:       &guestState{},
+		_unknown:     &unknownTracker{keys: make(map[string]*UnknownProperty)},
+		_anomalies:   &anomalyTracker{active: make(map[string]MeasurementAnomaly)},
+		_faults:      &faultTracker{},
+		_budget:      &budgetState{},
+		_watchdog:    &watchdog{},
+		_temperature: &temperatureTracker{thresholds: TemperatureThresholds{Warning: TEMPERATURE_WARNING, Max: TEMPERATURE_MAX}, capped: make(map[string]bool)},
+		_schema:      &schemaChecker{unknown: make(map[string]bool)},
+		_presets:     newPresetStore(""),
+		_controllers: &controllerSwitch{disabled: make(map[string]bool)},
 	}
 
 	w._readContext, w._readCancel = context.WithCancel(context.Background())
@@ -93,13 +179,18 @@ func New(host string, password string) *Wattpilot {
 	w._log.SetLevel(log.ErrorLevel)
 	if level := os.Getenv("WATTPILOT_LOG"); level != "" {
 		if err := w.ParseLogLevel(level); err != nil {
-			w._log.Warn("Could not parse log level setting ", err)
+			w.logEntry().Warn("Could not parse log level setting ", err)
 		}
 	}
 
 	signal.Notify(w._interrupt, os.Interrupt) // Notify the interrupt channel for SIGINT
 
+	w.registerBuiltinVirtuals()
+
 	w._notifications = NewPubsub()
+	w._notifications.OnSlowSubscriber = func(stats SubscriberStats) {
+		w.logEntry().Warn("Slow subscriber on ", stats.Topic, " dropped ", stats.Dropped, " messages")
+	}
 
 	w._eventHandler = map[string]eventFunc{
 		"hello":          w.onEventHello,
@@ -111,9 +202,21 @@ func New(host string, password string) *Wattpilot {
 		"deltaStatus":    w.onEventDeltaStatus,
 		"clearInverters": w.onEventClearInverters,
 		"updateInverter": w.onEventUpdateInverter,
+		"securedMsg":     w.onEventSecuredMsg,
 	}
 
-	go w.processLoop(context.Background())
+	for _, option := range options {
+		option(w)
+	}
+	installRedactHook(w._log, w)
+
+	w._context, w._stop = context.WithCancel(context.Background())
+	w._loopDone = make(chan struct{})
+	go w.processLoop(w._context, 0)
+	w.goBackground(w.supervise)
+	if w.canFailover() {
+		w.goBackground(w.runFailback)
+	}
 
 	return w
 
@@ -132,19 +235,37 @@ func (w *Wattpilot) ParseLogLevel(level string) error {
 }
 
 func (w *Wattpilot) GetName() string {
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
 	return w._name
 }
 
 func (w *Wattpilot) GetSerial() string {
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
 	return w._serial
 }
 
+// firmwareVersion is the version announced in the hello, empty before it
+func (w *Wattpilot) firmwareVersion() string {
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
+	return w._version
+}
+
+// isSecured reports whether the charger requires secured messages for writes
+func (w *Wattpilot) isSecured() bool {
+	w._identityMutex.RLock()
+	defer w._identityMutex.RUnlock()
+	return w._secured
+}
+
 func (w *Wattpilot) GetHost() string {
 	return w._host
 }
 
 func (w *Wattpilot) IsInitialized() bool {
-	return w._isInitialized
+	return w._isInitialized.Load()
 }
 
 func (w *Wattpilot) Properties() []string {
@@ -175,17 +296,22 @@ func (w *Wattpilot) getRequestId() int64 {
 
 func (w *Wattpilot) onEventHello(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Hello from Wattpilot")
+	w.logEntry().Info("Hello from Wattpilot")
 
+	hostname := w._hostname
 	if hasKey(message, "hostname") {
-		w._hostname = message["hostname"].(string)
+		hostname = message["hostname"].(string)
 	}
+	name := hostname
 	if hasKey(message, "friendly_name") {
-		w._name = message["friendly_name"].(string)
-	} else {
-		w._name = w._hostname
+		name = message["friendly_name"].(string)
 	}
-	w._serial = message["serial"].(string)
+	serial := message["serial"].(string)
+	w._identityMutex.Lock()
+	w._hostname = hostname
+	w._name = name
+	w._serial = serial
+	w._logEntry.Store(nil)
 	if hasKey(message, "version") {
 		w._version = message["version"].(string)
 	}
@@ -195,67 +321,111 @@ func (w *Wattpilot) onEventHello(message map[string]interface{}) {
 	if hasKey(message, "secured") {
 		w._secured = message["secured"].(bool)
 	}
+	w._identityMutex.Unlock()
+
+	if err := w.verifySerial(); err != nil {
+		w._connectError = err
+		w.connected <- false
+		return
+	}
 
-	pwd_data := pbkdf2.Key([]byte(w._password), []byte(w._serial), 100000, 256, sha512.New)
-	w._hashedpassword = base64.StdEncoding.EncodeToString([]byte(pwd_data))[:32]
+	// the hash is derived off the receive loop while the charger prepares
+	// the authRequired message
+	w._authHash = w._hashes.get(w._password, serial)
 
 }
 
 func (w *Wattpilot) onEventAuthRequired(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Auhtentication required")
+	w.logEntry().Info("Auhtentication required")
+
+	if w._connectError != nil {
+		return
+	}
 
 	token1 := message["token1"].(string)
 	token2 := message["token2"].(string)
+	if err := w.checkToken2(token2); err != nil {
+		w._connectError = err
+		w.connected <- false
+		return
+	}
 
-	w._token3 = randomHexString(32)
-	hash1 := sha256sum(token1 + w._hashedpassword)
-	hash := sha256sum(w._token3 + token2 + hash1)
+	if w._authHash == nil {
+		// the serial of the hello is needed for the hash
+		w._connectError = fmt.Errorf("%w: authRequired before hello", ErrAuthFailed)
+		w.connected <- false
+		return
+	}
+	hashedPassword := w._authHash.wait()
+	token3, err := auth.Token3()
+	if err != nil {
+		w._connectError = err
+		w.connected <- false
+		return
+	}
+	w._authSecrets.Store(&authSecrets{hashedPassword: hashedPassword, token3: token3})
+	hash := auth.Response(token1, token2, token3, hashedPassword)
 	response := map[string]interface{}{
 		"type":   "auth",
-		"token3": w._token3,
+		"token3": token3,
 		"hash":   hash,
 	}
-	err := w.onSendResponse(false, response)
-	w._isInitialized = (err != nil)
+	if err := w.onSendResponse(false, response); err != nil {
+		w.logEntry().Error("Could not send the authentication: ", err)
+	}
 }
 
 func (w *Wattpilot) onSendResponse(secured bool, message map[string]interface{}) error {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Sending data to wattpilot: ", message["requestId"], " secured: ", secured)
+	w.logEntry().Trace("Sending data to wattpilot: ", message["requestId"], " secured: ", secured)
 
+	request := message
+	if w._capture != nil {
+		w._capture(false, request)
+	}
 	if secured {
 		msgId := message["requestId"].(int64)
 		payload, _ := json.Marshal(message)
 
-		mac := hmac.New(sha256.New, []byte(w._hashedpassword))
-		mac.Write(payload)
 		message = make(map[string]interface{})
 		message["type"] = "securedMsg"
 		message["data"] = string(payload)
 		message["requestId"] = fmt.Sprintf("%d", msgId) + "sm"
-		message["hmac"] = hex.EncodeToString(mac.Sum(nil))
+		message["hmac"] = auth.Sign(w.hashedPassword(), payload)
 	}
 
+	c := w._currentConnection.Load()
+	if c == nil {
+		return ErrNotConnected
+	}
 	data, _ := json.Marshal(message)
-	err := wsutil.WriteClientMessage(*w._currentConnection, ws.OpText, data)
+	if w._log.IsLevelEnabled(log.TraceLevel) {
+		w.logEntry().Trace("Sending ", string(data))
+	}
+	err := wsutil.WriteClientMessage(c.conn, ws.OpText, data)
 	if err != nil {
-		return err
+		return fmt.Errorf("sending %v: %w", message["type"], err)
 	}
+	w.trackRequest(request)
 	return nil
 }
 
 func (w *Wattpilot) onEventResponse(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Response on Event ", message["type"])
+	w.logEntry().Trace("Response on Event ", message["type"])
+
+	if info, ok := w.completeRequest(message["requestId"]); ok {
+		w.recordRoundTrip(info)
+	}
 
 	mType := message["type"].(string)
-	success, ok := message["success"]
-	if ok && success.(bool) {
+	success, ok := message["success"].(bool)
+	if ok && success {
 		return
 	}
-	if !success.(bool) {
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Error("Failure happened: ", message["message"])
+	if !success {
+		w.logEntry().Error("Failure happened: ", message["message"])
 		return
 	}
 	if mType == "response" {
@@ -266,19 +436,20 @@ func (w *Wattpilot) onEventResponse(message map[string]interface{}) {
 
 func (w *Wattpilot) onEventAuthSuccess(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Auhtentication successful")
+	w.logEntry().Info("Auhtentication successful")
 	w.connected <- true
 
 }
 
 func (w *Wattpilot) onEventAuthError(message map[string]interface{}) {
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Error("Auhtentication error", message)
+	w.logEntry().Error("Auhtentication error", message)
+	w._connectError = fmt.Errorf("%w: %v", ErrAuthFailed, message["message"])
 	w.connected <- false
 }
 
 func (w *Wattpilot) onEventFullStatus(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Full status update - is partial: ", message["partial"])
+	w.logEntry().Trace("Full status update - is partial: ", message["partial"])
 
 	isPartial := message["partial"].(bool)
 
@@ -287,18 +458,29 @@ func (w *Wattpilot) onEventFullStatus(message map[string]interface{}) {
 	if isPartial {
 		return
 	}
+	if info, ok := w.completeStatusRequest(); ok {
+		w.recordRoundTrip(info)
+	}
 	if w.IsInitialized() {
 		return
 	}
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Initialization done")
+	w.logEntry().Trace("Initialization done")
 
+	if w._password == "" && !w._isConnected.Load() {
+		// chargers without authentication send the status right after hello
+		w.connected <- true
+	}
+	w._isInitialized.Store(true)
+	w.emitEvent(EventConnected, map[string]interface{}{
+		"serial":  w.GetSerial(),
+		"version": w.firmwareVersion(),
+	})
 	w.initialized <- true
-	w._isInitialized = true
 }
 func (w *Wattpilot) onEventDeltaStatus(message map[string]interface{}) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Delta status update")
+	w.logEntry().Trace("Delta status update")
 	w.updateStatus(message)
 
 }
@@ -306,188 +488,356 @@ func (w *Wattpilot) onEventDeltaStatus(message map[string]interface{}) {
 func (w *Wattpilot) updateStatus(message map[string]interface{}) {
 
 	statusUpdates := message["status"].(map[string]interface{})
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Data-status gets updates #", len(statusUpdates))
+	w.logEntry().Trace("Data-status gets updates #", len(statusUpdates))
 
 	w._readMutex.Lock()
 	defer w._readMutex.Unlock()
 
+	w._lastStatusUpdate = w._clock.Now()
 	for k, v := range statusUpdates {
+		k = internKey(k)
+		if w._schema.policy != SchemaLenient && !w.checkSchema(k, v) {
+			continue
+		}
 		w._status[k] = v
-		go w._notifications.Publish(k, v)
+		w._notifications.Publish(k, v)
+		w.publishGroupUpdate(k, v)
+		w.forward(FleetPropertyChanged, k, v)
 	}
+	w.publishVirtuals(statusUpdates)
+	w.trackCompletion(statusUpdates)
+	w.trackSignal(statusUpdates)
+	w.trackUnknown(statusUpdates)
+	w.trackAnomalies(statusUpdates)
+	w.trackFaults(statusUpdates)
+	w.trackTemperature(statusUpdates)
+	w.trackWear(statusUpdates)
 }
 
 func (w *Wattpilot) GetNotifications(prop string) <-chan interface{} {
 	return w._notifications.Subscribe(prop)
 }
 
-func (w *Wattpilot) onEventClearInverters(message map[string]interface{}) {
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("clear inverters")
+func (w *Wattpilot) SubscriberStats() []SubscriberStats {
+	return w._notifications.SubscriberStats()
 }
-func (w *Wattpilot) onEventUpdateInverter(message map[string]interface{}) {
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("update inverters")
+
+func (w *Wattpilot) DispatchStats() DispatchStats {
+	return w._notifications.DispatchStats()
 }
+
 func (w *Wattpilot) Disconnect() {
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Going to disconnect...")
-	w._isConnected = false
+	w.logEntry().Info("Going to disconnect...")
+	w._isConnected.Store(false)
 	w.disconnectImpl()
 	<-w._interrupt
 }
 
+// connection is a websocket connection to the charger, receiveDone is
+// closed when its receive handler ended. The messages are read into the
+// buffer of the connection, so the handler of a replaced connection never
+// shares it with the handler of the next one.
+type connection struct {
+	conn        net.Conn
+	receiveDone chan struct{}
+	readBuffer  bytes.Buffer
+}
+
+// disconnectImpl closes the current connection
 func (w *Wattpilot) disconnectImpl() {
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Disconnecting...")
+	w.disconnect(nil)
+}
+
+// disconnect closes the connection c if it is still the current one, nil
+// closes any current connection. It is serialized with connecting and
+// returns after the receive handler of the connection ended.
+func (w *Wattpilot) disconnect(c *connection) {
+	w._connMutex.Lock()
+	defer w._connMutex.Unlock()
 
-	if !w._isInitialized {
+	current := w._currentConnection.Load()
+	if current == nil || (c != nil && c != current) {
 		return
 	}
+	w.logEntry().Info("Disconnecting...")
+	w._currentConnection.Store(nil)
+	w.closeConnection(current)
 
-	if err := (*w._currentConnection).Close(); err != nil {
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Error on closing connection: ", err)
-	}
+	w.logEntry().Trace("closed connection")
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("closed connection")
+	w._isInitialized.Store(false)
+	w._isConnected.Store(false)
+	// clearing keeps the allocated map for the next connection
+	w._readMutex.Lock()
+	clear(w._status)
+	w._readMutex.Unlock()
+
+}
 
-	w._isInitialized = false
-	w._isConnected = false
-	w._currentConnection = nil
-	w._status = make(map[string]interface{})
+// bufferedConn reads the data buffered during the handshake before the
+// data of the connection
+type bufferedConn struct {
+	net.Conn
+	reader io.Reader
+}
 
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
 }
 
 func (w *Wattpilot) Connect() error {
+	w._connMutex.Lock()
+	defer w._connMutex.Unlock()
 
-	if w._isConnected || w._isInitialized {
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Debug("Already Connected")
+	if w._isConnected.Load() || w._isInitialized.Load() {
+		w.logEntry().Debug("Already Connected")
 		return nil
 	}
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Connecting")
+	err := w.connectImpl()
+	if err != nil && w.onConnectFailure() {
+		err = w.connectImpl()
+	}
+	return err
+}
+
+// connectImpl opens a connection and waits for its initialization, it is
+// called with the connection mutex held
+func (w *Wattpilot) connectImpl() error {
+
+	w.logEntry().Info("Connecting via ", w.GetConnectionPath())
+	w._connectError = nil
+	w._authHash = nil
 
 	var err error
 	dialContext, cancel := context.WithTimeout(w._readContext, time.Second*CONTEXT_TIMEOUT)
 	defer cancel()
-	conn, reader, _, err := ws.DefaultDialer.Dial(dialContext, fmt.Sprintf("ws://%s/ws", w._host))
+	dialer := ws.Dialer{
+		Header: ws.HandshakeHeaderHTTP(http.Header{
+			"User-Agent": []string{w._userAgent},
+		}),
+		Extensions: w.dialExtensions(),
+	}
+	conn, reader, hs, err := dialer.Dial(dialContext, w.connectionURL())
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %w", ErrConnectFailed, err)
 	}
-	w._currentConnection = &conn
+	w._deflate = isDeflateNegotiated(hs)
+	w.onConnectSuccess()
 	if reader != nil {
-		ws.PutReader(reader)
+		// the reader holds frames sent right after the handshake, e.g. hello
+		conn = &bufferedConn{Conn: conn, reader: reader}
 	}
-	go w.receiveHandler(w._readContext)
-
-	w._isConnected = <-w.connected
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Connection is ", w._isConnected)
-	if !w._isConnected {
-		return errors.New("could not connect")
+	c := &connection{conn: conn, receiveDone: make(chan struct{})}
+	w._currentConnection.Store(c)
+	go w.receiveHandler(w._readContext, c)
+
+	// the receive handler ending or the instance being closed abort the
+	// handshake, the events of the handshake are only sent by a running
+	// receive handler
+	fail := func(err error) error {
+		w._currentConnection.Store(nil)
+		w.closeConnection(c)
+		return err
+	}
+	select {
+	case connected := <-w.connected:
+		w._isConnected.Store(connected)
+	case <-c.receiveDone:
+		return fail(ErrConnectFailed)
+	case <-w._context.Done():
+		return fail(ErrConnectFailed)
+	}
+	w.logEntry().Trace("Connection is ", w._isConnected.Load())
+	if !w._isConnected.Load() {
+		if err := w._connectError; err != nil {
+			return fail(err)
+		}
+		return fail(ErrConnectFailed)
 	}
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Connected - waiting for initializiation...")
+	w.logEntry().Trace("Connected - waiting for initializiation...")
 
-	<-w.initialized
+	select {
+	case <-w.initialized:
+	case <-c.receiveDone:
+		w._isConnected.Store(false)
+		return fail(ErrConnectFailed)
+	case <-w._context.Done():
+		w._isConnected.Store(false)
+		return fail(ErrConnectFailed)
+	}
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Connected - and initializiated")
+	w.logEntry().Trace("Connected - and initializiated")
 
 	return nil
 }
 
-func (w *Wattpilot) reconnect() {
-
-	if w._isConnected && !w._isInitialized {
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Reconnect - Is still connected")
-		return
-	}
-
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Debug("Reconnecting..")
-	time.Sleep(time.Second * time.Duration(RECONNECT_TIMEOUT))
-	if err := w.Connect(); err != nil {
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Debug("Reconnect failure: ", err)
-		return
+// reconnect replaces the connection c, or the current one for nil, in the
+// background. A single reconnect runs at a time and owns closing and
+// reopening the connection, requests while it runs join it. It waits for
+// delay after closing and retries to connect until it succeeds or the
+// instance is closed. The returned channel is closed when it ended.
+func (w *Wattpilot) reconnect(c *connection, delay time.Duration) <-chan struct{} {
+	w._reconnectMutex.Lock()
+	defer w._reconnectMutex.Unlock()
+	if w._reconnectDone != nil {
+		w.logEntry().Debug("Reconnect - already in progress")
+		return w._reconnectDone
 	}
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Successfully reconnected")
+	done := make(chan struct{})
+	w._reconnectDone = done
+
+	go func() {
+		defer func() {
+			w._reconnectMutex.Lock()
+			w._reconnectDone = nil
+			w._reconnectMutex.Unlock()
+			close(done)
+		}()
+
+		w.logEntry().Debug("Reconnecting..")
+		w.disconnect(c)
+		for attempt := 1; ; attempt++ {
+			if !sleepContext(w._context, w._clock, delay) {
+				return
+			}
+			err := w.Connect()
+			if err == nil {
+				break
+			}
+			w.logEntry().Debug("Reconnect attempt ", attempt, " failed: ", err)
+			delay = time.Second * RECONNECT_TIMEOUT
+		}
+		w.logEntry().Info("Successfully reconnected")
+	}()
+	return done
+}
 
+// goBackground runs f with the context of the client, Close waits for it
+// to return
+func (w *Wattpilot) goBackground(f func(ctx context.Context)) {
+	w._background.Add(1)
+	go func() {
+		defer w._background.Done()
+		f(w._context)
+	}()
 }
 
-func (w *Wattpilot) processLoop(ctx context.Context) {
+func (w *Wattpilot) processLoop(ctx context.Context, generation uint64) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Starting processing loop...")
+	w.logEntry().Info("Starting processing loop...")
+	defer func() {
+		// a replaced loop leaves the shutdown to its successor
+		if w._watchdog.generation.Load() == generation {
+			close(w._loopDone)
+		}
+	}()
 	delayDuration := time.Duration(time.Second * CONTEXT_TIMEOUT)
-	delay := time.NewTimer(delayDuration)
+	delay := w._clock.NewTimer(delayDuration)
+	stop := func() {
+		w.logEntry().Trace("Stopping process loop...")
+		w.disconnectImpl()
+		if !delay.Stop() {
+			<-delay.C()
+		}
+	}
 
 	for {
+		if w._watchdog.generation.Load() != generation {
+			delay.Stop()
+			return
+		}
+		w._watchdog.lastLoop.Store(w._clock.Now().UnixNano())
 		select {
-		case <-delay.C:
+		case <-delay.C():
 			delay.Reset(delayDuration)
-			if !w._isInitialized {
-				w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("No Hello there")
+			if !w._isInitialized.Load() {
+				w.logEntry().Trace("No Hello there")
 				continue
 			}
-			w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Hello there")
+			w.logEntry().Trace("Hello there")
 			go func() {
-				time.Sleep(time.Millisecond * 100)
+				w._clock.Sleep(time.Millisecond * 100)
 				if err := w.RequestStatusUpdate(); err != nil {
-					w._log.WithFields(log.Fields{"wattpilot": w._host}).Error("Full Status Update failed: ", err)
-					w.disconnectImpl()
-					w.reconnect()
+					w.logEntry().Error("Full Status Update failed: ", err)
+					w.reconnect(nil, time.Second*RECONNECT_TIMEOUT)
 				}
 			}()
 			break
 		case <-w._readContext.Done():
-			w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Read context is done")
-			w.disconnectImpl()
-			w.reconnect()
+			w.logEntry().Trace("Read context is done")
+			w.reconnect(nil, time.Second*RECONNECT_TIMEOUT)
 			break
 
 		case <-ctx.Done():
+			stop()
+			return
 		case <-w._interrupt:
-			w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("Stopping process loop...")
-			w.disconnectImpl()
-			if !delay.Stop() {
-				<-delay.C
-			}
+			stop()
 			return
 		}
 	}
 }
 
-func (w *Wattpilot) receiveHandler(ctx context.Context) {
+func (w *Wattpilot) receiveHandler(ctx context.Context, c *connection) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Starting receive handler...")
+	w.logEntry().Info("Starting receive handler...")
 
+	conn := c.conn
+	defer close(c.receiveDone)
+	w.receiveStarted()
+	defer w.receiveStopped()
+	w.resetMessageGap()
 	for {
-		msg, err := wsutil.ReadServerText(*w._currentConnection)
+		if w._readTimeout > 0 {
+			// the charger pushes delta updates continuously and answers the
+			// periodic status requests, silence means the connection is dead
+			if err := conn.SetReadDeadline(time.Now().Add(w._readTimeout)); err != nil {
+				w.logEntry().Debug("Could not set read deadline: ", err)
+			}
+		}
+		msg, err := w.readMessage(c)
 		if err != nil {
 			// w._readCancel()
-			w._log.WithFields(log.Fields{"wattpilot": w._host}).Info("Stopping receive handler...")
+			w.logEntry().Info("Stopping receive handler...")
+			w.onReceiveError(c, err)
 			return
 		}
-		data := make(map[string]interface{})
-		err = json.Unmarshal(msg, &data)
+		w.recordMessage()
+		if w._log.IsLevelEnabled(log.TraceLevel) {
+			w.logEntry().Trace("Received ", string(msg))
+		}
+		data, err := w.decodeMessage(msg)
 		if err != nil {
 			continue
 		}
+		if w._capture != nil {
+			w._capture(true, data)
+		}
 		msgType, isTypeAvailable := data["type"]
 		if !isTypeAvailable {
 			continue
 		}
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("receiving ", msgType)
+		w.logEntry().Trace("receiving ", msgType)
 
-		funcCall, isKnown := w._eventHandler[msgType.(string)]
+		name, _ := msgType.(string)
+		funcCall, isKnown := w._eventHandler[name]
 		if !isKnown {
 			continue
 		}
-		funcCall(data)
-		w._log.WithFields(log.Fields{"wattpilot": w._host}).Trace("done ", msgType)
+		w.dispatch(name, funcCall, data)
+		w.logEntry().Trace("done ", msgType)
 	}
 
 }
 
 func (w *Wattpilot) GetProperty(name string) (interface{}, error) {
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Debug("Get Property ", name)
+	w.logEntry().Debug("Get Property ", name)
 
-	if !w._isInitialized {
-		return nil, errors.New("connection is not valid")
+	if !w._isInitialized.Load() {
+		return nil, ErrNotConnected
 	}
 
 	origName := name
@@ -502,8 +852,11 @@ func (w *Wattpilot) GetProperty(name string) (interface{}, error) {
 	w._readMutex.Lock()
 	defer w._readMutex.Unlock()
 
+	if v, isVirtual := w._virtuals[origName]; isVirtual {
+		return w.computeVirtual(v)
+	}
 	if !hasKey(w._status, name) {
-		return nil, errors.New("could not find value of " + name)
+		return nil, fmt.Errorf("could not find value of %s: %w", name, ErrPropertyNotFound)
 	}
 	value := w._status[name]
 	if post {
@@ -513,21 +866,37 @@ func (w *Wattpilot) GetProperty(name string) (interface{}, error) {
 }
 
 func (w *Wattpilot) SetProperty(name string, value interface{}) error {
+	return w.setProperty(SOURCE_API, name, value)
+}
+
+func (w *Wattpilot) setProperty(source string, name string, value interface{}) error {
+
+	w.logEntry().WithField("source", source).Debug("setting property ", name, " to ", value)
 
-	w._log.WithFields(log.Fields{"wattpilot": w._host}).Debug("setting property ", name, " to ", value)
+	if !w._isInitialized.Load() {
+		return ErrNotConnected
+	}
 
-	if !w._isInitialized {
-		return errors.New("Connection is not valid")
+	if w.isInstallationLocked(name) {
+		return ErrInstallationSettingsLocked
 	}
 
 	w._readMutex.Lock()
-	defer w._readMutex.Unlock()
+	oldValue, isKnown := w._status[name]
+	w._readMutex.Unlock()
 
-	if !hasKey(w._status, name) {
-		return errors.New("Could not find reference for update on " + name)
+	if !isKnown {
+		return fmt.Errorf("could not find reference for update on %s: %w", name, ErrPropertyNotFound)
+	}
+
+	if owner, err := w.arbitrate(source, name); err != nil {
+		w.logEntry().WithField("source", source).Info("Rejected write of ", name, ", controlled by ", owner)
+		return err
 	}
 
-	return w.sendUpdate(name, value)
+	err := w.sendUpdate(name, value)
+	w.audit(source, name, oldValue, w.transformValue(value), err)
+	return err
 
 }
 
@@ -555,14 +924,37 @@ func (w *Wattpilot) transformValue(value interface{}) interface{} {
 	return in_value
 }
 
+// SetDryRun enables the simulation mode in which writes are computed and
+// logged but never sent to the charger
+func (w *Wattpilot) SetDryRun(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&w._dryRun, v)
+}
+
+func (w *Wattpilot) IsDryRun() bool {
+	return atomic.LoadInt32(&w._dryRun) == 1
+}
+
 func (w *Wattpilot) sendUpdate(name string, value interface{}) error {
 
+	if w._observer {
+		return ErrObserverMode
+	}
+
+	if w.IsDryRun() {
+		w.logEntry().WithField("dryrun", true).Info("would set ", name, " to ", w.transformValue(value))
+		return nil
+	}
+
 	message := make(map[string]interface{})
 	message["type"] = "setValue"
 	message["requestId"] = w.getRequestId()
 	message["key"] = name
 	message["value"] = w.transformValue(value)
-	return w.onSendResponse(w._secured, message)
+	return w.sendWithRetry(w.isSecured(), message)
 
 }
 
@@ -572,8 +964,8 @@ func (w *Wattpilot) sendUpdate(name string, value interface{}) error {
 
 func (w *Wattpilot) StatusInfo() {
 
-	fmt.Println("Wattpilot: " + w._name)
-	fmt.Println("Serial: ", w._serial)
+	fmt.Println("Wattpilot: " + w.GetName())
+	fmt.Println("Serial: ", w.GetSerial())
 
 	v, _ := w.GetProperty("car")
 	fmt.Printf("Car Connected: %v\n", v)
@@ -623,13 +1015,17 @@ func (w *Wattpilot) GetCurrents() (float64, float64, float64, error) {
 
 		currents = append(currents, fi)
 	}
+	cal := w.GetCalibration()
+	for i := range currents {
+		currents[i] = cal.Current[i].apply(currents[i])
+	}
 	return currents[0], currents[1], currents[2], nil
 }
 
 func (w *Wattpilot) GetVoltages() (float64, float64, float64, error) {
 
 	var voltages []float64
-	for _, i := range []string{"voltage1", "voltage2", "voltage2"} {
+	for _, i := range []string{"voltage1", "voltage2", "voltage3"} {
 		v, err := w.GetProperty(i)
 		if err != nil {
 			return -1, -1, -1, err
@@ -641,6 +1037,10 @@ func (w *Wattpilot) GetVoltages() (float64, float64, float64, error) {
 
 		voltages = append(voltages, fi)
 	}
+	cal := w.GetCalibration()
+	for i := range voltages {
+		voltages[i] = cal.Voltage[i].apply(voltages[i])
+	}
 	return voltages[0], voltages[1], voltages[2], nil
 }
 
@@ -680,5 +1080,5 @@ func (w *Wattpilot) RequestStatusUpdate() error {
 	message := make(map[string]interface{})
 	message["type"] = "requestFullStatus"
 	message["requestId"] = w.getRequestId()
-	return w.onSendResponse(w._secured, message)
+	return w.sendWithRetry(w.isSecured(), message)
 }
@@ -22,6 +22,11 @@ var PostProcess = map[string]struct {
 	"power3":   {"nrg", power3Process},
 	"powerM":   {"nrg", powerNProcess},
 	"power":    {"nrg", powerProcess},
+
+	"powerFactor1": {"nrg", powerFactor1Process},
+	"powerFactor2": {"nrg", powerFactor2Process},
+	"powerFactor3": {"nrg", powerFactor3Process},
+	"powerFactorN": {"nrg", powerFactorNProcess},
 }
 
 func voltage1Process(data interface{}) (string, error) {
@@ -71,6 +76,22 @@ func powerProcess(data interface{}) (string, error) {
 	return float2String(voltageData(data, 11)), nil
 }
 
+func powerFactor1Process(data interface{}) (string, error) {
+	return float2String(voltageData(data, 12)), nil
+}
+
+func powerFactor2Process(data interface{}) (string, error) {
+	return float2String(voltageData(data, 13)), nil
+}
+
+func powerFactor3Process(data interface{}) (string, error) {
+	return float2String(voltageData(data, 14)), nil
+}
+
+func powerFactorNProcess(data interface{}) (string, error) {
+	return float2String(voltageData(data, 15)), nil
+}
+
 func voltageData(data interface{}, idx int) float64 {
 	vars := data.([]interface{})
 	v := vars[idx].(float64)
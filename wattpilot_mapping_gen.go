@@ -215,3 +215,247 @@ var propertyMap = map[string]string{
 	"zeroFeedin":                         "fzf",
 	"zeroFeedinOffset":                   "zfo",
 }
+
+var propertyGroups = map[string]string{
+	"accessState":                        "charging",
+	"adapterLimit":                       "charging",
+	"adapterLimit1":                      "charging",
+	"adapterLimit2":                      "charging",
+	"adapterLimit3":                      "charging",
+	"adapterLimit4":                      "charging",
+	"adapterLimit5":                      "charging",
+	"akkuMode":                           "energy",
+	"akkuSoc":                            "energy",
+	"allowCharging":                      "charging",
+	"allowedCurrent":                     "charging",
+	"appRecommendedVersion":              "system",
+	"averagePAkku":                       "energy",
+	"averagePGrid":                       "energy",
+	"averagePPv":                         "energy",
+	"avgPowerOhmpilot":                   "energy",
+	"awattarCountry":                     "energy",
+	"awattarCurrentPrice":                "energy",
+	"awattarMaxPrice":                    "energy",
+	"awattarPriceList":                   "energy",
+	"buttonAllowCurrentChange":           "charging",
+	"cableCurrentLimit":                  "charging",
+	"cableLock":                          "charging",
+	"cableUnlockStatus":                  "charging",
+	"carConsumption":                     "energy",
+	"carState":                           "charging",
+	"carType":                            "charging",
+	"chargeControllerRecommendedVersion": "system",
+	"chargeControllerUpdateProgress":     "system",
+	"chargingCurrent":                    "charging",
+	"chargingDurationInfo":               "charging",
+	"chargingEnergyLimit":                "charging",
+	"cloudClientAuth":                    "network",
+	"cloudWsConnected":                   "network",
+	"cloudWsConnectedAge":                "network",
+	"cloudWsEnabled":                     "network",
+	"cloudWsStarted":                     "network",
+	"colorCharging":                      "lumina",
+	"colorFinished":                      "lumina",
+	"colorIdle":                          "lumina",
+	"colorWaitCar":                       "lumina",
+	"cpEnable":                           "charging",
+	"cpEnableRequest":                    "charging",
+	"currentLimitPresets":                "charging",
+	"currentlyConnectedWifi":             "network",
+	"defaultRoute":                       "network",
+	"deltaCurrent":                       "charging",
+	"deltaPower":                         "charging",
+	"deviceType":                         "system",
+	"dnsServer":                          "network",
+	"effectiveLockSetting":               "charging",
+	"effectiveRoundingMode":              "charging",
+	"energy":                             "energy",
+	"energyCounterSinceStart":            "energy",
+	"energyCounterTotal":                 "energy",
+	"energySetKwh":                       "energy",
+	"energyTotalPersisted":               "energy",
+	"errorState":                         "system",
+	"espChipInfo":                        "system",
+	"espCpuFreq":                         "system",
+	"espFlashInfo":                       "system",
+	"espFreeHeap":                        "system",
+	"espFreeHeap32":                      "system",
+	"espFreeHeap8":                       "system",
+	"espHeapSize":                        "system",
+	"espMaxHeap":                         "system",
+	"espMinFreeHeap":                     "system",
+	"espResetReason":                     "system",
+	"factoryFriendlyName":                "system",
+	"factoryWifiApKey":                   "system",
+	"factoryWifiApName":                  "system",
+	"fbufAge":                            "energy",
+	"firmwareCarControl":                 "system",
+	"firmwareDescription":                "system",
+	"firmwareVersion":                    "system",
+	"flashEncryptionMode":                "system",
+	"forceSinglePhase":                   "charging",
+	"forceSinglePhaseDuration":           "charging",
+	"forceSinglePhaseToggleWishedSince":  "charging",
+	"forceState":                         "charging",
+	"frequency":                          "energy",
+	"friendlyName":                       "system",
+	"hostname":                           "network",
+	"httpConnectedClients":               "network",
+	"httpStaAuthentication":              "network",
+	"httpStaReachable":                   "network",
+	"inverterDataAge":                    "energy",
+	"inverterDataOverride":               "energy",
+	"lastButtonPress":                    "system",
+	"lastCarStateChangedFromCharging":    "charging",
+	"lastCarStateChangedFromIdle":        "charging",
+	"lastCarStateChangedToCharging":      "charging",
+	"lastForceSinglePhaseToggle":         "charging",
+	"lastModelStatusChange":              "system",
+	"lastPvSurplusCalculation":           "system",
+	"lastStaSwitchedFromConnected":       "network",
+	"lastStaSwitchedToConnected":         "network",
+	"ledBrightness":                      "lumina",
+	"ledInfo":                            "lumina",
+	"ledSaveEnergy":                      "lumina",
+	"loadBalancingAmpere":                "charging",
+	"loadBalancingEnabled":               "charging",
+	"loadBalancingMembers":               "charging",
+	"loadBalancingStatus":                "charging",
+	"loadBalancingTotalAmpere":           "charging",
+	"loadBalancingType":                  "charging",
+	"loadFallback":                       "charging",
+	"loadGroupId":                        "charging",
+	"loadMapping":                        "charging",
+	"loadPriority":                       "charging",
+	"localTime":                          "system",
+	"lockFeedback":                       "charging",
+	"lockFeedbackAge":                    "charging",
+	"logicMode":                          "charging",
+	"maxCurrentLimit":                    "charging",
+	"minChargePauseDuration":             "charging",
+	"minChargePauseEndsAt":               "charging",
+	"minChargeTime":                      "charging",
+	"minChargingCurrent":                 "charging",
+	"minPhaseToggleWaitTime":             "charging",
+	"minPhaseWishSwitchTime":             "charging",
+	"minimumChargingInterval":            "charging",
+	"modelStatus":                        "charging",
+	"modelStatusInternal":                "charging",
+	"moduleHwPcbVersion":                 "system",
+	"norwayMode":                         "charging",
+	"numberOfPhases":                     "charging",
+	"oemManufacturer":                    "system",
+	"ohmpilotState":                      "energy",
+	"ohmpilotTemperature":                "energy",
+	"ohmpilotTemperatureLimit":           "energy",
+	"otaCloudApp":                        "system",
+	"otaCloudBranches":                   "system",
+	"otaCloudLength":                     "system",
+	"otaCloudMessage":                    "system",
+	"otaCloudProgress":                   "system",
+	"otaCloudStatus":                     "system",
+	"otaCloudUseClientAuth":              "system",
+	"otaNewestVersion":                   "system",
+	"otaPartition":                       "system",
+	"pAkku":                              "energy",
+	"pGrid":                              "energy",
+	"pPv":                                "energy",
+	"partitionTable":                     "system",
+	"partitionTableOffset":               "system",
+	"phaseSwitchHysteresis":              "charging",
+	"phaseSwitchMode":                    "charging",
+	"phaseWishMode":                      "charging",
+	"phases":                             "charging",
+	"powerAcTotal":                       "energy",
+	"powerAkku":                          "energy",
+	"powerGrid":                          "energy",
+	"powerPv":                            "energy",
+	"prioOffset":                         "charging",
+	"pvBatteryLimit":                     "charging",
+	"pvOptSpecialCase":                   "charging",
+	"queueSizeCloud":                     "network",
+	"queueSizeWs":                        "network",
+	"rebootCharger":                      "system",
+	"rebootCounter":                      "system",
+	"registeredCards":                    "rfid",
+	"relayFeedback":                      "charging",
+	"residualCurrentDetection":           "charging",
+	"roundingMode":                       "charging",
+	"rtcResetReasons":                    "system",
+	"schedulerSaturday":                  "charging",
+	"schedulerSunday":                    "charging",
+	"schedulerWeekday":                   "charging",
+	"secureBootEnabled":                  "system",
+	"serialNumber":                       "system",
+	"simulateUnplugging":                 "charging",
+	"simulateUnpluggingAlways":           "charging",
+	"simulateUnpluggingDuration":         "charging",
+	"startingPower":                      "charging",
+	"stopHysteresis":                     "charging",
+	"temperatureCurrentLimit":            "charging",
+	"temperatureSensors":                 "system",
+	"threePhaseSwitchLevel":              "charging",
+	"timeServer":                         "network",
+	"timeServerEnabled":                  "network",
+	"timeServerOperatingMode":            "network",
+	"timeServerSyncInterval":             "network",
+	"timeServerSyncMode":                 "network",
+	"timeServerSyncStatus":               "network",
+	"timeSinceBoot":                      "system",
+	"timezoneDaylightSavingMode":         "system",
+	"timezoneOffset":                     "system",
+	"totalPowerAverage":                  "energy",
+	"transaction":                        "rfid",
+	"unlockPowerOutage":                  "charging",
+	"useDynamicPricing":                  "charging",
+	"usePvSurplus":                       "charging",
+	"utcTime":                            "system",
+	"variant":                            "system",
+	"wifiApKey":                          "network",
+	"wifiApName":                         "network",
+	"wifiConfigs":                        "network",
+	"wifiCurrentMac":                     "network",
+	"wifiEnabled":                        "network",
+	"wifiFailedMac":                      "network",
+	"wifiPlannedMac":                     "network",
+	"wifiRssi":                           "network",
+	"wifiScanAge":                        "network",
+	"wifiScanResult":                     "network",
+	"wifiScanStatus":                     "network",
+	"wifiSsid":                           "network",
+	"wifiStaErrorCount":                  "network",
+	"wifiStaErrorMessage":                "network",
+	"wifiStaStatus":                      "network",
+	"wifiStateMachineState":              "network",
+	"wsConnectedClients":                 "network",
+	"zeroFeedin":                         "energy",
+	"zeroFeedinOffset":                   "energy",
+}
+
+var propertyDescriptions = map[string]labels{
+	"acs": {"de": "Zugangskontrolle, ob das Laden eine Authentifizierung erfordert", "en": "Access control, whether charging needs an authentication"},
+	"adi": {"de": "Ob der 16-A-Adapter den Strom begrenzt", "en": "Whether the 16 A adapter limits the current"},
+	"alw": {"de": "Ob das Fahrzeug jetzt laden darf", "en": "Whether the car is allowed to charge now"},
+	"ama": {"de": "Maximaler Strom der Installation in Ampere", "en": "Maximum current limit of the installation in ampere"},
+	"amp": {"de": "Angeforderter Ladestrom in Ampere", "en": "Requested charging current in ampere"},
+	"car": {"de": "Zustand der Fahrzeugverbindung", "en": "State of the car connection"},
+	"cbl": {"de": "Strombegrenzung des Kabels in Ampere", "en": "Current limit of the cable in ampere"},
+	"cus": {"de": "Zustand der Kabelentriegelung", "en": "State of the cable unlock"},
+	"dwo": {"de": "Energielimit des Ladevorgangs in Wh, null ohne Limit", "en": "Energy limit of the session in Wh, null without limit"},
+	"err": {"de": "Fehlerzustand der Wallbox", "en": "Error state of the charger"},
+	"eto": {"de": "Insgesamt geladene Energie in Wh", "en": "Total charged energy in Wh"},
+	"fhz": {"de": "Netzfrequenz in Hz", "en": "Grid frequency in Hz"},
+	"fna": {"de": "Vom Benutzer vergebener Name der Wallbox", "en": "Name of the charger given by the user"},
+	"frc": {"de": "Erzwungener Zustand, neutral folgt dem Lademodus", "en": "Forced state, neutral follows the logic mode"},
+	"fwv": {"de": "Firmware-Version", "en": "Firmware version"},
+	"lmo": {"de": "Lademodus", "en": "Logic mode of the charging"},
+	"nrg": {"de": "Spannungen, Ströme, Leistungen und Leistungsfaktoren der Phasen", "en": "Voltages, currents, powers and power factors of the phases"},
+	"psm": {"de": "Phasenumschaltung", "en": "Phase switch mode"},
+	"rbc": {"de": "Anzahl der Neustarts", "en": "Number of reboots"},
+	"rbt": {"de": "Zeit seit dem Start in Millisekunden", "en": "Time since the boot in milliseconds"},
+	"tma": {"de": "Temperaturen der Sensoren in °C", "en": "Temperatures of the sensors in °C"},
+	"trx": {"de": "Transaktion, der Index der Karte, die den Ladevorgang gestartet hat", "en": "Transaction, the index of the card which started the session"},
+	"ust": {"de": "Kabelverriegelung", "en": "Cable lock mode"},
+	"wh":  {"de": "Seit dem Anstecken des Fahrzeugs geladene Energie in Wh", "en": "Energy charged since the car was connected in Wh"},
+	"wst": {"de": "Zustand der WLAN-Verbindung", "en": "State of the WiFi station"},
+}
@@ -0,0 +1,142 @@
+package wattpilot
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deltaStatus is a delta update like the charger pushes it while charging
+func deltaStatus() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "deltaStatus",
+		"status": map[string]interface{}{
+			"car": 2.0,
+			"amp": 16.0,
+			"eto": 123456.0,
+			"wh":  4200.0,
+			"nrg": []interface{}{230.0, 231.0, 229.0, 0.0, 16.0, 16.0, 16.0, 3680.0, 3696.0, 3664.0, 0.0, 11040.0, 99.0, 99.0, 99.0, 0.0},
+		},
+	}
+}
+
+func BenchmarkUpdateStatus(b *testing.B) {
+	w := New("localhost", "")
+	defer w.Close()
+	_ = w.GetNotifications("amp")
+	message := deltaStatus()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.updateStatus(message)
+	}
+}
+
+func BenchmarkPublish(b *testing.B) {
+	ps := NewPubsub()
+	defer ps.Close()
+	ch := ps.Subscribe("amp")
+	go func() {
+		for range ch {
+		}
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ps.Publish("amp", 16.0)
+	}
+}
+
+func TestLogEntryFollowsHello(t *testing.T) {
+	w := New("localhost", "")
+	defer w.Close()
+	if _, isSet := w.logEntry().Data["serial"]; isSet {
+		t.Fatal("serial logged before hello")
+	}
+
+	w.onEventHello(map[string]interface{}{
+		"type": "hello", "serial": "12345678", "hostname": "Wattpilot_12345678", "friendly_name": "Garage",
+		"manufacturer": "fronius", "devicetype": "wattpilot", "protocol": 2.0,
+	})
+	entry := w.logEntry()
+	if entry.Data["serial"] != "12345678" || entry.Data["name"] != "Garage" {
+		t.Fatal("log entry not updated by hello: ", entry.Data)
+	}
+}
+
+// syncBuffer collects the log output of the test and of the background
+// goroutines of the instances
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func TestSharedLoggerRedaction(t *testing.T) {
+	var out syncBuffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetLevel(log.InfoLevel)
+
+	a := New("charger-a", "password-a", WithLogger(logger), WithFullDump())
+	b := New("charger-b", "password-b", WithLogger(logger))
+	defer b.Close()
+
+	a.logEntry().Info("sent password-a")
+	b.logEntry().Info("sent password-b and password-a")
+	if !strings.Contains(out.String(), "sent password-a") {
+		t.Error("full dump of a redacted: ", out.String())
+	}
+	if strings.Contains(out.String(), "password-b") || strings.Contains(out.String(), "and password-a") {
+		t.Error("secrets logged by b: ", out.String())
+	}
+	if hooks := len(logger.Hooks[log.InfoLevel]); hooks != 1 {
+		t.Error("expected a single hook on the shared logger, got ", hooks)
+	}
+
+	a.Close()
+	out.Reset()
+	logger.Info("password-b")
+	if strings.Contains(out.String(), "password-b") {
+		t.Error("hook removed while b uses the logger")
+	}
+}
+
+func TestSensitiveFieldsRedacted(t *testing.T) {
+	var out syncBuffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetLevel(log.InfoLevel)
+
+	w := New("localhost", "secret", WithLogger(logger))
+	defer w.Close()
+	w.logEntry().WithField("token1", "0d9c5be0a8f14a6c").WithField("Token2", "7e41c2b9d03f5a86").Info("auth required")
+	if strings.Contains(out.String(), "0d9c5be0a8f14a6c") || strings.Contains(out.String(), "7e41c2b9d03f5a86") {
+		t.Error("tokens of the charger logged: ", out.String())
+	}
+	if !strings.Contains(out.String(), `token1="`+REDACTED+`"`) {
+		t.Error("token1 not marked redacted: ", out.String())
+	}
+}
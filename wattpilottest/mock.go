@@ -0,0 +1,170 @@
+package wattpilottest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// MockCharger plays a charger for examples and smoke tests. It accepts any
+// number of clients, sends them its status and applies their setValue
+// requests, so a client can be used without hardware.
+type MockCharger struct {
+	server   *Server
+	password string
+	serial   string
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	status  map[string]interface{}
+	clients map[*Conn]bool
+}
+
+// NewMockCharger starts a charger with the serial and the initial status
+func NewMockCharger(serial string, password string, status map[string]interface{}) (*MockCharger, error) {
+	server, err := NewServer()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &MockCharger{
+		server:   server,
+		password: password,
+		serial:   serial,
+		cancel:   cancel,
+		status:   make(map[string]interface{}),
+		clients:  make(map[*Conn]bool),
+	}
+	for k, v := range status {
+		m.status[k] = v
+	}
+	go m.accept(ctx)
+	return m, nil
+}
+
+// Addr is the host to pass to wattpilot.New
+func (m *MockCharger) Addr() string {
+	return m.server.Addr()
+}
+
+// Status returns a copy of the current status
+func (m *MockCharger) Status() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := make(map[string]interface{}, len(m.status))
+	for k, v := range m.status {
+		status[k] = v
+	}
+	return status
+}
+
+// Update changes the status and sends it as deltaStatus to all clients
+func (m *MockCharger) Update(status map[string]interface{}) {
+	m.mu.Lock()
+	for k, v := range status {
+		m.status[k] = v
+	}
+	clients := make([]*Conn, 0, len(m.clients))
+	for c := range m.clients {
+		clients = append(clients, c)
+	}
+	m.mu.Unlock()
+
+	for _, c := range clients {
+		_ = c.Send(map[string]interface{}{"type": "deltaStatus", "status": status})
+	}
+}
+
+func (m *MockCharger) Close() {
+	m.cancel()
+	m.server.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for c := range m.clients {
+		c.Close()
+	}
+}
+
+func (m *MockCharger) accept(ctx context.Context) {
+	for {
+		conn, err := m.server.Accept(ctx, m.password)
+		if err != nil {
+			return
+		}
+		go m.serve(conn)
+	}
+}
+
+func (m *MockCharger) serve(conn *Conn) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.clients, conn)
+		m.mu.Unlock()
+		conn.Close()
+	}()
+
+	if err := m.handshake(conn); err != nil {
+		return
+	}
+	m.mu.Lock()
+	m.clients[conn] = true
+	m.mu.Unlock()
+	if err := conn.Send(map[string]interface{}{"type": "fullStatus", "partial": false, "status": m.Status()}); err != nil {
+		return
+	}
+
+	for {
+		message, err := conn.Receive()
+		if err != nil {
+			return
+		}
+		if message["type"] != "setValue" {
+			continue
+		}
+		key, _ := message["key"].(string)
+		update := map[string]interface{}{key: message["value"]}
+		if err := conn.Send(map[string]interface{}{
+			"type":      "response",
+			"requestId": message["requestId"],
+			"success":   true,
+			"status":    update,
+		}); err != nil {
+			return
+		}
+		m.Update(update)
+	}
+}
+
+func (m *MockCharger) handshake(conn *Conn) error {
+	if err := conn.Send(map[string]interface{}{
+		"type":          "hello",
+		"serial":        m.serial,
+		"hostname":      "Wattpilot_" + m.serial,
+		"friendly_name": "Wattpilot " + m.serial,
+		"manufacturer":  "fronius",
+		"devicetype":    "wattpilot",
+		"version":       "38.5",
+		"protocol":      2,
+		"secured":       false,
+	}); err != nil {
+		return err
+	}
+	if err := conn.Send(map[string]interface{}{
+		"type":   "authRequired",
+		"token1": randomToken(),
+		"token2": randomToken(),
+	}); err != nil {
+		return err
+	}
+	if _, err := conn.Receive(); err != nil {
+		return err
+	}
+	return conn.Send(map[string]interface{}{"type": "authSuccess"})
+}
+
+func randomToken() string {
+	token := make([]byte, 8)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
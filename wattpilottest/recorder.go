@@ -0,0 +1,216 @@
+package wattpilottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+const (
+	// CAPTURE_PASSWORD replaces the password in the header of captures, the
+	// replay server and client both derive the hash from it
+	CAPTURE_PASSWORD = "captured"
+	// CAPTURE_SERIAL replaces the serial of the charger in captures
+	CAPTURE_SERIAL = "00000000"
+)
+
+var knownMessageTypes = map[string]bool{
+	"hello":          true,
+	"authRequired":   true,
+	"authSuccess":    true,
+	"authError":      true,
+	"fullStatus":     true,
+	"deltaStatus":    true,
+	"response":       true,
+	"securedMsg":     true,
+	"clearInverters": true,
+	"updateInverter": true,
+}
+
+// Recorder writes the messages passed by wattpilot.WithFrameCapture as a
+// replayable transcript. The serial and the authentication material are
+// replaced, the values of RedactKeys are removed. Unknown message types
+// and property keys are annotated with note steps and the unknown keys are
+// written to Discovered, one per line, for the property generator.
+type Recorder struct {
+	RedactKeys []string
+	Discovered io.Writer
+
+	mu      sync.Mutex
+	out     io.Writer
+	serial  string
+	header  bool
+	unknown map[string]bool
+	err     error
+}
+
+func NewRecorder(out io.Writer) *Recorder {
+	return &Recorder{out: out, unknown: make(map[string]bool)}
+}
+
+// Err returns the first write error, the recorder stops writing afterwards
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Capture is the callback for wattpilot.WithFrameCapture
+func (r *Recorder) Capture(incoming bool, message map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	msgType, _ := message["type"].(string)
+	if incoming && msgType == "hello" {
+		r.serial, _ = message["serial"].(string)
+		if !r.header {
+			r.writeHeader(message)
+		}
+	}
+	if !r.header {
+		return
+	}
+	if incoming {
+		r.captureIncoming(msgType, message)
+	} else {
+		r.captureOutgoing(msgType, message)
+	}
+}
+
+func (r *Recorder) writeHeader(hello map[string]interface{}) {
+	version, _ := hello["version"].(string)
+	r.header = true
+	r.write(Transcript{
+		Firmware: version,
+		Password: CAPTURE_PASSWORD,
+		Note:     "captured, serial and authentication replaced",
+	})
+}
+
+func (r *Recorder) captureIncoming(msgType string, message map[string]interface{}) {
+	if !knownMessageTypes[msgType] {
+		r.note(fmt.Sprintf("unknown message type %q", msgType))
+	}
+	switch msgType {
+	case "securedMsg":
+		data, _ := message["data"].(string)
+		inner := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(data), &inner); err != nil {
+			r.note("undecodable secured message")
+			return
+		}
+		r.annotateKeys(inner)
+		r.write(Step{SendSecured: r.redact(inner)})
+		return
+	case "authSuccess":
+		message = r.redact(message)
+		message["token3"] = "*"
+		message["hash"] = "*"
+		r.write(Step{Send: message})
+		return
+	case "response":
+		message = r.redact(message)
+		message["requestId"] = "$requestId"
+		r.write(Step{Send: message})
+		return
+	}
+	r.annotateKeys(message)
+	r.write(Step{Send: r.redact(message)})
+}
+
+func (r *Recorder) captureOutgoing(msgType string, message map[string]interface{}) {
+	switch msgType {
+	case "auth":
+		r.write(Step{Expect: map[string]interface{}{"type": "auth", "token3": "*", "hash": "*"}})
+	case "setValue":
+		key, _ := message["key"].(string)
+		value := r.redactValue(message["value"])
+		r.write(Step{Set: map[string]interface{}{key: value}})
+		r.write(Step{Expect: map[string]interface{}{"type": "setValue", "key": key, "value": value, "requestId": "*"}})
+	}
+	// periodic requests of the client are not replayable in order
+}
+
+func (r *Recorder) annotateKeys(message map[string]interface{}) {
+	status, ok := message["status"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	unknown := []string{}
+	for key := range status {
+		if !wattpilot.IsKnownKey(key) && !r.unknown[key] {
+			r.unknown[key] = true
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return
+	}
+	sort.Strings(unknown)
+	r.note("unknown keys " + strings.Join(unknown, ", "))
+	if r.Discovered != nil {
+		for _, key := range unknown {
+			fmt.Fprintln(r.Discovered, key)
+		}
+	}
+}
+
+func (r *Recorder) note(note string) {
+	r.write(Step{Note: note})
+}
+
+// redact copies the message with the serial replaced and the values of
+// RedactKeys removed
+func (r *Recorder) redact(message map[string]interface{}) map[string]interface{} {
+	redacted := r.redactValue(message).(map[string]interface{})
+	if status, ok := redacted["status"].(map[string]interface{}); ok {
+		for _, key := range r.RedactKeys {
+			if _, isSet := status[key]; isSet {
+				status[key] = nil
+			}
+		}
+	}
+	return redacted
+}
+
+func (r *Recorder) redactValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			copied[k] = r.redactValue(v)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, v := range value {
+			copied[i] = r.redactValue(v)
+		}
+		return copied
+	case string:
+		if r.serial != "" {
+			return strings.ReplaceAll(value, r.serial, CAPTURE_SERIAL)
+		}
+	}
+	return value
+}
+
+func (r *Recorder) write(line interface{}) {
+	data, err := json.Marshal(line)
+	if err != nil {
+		r.err = err
+		return
+	}
+	if _, err := r.out.Write(append(data, '\n')); err != nil {
+		r.err = err
+	}
+}
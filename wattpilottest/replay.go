@@ -0,0 +1,211 @@
+package wattpilottest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mabunixda/wattpilot"
+)
+
+// STEP_TIMEOUT is the time a step waits for the client, in seconds
+const STEP_TIMEOUT = 5
+
+// Replay connects a client to a server replaying the transcript and
+// returns the first deviation of the client from the transcript
+func Replay(ctx context.Context, t *Transcript, options ...wattpilot.Option) error {
+	server, err := NewServer()
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	client := wattpilot.New(server.Addr(), t.Password, options...)
+	defer client.Close()
+
+	connected := make(chan error, 1)
+	go func() {
+		connected <- client.Connect()
+	}()
+
+	acceptCtx, cancel := context.WithTimeout(ctx, time.Second*STEP_TIMEOUT)
+	defer cancel()
+	conn, err := server.Accept(acceptCtx, t.Password)
+	if err != nil {
+		return fmt.Errorf("client did not connect: %w", err)
+	}
+	defer conn.Close()
+
+	r := &replay{ctx: ctx, client: client, conn: conn, connected: connected}
+	for i, step := range t.Steps {
+		if err := r.run(step); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+type replay struct {
+	ctx       context.Context
+	client    *wattpilot.Wattpilot
+	conn      *Conn
+	connected chan error
+	ready     bool
+	requestId interface{}
+}
+
+func (r *replay) run(step Step) error {
+	switch {
+	case step.Send != nil:
+		return r.conn.Send(r.substitute(step.Send).(map[string]interface{}))
+	case step.SendSecured != nil:
+		return r.conn.SendSecured(r.substitute(step.SendSecured).(map[string]interface{}))
+	case step.Expect != nil:
+		return r.expect(step.Expect)
+	case step.Set != nil:
+		return r.set(step.Set)
+	case step.Check != nil:
+		return r.check(step.Check)
+	case step.Note != "":
+		return nil
+	}
+	return errors.New("empty step")
+}
+
+// substitute replaces "$requestId" by the id of the last client message
+func (r *replay) substitute(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			result[k] = r.substitute(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, v := range value {
+			result[i] = r.substitute(v)
+		}
+		return result
+	case string:
+		if value == "$requestId" {
+			return r.requestId
+		}
+	}
+	return value
+}
+
+func (r *replay) expect(expected map[string]interface{}) error {
+	if err := r.conn.conn.SetReadDeadline(time.Now().Add(time.Second * STEP_TIMEOUT)); err != nil {
+		return err
+	}
+	message, err := r.conn.Receive()
+	if err != nil {
+		return fmt.Errorf("expected %v: %w", expected["type"], err)
+	}
+	r.requestId = message["requestId"]
+	if !match(expected, message) {
+		return fmt.Errorf("expected %v, got %v", expected, message)
+	}
+	return nil
+}
+
+// waitConnected waits for Connect of the client to return
+func (r *replay) waitConnected() error {
+	if r.ready {
+		return nil
+	}
+	select {
+	case err := <-r.connected:
+		if err != nil {
+			return fmt.Errorf("connect failed: %w", err)
+		}
+	case <-time.After(time.Second * STEP_TIMEOUT):
+		return errors.New("client did not finish connecting")
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	}
+	r.ready = true
+	return nil
+}
+
+func (r *replay) set(values map[string]interface{}) error {
+	if err := r.waitConnected(); err != nil {
+		return err
+	}
+	keys := wattpilot.Keys(values)
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := r.client.SetProperty(key, values[key]); err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (r *replay) check(values map[string]interface{}) error {
+	if err := r.waitConnected(); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(time.Second * STEP_TIMEOUT)
+	for {
+		mismatch := ""
+		for key, expected := range values {
+			actual, err := r.client.GetProperty(key)
+			if err != nil || !match(expected, actual) {
+				mismatch = fmt.Sprintf("%s is %v, expected %v", key, actual, expected)
+				break
+			}
+		}
+		if mismatch == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New(mismatch)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// match compares recursively, "*" matches any value and maps only need to
+// contain the expected keys
+func match(expected interface{}, actual interface{}) bool {
+	switch e := expected.(type) {
+	case string:
+		if e == "*" {
+			return true
+		}
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			av, isKnown := a[k]
+			if !isKnown || !match(v, av) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !match(e[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		if a, err := strconv.ParseFloat(fmt.Sprint(actual), 64); err == nil {
+			return a == e
+		}
+	}
+	return reflect.DeepEqual(expected, actual) || fmt.Sprint(expected) == fmt.Sprint(actual)
+}
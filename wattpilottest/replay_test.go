@@ -0,0 +1,79 @@
+package wattpilottest
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// syntheticTranscripts are hand written from the protocol of the python
+// reference implementation, no sessions captured from real chargers are
+// available yet. They only show that the client follows that description
+// and can not detect changes of the firmware.
+var syntheticTranscripts = []struct {
+	file     string
+	firmware string
+}{
+	{"synthetic-fw36-unsecured.jsonl", "36.3"},
+	{"synthetic-fw38-secured.jsonl", "38.5"},
+	{"synthetic-fw40-partial-status.jsonl", "40.7"},
+}
+
+func TestReplaySyntheticTranscripts(t *testing.T) {
+	for _, test := range syntheticTranscripts {
+		t.Run(test.file, func(t *testing.T) {
+			transcript, err := LoadTranscriptFile(filepath.Join("testdata", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if transcript.Firmware != test.firmware || !transcript.Synthetic {
+				t.Fatalf("header firmware %s synthetic %v, want %s true", transcript.Firmware, transcript.Synthetic, test.firmware)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := Replay(ctx, transcript); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestTranscriptsListed(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make(map[string]bool)
+	for _, test := range syntheticTranscripts {
+		listed[test.file] = true
+	}
+	for _, file := range files {
+		if !listed[filepath.Base(file)] {
+			t.Errorf("%s is not replayed by TestReplaySyntheticTranscripts", file)
+		}
+	}
+}
+
+// TestReplayDetectsDeviation replays a transcript expecting a write the
+// client does not send
+func TestReplayDetectsDeviation(t *testing.T) {
+	transcript, err := LoadTranscript(strings.NewReader(`{"firmware":"38.5","password":"wattpilot-test","synthetic":true}
+{"send":{"type":"hello","serial":"90000001","hostname":"Wattpilot_90000001","manufacturer":"fronius","devicetype":"wattpilot","version":"38.5","protocol":2,"secured":false}}
+{"send":{"type":"authRequired","token1":"0d9c5be0a8f14a6c","token2":"7e41c2b9d03f5a86"}}
+{"expect":{"type":"auth","token3":"*","hash":"*"}}
+{"send":{"type":"authSuccess"}}
+{"send":{"type":"fullStatus","partial":false,"status":{"amp":6,"frc":0}}}
+{"set":{"frc":1}}
+{"expect":{"type":"setValue","key":"amp","value":1,"requestId":"*"}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := Replay(ctx, transcript); err == nil || !strings.Contains(err.Error(), "step 7") {
+		t.Fatal("expected a deviation in step 7, got ", err)
+	}
+}
@@ -0,0 +1,176 @@
+package wattpilottest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/mabunixda/wattpilot/auth"
+)
+
+// Server accepts websocket connections of the client on a local port
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	conns    chan net.Conn
+	once     sync.Once
+}
+
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		listener: listener,
+		conns:    make(chan net.Conn, 4),
+	}
+	s.server = &http.Server{Handler: http.HandlerFunc(s.upgrade)}
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+	return s, nil
+}
+
+// Addr is the host to pass to wattpilot.New
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *Server) upgrade(rw http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := ws.UpgradeHTTP(r, rw)
+	if err != nil {
+		return
+	}
+	select {
+	case s.conns <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// Accept waits for the next client connection
+func (s *Server) Accept(ctx context.Context, password string) (*Conn, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case conn := <-s.conns:
+		return &Conn{conn: conn, password: password}, nil
+	}
+}
+
+func (s *Server) Close() {
+	s.once.Do(func() {
+		s.server.Close()
+	})
+}
+
+// Conn is the charger side of a client connection. It remembers the
+// authentication tokens it sent to verify the auth hash and the hmac of
+// secured messages.
+type Conn struct {
+	conn     net.Conn
+	password string
+	serial   string
+	token1   string
+	token2   string
+	hashed   string
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) Send(message map[string]interface{}) error {
+	switch message["type"] {
+	case "hello":
+		c.serial, _ = message["serial"].(string)
+		if c.password != "" {
+			c.hashed = auth.HashPassword(c.password, c.serial)
+		}
+	case "authRequired":
+		c.token1, _ = message["token1"].(string)
+		c.token2, _ = message["token2"].(string)
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return wsutil.WriteServerText(c.conn, data)
+}
+
+// SendSecured wraps the message in a securedMsg signed like the charger
+func (c *Conn) SendSecured(message map[string]interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return c.Send(map[string]interface{}{
+		"type":      "securedMsg",
+		"data":      string(payload),
+		"requestId": fmt.Sprintf("%vsm", message["requestId"]),
+		"hmac":      auth.Sign(c.hashed, payload),
+	})
+}
+
+// Receive reads the next client message, secured messages are verified and
+// unwrapped
+func (c *Conn) Receive() (map[string]interface{}, error) {
+	for {
+		data, op, err := wsutil.ReadClientData(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if op != ws.OpText && op != ws.OpBinary {
+			continue
+		}
+		message := make(map[string]interface{})
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, err
+		}
+		switch message["type"] {
+		case "auth":
+			if err := c.verifyAuth(message); err != nil {
+				return nil, err
+			}
+		case "securedMsg":
+			return c.unwrap(message)
+		}
+		return message, nil
+	}
+}
+
+func (c *Conn) verifyAuth(message map[string]interface{}) error {
+	if c.hashed == "" {
+		return nil
+	}
+	token3, _ := message["token3"].(string)
+	hash, _ := message["hash"].(string)
+	if !auth.VerifyResponse(c.token1, c.token2, token3, c.hashed, hash) {
+		return errors.New("auth hash does not match the password")
+	}
+	return nil
+}
+
+func (c *Conn) unwrap(message map[string]interface{}) (map[string]interface{}, error) {
+	payload, _ := message["data"].(string)
+	if c.hashed != "" {
+		signature, _ := message["hmac"].(string)
+		if !auth.Verify(c.hashed, []byte(payload), signature) {
+			return nil, errors.New("hmac of secured message does not match")
+		}
+	}
+	inner := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(payload), &inner); err != nil {
+		return nil, fmt.Errorf("secured message: %w", err)
+	}
+	// responses refer to the id of the secured message
+	inner["requestId"] = message["requestId"]
+	return inner, nil
+}
@@ -0,0 +1,88 @@
+// Package wattpilottest replays protocol transcripts against the client,
+// with a websocket server playing the part of the charger.
+package wattpilottest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Step is one line of a transcript, exactly one field is set:
+// Send is written by the charger, SendSecured is written wrapped in a
+// securedMsg signed with the password, Expect has to match the next
+// message of the client, Set calls SetProperty on the client for each key
+// and Check waits until GetProperty of the client returns the given values.
+// Note annotates the transcript and is skipped on replay.
+type Step struct {
+	Send        map[string]interface{} `json:"send,omitempty"`
+	SendSecured map[string]interface{} `json:"sendSecured,omitempty"`
+	Expect      map[string]interface{} `json:"expect,omitempty"`
+	Set         map[string]interface{} `json:"set,omitempty"`
+	Check       map[string]interface{} `json:"check,omitempty"`
+	Note        string                 `json:"note,omitempty"`
+}
+
+// Transcript is a recorded or written session with a charger. The first
+// line of a transcript file is the header, the remaining lines are steps.
+type Transcript struct {
+	Name     string `json:"-"`
+	Firmware string `json:"firmware"`
+	Password string `json:"password"`
+	// Synthetic marks hand written transcripts, which were not captured
+	// from a real charger
+	Synthetic bool   `json:"synthetic"`
+	Note      string `json:"note,omitempty"`
+	Steps     []Step `json:"-"`
+}
+
+func LoadTranscript(r io.Reader) (*Transcript, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	t := &Transcript{}
+	header := true
+	for line := 1; scanner.Scan(); line++ {
+		data := scanner.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		if header {
+			if err := json.Unmarshal(data, t); err != nil {
+				return nil, fmt.Errorf("header: %w", err)
+			}
+			header = false
+			continue
+		}
+		var step Step
+		if err := json.Unmarshal(data, &step); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		t.Steps = append(t.Steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if header {
+		return nil, errors.New("empty transcript")
+	}
+	return t, nil
+}
+
+func LoadTranscriptFile(path string) (*Transcript, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := LoadTranscript(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	t.Name = filepath.Base(path)
+	return t, nil
+}
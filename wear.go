@@ -0,0 +1,147 @@
+package wattpilot
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+const EventRelayWear EventType = "relayWear"
+
+// RelayWear counts the switching cycles of the relays. A contactor cycle is
+// counted when charging starts, a phase switch when the charger toggles
+// between single and three phase charging (fsp).
+type RelayWear struct {
+	ContactorCycles uint64 `json:"contactorCycles"`
+	PhaseSwitches   uint64 `json:"phaseSwitches"`
+}
+
+// WearThresholds emit EventRelayWear once a counter reaches them, zero
+// values disable the warning
+type WearThresholds struct {
+	ContactorCycles uint64
+	PhaseSwitches   uint64
+}
+
+// WearCounter holds the relay wear of one charger, persisted as JSON in
+// the file given to LoadWearCounter
+type WearCounter struct {
+	mu         sync.Mutex
+	path       string
+	wear       RelayWear
+	thresholds WearThresholds
+
+	charging    *bool
+	singlePhase *bool
+}
+
+// LoadWearCounter reads the totals, a missing file starts from zero
+func LoadWearCounter(path string) (*WearCounter, error) {
+	c := &WearCounter{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.wear); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *WearCounter) SetThresholds(thresholds WearThresholds) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.thresholds = thresholds
+}
+
+func (c *WearCounter) Wear() RelayWear {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.wear
+}
+
+func (c *WearCounter) save() error {
+	if c.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(c.wear)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0600)
+}
+
+// WithRelayWear counts the relay cycles of the charger in the counter
+func WithRelayWear(counter *WearCounter) Option {
+	return func(w *Wattpilot) {
+		w._wear = counter
+	}
+}
+
+// RelayWear returns the relay cycles when WithRelayWear is used
+func (w *Wattpilot) RelayWear() (RelayWear, bool) {
+	if w._wear == nil {
+		return RelayWear{}, false
+	}
+	return w._wear.Wear(), true
+}
+
+// transition stores the state and reports whether it turned on
+func transition(last **bool, state bool) bool {
+	switched := *last != nil && !**last && state
+	*last = &state
+	return switched
+}
+
+// toggle stores the state and reports whether it changed
+func toggle(last **bool, state bool) bool {
+	changed := *last != nil && **last != state
+	*last = &state
+	return changed
+}
+
+// trackWear is called with the read mutex held for every status update
+func (w *Wattpilot) trackWear(updates map[string]interface{}) {
+	c := w._wear
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	before := c.wear
+	if v, ok := updates["car"]; ok {
+		if state, err := toFloat(v); err == nil && transition(&c.charging, int(state) == carStateCharging) {
+			c.wear.ContactorCycles++
+		}
+	}
+	if v, ok := updates["fsp"]; ok {
+		if single, err := toBool(v); err == nil && toggle(&c.singlePhase, single) {
+			c.wear.PhaseSwitches++
+		}
+	}
+	if c.wear == before {
+		c.mu.Unlock()
+		return
+	}
+	err := c.save()
+	wear := c.wear
+	thresholds := c.thresholds
+	c.mu.Unlock()
+
+	if err != nil {
+		w.logEntry().Warn("Could not save relay wear: ", err)
+	}
+	reached := []string{}
+	if thresholds.ContactorCycles > 0 && before.ContactorCycles < thresholds.ContactorCycles && wear.ContactorCycles >= thresholds.ContactorCycles {
+		reached = append(reached, "contactorCycles")
+	}
+	if thresholds.PhaseSwitches > 0 && before.PhaseSwitches < thresholds.PhaseSwitches && wear.PhaseSwitches >= thresholds.PhaseSwitches {
+		reached = append(reached, "phaseSwitches")
+	}
+	for _, counter := range reached {
+		w.logEntry().Warn("Relay wear threshold of ", counter, " reached")
+		w.emitEvent(EventRelayWear, map[string]interface{}{"counter": counter, "wear": wear})
+	}
+}